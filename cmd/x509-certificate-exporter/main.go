@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -11,6 +13,8 @@ import (
 	"time"
 
 	_ "github.com/KimMachineGun/automemlimit"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
 	_ "go.uber.org/automaxprocs"
 	"k8s.io/client-go/util/flowcontrol"
 
@@ -29,16 +33,81 @@ func main() {
 		systemdSocket = getopt.BoolLong("web.systemd-socket", 0, "use systemd socket activation listeners instead of port listeners (Linux only)")
 	}
 	configFile := getopt.StringLong("web.config.file", 0, "", "[EXPERIMENTAL] path to configuration file that can enable TLS or authentication")
+	tlsCertFile := getopt.StringLong("tls-cert-file", 0, "", "serve metrics over HTTPS using this certificate (requires --tls-key-file)")
+	tlsKeyFile := getopt.StringLong("tls-key-file", 0, "", "private key matching --tls-cert-file")
+	tlsClientCAFile := getopt.StringLong("tls-client-ca-file", 0, "", "require clients to present a certificate signed by one of the CAs in this file (mutual TLS)")
 
 	debug := getopt.BoolLong("debug", 0, "enable debug mode")
 	trimPathComponents := getopt.IntLong("trim-path-components", 0, 0, "remove <n> leading component(s) from path(s) in label(s)")
 	exposeRelativeMetrics := getopt.BoolLong("expose-relative-metrics", 0, "expose additionnal metrics with relative durations instead of absolute timestamps")
+	expiresInUnit := getopt.StringLong("expires-in-unit", 0, "seconds", "unit of the remaining time before expiration exposed by --expose-relative-metrics (seconds, hours or days), suffixing its metric name")
 	exposeErrorMetrics := getopt.BoolLong("expose-per-cert-error-metrics", 0, "expose additionnal error metric for each certificate indicating wether it has failure(s)")
+	exposeIssuerMetrics := getopt.BoolLong("expose-issuer-metrics", 0, "expose additional metrics about the issuer of each certificate, when it can be found in the same bundle or in a --ca-file")
+	exposeTypeMetrics := getopt.BoolLong("expose-type-metrics", 0, "expose an additional metric for each certificate with a type label telling whether it's a leaf, an intermediate or a root")
+	exposeSANMetrics := getopt.BoolLong("expose-san-metrics", 0, "expose additional metrics about subject alternative names: their count for each certificate, whether server certificates have any (so are valid for TLS servers), and whether their CN is one of them")
+	exposeSANExpiryMetrics := getopt.BoolLong("expose-san-expiry-metrics", 0, "expose the remaining time before expiration of certificates for each of their DNS names and IP addresses")
+	sanExpiryLimit := getopt.IntLong("san-expiry-limit", 0, 20, "maximum number of subject alternative names exposed by --expose-san-expiry-metrics for each certificate (0 for no limit)")
+	exposeRevocationMetrics := getopt.BoolLong("expose-revocation-metrics", 0, "expose additional metrics listing the CRL distribution points and OCSP servers of each certificate, and flagging certificates which have none")
+	exposeEmailMetrics := getopt.BoolLong("expose-email-metrics", 0, "expose an additional metric for each certificate having email addresses in its subject alternative names, labeled with (up to 5 of) them")
+	exposeIPSANMetrics := getopt.BoolLong("expose-ip-san-metrics", 0, "expose an additional metric for each certificate having IP addresses in its subject alternative names, labeled with (up to 5 of) them")
+	exposeRotationMetrics := getopt.BoolLong("expose-rotation-metrics", 0, "expose an additional counter for each source, incremented each time its leaf certificate changes, and a gauge of the interval between the last two issuances")
+	exposeCacheAgeMetrics := getopt.BoolLong("expose-cache-age-metrics", 0, "expose an additional metric for each source telling how long ago it was last parsed successfully")
+	exposeSHA1Metrics := getopt.BoolLong("expose-sha1-metrics", 0, "expose an additional metric for each certificate labeled with its SHA-1 fingerprint, to correlate with legacy systems pinning certificates this way (SHA-1 is insecure)")
+	exposePathLenMetrics := getopt.BoolLong("expose-path-len-metrics", 0, "expose an additional metric for CA certificates having a path length constraint, valued with the maximum number of intermediates allowed below them")
+	exposeChainDepthMetrics := getopt.BoolLong("expose-chain-depth-metrics", 0, "expose an additional metric for leaf certificates whose source holds the full chain, valued with the number of certificates up to the root")
+	exposeWildcardMetrics := getopt.BoolLong("expose-wildcard-metrics", 0, "expose an additional metric for each wildcard DNS name of certificates, labeled with the domain it covers")
+	exposeChainOrderMetrics := getopt.BoolLong("expose-chain-order-metrics", 0, "expose an additional metric for each source holding a leaf and its chain, telling whether they aren't listed leaf first, each certificate followed by its issuer")
+	exposeSkippedPEMMetrics := getopt.BoolLong("expose-skipped-pem-metrics", 0, "expose an additional metric for each watched file with PEM blocks which didn't yield a certificate, counting them by reason")
+	exposeKeyReuseMetrics := getopt.BoolLong("expose-key-reuse-metrics", 0, "expose an additional metric for each certificate counting the certificates sharing its public key")
+	consolidatedMetrics := getopt.BoolLong("consolidated-metrics", 0, "expose a single x509_cert_info metric per certificate, valued with its not after timestamp and labeled with its attributes, instead of the per-certificate metrics")
+	exposeCTMetrics := getopt.BoolLong("expose-ct-metrics", 0, "expose an additional metric for leaf certificates telling whether they're found in Certificate Transparency logs, looked up in the background through --ct-lookup-url")
+	ctLookupURL := getopt.StringLong("ct-lookup-url", 0, internal.DefaultCTLookupURL, "crt.sh compatible API searching Certificate Transparency logs by SHA-256 fingerprint")
+	exposeHostnameMetrics := getopt.BoolLong("expose-hostname-metrics", 0, "expose an additional metric for the leaf certificates of TLS endpoints telling whether they're valid for the dialed hostname")
+	expectedHostnames := stringArrayFlag{}
+	getopt.FlagLong(&expectedHostnames, "expected-hostname", 0, "one or more <path pattern>=<hostname> the leaf certificates of matching files must be valid for, checked by x509_cert_hostname_match")
+	serviceFiles := stringArrayFlag{}
+	getopt.FlagLong(&serviceFiles, "service-file", 0, "one or more <service>=<path pattern> files holding the certificate of a service, compared with the one served by its --service-endpoint in x509_service_served_cert_matches_file")
+	serviceEndpoints := stringArrayFlag{}
+	getopt.FlagLong(&serviceEndpoints, "service-endpoint", 0, "one or more <service>=<host:port> watched TLS endpoints serving the certificate of a service, compared with its --service-file")
+	minRSAKeySize := getopt.IntLong("min-rsa-key-size", 0, 2048, "flag RSA keys smaller than <n> bits in x509_cert_weak_rsa_key (0 to disable the metric)")
+	farFutureNotAfter := getopt.StringLong("far-future-not-after", 0, internal.DefaultFarFutureNotAfter.Format(time.RFC3339), "flag certificates expiring after this RFC 3339 timestamp in x509_cert_not_after_far_future, by default the last one 32-bit platforms can represent (empty to disable the metric)")
+	issuerCountLimit := getopt.IntLong("issuer-count-limit", 0, 0, "only count certificates of the <n> biggest issuers separately in x509_cert_by_issuer_count, the others are grouped (0 for no limit)")
 	exposeLabels := getopt.StringLong("expose-labels", 'l', "one or more comma-separated labels to enable (defaults to all if not specified)")
+	exposeFormatLabel := getopt.BoolLong("expose-format-label", 0, "label certificate metrics with the format their source was read as (e.g. pem, kube-secret), or detected as for --watch-auto-file (e.g. der, pkcs12)")
+	labelMappingsFile := getopt.StringLong("label-mappings-file", 0, "", "path to a CSV or YAML file adding labels to the metrics of certificates matching a SHA-256 fingerprint or a source path pattern, reloaded when modified")
 	profile := getopt.BoolLong("profile", 0, "optionally enable a pprof server to monitor cpu and memory usage at runtime")
 
+	pushGateway := getopt.StringLong("push-gateway", 0, "", "parse certificates once, push metrics to the Pushgateway at this URL and exit instead of serving them")
+	pushJob := getopt.StringLong("push-job", 0, "x509-certificate-exporter", "job name used when pushing to --push-gateway")
+	failOnExpired := getopt.BoolLong("fail-on-expired", 0, "with --push-gateway, exit with a non-zero code after pushing if any certificate is expired (no effect when serving metrics)")
+	pushGrouping := stringArrayFlag{}
+	getopt.FlagLong(&pushGrouping, "push-grouping", 0, "one or more key=value label to add to the grouping key used when pushing to --push-gateway (e.g. \"instance=myhost\")")
+
+	otlpEndpoint := getopt.StringLong("otlp-endpoint", 0, "", "also push metrics to this OTLP/HTTP metrics endpoint (e.g. \"http://collector:4318\", \"/v1/metrics\" being used when no path is given)")
+	otlpOnly := getopt.BoolLong("otlp-only", 0, "with --otlp-endpoint, only push metrics over OTLP instead of also serving them")
+	otlpHeaders := stringArrayFlag{}
+	getopt.FlagLong(&otlpHeaders, "otlp-header", 0, "one or more key=value HTTP header to send with OTLP requests (e.g. \"Authorization=Bearer <token>\")")
+	otlpInterval := durationFlag(time.Minute)
+	getopt.FlagLong(&otlpInterval, "otlp-interval", 0, "interval between two pushes to --otlp-endpoint")
+
+	scrapeTimeout := durationFlag(0)
+	getopt.FlagLong(&scrapeTimeout, "scrape-timeout", 0, "maximum time spent reading sources on each scrape, slower sources are reported as read errors (0 for no limit)")
+	circuitBreakerFailures := getopt.IntLong("circuit-breaker-failures", 0, 0, "stop reading a network source (TLS endpoint, SQL, GCS, Git, Azure Key Vault, Consul, etcd) after <n> consecutive failures, only probing it again every --circuit-breaker-backoff (0 to always read)")
+	circuitBreakerBackoff := durationFlag(5 * time.Minute)
+	getopt.FlagLong(&circuitBreakerBackoff, "circuit-breaker-backoff", 0, "how long a source stopped by --circuit-breaker-failures isn't read, before being probed again")
+	staleTolerance := durationFlag(0)
+	getopt.FlagLong(&staleTolerance, "stale-tolerance", 0, "keep exporting the last known certificates of a failing or missing source for this long after its last successful read, instead of dropping its series (0 to disable)")
+
+	readinessMinLifetime := durationFlag(0)
+	getopt.FlagLong(&readinessMinLifetime, "readiness-min-lifetime", 0, "make /readyz fail while any certificate selected by --readiness-selector has less than this left before expiration (0 to always be ready)")
+	readinessSelectors := stringArrayFlag{}
+	getopt.FlagLong(&readinessSelectors, "readiness-selector", 0, "one or more <label>=<regular expression> the labels of the certificates gating --readiness-min-lifetime must all fully match (all certificates by default)")
+
+	clockSkewThreshold := durationFlag(5 * time.Minute)
+	getopt.FlagLong(&clockSkewThreshold, "clock-skew-threshold", 0, "set x509_cert_clock_skew_suspected when a certificate's not before timestamp is further than this in the future")
+
 	maxCacheDuration := durationFlag(0)
-	getopt.FlagLong(&maxCacheDuration, "max-cache-duration", 0, "maximum cache duration for kube secrets. cache is per namespace and randomized to avoid massive requests.")
+	getopt.FlagLong(&maxCacheDuration, "max-cache-duration", 0, "maximum cache duration for kube secrets. cache is per namespace (per secret for the ones referenced by ingresses) and randomized to avoid massive requests.")
 
 	rateLimitQPS := getopt.IntLong("kube-api-rate-limit-qps", 0, 0, "Kubernetes API request rate limit")
 	rateLimitBurst := getopt.IntLong("kube-api-rate-limit-burst", 0, 0, "Kubernetes API request burst")
@@ -49,15 +118,154 @@ func main() {
 	directories := stringArrayFlag{}
 	getopt.FlagLong(&directories, "watch-dir", 'd', "watch one or more directory which contains x509 certificate files (not recursive)")
 
+	hashedDirectories := stringArrayFlag{}
+	getopt.FlagLong(&hashedDirectories, "watch-hashed-dir", 0, "watch one or more OpenSSL c_rehash directory (e.g. \"/etc/ssl/certs\"), only reading its <subject hash>.<n> entries")
+
 	yamls := stringArrayFlag{}
 	getopt.FlagLong(&yamls, "watch-kubeconf", 'k', "watch one or more Kubernetes client configuration (kind Config) which contains embedded x509 certificates or PEM file paths")
+	yamlPathsFile := getopt.StringLong("yaml-paths-file", 0, "", "path to a YAML file listing custom paths to certificates, used instead of the kubeconfig ones for --watch-kubeconf files (e.g. to watch Helm values files)")
+
+	autoFiles := stringArrayFlag{}
+	getopt.FlagLong(&autoFiles, "watch-auto-file", 0, "watch one or more certificate files whose format is detected from their contents: PEM, DER, PKCS#12 (without password) or JKS, possibly gzipped or in a zip archive")
+
+	embeddedPEMFiles := stringArrayFlag{}
+	getopt.FlagLong(&embeddedPEMFiles, "watch-embedded-pem", 0, "watch one or more text file of any format (e.g. OpenSSL configs) with inline PEM certificates, extracted wherever they are in the file")
+
+	sdsFiles := stringArrayFlag{}
+	getopt.FlagLong(&sdsFiles, "watch-sds-file", 0, "watch one or more JSON or YAML file holding Envoy secrets (SDS resources or config dumps, e.g. from Istio) whose inline certificate chains and trusted CAs are extracted")
+
+	systemdCredentials := stringArrayFlag{}
+	getopt.FlagLong(&systemdCredentials, "watch-systemd-credential", 0, "watch one or more credentials of the systemd service (glob patterns allowed, e.g. \"*.crt\"), read from $CREDENTIALS_DIRECTORY whatever their format like --watch-auto-file")
+
+	inis := stringArrayFlag{}
+	getopt.FlagLong(&inis, "watch-ini", 0, "watch one or more INI file which contains embedded x509 certificates or PEM file paths, at the keys given with --ini-key")
+	iniKeys := stringArrayFlag{}
+	getopt.FlagLong(&iniKeys, "ini-key", 0, "one or more INI key holding certificates, given as <section>.<key>[:base64|base64-der|hex|file] (base64 by default)")
+	dotenvFiles := stringArrayFlag{}
+	getopt.FlagLong(&dotenvFiles, "watch-dotenv", 0, "watch one or more dotenv (.env) file which contains embedded x509 certificates or PEM file paths, in the variables given with --dotenv-key")
+	dotenvKeys := stringArrayFlag{}
+	getopt.FlagLong(&dotenvKeys, "dotenv-key", 0, "one or more dotenv variable holding certificates, given as <key>[:base64|base64-der|hex|file] (base64 by default)")
+
+	leafOnlySources := stringArrayFlag{}
+	getopt.FlagLong(&leafOnlySources, "leaf-only", 0, "only export the leaf certificate of sources whose path matches one or more glob pattern (e.g. \"/etc/ssl/bundles/*.pem\" or \"k8s/default/*\"), dropping intermediates and roots of their bundles")
+
+	caFiles := stringArrayFlag{}
+	getopt.FlagLong(&caFiles, "ca-file", 0, "one or more PEM file containing CA certificates used to resolve certificate issuers (these certificates are not exported)")
+
+	trustedRootFiles := stringArrayFlag{}
+	getopt.FlagLong(&trustedRootFiles, "trusted-roots-file", 0, "one or more PEM file containing root certificates trusted to verify leaf certificates, enables the x509_cert_verified metric")
+	allowedSigAlgorithms := stringArrayFlag{}
+	getopt.FlagLong(&allowedSigAlgorithms, "allowed-signature-algorithm", 0, "one or more signature algorithms (e.g. \"SHA256-RSA\", \"ECDSA-SHA384\") certificates may be signed with, enables the x509_cert_signature_algorithm_compliant metric")
+	deniedSigAlgorithms := stringArrayFlag{}
+	getopt.FlagLong(&deniedSigAlgorithms, "denied-signature-algorithm", 0, "one or more signature algorithms certificates must not be signed with (applied after --allowed-signature-algorithm), enables the x509_cert_signature_algorithm_compliant metric")
+
+	pinnedPublicKeys := stringArrayFlag{}
+	getopt.FlagLong(&pinnedPublicKeys, "pinned-public-key", 0, "one or more SHA-256 hash of a pinned public key (SubjectPublicKeyInfo), in base64 like HPKP pins or in hex, enables the x509_cert_public_key_pinned metric")
+
+	includeExtKeyUsages := stringArrayFlag{}
+	getopt.FlagLong(&includeExtKeyUsages, "include-ext-key-usage", 0, "only export certificates having one or more of these extended key usages (e.g. \"serverAuth\", \"clientAuth\")")
+	excludeExtKeyUsages := stringArrayFlag{}
+	getopt.FlagLong(&excludeExtKeyUsages, "exclude-ext-key-usage", 0, "don't export certificates having one or more of these extended key usages (applied after --include-ext-key-usage)")
+	validityStates := stringArrayFlag{}
+	getopt.FlagLong(&validityStates, "only-validity-state", 0, "only export the series of certificates in one or more of these states: \"valid\", \"warning\" (less than 30 days left), \"critical\" (less than 7 days left) or \"expired\"")
+
+	pinnedRoots := stringArrayFlag{}
+	getopt.FlagLong(&pinnedRoots, "pinned-root", 0, "one or more SHA-256 fingerprint of a trusted root, enables the x509_cert_chains_to_root metric telling which leaf certificates chain up to it")
+	useSystemRoots := getopt.BoolLong("use-system-roots", 0, "trust the system root certificates to verify leaf certificates, enables the x509_cert_verified metric")
+
+	sqlSources := stringArrayFlag{}
+	getopt.FlagLong(&sqlSources, "watch-sql", 0, "watch one or more database, given as <driver>:<dsn> where driver is \"postgres\" or \"mysql\"")
+	sqlQueries := stringArrayFlag{}
+	getopt.FlagLong(&sqlQueries, "sql-query", 0, "query returning PEM data in its first column and an identifier in its second one, given either once for all --watch-sql databases or once per database, in the same order")
+
+	tlsEndpoints := stringArrayFlag{}
+	getopt.FlagLong(&tlsEndpoints, "watch-tls-endpoint", 0, "watch the certificates presented by one or more TLS server (e.g. \"example.com:443\")")
+	endpointRefreshInterval := durationFlag(5 * time.Minute)
+	getopt.FlagLong(&endpointRefreshInterval, "endpoint-refresh-interval", 0, "how often --watch-tls-endpoint certificates are fetched in the background, scrapes are served from cache (0 to fetch on each scrape)")
+	endpointRefreshJitter := durationFlag(30 * time.Second)
+	getopt.FlagLong(&endpointRefreshJitter, "endpoint-refresh-jitter", 0, "maximum random delay added to each endpoint refresh, to spread connections over time")
+	httpSDURL := getopt.StringLong("http-sd-url", 0, "", "watch the TLS endpoints listed by this Prometheus HTTP service discovery URL, returning target groups such as [{\"targets\": [\"example.com:443\"]}]")
+	httpSDRefreshInterval := durationFlag(time.Minute)
+	getopt.FlagLong(&httpSDRefreshInterval, "http-sd-refresh-interval", 0, "how often the targets of --http-sd-url are fetched again")
+	httpSDCAFile := getopt.StringLong("http-sd-ca-file", 0, "", "PEM file containing the CA certificates used to verify the --http-sd-url server (defaults to the system roots)")
+	clientCertListen := getopt.StringLong("client-cert-listen", 0, "", "accept TLS connections on this address (e.g. :8443) and watch the certificates presented by clients")
+	clientCertRetention := durationFlag(24 * time.Hour)
+	getopt.FlagLong(&clientCertRetention, "client-cert-retention", 0, "forget the certificates of clients which didn't connect to --client-cert-listen for this long")
+	clientCertMaxRecords := getopt.IntLong("client-cert-max-records", 0, 1000, "maximum number of clients of --client-cert-listen whose certificates are kept, the least recently seen ones being forgotten first")
+	clientCertNetworks := stringArrayFlag{}
+	getopt.FlagLong(&clientCertNetworks, "client-cert-allowed-network", 0, "only record the certificates of --client-cert-listen clients connecting from one or more of these networks (CIDR, e.g. \"10.0.0.0/8\")")
+	ingestListen := getopt.StringLong("ingest-listen", 0, "", "serve the certificate ingestion gRPC service on this address (e.g. :9794), exposing the certificates reported by remote agents")
+	ingestClientCAFile := getopt.StringLong("ingest-client-ca-file", 0, "", "PEM CA certificates agents' client certificates must be issued by to report to --ingest-listen")
+	ingestRetention := durationFlag(24 * time.Hour)
+	getopt.FlagLong(&ingestRetention, "ingest-retention", 0, "forget the certificates of agent sources which weren't reported to --ingest-listen for this long")
+	endpointTimeout := durationFlag(10 * time.Second)
+	getopt.FlagLong(&endpointTimeout, "endpoint-timeout", 0, "timeout for connecting to an endpoint and completing the TLS handshake")
+
+	watchControlPlane := getopt.BoolLong("watch-control-plane", 0, "watch the serving certificates of the local kubelet and API server, labeled with their control_plane_component")
+	kubeletAddress := getopt.StringLong("kubelet-address", 0, "localhost:10250", "address of the kubelet watched with --watch-control-plane (empty to skip it)")
+	apiServerAddress := getopt.StringLong("apiserver-address", 0, "localhost:6443", "address of the API server watched with --watch-control-plane (empty to skip it)")
+	controlPlaneInsecureSkipVerify := true
+	getopt.FlagLong(&controlPlaneInsecureSkipVerify, "control-plane-insecure-skip-verify", 0, "don't verify control plane serving certificates, use --control-plane-insecure-skip-verify=false to only accept the ones chaining up to --control-plane-ca-file")
+	controlPlaneCAFile := getopt.StringLong("control-plane-ca-file", 0, "", "PEM file containing the CA certificates used to verify control plane serving certificates (defaults to system roots)")
+
+	gcsObjects := stringArrayFlag{}
+	getopt.FlagLong(&gcsObjects, "watch-gcs-object", 0, "watch one or more Google Cloud Storage object containing x509 certificates (e.g. \"gs://bucket/tls.crt\"), using Application Default Credentials")
+
+	gitFiles := stringArrayFlag{}
+	getopt.FlagLong(&gitFiles, "watch-git-file", 0, "watch one or more PEM file of a Git repository, given as <repository>#[<branch or tag>:]<path> (e.g. \"https://example.com/pki.git#main:certs/ca.pem\")")
+	gitRefreshInterval := durationFlag(5 * time.Minute)
+	getopt.FlagLong(&gitRefreshInterval, "git-refresh-interval", 0, "how often the repositories of --watch-git-file are fetched again")
+	gitTokenFile := getopt.StringLong("git-token-file", 0, "", "path to a file containing a token used to fetch --watch-git-file repositories over HTTP(S)")
+	gitSSHKeyFile := getopt.StringLong("git-ssh-key-file", 0, "", "path to a private key used to fetch --watch-git-file repositories over SSH, host keys being checked against known_hosts")
+
+	azureCertificates := stringArrayFlag{}
+	getopt.FlagLong(&azureCertificates, "watch-azure-certificate", 0, "watch one or more Azure Key Vault certificate (e.g. \"https://myvault.vault.azure.net/certificates/tls\"), using the default Azure credential chain")
+
+	consulKeys := stringArrayFlag{}
+	getopt.FlagLong(&consulKeys, "watch-consul-key", 0, "watch one or more Consul KV entry containing PEM certificates, given as consul://[<datacenter>]/<key>[?encoding=base64]")
+	consulAddress := getopt.StringLong("consul-address", 0, "", "address of the Consul agent (defaults to CONSUL_HTTP_ADDR or 127.0.0.1:8500)")
+	consulTokenFile := getopt.StringLong("consul-token-file", 0, "", "path to a file containing the Consul ACL token (defaults to CONSUL_HTTP_TOKEN or CONSUL_HTTP_TOKEN_FILE)")
+
+	etcdKeys := stringArrayFlag{}
+	getopt.FlagLong(&etcdKeys, "watch-etcd-key", 0, "watch one or more etcd v3 key containing PEM certificates, given as etcd://[<user>:<password>@]<host:port>[,<host:port>...]/<key>")
+	etcdCAFile := getopt.StringLong("etcd-ca-file", 0, "", "PEM file containing the CA certificates used to verify etcd servers, enables TLS")
+	etcdCertFile := getopt.StringLong("etcd-cert-file", 0, "", "PEM file containing the client certificate presented to etcd servers, enables TLS")
+	etcdKeyFile := getopt.StringLong("etcd-key-file", 0, "", "PEM file containing the private key of --etcd-cert-file")
+
+	ldapSearches := stringArrayFlag{}
+	getopt.FlagLong(&ldapSearches, "watch-ldap", 0, "watch one or more LDAP search returning DER certificates, given as ldap[s]://<host>[:<port>]/<base DN>[?<attributes>[?<scope>[?<filter>]]] (e.g. \"ldaps://ldap.example.com/ou=people,dc=example,dc=com?userCertificate;binary?sub\")")
+	ldapBindDN := getopt.StringLong("ldap-bind-dn", 0, "", "DN to bind to LDAP servers as (binds anonymously by default)")
+	ldapBindPasswordFile := getopt.StringLong("ldap-bind-password-file", 0, "", "path to a file containing the password of --ldap-bind-dn")
+	ldapCAFile := getopt.StringLong("ldap-ca-file", 0, "", "PEM file containing the CA certificates used to verify LDAP servers (defaults to the system roots)")
+	ldapStartTLS := getopt.BoolLong("ldap-start-tls", 0, "upgrade ldap:// connections to TLS with StartTLS")
+
+	ftpFiles := stringArrayFlag{}
+	getopt.FlagLong(&ftpFiles, "watch-ftp", 0, "watch one or more file on an FTP server, given as ftp[s]://<host>[:<port>]/<path> (e.g. \"ftps://appliance.example.com/certs/server.pem\"), ftps:// using implicit TLS")
+	ftpUser := getopt.StringLong("ftp-user", 0, "", "user to log in to FTP servers as (logs in anonymously by default)")
+	ftpPasswordFile := getopt.StringLong("ftp-password-file", 0, "", "path to a file containing the password of --ftp-user")
+	ftpCAFile := getopt.StringLong("ftp-ca-file", 0, "", "PEM file containing the CA certificates used to verify FTPS servers (defaults to the system roots)")
+	ftpExplicitTLS := getopt.BoolLong("ftp-explicit-tls", 0, "upgrade ftp:// connections to TLS with AUTH TLS")
+	ftpDisableEPSV := getopt.BoolLong("ftp-disable-epsv", 0, "open data connections with PASV rather than extended passive mode (EPSV), for servers not supporting it")
+
+	windowsCertStores := stringArrayFlag{} // Certificate stores and named pipes only available on Windows
+	namedPipes := stringArrayFlag{}
+	if runtime.GOOS == "windows" {
+		getopt.FlagLong(&windowsCertStores, "watch-windows-store", 0, "watch one or more Windows system certificate store, given as <location>/<store> where location is \"CurrentUser\" or \"LocalMachine\" (e.g. \"LocalMachine/MY\")")
+		getopt.FlagLong(&namedPipes, "watch-named-pipe", 0, "watch PEM certificates served by one or more local named pipe (e.g. \"\\\\.\\pipe\\certificates\")")
+	}
 
 	kubeEnabled := getopt.BoolLong("watch-kube-secrets", 0, "scrape kubernetes secrets and monitor them")
+	caBundlesEnabled := getopt.BoolLong("watch-ca-bundles", 0, "monitor the caBundle fields of validating and mutating webhook configurations, and of APIServices")
+	ingressesEnabled := getopt.BoolLong("watch-kube-ingresses", 0, "monitor the secrets referenced by the TLS entries of Ingresses, labeled with the Ingress name, namespace and hosts")
+	monitorsEnabled := getopt.BoolLong("watch-certificate-monitors", 0, "monitor the secrets and endpoints declared by CertificateMonitor resources of all namespaces")
+	kubeInformerResync := durationFlag(0)
+	getopt.FlagLong(&kubeInformerResync, "kube-informer-resync", 0, "how often the resources watched through Kubernetes informers (CertificateMonitors, Ingresses and CA bundles) are all reconciled again, even unchanged (0 to never resync, at least 1m)")
 
 	kubeConfig := getopt.StringLong("kubeconfig", 0, "", "Path to the kubeconfig file to use for requests. Takes precedence over the KUBECONFIG environment variable, and default path (~/.kube/config).", "path")
 
 	kubeSecretTypes := stringArrayFlag{}
-	getopt.FlagLong(&kubeSecretTypes, "secret-type", 's', "one or more kubernetes secret type & key to watch (e.g. \"kubernetes.io/tls:tls.crt\"")
+	getopt.FlagLong(&kubeSecretTypes, "secret-type", 's', "one or more kubernetes secret type & key to watch (e.g. \"kubernetes.io/tls:tls.crt\"), the key possibly being a Go template evaluated against the secret's metadata (e.g. \"Opaque:{{ .Name }}.crt\"), several keys joined with '+' being read as a single chain (e.g. \"kubernetes.io/tls:tls.crt+ca.crt\"), suffixed with \":file\" when the key holds the path of a PEM file, or with \":kubeconfig\" when it holds a kubeconfig embedding certificates")
+	kubeSecretPathRoot := getopt.StringLong("secret-path-root", 0, "", "directory containing the PEM files referenced by \":file\" secret types, other paths are rejected")
 
 	kubeIncludeNamespaces := stringArrayFlag{}
 	getopt.FlagLong(&kubeIncludeNamespaces, "include-namespace", 0, "add the given kube namespace to the watch list (when used, all namespaces are excluded by default)")
@@ -71,6 +279,8 @@ func main() {
 	kubeExcludeLabels := stringArrayFlag{}
 	getopt.FlagLong(&kubeExcludeLabels, "exclude-label", 0, "removes the kube secrets with the given label (or label value if specified) from the watch list (applied after --include-label)")
 
+	kubeSecretOwnerLabels := getopt.BoolLong("expose-secret-owner-labels", 0, "label the metrics of kube secrets with the kind and name of the resource owning them (e.g. a cert-manager Certificate)")
+
 	getopt.Parse()
 
 	if *help {
@@ -100,29 +310,424 @@ func main() {
 	}
 
 	exporter := internal.Exporter{
-		ListenAddress:         *listenAddress,
-		SystemdSocket:         *systemdSocket,
-		ConfigFile:            *configFile,
-		Files:                 files,
-		Directories:           directories,
-		YAMLs:                 yamls,
-		YAMLPaths:             internal.DefaultYamlPaths,
-		TrimPathComponents:    *trimPathComponents,
-		MaxCacheDuration:      time.Duration(maxCacheDuration),
-		ExposeRelativeMetrics: *exposeRelativeMetrics,
-		ExposeErrorMetrics:    *exposeErrorMetrics,
-		KubeSecretTypes:       kubeSecretTypes,
-		KubeIncludeNamespaces: kubeIncludeNamespaces,
-		KubeExcludeNamespaces: kubeExcludeNamespaces,
-		KubeIncludeLabels:     kubeIncludeLabels,
-		KubeExcludeLabels:     kubeExcludeLabels,
+		ListenAddress:           *listenAddress,
+		SystemdSocket:           *systemdSocket,
+		ConfigFile:              *configFile,
+		TLSCertFile:             *tlsCertFile,
+		TLSKeyFile:              *tlsKeyFile,
+		TLSClientCAFile:         *tlsClientCAFile,
+		Files:                   files,
+		Directories:             directories,
+		HashedDirectories:       hashedDirectories,
+		YAMLs:                   yamls,
+		YAMLPaths:               internal.DefaultYamlPaths,
+		INIs:                    inis,
+		DotenvFiles:             dotenvFiles,
+		AutoFiles:               autoFiles,
+		EmbeddedPEMFiles:        embeddedPEMFiles,
+		SDSFiles:                sdsFiles,
+		SystemdCredentials:      systemdCredentials,
+		LeafOnlySources:         leafOnlySources,
+		ConsulAddress:           *consulAddress,
+		ConsulTokenFile:         *consulTokenFile,
+		EtcdCAFile:              *etcdCAFile,
+		EtcdCertFile:            *etcdCertFile,
+		EtcdKeyFile:             *etcdKeyFile,
+		LDAPBindDN:              *ldapBindDN,
+		LDAPBindPasswordFile:    *ldapBindPasswordFile,
+		LDAPCAFile:              *ldapCAFile,
+		LDAPStartTLS:            *ldapStartTLS,
+		FTPUser:                 *ftpUser,
+		FTPPasswordFile:         *ftpPasswordFile,
+		FTPCAFile:               *ftpCAFile,
+		FTPExplicitTLS:          *ftpExplicitTLS,
+		FTPDisableEPSV:          *ftpDisableEPSV,
+		TrimPathComponents:      *trimPathComponents,
+		MaxCacheDuration:        time.Duration(maxCacheDuration),
+		KubeInformerResync:      time.Duration(kubeInformerResync),
+		ScrapeTimeout:           time.Duration(scrapeTimeout),
+		StaleTolerance:          time.Duration(staleTolerance),
+		CircuitBreakerFailures:  *circuitBreakerFailures,
+		CircuitBreakerBackoff:   time.Duration(circuitBreakerBackoff),
+		ClockSkewThreshold:      time.Duration(clockSkewThreshold),
+		ReadinessMinLifetime:    time.Duration(readinessMinLifetime),
+		OTLPEndpoint:            *otlpEndpoint,
+		OTLPHeaders:             map[string]string{},
+		OTLPInterval:            time.Duration(otlpInterval),
+		ClientCertListenAddress: *clientCertListen,
+		ClientCertRetention:     time.Duration(clientCertRetention),
+		ClientCertMaxRecords:    *clientCertMaxRecords,
+		IngestListenAddress:     *ingestListen,
+		IngestClientCAFile:      *ingestClientCAFile,
+		IngestRetention:         time.Duration(ingestRetention),
+		FailOnExpired:           *failOnExpired,
+		ExposeRelativeMetrics:   *exposeRelativeMetrics,
+		ExposeErrorMetrics:      *exposeErrorMetrics,
+		ExposeIssuerMetrics:     *exposeIssuerMetrics,
+		ExposeTypeMetrics:       *exposeTypeMetrics,
+		ExposeSANMetrics:        *exposeSANMetrics,
+		ExposeFormatLabel:       *exposeFormatLabel,
+		ExposeSANExpiryMetrics:  *exposeSANExpiryMetrics,
+		SANExpiryLimit:          *sanExpiryLimit,
+		ExposeEmailMetrics:      *exposeEmailMetrics,
+		ExposeIPSANMetrics:      *exposeIPSANMetrics,
+		ExposeRevocationMetrics: *exposeRevocationMetrics,
+		ExposeRotationMetrics:   *exposeRotationMetrics,
+		ExposeCacheAgeMetrics:   *exposeCacheAgeMetrics,
+		ExposeSHA1Metrics:       *exposeSHA1Metrics,
+		ExposeKeyReuseMetrics:   *exposeKeyReuseMetrics,
+		ExposePathLenMetrics:    *exposePathLenMetrics,
+		ExposeChainDepthMetrics: *exposeChainDepthMetrics,
+		ExposeWildcardMetrics:   *exposeWildcardMetrics,
+		ExposeChainOrderMetrics: *exposeChainOrderMetrics,
+		ExposeSkippedPEMMetrics: *exposeSkippedPEMMetrics,
+		ExposeHostnameMetrics:   *exposeHostnameMetrics,
+		ExposeCTMetrics:         *exposeCTMetrics,
+		CTLookupURL:             *ctLookupURL,
+		ConsolidatedMetrics:     *consolidatedMetrics,
+		LabelMappingsFile:       *labelMappingsFile,
+		CAFiles:                 caFiles,
+		TrustedRootFiles:        trustedRootFiles,
+		UseSystemRoots:          *useSystemRoots,
+		IssuerCountLimit:        *issuerCountLimit,
+		MinRSAKeySize:           *minRSAKeySize,
+		EndpointRefreshInterval: time.Duration(endpointRefreshInterval),
+		EndpointRefreshJitter:   time.Duration(endpointRefreshJitter),
+		EndpointTimeout:         time.Duration(endpointTimeout),
+		HTTPSDURL:               *httpSDURL,
+		HTTPSDRefreshInterval:   time.Duration(httpSDRefreshInterval),
+		HTTPSDCAFile:            *httpSDCAFile,
+		GitRefreshInterval:      time.Duration(gitRefreshInterval),
+		GitTokenFile:            *gitTokenFile,
+		GitSSHKeyFile:           *gitSSHKeyFile,
+		KubeSecretTypes:         kubeSecretTypes,
+		KubeSecretPathRoot:      *kubeSecretPathRoot,
+		KubeIncludeNamespaces:   kubeIncludeNamespaces,
+		KubeExcludeNamespaces:   kubeExcludeNamespaces,
+		KubeIncludeLabels:       kubeIncludeLabels,
+		KubeExcludeLabels:       kubeExcludeLabels,
+		KubeSecretOwnerLabels:   *kubeSecretOwnerLabels,
+	}
+
+	if len(sqlSources) > 0 && len(sqlQueries) != 1 && len(sqlQueries) != len(sqlSources) {
+		log.Fatal("--sql-query must be given either once for all --watch-sql databases or once per database")
+	}
+	for index, spec := range sqlSources {
+		query := sqlQueries[0]
+		if len(sqlQueries) > 1 {
+			query = sqlQueries[index]
+		}
+
+		source, err := internal.ParseSQLSource(spec, query)
+		if err != nil {
+			log.Fatalf("malformed sql source: %s", err.Error())
+		}
+
+		exporter.SQLSources = append(exporter.SQLSources, source)
+	}
+
+	for _, spec := range iniKeys {
+		ref, err := internal.ParseINICertRef(spec)
+		if err != nil {
+			log.Fatalf("malformed ini key: %s", err.Error())
+		}
+
+		exporter.INIKeys = append(exporter.INIKeys, ref)
+	}
+	if len(inis) > 0 && len(exporter.INIKeys) == 0 {
+		log.Fatal("--ini-key is required when using --watch-ini")
+	}
+
+	for _, spec := range dotenvKeys {
+		ref, err := internal.ParseDotenvCertRef(spec)
+		if err != nil {
+			log.Fatalf("malformed dotenv key: %s", err.Error())
+		}
+
+		exporter.DotenvKeys = append(exporter.DotenvKeys, ref)
+	}
+	if len(dotenvFiles) > 0 && len(exporter.DotenvKeys) == 0 {
+		log.Fatal("--dotenv-key is required when using --watch-dotenv")
+	}
+
+	for _, endpoint := range tlsEndpoints {
+		if _, _, err := net.SplitHostPort(endpoint); err != nil {
+			log.Fatalf("malformed tls endpoint \"%s\": %s", endpoint, err.Error())
+		}
+
+		exporter.TLSEndpoints = append(exporter.TLSEndpoints, internal.TLSEndpoint{Address: endpoint})
+	}
+
+	if *watchControlPlane {
+		endpoints, err := internal.ControlPlaneEndpoints(*kubeletAddress, *apiServerAddress, controlPlaneInsecureSkipVerify, *controlPlaneCAFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		exporter.TLSEndpoints = append(exporter.TLSEndpoints, endpoints...)
+	}
+
+	for _, gcsURL := range gcsObjects {
+		object, err := internal.ParseGCSURL(gcsURL)
+		if err != nil {
+			log.Fatalf("malformed gcs object: %s", err.Error())
+		}
+
+		exporter.GCSObjects = append(exporter.GCSObjects, object)
+	}
+
+	for _, spec := range gitFiles {
+		file, err := internal.ParseGitFileURL(spec)
+		if err != nil {
+			log.Fatalf("malformed git file: %s", err.Error())
+		}
+
+		exporter.GitFiles = append(exporter.GitFiles, file)
+	}
+
+	for _, certURL := range azureCertificates {
+		cert, err := internal.ParseAzureKeyVaultCertificateURL(certURL)
+		if err != nil {
+			log.Fatalf("malformed azure certificate: %s", err.Error())
+		}
+
+		exporter.AzureCertificates = append(exporter.AzureCertificates, cert)
+	}
+
+	for _, consulURL := range consulKeys {
+		key, err := internal.ParseConsulURL(consulURL)
+		if err != nil {
+			log.Fatalf("malformed consul key: %s", err.Error())
+		}
+
+		exporter.ConsulKeys = append(exporter.ConsulKeys, key)
+	}
+
+	for _, etcdURL := range etcdKeys {
+		key, err := internal.ParseEtcdURL(etcdURL)
+		if err != nil {
+			log.Fatalf("malformed etcd key: %s", err.Error())
+		}
+
+		exporter.EtcdKeys = append(exporter.EtcdKeys, key)
+	}
+	if (len(*etcdCertFile) > 0) != (len(*etcdKeyFile) > 0) {
+		log.Fatal("--etcd-cert-file and --etcd-key-file must be used together")
+	}
+
+	for _, ldapURL := range ldapSearches {
+		search, err := internal.ParseLDAPURL(ldapURL)
+		if err != nil {
+			log.Fatalf("malformed ldap search: %s", err.Error())
+		}
+
+		exporter.LDAPSearches = append(exporter.LDAPSearches, search)
+	}
+	if len(*ldapBindPasswordFile) > 0 && len(*ldapBindDN) == 0 {
+		log.Fatal("--ldap-bind-password-file requires --ldap-bind-dn")
+	}
+
+	for _, ftpURL := range ftpFiles {
+		file, err := internal.ParseFTPURL(ftpURL)
+		if err != nil {
+			log.Fatalf("malformed ftp file: %s", err.Error())
+		}
+
+		exporter.FTPFiles = append(exporter.FTPFiles, file)
+	}
+	if len(*ftpPasswordFile) > 0 && len(*ftpUser) == 0 {
+		log.Fatal("--ftp-password-file requires --ftp-user")
+	}
+
+	for _, spec := range expectedHostnames {
+		expected, err := internal.ParseExpectedHostname(spec)
+		if err != nil {
+			log.Fatalf("malformed expected hostname: %s", err.Error())
+		}
+
+		exporter.ExpectedHostnames = append(exporter.ExpectedHostnames, expected)
+	}
+
+	for _, spec := range serviceFiles {
+		source, err := internal.ParseServiceSource(spec)
+		if err != nil {
+			log.Fatalf("malformed service file: %s", err.Error())
+		}
+
+		exporter.ServiceFiles = append(exporter.ServiceFiles, source)
+	}
+
+	for _, spec := range serviceEndpoints {
+		source, err := internal.ParseServiceSource(spec)
+		if err != nil {
+			log.Fatalf("malformed service endpoint: %s", err.Error())
+		}
+
+		exporter.ServiceEndpoints = append(exporter.ServiceEndpoints, source)
+	}
+
+	if unit, err := internal.ParseExpiryUnit(*expiresInUnit); err == nil {
+		exporter.ExpiresInUnit = unit
+	} else {
+		log.Fatalf("malformed expires-in unit: %s", err.Error())
+	}
+
+	for _, spec := range pinnedRoots {
+		fingerprint, err := internal.ParseFingerprint(spec)
+		if err != nil {
+			log.Fatalf("malformed pinned root: %s", err.Error())
+		}
+
+		exporter.PinnedRoots = append(exporter.PinnedRoots, fingerprint)
+	}
+	if len(exporter.PinnedRoots) > 0 && !exporter.UseSystemRoots && len(exporter.TrustedRootFiles) == 0 {
+		log.Fatal("--pinned-root requires --trusted-roots-file or --use-system-roots")
+	}
+
+	if *clientCertMaxRecords < 1 {
+		log.Fatal("--client-cert-max-records must be at least 1")
+	}
+
+	for _, cidr := range clientCertNetworks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Fatalf("malformed client certificate network: %s", err.Error())
+		}
+
+		exporter.ClientCertNetworks = append(exporter.ClientCertNetworks, network)
+	}
+
+	if len(exporter.IngestListenAddress) > 0 && (len(exporter.TLSCertFile) == 0 || len(exporter.TLSKeyFile) == 0 || len(exporter.IngestClientCAFile) == 0) {
+		log.Fatal("--ingest-listen requires --tls-cert-file, --tls-key-file and --ingest-client-ca-file")
+	}
+
+	for _, spec := range readinessSelectors {
+		selector, err := internal.ParseReadinessSelector(spec)
+		if err != nil {
+			log.Fatalf("malformed readiness selector: %s", err.Error())
+		}
+
+		exporter.ReadinessSelectors = append(exporter.ReadinessSelectors, selector)
+	}
+
+	for _, name := range allowedSigAlgorithms {
+		algorithm, err := internal.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		exporter.AllowedSigAlgorithms = append(exporter.AllowedSigAlgorithms, algorithm)
+	}
+
+	for _, name := range deniedSigAlgorithms {
+		algorithm, err := internal.ParseSignatureAlgorithm(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		exporter.DeniedSigAlgorithms = append(exporter.DeniedSigAlgorithms, algorithm)
+	}
+
+	for _, spec := range pinnedPublicKeys {
+		pin, err := internal.ParsePublicKeyPin(spec)
+		if err != nil {
+			log.Fatalf("malformed public key pin: %s", err.Error())
+		}
+
+		exporter.PinnedPublicKeys = append(exporter.PinnedPublicKeys, pin)
+	}
+
+	for _, name := range includeExtKeyUsages {
+		usage, err := internal.ParseExtKeyUsage(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		exporter.IncludeExtKeyUsages = append(exporter.IncludeExtKeyUsages, usage)
+	}
+
+	for _, name := range excludeExtKeyUsages {
+		usage, err := internal.ParseExtKeyUsage(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		exporter.ExcludeExtKeyUsages = append(exporter.ExcludeExtKeyUsages, usage)
+	}
+
+	if len(*farFutureNotAfter) > 0 {
+		threshold, err := time.Parse(time.RFC3339, *farFutureNotAfter)
+		if err != nil {
+			log.Fatalf("malformed far future timestamp: %s", err.Error())
+		}
+
+		exporter.FarFutureNotAfter = threshold
+	}
+
+	for _, name := range validityStates {
+		state, err := internal.ParseValidityState(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		exporter.ValidityStates = append(exporter.ValidityStates, state)
+	}
+
+	for _, spec := range windowsCertStores {
+		store, err := internal.ParseWindowsCertStore(spec)
+		if err != nil {
+			log.Fatalf("malformed windows store: %s", err.Error())
+		}
+
+		exporter.WindowsCertStores = append(exporter.WindowsCertStores, store)
+	}
+
+	for _, spec := range namedPipes {
+		pipe, err := internal.ParseNamedPipe(spec)
+		if err != nil {
+			log.Fatalf("malformed named pipe: %s", err.Error())
+		}
+
+		exporter.NamedPipes = append(exporter.NamedPipes, pipe)
+	}
+
+	if len(systemdCredentials) > 0 && len(internal.GetSystemdCredentialsDirectory()) == 0 {
+		log.Warnln("$CREDENTIALS_DIRECTORY is not set, systemd credentials won't be watched (is the exporter run by systemd with LoadCredential=?)")
+	}
+
+	if exporter.KubeInformerResync != 0 && exporter.KubeInformerResync < internal.MinKubeInformerResync {
+		log.Fatalf("--kube-informer-resync must be 0 or at least %s, got %s", internal.MinKubeInformerResync, exporter.KubeInformerResync)
+	}
+
+	for _, secretType := range kubeSecretTypes {
+		if strings.HasSuffix(secretType, ":file") && len(*kubeSecretPathRoot) == 0 {
+			log.Fatalf("--secret-path-root is required to watch \"%s\"", secretType)
+		}
+	}
+
+	if len(*yamlPathsFile) > 0 {
+		yamlPaths, err := internal.LoadYAMLPaths(*yamlPathsFile)
+		if err != nil {
+			log.Fatalf("failed to load yaml paths from \"%s\": %s", *yamlPathsFile, err.Error())
+		}
+		exporter.YAMLPaths = yamlPaths
+	}
+
+	if len(*labelMappingsFile) > 0 {
+		if _, err := internal.LoadLabelMappings(*labelMappingsFile); err != nil {
+			log.Fatalf("failed to load label mappings from \"%s\": %s", *labelMappingsFile, err.Error())
+		}
+	}
+
+	if len(*tlsCertFile) > 0 && len(*configFile) > 0 {
+		log.Fatal("--tls-cert-file and --web.config.file are mutually exclusive")
 	}
 
 	if getopt.Lookup("expose-labels").Seen() {
 		exporter.ExposeLabels = strings.Split(*exposeLabels, ",")
 	}
 
-	if *kubeEnabled {
+	if *kubeEnabled || *ingressesEnabled || *caBundlesEnabled || *monitorsEnabled {
 		defaultKubeConfig := path.Join(os.Getenv("HOME"), ".kube", "config")
 		kubeConfigEnv := os.Getenv("KUBECONFIG")
 
@@ -142,10 +747,72 @@ func main() {
 			rateLimiter = flowcontrol.NewTokenBucketRateLimiter(float32(*rateLimitQPS), *rateLimitBurst)
 		}
 
-		err := exporter.ConnectToKubernetesCluster(configpath, rateLimiter)
-		if err != nil {
+		if *kubeEnabled {
+			err := exporter.ConnectToKubernetesCluster(configpath, rateLimiter)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if *ingressesEnabled {
+			err := exporter.ConnectToKubeIngresses(configpath, rateLimiter)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if *caBundlesEnabled {
+			err := exporter.ConnectToCABundles(configpath, rateLimiter)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if *monitorsEnabled {
+			err := exporter.WatchCertificateMonitors(configpath, rateLimiter)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	if len(*pushGateway) > 0 {
+		grouping := map[string]string{}
+		for _, keyAndValue := range pushGrouping {
+			parts := strings.SplitN(keyAndValue, "=", 2)
+			if len(parts) != 2 {
+				log.Fatalf("malformed push grouping: \"%s\"", keyAndValue)
+			}
+			grouping[parts[0]] = parts[1]
+		}
+
+		err := exporter.Push(*pushGateway, *pushJob, grouping)
+		if errors.Is(err, internal.ErrExpiredCertificates) {
+			log.Infof("pushed metrics to %s", *pushGateway)
 			log.Fatal(err)
 		}
+		if err != nil {
+			log.Fatal("failed to push metrics: ", err)
+		}
+
+		log.Infof("pushed metrics to %s", *pushGateway)
+		return
+	}
+
+	for _, keyAndValue := range otlpHeaders {
+		parts := strings.SplitN(keyAndValue, "=", 2)
+		if len(parts) != 2 {
+			log.Fatalf("malformed OTLP header: \"%s\"", keyAndValue)
+		}
+		exporter.OTLPHeaders[parts[0]] = parts[1]
+	}
+
+	if *otlpOnly {
+		err := exporter.RunOTLPExporter()
+		if err != nil {
+			log.Fatal("failed to run OTLP exporter: ", err)
+		}
+		return
 	}
 
 	err := exporter.ListenAndServe()
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// AzureKeyVaultCertificate : A certificate stored in an Azure Key Vault
+type AzureKeyVaultCertificate struct {
+	VaultURL string
+	Name     string
+	Version  string
+}
+
+// azureCertificateGetter : Download the public DER certificate of a Key Vault certificate
+type azureCertificateGetter interface {
+	GetCertificate(ctx context.Context, name string, version string) ([]byte, error)
+}
+
+// azureCertificatesClient : Key Vault certificates API client, which only needs the get permission on certificates
+// and never sees private keys, unlike reading the secret backing a certificate
+type azureCertificatesClient struct {
+	vaultURL string
+	pipeline runtime.Pipeline
+}
+
+const azureTimeout = 30 * time.Second
+
+const azureKeyVaultAPIVersion = "7.4"
+
+// ParseAzureKeyVaultCertificateURL : Split a https://<vault>.vault.azure.net/certificates/<name>[/<version>] URL
+func ParseAzureKeyVaultCertificateURL(certURL string) (AzureKeyVaultCertificate, error) {
+	parsed, err := url.Parse(certURL)
+	if err != nil {
+		return AzureKeyVaultCertificate{}, err
+	}
+
+	components := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if parsed.Scheme != "https" || len(parsed.Host) == 0 || len(components) < 2 || len(components) > 3 || components[0] != "certificates" || len(components[1]) == 0 {
+		return AzureKeyVaultCertificate{}, fmt.Errorf("expected https://<vault>/certificates/<name>[/<version>], got \"%s\"", certURL)
+	}
+
+	cert := AzureKeyVaultCertificate{
+		VaultURL: fmt.Sprintf("https://%s", parsed.Host),
+		Name:     components[1],
+	}
+	if len(components) == 3 {
+		cert.Version = components[2]
+	}
+
+	return cert, nil
+}
+
+func (exporter *Exporter) collectAzureCertificates() []*certificateRef {
+	output := []*certificateRef{}
+
+	for index := range exporter.AzureCertificates {
+		cert := &exporter.AzureCertificates[index]
+		output = append(output, &certificateRef{
+			path:        fmt.Sprintf("%s/certificates/%s", cert.VaultURL, cert.Name),
+			format:      certificateFormatAzureKeyVault,
+			azureCert:   cert,
+			azureClient: exporter.getAzureClient,
+		})
+	}
+
+	return output
+}
+
+// getAzureClient : Lazily create one certificates client per vault, authenticating with
+// the default credential chain (environment service principal, workload or managed identity, ...)
+func (exporter *Exporter) getAzureClient(vaultURL string) (azureCertificateGetter, error) {
+	exporter.azureMutex.Lock()
+	defer exporter.azureMutex.Unlock()
+
+	if exporter.azureClients == nil {
+		exporter.azureClients = map[string]azureCertificateGetter{}
+	}
+
+	if client, found := exporter.azureClients[vaultURL]; found {
+		return client, nil
+	}
+
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Azure credentials: %s", err.Error())
+	}
+
+	client, err := newAzureCertificatesClient(vaultURL, credential, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter.azureClients[vaultURL] = client
+	return client, nil
+}
+
+// newAzureCertificatesClient : Client of a vault, tokens being requested for the Key Vault resource of its cloud
+// (e.g. https://vault.azure.net for https://<vault>.vault.azure.net)
+func newAzureCertificatesClient(vaultURL string, credential azcore.TokenCredential, options *policy.ClientOptions) (*azureCertificatesClient, error) {
+	parsed, err := url.Parse(vaultURL)
+	if err != nil {
+		return nil, err
+	}
+
+	_, resource, found := strings.Cut(parsed.Hostname(), ".")
+	if !found {
+		return nil, fmt.Errorf("unexpected Key Vault URL \"%s\"", vaultURL)
+	}
+
+	return &azureCertificatesClient{
+		vaultURL: vaultURL,
+		pipeline: runtime.NewPipeline("x509-certificate-exporter", Version, runtime.PipelineOptions{
+			PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(credential, []string{fmt.Sprintf("https://%s/.default", resource)}, nil)},
+		}, options),
+	}, nil
+}
+
+// GetCertificate : Get a version of a certificate (the latest one if version is empty) and return its "cer" field
+func (client *azureCertificatesClient) GetCertificate(ctx context.Context, name string, version string) ([]byte, error) {
+	request, err := runtime.NewRequest(ctx, http.MethodGet, runtime.JoinPaths(client.vaultURL, "certificates", url.PathEscape(name), url.PathEscape(version)))
+	if err != nil {
+		return nil, err
+	}
+	request.Raw().URL.RawQuery = url.Values{"api-version": []string{azureKeyVaultAPIVersion}}.Encode()
+	request.Raw().Header.Set("Accept", "application/json")
+
+	response, err := client.pipeline.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if !runtime.HasStatusCode(response, http.StatusOK) {
+		return nil, runtime.NewResponseError(response)
+	}
+
+	bundle := struct {
+		CER string `json:"cer"`
+	}{}
+	if err := runtime.UnmarshalAsJSON(response, &bundle); err != nil {
+		return nil, err
+	}
+	if len(bundle.CER) == 0 {
+		return nil, fmt.Errorf("certificate \"%s\" has no value", name)
+	}
+
+	der, err := base64.StdEncoding.DecodeString(bundle.CER)
+	if err != nil {
+		der, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(bundle.CER, "="))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("certificate \"%s\": %s", name, err.Error())
+	}
+
+	return der, nil
+}
+
+// readAndParseAzureCertificate : Download the public part of a Key Vault certificate, which is the leaf only,
+// issuers not being stored by Key Vault outside of the secret holding the private key
+func readAndParseAzureCertificate(ctx context.Context, cert *AzureKeyVaultCertificate, getClient func(string) (azureCertificateGetter, error)) ([]*parsedCertificate, error) {
+	client, err := getClient(cert.VaultURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, azureTimeout)
+	defer cancel()
+
+	der, err := client.GetCertificate(ctx, cert.Name, cert.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*parsedCertificate{{cert: parsed}}, nil
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockAzureClient struct {
+	certificates map[string][]byte
+	err          error
+}
+
+func (client *mockAzureClient) GetCertificate(_ context.Context, name string, _ string) ([]byte, error) {
+	if client.err != nil {
+		return nil, client.err
+	}
+
+	der, found := client.certificates[name]
+	if !found {
+		return nil, errors.New("CertificateNotFound")
+	}
+
+	return der, nil
+}
+
+type fakeAzureCredential struct {
+	scopes []string
+}
+
+func (credential *fakeAzureCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	credential.scopes = options.Scopes
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestAzureKeyVault(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	leaf := generateTestCertificate(leafTemplate("leaf", time.Now().Add(time.Hour)), root)
+
+	exporter := &Exporter{
+		AzureCertificates: []AzureKeyVaultCertificate{
+			{VaultURL: "https://main.vault.azure.net", Name: "tls"},
+			{VaultURL: "https://main.vault.azure.net", Name: "garbage"},
+			{VaultURL: "https://main.vault.azure.net", Name: "missing"},
+			{VaultURL: "https://forbidden.vault.azure.net", Name: "tls"},
+		},
+		ExposeErrorMetrics: true,
+		azureClients: map[string]azureCertificateGetter{
+			"https://main.vault.azure.net": &mockAzureClient{certificates: map[string][]byte{
+				"tls":     leaf.cert.Raw,
+				"garbage": []byte("garbage"),
+			}},
+			"https://forbidden.vault.azure.net": &mockAzureClient{err: errors.New("failed to acquire a token")},
+		},
+	}
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		found := map[string][]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			assert.Equal(t, "main.vault.azure.net", getLabelValue(metric, "azure_vault"))
+			name := getLabelValue(metric, "azure_certificate")
+			found[name] = append(found[name], getLabelValue(metric, "subject_CN"))
+		}
+		assert.Equal(t, map[string][]string{"tls": {"leaf"}}, found)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 3., errMetric[0].GetGauge().GetValue())
+
+		certErrors := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_error") {
+			certErrors[getLabelValue(metric, "azure_vault")+"/"+getLabelValue(metric, "azure_certificate")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{
+			"main.vault.azure.net/tls":      0,
+			"main.vault.azure.net/garbage":  1,
+			"main.vault.azure.net/missing":  1,
+			"forbidden.vault.azure.net/tls": 1,
+		}, certErrors)
+	})
+}
+
+func TestAzureCertificatesClient(t *testing.T) {
+	cert := generateTestCertificate(leafTemplate("leaf", time.Now().Add(time.Hour)), nil)
+
+	requests := []string{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		if r.URL.Path == "/certificates/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		// the certificates API returns the public DER certificate only, never the private key
+		//nolint:errcheck
+		json.NewEncoder(w).Encode(map[string]string{
+			"id":  "https://main.vault.azure.net" + r.URL.Path,
+			"cer": base64.StdEncoding.EncodeToString(cert.cert.Raw),
+		})
+	}))
+	defer server.Close()
+
+	credential := &fakeAzureCredential{}
+	client, err := newAzureCertificatesClient(server.URL, credential, &policy.ClientOptions{Transport: server.Client()})
+	assert.NoError(t, err)
+
+	der, err := client.GetCertificate(context.Background(), "tls", "0123")
+	assert.NoError(t, err)
+	assert.Equal(t, cert.cert.Raw, der)
+	_, err = client.GetCertificate(context.Background(), "missing", "")
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"/certificates/tls/0123?api-version=7.4", "/certificates/missing?api-version=7.4"}, requests)
+	// the token is requested for the Key Vault resource, the vault URL without the vault name
+	assert.Equal(t, []string{"https://0.0.1/.default"}, credential.scopes)
+}
+
+func TestParseAzureKeyVaultCertificateURL(t *testing.T) {
+	cert, err := ParseAzureKeyVaultCertificateURL("https://main.vault.azure.net/certificates/tls")
+	assert.NoError(t, err)
+	assert.Equal(t, AzureKeyVaultCertificate{VaultURL: "https://main.vault.azure.net", Name: "tls"}, cert)
+
+	cert, err = ParseAzureKeyVaultCertificateURL("https://main.vault.azure.net/certificates/tls/0123456789abcdef")
+	assert.NoError(t, err)
+	assert.Equal(t, AzureKeyVaultCertificate{VaultURL: "https://main.vault.azure.net", Name: "tls", Version: "0123456789abcdef"}, cert)
+
+	for _, invalid := range []string{
+		"http://main.vault.azure.net/certificates/tls",
+		"https://main.vault.azure.net/secrets/tls",
+		"https://main.vault.azure.net/certificates",
+		"https://main.vault.azure.net/certificates/tls/version/extra",
+	} {
+		_, err := ParseAzureKeyVaultCertificateURL(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultCircuitBreakerBackoff = 5 * time.Minute
+
+type breakerState int
+
+const (
+	// breakerClosed : The source is read on each parse
+	breakerClosed breakerState = iota
+	// breakerOpen : The source failed too many times in a row and isn't read until its backoff elapses
+	breakerOpen
+	// breakerHalfOpen : A single parse is probing the source after its backoff
+	breakerHalfOpen
+)
+
+// circuitBreaker : Consecutive failures of a network source across parses
+type circuitBreaker struct {
+	state    breakerState
+	failures int
+	lastErr  error
+	retryAt  time.Time
+}
+
+// isNetworkSource : Tell if reading a source involves a remote service, local files being cheap to retry
+func isNetworkSource(ref *certificateRef) bool {
+	switch ref.format {
+	case certificateFormatSQL, certificateFormatEndpoint, certificateFormatGCS, certificateFormatGit, certificateFormatAzureKeyVault, certificateFormatConsul, certificateFormatEtcd, certificateFormatLDAP, certificateFormatFTP:
+		return true
+	}
+
+	return false
+}
+
+// parseThroughBreaker : Parse a source unless it failed CircuitBreakerFailures times in a row, in which case it's only
+// probed again once CircuitBreakerBackoff elapsed; meanwhile the last error is reported so its error metric stays set
+func (exporter *Exporter) parseThroughBreaker(ctx context.Context, ref *certificateRef) error {
+	if exporter.CircuitBreakerFailures == 0 || !isNetworkSource(ref) {
+		return ref.parse(ctx)
+	}
+
+	key := getSourceKey(ref)
+	if err := exporter.acquireBreaker(key); err != nil {
+		return err
+	}
+
+	err := ref.parse(ctx)
+	exporter.releaseBreaker(key, err)
+	return err
+}
+
+func (exporter *Exporter) acquireBreaker(key string) error {
+	exporter.breakersMutex.Lock()
+	defer exporter.breakersMutex.Unlock()
+
+	if exporter.breakers == nil {
+		exporter.breakers = map[string]*circuitBreaker{}
+	}
+
+	breaker, found := exporter.breakers[key]
+	if !found {
+		breaker = &circuitBreaker{}
+		exporter.breakers[key] = breaker
+	}
+
+	switch breaker.state {
+	case breakerOpen:
+		if time.Now().Before(breaker.retryAt) {
+			return fmt.Errorf("not retried before %s after %d consecutive failures, last one: %s", breaker.retryAt.Format(time.RFC3339), breaker.failures, breaker.lastErr.Error())
+		}
+		breaker.state = breakerHalfOpen
+	case breakerHalfOpen:
+		// another parse is probing it
+		return fmt.Errorf("being probed after %d consecutive failures, last one: %s", breaker.failures, breaker.lastErr.Error())
+	}
+
+	return nil
+}
+
+func (exporter *Exporter) releaseBreaker(key string, err error) {
+	exporter.breakersMutex.Lock()
+	defer exporter.breakersMutex.Unlock()
+
+	breaker, found := exporter.breakers[key]
+	if !found {
+		// pruned meanwhile by a parse which didn't find the source anymore
+		return
+	}
+
+	if err == nil {
+		breaker.state = breakerClosed
+		breaker.failures = 0
+		breaker.lastErr = nil
+		return
+	}
+
+	breaker.failures++
+	breaker.lastErr = err
+	if breaker.state == breakerHalfOpen || breaker.failures >= exporter.CircuitBreakerFailures {
+		backoff := exporter.CircuitBreakerBackoff
+		if backoff == 0 {
+			backoff = defaultCircuitBreakerBackoff
+		}
+
+		breaker.state = breakerOpen
+		breaker.retryAt = time.Now().Add(backoff)
+	}
+}
+
+// pruneBreakers : Forget the failures of sources which are gone, starting over if they show up again
+func (exporter *Exporter) pruneBreakers(refs []*certificateRef) {
+	exporter.breakersMutex.Lock()
+	defer exporter.breakersMutex.Unlock()
+
+	present := getSourceKeys(refs)
+	for key := range exporter.breakers {
+		if !present[key] {
+			delete(exporter.breakers, key)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	failing := atomic.Bool{}
+	attempts := atomic.Int32{}
+	failing.Store(true)
+
+	server := startFakeTLSServer(t, generateTestCertificate(leafTemplate("endpoint", time.Now().Add(time.Hour)), nil), &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			attempts.Add(1)
+			if failing.Load() {
+				return nil, errors.New("unavailable")
+			}
+			return nil, nil
+		},
+	})
+
+	exporter := &Exporter{
+		TLSEndpoints:           []TLSEndpoint{{Address: server.address()}},
+		CircuitBreakerFailures: 2,
+		CircuitBreakerBackoff:  100 * time.Millisecond,
+	}
+	key := "endpoint/" + server.address() + ":"
+
+	// parse and return the number of connection attempts it made and its error, if any
+	parse := func() (int32, error) {
+		before := attempts.Load()
+		refs, errs := exporter.parseAllCertificates(context.Background())
+		if len(errs) > 0 {
+			assert.Len(t, errs, 1)
+			assert.Equal(t, server.address(), exporter.getBaseLabels(errs[0].ref)["endpoint"])
+			assert.Len(t, refs[0].certificates, 0)
+			return attempts.Load() - before, errs[0].err
+		}
+
+		assert.Len(t, refs[0].certificates, 1)
+		return attempts.Load() - before, nil
+	}
+	getState := func() breakerState {
+		exporter.breakersMutex.Lock()
+		defer exporter.breakersMutex.Unlock()
+		return exporter.breakers[key].state
+	}
+
+	// closed until the failure threshold
+	count, err := parse()
+	assert.Equal(t, int32(1), count)
+	assert.Error(t, err)
+	assert.Equal(t, breakerClosed, getState())
+
+	count, _ = parse()
+	assert.Equal(t, int32(1), count)
+	assert.Equal(t, breakerOpen, getState())
+
+	// open: the error is still reported, without connecting
+	count, err = parse()
+	assert.Equal(t, int32(0), count)
+	assert.ErrorContains(t, err, "after 2 consecutive failures")
+
+	// half-open: a single failing probe opens it again
+	time.Sleep(150 * time.Millisecond)
+	count, _ = parse()
+	assert.Equal(t, int32(1), count)
+	assert.Equal(t, breakerOpen, getState())
+	count, _ = parse()
+	assert.Equal(t, int32(0), count)
+
+	// half-open: a successful probe closes it
+	failing.Store(false)
+	time.Sleep(150 * time.Millisecond)
+	count, err = parse()
+	assert.Equal(t, int32(1), count)
+	assert.NoError(t, err)
+	assert.Equal(t, breakerClosed, getState())
+
+	// closed: the failure count starts over
+	failing.Store(true)
+	count, _ = parse()
+	assert.Equal(t, int32(1), count)
+	assert.Equal(t, breakerClosed, getState())
+	count, _ = parse()
+	assert.Equal(t, int32(1), count)
+	assert.Equal(t, breakerOpen, getState())
+
+	// the failures of sources which are gone are forgotten
+	exporter.TLSEndpoints = nil
+	exporter.parseAllCertificates(context.Background())
+	exporter.breakersMutex.Lock()
+	assert.Empty(t, exporter.breakers)
+	exporter.breakersMutex.Unlock()
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	exporter := &Exporter{
+		TLSEndpoints:    []TLSEndpoint{{Address: "127.0.0.1:1"}},
+		EndpointTimeout: time.Second,
+	}
+
+	for index := 0; index < 3; index++ {
+		_, errs := exporter.parseAllCertificates(context.Background())
+		assert.Len(t, errs, 1)
+	}
+	assert.Len(t, exporter.breakers, 0)
+}
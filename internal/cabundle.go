@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// caBundleResource : A cluster-scoped API carrying caBundle fields, either in a list of webhooks or in its spec
+type caBundleResource struct {
+	kind     string
+	resource schema.GroupVersionResource
+	webhooks bool
+}
+
+var caBundleResources = []caBundleResource{
+	{
+		kind:     "ValidatingWebhookConfiguration",
+		resource: schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingwebhookconfigurations"},
+		webhooks: true,
+	},
+	{
+		kind:     "MutatingWebhookConfiguration",
+		resource: schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "mutatingwebhookconfigurations"},
+		webhooks: true,
+	},
+	{
+		kind:     "APIService",
+		resource: schema.GroupVersionResource{Group: "apiregistration.k8s.io", Version: "v1", Resource: "apiservices"},
+	},
+}
+
+// caBundle : The certificates of a caBundle field, webhook being empty for APIServices
+type caBundle struct {
+	kind    string
+	name    string
+	webhook string
+	data    string
+}
+
+// ConnectToCABundles : Connect to a cluster like ConnectToKubernetesCluster, to watch the caBundle fields
+// of webhook configurations and APIServices
+func (exporter *Exporter) ConnectToCABundles(path string, rateLimiter flowcontrol.RateLimiter) error {
+	config, err := parseKubeConfig(path)
+	if err != nil {
+		return err
+	}
+
+	if rateLimiter != nil {
+		config.RateLimiter = rateLimiter
+	}
+
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	return exporter.startCABundleListers(client)
+}
+
+// startCABundleListers : Watch the resources carrying caBundle fields through the shared informer factory
+func (exporter *Exporter) startCABundleListers(dynamicClient dynamic.Interface) error {
+	listers := []cache.GenericLister{}
+	for _, resource := range caBundleResources {
+		lister, err := exporter.startKubeLister(dynamicClient, resource.resource)
+		if err != nil {
+			return err
+		}
+
+		listers = append(listers, lister)
+	}
+
+	exporter.caBundlesListers = listers
+	return nil
+}
+
+// collectCABundles : List the watched webhook configurations and APIServices, a ref being created for each non-empty caBundle
+func (exporter *Exporter) collectCABundles() ([]*certificateRef, []error) {
+	output := []*certificateRef{}
+	outputErrors := []error{}
+
+	for index, resource := range caBundleResources {
+		objects, err := exporter.caBundlesListers[index].List(labels.Everything())
+		if err != nil {
+			outputErrors = append(outputErrors, fmt.Errorf("failed to list %s: %s", resource.resource.Resource, err.Error()))
+			continue
+		}
+
+		for _, item := range objects {
+			object, ok := item.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+
+			bundles, err := getCABundles(resource, object)
+			if err != nil {
+				outputErrors = append(outputErrors, fmt.Errorf("failed to read %s \"%s\": %s", resource.kind, object.GetName(), err.Error()))
+				continue
+			}
+
+			for _, bundle := range bundles {
+				path := fmt.Sprintf("cabundle/%s/%s", bundle.kind, bundle.name)
+				if len(bundle.webhook) > 0 {
+					path = fmt.Sprintf("%s/%s", path, bundle.webhook)
+				}
+
+				output = append(output, &certificateRef{
+					path:     path,
+					format:   certificateFormatCABundle,
+					caBundle: bundle,
+				})
+			}
+		}
+	}
+
+	return output, outputErrors
+}
+
+func getCABundles(resource caBundleResource, object *unstructured.Unstructured) ([]*caBundle, error) {
+	if !resource.webhooks {
+		data, _, err := unstructured.NestedString(object.Object, "spec", "caBundle")
+		if err != nil || len(data) == 0 {
+			return nil, err
+		}
+
+		return []*caBundle{{kind: resource.kind, name: object.GetName(), data: data}}, nil
+	}
+
+	webhooks, _, err := unstructured.NestedSlice(object.Object, "webhooks")
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*caBundle{}
+	for _, rawWebhook := range webhooks {
+		webhook, ok := rawWebhook.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _, _ := unstructured.NestedString(webhook, "name")
+		data, _, err := unstructured.NestedString(webhook, "clientConfig", "caBundle")
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) > 0 {
+			output = append(output, &caBundle{kind: resource.kind, name: object.GetName(), webhook: name, data: data})
+		}
+	}
+
+	return output, nil
+}
+
+func readAndParseCABundle(bundle *caBundle) ([]*parsedCertificate, error) {
+	data, err := base64.StdEncoding.DecodeString(bundle.data)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := parsePEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*parsedCertificate{}
+	for _, cert := range certs {
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	return output, nil
+}
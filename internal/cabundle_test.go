@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"encoding/base64"
+	"os"
+	"testing"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestCABundles(t *testing.T) {
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+	double, err := os.ReadFile("../test/double.pem")
+	assert.NoError(t, err)
+
+	listKinds := map[schema.GroupVersionResource]string{}
+	for _, resource := range caBundleResources {
+		listKinds[resource.resource] = resource.kind + "List"
+	}
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds,
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "admissionregistration.k8s.io/v1",
+			"kind":       "ValidatingWebhookConfiguration",
+			"metadata":   map[string]interface{}{"name": "policy"},
+			"webhooks": []interface{}{
+				map[string]interface{}{
+					"name":         "validate.policy.example.com",
+					"clientConfig": map[string]interface{}{"caBundle": base64.StdEncoding.EncodeToString(basic)},
+				},
+				// relying on the system roots
+				map[string]interface{}{
+					"name":         "external.policy.example.com",
+					"clientConfig": map[string]interface{}{"url": "https://policy.example.com"},
+				},
+			},
+		}},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apiregistration.k8s.io/v1",
+			"kind":       "APIService",
+			"metadata":   map[string]interface{}{"name": "v1beta1.metrics.k8s.io"},
+			"spec":       map[string]interface{}{"caBundle": base64.StdEncoding.EncodeToString(double)},
+		}},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apiregistration.k8s.io/v1",
+			"kind":       "APIService",
+			"metadata":   map[string]interface{}{"name": "v1.apps"},
+			"spec":       map[string]interface{}{},
+		}},
+	)
+
+	exporter := &Exporter{}
+	assert.NoError(t, exporter.startCABundleListers(client))
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		assert.Len(t, foundMetrics, 3)
+
+		counts := map[string]int{}
+		for _, metric := range foundMetrics {
+			counts[getLabelValue(metric, "ca_bundle_kind")+"/"+getLabelValue(metric, "ca_bundle_object")+"/"+getLabelValue(metric, "ca_bundle_webhook")]++
+		}
+		assert.Equal(t, map[string]int{
+			"ValidatingWebhookConfiguration/policy/validate.policy.example.com": 1,
+			"APIService/v1beta1.metrics.k8s.io/":                                2,
+		}, counts)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+	})
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"time"
+)
+
+// now : Current time, from the clock overridden by tests if any
+func (exporter *Exporter) now() time.Time {
+	if exporter.clock != nil {
+		return exporter.clock()
+	}
+
+	return time.Now()
+}
+
+// trackParseAges : Remember when each source was last parsed successfully, and return how long ago that was for
+// each of them, failing sources (served stale or not at all) getting older until they succeed again;
+// sources which are gone are forgotten
+func (exporter *Exporter) trackParseAges(refs []*certificateRef) map[*certificateRef]time.Duration {
+	exporter.parseTimesMutex.Lock()
+	defer exporter.parseTimesMutex.Unlock()
+
+	if exporter.parseTimes == nil {
+		exporter.parseTimes = map[string]time.Time{}
+	}
+
+	present := getSourceKeys(refs)
+	for key := range exporter.parseTimes {
+		if !present[key] {
+			delete(exporter.parseTimes, key)
+		}
+	}
+
+	now := exporter.now()
+	output := map[*certificateRef]time.Duration{}
+	for _, ref := range refs {
+		key := getSourceKey(ref)
+		if len(ref.certificates) > 0 && !ref.stale {
+			exporter.parseTimes[key] = now
+		}
+
+		// never parsed successfully since the exporter started
+		parsedAt, found := exporter.parseTimes[key]
+		if !found {
+			continue
+		}
+
+		output[ref] = now.Sub(parsedAt)
+	}
+
+	return output
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheAge(t *testing.T) {
+	dir := t.TempDir()
+	certPath := path.Join(dir, "tls.pem")
+	writeTestCertificates(certPath, generateTestCertificate(leafTemplate("tls", time.Now().Add(time.Hour)), nil))
+
+	now := time.Now()
+	exporter := &Exporter{
+		Files:                 []string{certPath},
+		StaleTolerance:        time.Hour,
+		ExposeCacheAgeMetrics: true,
+		clock: func() time.Time {
+			return now
+		},
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{exporter: exporter})
+
+	scrape := func() []float64 {
+		metrics, err := registry.Gather()
+		assert.NoError(t, err)
+
+		ages := []float64{}
+		for index := range metrics {
+			if metrics[index].GetName() != "x509_cert_cache_age_seconds" {
+				continue
+			}
+			for _, metric := range metrics[index].GetMetric() {
+				assert.Equal(t, "tls.pem", getLabelValue(metric, "filename"))
+				ages = append(ages, metric.GetGauge().GetValue())
+			}
+		}
+		return ages
+	}
+	assert.Equal(t, []float64{0}, scrape())
+
+	// reset on each successful parse
+	now = now.Add(time.Minute)
+	assert.Equal(t, []float64{0}, scrape())
+
+	// served from the last successful parse, getting older
+	assert.NoError(t, os.Remove(certPath))
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, []float64{30}, scrape())
+	now = now.Add(90 * time.Second)
+	assert.Equal(t, []float64{120}, scrape())
+
+	writeTestCertificates(certPath, generateTestCertificate(leafTemplate("tls", time.Now().Add(time.Hour)), nil))
+	now = now.Add(time.Minute)
+	assert.Equal(t, []float64{0}, scrape())
+
+	// sources which are gone are forgotten
+	exporter.trackParseAges(nil)
+	assert.Empty(t, exporter.parseTimes)
+}
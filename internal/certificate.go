@@ -1,17 +1,25 @@
 package internal
 
 import (
+	"bytes"
+	"context"
 	"crypto/x509"
+	"database/sql"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
+	"github.com/jlaffaye/ftp"
 	"github.com/yalp/jsonpath"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"gopkg.in/yaml.v3"
 	v1 "k8s.io/api/core/v1"
 )
@@ -22,6 +30,8 @@ type YAMLCertRef struct {
 	CertMatchSubExpr  string
 	IDMatchSubExpr    string
 	Format            YAMLCertFormat
+	Guards            []YAMLGuard
+	GuardMode         YAMLGuardMode
 }
 
 // YAMLCertFormat : Type of cert encoding in YAML files
@@ -29,8 +39,26 @@ type YAMLCertFormat int
 
 // YAMLCertFormat : Impl
 const (
-	YAMLCertFormatFile   YAMLCertFormat = iota
-	YAMLCertFormatBase64                = iota
+	YAMLCertFormatFile      YAMLCertFormat = iota
+	YAMLCertFormatBase64                   = iota
+	YAMLCertFormatHex                      = iota
+	YAMLCertFormatBase64DER                = iota
+)
+
+// YAMLGuard : Condition on a sibling field of the matched certificate,
+// MatchSubExpr is evaluated against the same entry as CertMatchSubExpr
+type YAMLGuard struct {
+	MatchSubExpr string
+	Value        string
+}
+
+// YAMLGuardMode : How multiple guards of a YAMLCertRef are combined
+type YAMLGuardMode int
+
+// YAMLGuardMode : Impl
+const (
+	YAMLGuardModeAll YAMLGuardMode = iota
+	YAMLGuardModeAny               = iota
 )
 
 // DefaultYamlPaths : Pre-written paths for some k8s config files
@@ -61,57 +89,336 @@ var DefaultYamlPaths = []YAMLCertRef{
 	},
 }
 
+type yamlCertRefConfig struct {
+	Base   string `yaml:"base"`
+	Cert   string `yaml:"cert"`
+	ID     string `yaml:"id"`
+	Format string `yaml:"format"`
+	Guards []struct {
+		Expr  string `yaml:"expr"`
+		Value string `yaml:"value"`
+	} `yaml:"guards"`
+	GuardMode string `yaml:"guardMode"`
+}
+
+// LoadYAMLPaths : Read custom yaml paths from a config file, containing a list of
+// {base, cert, id, format, guards, guardMode} objects
+func LoadYAMLPaths(file string) ([]YAMLCertRef, error) {
+	contents, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := []yamlCertRefConfig{}
+	if err := yaml.Unmarshal(contents, &configs); err != nil {
+		return nil, err
+	}
+
+	output := []YAMLCertRef{}
+	for index, config := range configs {
+		if len(config.Base) == 0 || len(config.Cert) == 0 {
+			return nil, fmt.Errorf("yaml path n°%d: \"base\" and \"cert\" are required", index+1)
+		}
+
+		ref := YAMLCertRef{
+			BasePathMatchExpr: config.Base,
+			CertMatchSubExpr:  config.Cert,
+			IDMatchSubExpr:    config.ID,
+		}
+
+		switch config.Format {
+		case "", "base64":
+			ref.Format = YAMLCertFormatBase64
+		case "file":
+			ref.Format = YAMLCertFormatFile
+		case "hex":
+			ref.Format = YAMLCertFormatHex
+		case "base64-der":
+			ref.Format = YAMLCertFormatBase64DER
+		default:
+			return nil, fmt.Errorf("yaml path n°%d: unknown format \"%s\"", index+1, config.Format)
+		}
+
+		switch config.GuardMode {
+		case "", "all":
+			ref.GuardMode = YAMLGuardModeAll
+		case "any":
+			ref.GuardMode = YAMLGuardModeAny
+		default:
+			return nil, fmt.Errorf("yaml path n°%d: unknown guard mode \"%s\"", index+1, config.GuardMode)
+		}
+
+		for _, guard := range config.Guards {
+			ref.Guards = append(ref.Guards, YAMLGuard{
+				MatchSubExpr: guard.Expr,
+				Value:        guard.Value,
+			})
+		}
+
+		output = append(output, ref)
+	}
+
+	return output, nil
+}
+
 type certificateRef struct {
-	path          string
-	format        certificateFormat
-	certificates  []*parsedCertificate
-	yamlPaths     []YAMLCertRef
-	kubeSecret    v1.Secret
-	kubeSecretKey string
+	path               string
+	format             certificateFormat
+	certificates       []*parsedCertificate
+	yamlPaths          []YAMLCertRef
+	yamlCache          *yamlFileCache
+	iniKeys            []INICertRef
+	dotenvKeys         []DotenvCertRef
+	kubeSecret         v1.Secret
+	kubeSecretKey      string
+	kubeSecretKeyErr   error
+	kubeSecretIsPath   bool
+	kubeSecretIsConfig bool
+	kubeSecretPathRoot string
+	subjectHash        string
+	sqlSource          *SQLSource
+	sqlClient          func(*SQLSource) (*sql.DB, error)
+	endpoint           *endpointState
+	gcsObject          *gcsObjectState
+	gitFile            *gitFileState
+	azureCert          *AzureKeyVaultCertificate
+	azureClient        func(string) (azureCertificateGetter, error)
+	consulKey          *ConsulKey
+	consulClient       func() (consulKVGetter, error)
+	etcdKey            *EtcdKey
+	etcdClient         func(*EtcdKey) (clientv3.KV, error)
+	ldapSearch         *LDAPSearch
+	ldapClient         func(*LDAPSearch) (ldapConn, error)
+	ftpFile            *FTPFile
+	ftpClient          func(context.Context, *FTPFile) (*ftp.ServerConn, error)
+	windowsStore       *WindowsCertStore
+	caBundle           *caBundle
+	clientCert         *clientCertRecord
+	ingested           *ingestedRecord
+	kubeIngress        *kubeIngressTLS
+	servingKeyPair     *servingKeyPair
+	certificateMonitor string
+	stale              bool
+	skippedBlocks      skippedPEMBlocks
+	chainOutOfOrder    *bool
 }
 
 type parsedCertificate struct {
-	cert        *x509.Certificate
-	userID      string
-	yqMatchExpr string
+	cert           *x509.Certificate
+	issuer         *x509.Certificate
+	chainBroken    *bool
+	verified       *bool
+	verifiedRoots  []string
+	userID         string
+	yqMatchExpr    string
+	detectedFormat detectedFormat
+	sdsSecret      string
+}
+
+// copyParsedCertificates : Copy certificates kept across scrapes, leaving out what each scrape works out about them
+// (issuer, chain, verification) so that concurrent scrapes don't write to the same structs
+func copyParsedCertificates(certs []*parsedCertificate) []*parsedCertificate {
+	output := make([]*parsedCertificate, 0, len(certs))
+	for _, cert := range certs {
+		copied := *cert
+		copied.issuer = nil
+		copied.chainBroken = nil
+		copied.verified = nil
+		copied.verifiedRoots = nil
+		output = append(output, &copied)
+	}
+
+	return output
 }
 
 type certificateError struct {
-	err error
-	ref *certificateRef
+	err     error
+	ref     *certificateRef
+	timeout bool
 }
 
 type certificateFormat int
 
 const (
-	certificateFormatPEM        certificateFormat = iota
-	certificateFormatYAML                         = iota
-	certificateFormatKubeSecret                   = iota
+	certificateFormatPEM               certificateFormat = iota
+	certificateFormatYAML                                = iota
+	certificateFormatKubeSecret                          = iota
+	certificateFormatSQL                                 = iota
+	certificateFormatEndpoint                            = iota
+	certificateFormatGCS                                 = iota
+	certificateFormatAzureKeyVault                       = iota
+	certificateFormatINI                                 = iota
+	certificateFormatConsul                              = iota
+	certificateFormatEtcd                                = iota
+	certificateFormatWindowsStore                        = iota
+	certificateFormatCABundle                            = iota
+	certificateFormatAuto                                = iota
+	certificateFormatDotenv                              = iota
+	certificateFormatClientCert                          = iota
+	certificateFormatGit                                 = iota
+	certificateFormatLDAP                                = iota
+	certificateFormatIngest                              = iota
+	certificateFormatKubeIngress                         = iota
+	certificateFormatFTP                                 = iota
+	certificateFormatSelf                                = iota
+	certificateFormatNamedPipe                           = iota
+	certificateFormatSystemdCredential                   = iota
+	certificateFormatEmbeddedPEM                         = iota
+	certificateFormatSDS                                 = iota
 )
 
-func (cert *certificateRef) parse() error {
-	var err error
+// certificateFormatNames : Values of the format label, certificates of auto-detected sources using their detected format
+var certificateFormatNames = map[certificateFormat]string{
+	certificateFormatPEM:               "pem",
+	certificateFormatYAML:              "yaml",
+	certificateFormatKubeSecret:        "kube-secret",
+	certificateFormatSQL:               "sql",
+	certificateFormatEndpoint:          "tls-endpoint",
+	certificateFormatGCS:               "gcs",
+	certificateFormatAzureKeyVault:     "azure-key-vault",
+	certificateFormatINI:               "ini",
+	certificateFormatConsul:            "consul",
+	certificateFormatEtcd:              "etcd",
+	certificateFormatWindowsStore:      "windows-store",
+	certificateFormatCABundle:          "ca-bundle",
+	certificateFormatAuto:              "auto",
+	certificateFormatDotenv:            "dotenv",
+	certificateFormatClientCert:        "client-cert",
+	certificateFormatGit:               "git",
+	certificateFormatLDAP:              "ldap",
+	certificateFormatIngest:            "ingest",
+	certificateFormatKubeIngress:       "kube-ingress",
+	certificateFormatFTP:               "ftp",
+	certificateFormatSelf:              "self",
+	certificateFormatNamedPipe:         "named-pipe",
+	certificateFormatSystemdCredential: "systemd-credential",
+	certificateFormatEmbeddedPEM:       "embedded-pem",
+	certificateFormatSDS:               "sds",
+}
+
+// getFormatName : Format label of a certificate, the one it was detected as if its source was auto-detected
+func getFormatName(certData *parsedCertificate, ref *certificateRef) string {
+	if len(certData.detectedFormat) > 0 {
+		return string(certData.detectedFormat)
+	}
+
+	return certificateFormatNames[ref.format]
+}
+
+// parse : Read the certificates of this ref, giving up when ctx is done;
+// readers which can't be interrupted (e.g. a hung NFS mount) are left running in the background
+func (cert *certificateRef) parse(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// kept only once read, as a reader left running in the background could still be counting
+	skipped := skippedPEMBlocks{}
+
+	// no deadline, no need to watch the reader
+	if ctx.Done() == nil {
+		var err error
+		cert.certificates, err = cert.read(ctx, skipped)
+		cert.skippedBlocks = skipped
+		return err
+	}
+
+	type readResult struct {
+		certificates []*parsedCertificate
+		err          error
+	}
 
+	done := make(chan readResult, 1)
+	go func() {
+		certificates, err := cert.read(ctx, skipped)
+		done <- readResult{certificates, err}
+	}()
+
+	select {
+	case result := <-done:
+		cert.certificates = result.certificates
+		cert.skippedBlocks = skipped
+		return result.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// read : Read the certificates of this ref, counting in skipped the PEM blocks of files which didn't yield one
+func (cert *certificateRef) read(ctx context.Context, skipped skippedPEMBlocks) ([]*parsedCertificate, error) {
 	switch cert.format {
 	case certificateFormatPEM:
-		cert.certificates, err = readAndParsePEMFile(cert.path)
+		return readAndParsePEMFile(cert.path, skipped)
 	case certificateFormatYAML:
-		cert.certificates, err = readAndParseYAMLFile(cert.path, cert.yamlPaths)
+		return readAndParseCachedYAMLFile(cert.path, cert.yamlPaths, cert.yamlCache)
 	case certificateFormatKubeSecret:
-		cert.certificates, err = readAndParseKubeSecret(&cert.kubeSecret, cert.kubeSecretKey)
+		if cert.kubeSecretKeyErr != nil {
+			return nil, cert.kubeSecretKeyErr
+		}
+		if cert.kubeSecretIsPath {
+			return readAndParseKubeSecretPath(&cert.kubeSecret, cert.kubeSecretKey, cert.kubeSecretPathRoot)
+		}
+		if cert.kubeSecretIsConfig {
+			return readAndParseKubeSecretKubeconfig(&cert.kubeSecret, cert.kubeSecretKey)
+		}
+		return readAndParseKubeSecret(&cert.kubeSecret, cert.kubeSecretKey)
+	case certificateFormatSQL:
+		return readAndParseSQLSource(ctx, cert.sqlSource, cert.sqlClient)
+	case certificateFormatEndpoint:
+		return readAndParseEndpoint(ctx, cert.endpoint)
+	case certificateFormatGCS:
+		return readAndParseGCSObject(ctx, cert.gcsObject)
+	case certificateFormatGit:
+		return readAndParseGitFile(ctx, cert.gitFile)
+	case certificateFormatAzureKeyVault:
+		return readAndParseAzureCertificate(ctx, cert.azureCert, cert.azureClient)
+	case certificateFormatINI:
+		return readAndParseINIFile(cert.path, cert.iniKeys)
+	case certificateFormatDotenv:
+		return readAndParseDotenvFile(cert.path, cert.dotenvKeys)
+	case certificateFormatConsul:
+		return readAndParseConsulKey(ctx, cert.consulKey, cert.consulClient)
+	case certificateFormatEtcd:
+		return readAndParseEtcdKey(ctx, cert.etcdKey, cert.etcdClient)
+	case certificateFormatLDAP:
+		return readAndParseLDAPSearch(ctx, cert.ldapSearch, cert.ldapClient)
+	case certificateFormatFTP:
+		return readAndParseFTPFile(ctx, cert.ftpFile, cert.ftpClient)
+	case certificateFormatWindowsStore:
+		return readAndParseWindowsCertStore(cert.windowsStore)
+	case certificateFormatNamedPipe:
+		return readAndParseNamedPipe(ctx, cert.path)
+	case certificateFormatSystemdCredential:
+		return readAndParseAutoFile(cert.path, skipped)
+	case certificateFormatCABundle:
+		return readAndParseCABundle(cert.caBundle)
+	case certificateFormatAuto:
+		return readAndParseAutoFile(cert.path, skipped)
+	case certificateFormatEmbeddedPEM:
+		return readAndParseEmbeddedPEMFile(cert.path)
+	case certificateFormatSDS:
+		return readAndParseSDSFile(cert.path)
+	case certificateFormatClientCert:
+		return readClientCertificates(cert.clientCert)
+	case certificateFormatIngest:
+		return readIngestedCertificates(cert.ingested)
+	case certificateFormatKubeIngress:
+		return readAndParseKubeIngressTLS(cert.kubeIngress)
+	case certificateFormatSelf:
+		return readServingCertificate(cert.servingKeyPair)
 	}
 
-	return err
+	return nil, nil
 }
 
-func readAndParsePEMFile(path string) ([]*parsedCertificate, error) {
+func readAndParsePEMFile(path string, skipped skippedPEMBlocks) ([]*parsedCertificate, error) {
 	contents, err := readFile(path)
 	if err != nil {
 		return nil, err
 	}
 
 	output := []*parsedCertificate{}
-	certs, err := parsePEM(contents)
+	certs, err := parsePEMBlocks(contents, skipped)
 	if err != nil {
 		return nil, err
 	}
@@ -123,78 +430,104 @@ func readAndParsePEMFile(path string) ([]*parsedCertificate, error) {
 	return output, nil
 }
 
-func readAndParseYAMLFile(filePath string, yamlPaths []YAMLCertRef) ([]*parsedCertificate, error) {
+// readAndParseYAMLFile : Extract the certificates matching yamlPaths, also returning the files referenced
+// by "file" formatted values; missing referenced files don't prevent reading the others, and are returned
+// as readWarnings along with the certificates found
+func readAndParseYAMLFile(filePath string, yamlPaths []YAMLCertRef) ([]*parsedCertificate, []string, error) {
+	documents, err := readYAMLDocuments(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parseYAMLDocuments(documents, filePath, yamlPaths)
+}
+
+// parseYAMLDocuments : Extract the certificates matching yamlPaths from decoded documents, file references
+// being resolved relative to filePath
+func parseYAMLDocuments(documents []interface{}, filePath string, yamlPaths []YAMLCertRef) ([]*parsedCertificate, []string, error) {
 	output := []*parsedCertificate{}
+	referencedFiles := []string{}
+	warnings := readWarnings{}
 
 	for _, exprs := range yamlPaths {
-		file, err := os.Open(filePath)
-		if err != nil {
-			return nil, err
+		// entries are numbered across documents, so default IDs stay unique
+		entryIndex := -1
+		entries := []interface{}{}
+		var baseErr error
+		for _, document := range documents {
+			documentEntries, err := jsonpath.Read(document, exprs.BasePathMatchExpr)
+			if err != nil {
+				baseErr = err
+				continue
+			}
+			entries = append(entries, flattenYAMLEntries(documentEntries)...)
 		}
-		defer file.Close()
 
-		var raw interface{}
-		err = yaml.NewDecoder(file).Decode(&raw)
-		if err != nil {
-			return nil, err
+		// only an error when no document has the base path
+		if len(entries) == 0 && baseErr != nil {
+			return nil, nil, baseErr
 		}
 
-		entries, err := jsonpath.Read(raw, exprs.BasePathMatchExpr)
-		if err != nil {
-			return nil, err
-		}
-		for _, entry := range entries.([]interface{}) {
+		for _, entry := range entries {
+			entryIndex++
 			line, err := jsonpath.Read(entry, exprs.CertMatchSubExpr)
 			if err != nil {
 				continue
 			}
-			id, err := jsonpath.Read(entry, exprs.IDMatchSubExpr)
-			if err != nil {
+			id := fmt.Sprintf("%d", entryIndex)
+			if len(exprs.IDMatchSubExpr) > 0 {
+				rawID, err := jsonpath.Read(entry, exprs.IDMatchSubExpr)
+				if err != nil {
+					continue
+				}
+				id = fmt.Sprintf("%v", rawID)
+			}
+			if !exprs.matchesGuards(entry) {
 				continue
 			}
 			rawCerts, ok := line.(string)
 			if !ok {
-				return nil, err
+				return nil, nil, err
 			}
 
 			var decodedCerts []byte
-			if exprs.Format == YAMLCertFormatBase64 {
-				decodedCerts = []byte{}
-				encodedCerts := strings.Split(rawCerts, "\n")
-
-				for _, encodedCert := range encodedCerts {
-					decodedCert, err := base64.StdEncoding.DecodeString(encodedCert)
-					if err != nil {
-						return nil, err
-					}
-
-					decodedCerts = append(decodedCerts, decodedCert...)
-					decodedCerts = append(decodedCerts, '\n')
-				}
-			} else if exprs.Format == YAMLCertFormatFile {
-				rawCertPaths := strings.TrimRight(string(rawCerts), "\n")
-
-				for _, certPath := range strings.Split(rawCertPaths, "\n") {
-					if !path.IsAbs(certPath) {
-						certPath = path.Join(filepath.Dir(filePath), rawCertPaths)
-					}
+			if exprs.Format == YAMLCertFormatFile {
+				missingFiles := 0
+				certPaths := getEmbeddedCertificatePaths(rawCerts, filePath)
+				for _, certPath := range certPaths {
+					referencedFiles = append(referencedFiles, certPath)
 
 					data, err := readFile(certPath)
+					if errors.Is(err, fs.ErrNotExist) {
+						// the other references of the file are still read
+						warnings = append(warnings, fmt.Errorf("missing file \"%s\" referenced by %s", certPath, id))
+						missingFiles++
+						continue
+					}
 					if err != nil {
-						return nil, err
+						return nil, nil, err
 					}
 
 					decodedCerts = append(decodedCerts, data...)
 				}
+
+				if missingFiles == len(certPaths) {
+					continue
+				}
+			} else {
+				decodedCerts, err = decodeEmbeddedCertificates(rawCerts, exprs.Format, filePath)
+				if err != nil {
+					return nil, nil, err
+				}
 			}
 
 			certs, err := parsePEM(decodedCerts)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 
 			for index, cert := range certs {
-				displayName := id.(string)
+				displayName := id
 				if len(certs) > 1 {
 					displayName = fmt.Sprintf("%s(%d)", id, index)
 				}
@@ -207,14 +540,192 @@ func readAndParseYAMLFile(filePath string, yamlPaths []YAMLCertRef) ([]*parsedCe
 		}
 	}
 
-	return output, nil
+	if len(warnings) > 0 {
+		return output, referencedFiles, warnings
+	}
+
+	return output, referencedFiles, nil
 }
 
-func readAndParseKubeSecret(secret *v1.Secret, key string) ([]*parsedCertificate, error) {
-	certs, err := parsePEM(secret.Data[key])
+// readWarnings : Problems which didn't prevent reading the other certificates of a source,
+// reported as read errors while its certificates are still exposed
+type readWarnings []error
+
+func (warnings readWarnings) Error() string {
+	messages := []string{}
+	for _, warning := range warnings {
+		messages = append(messages, warning.Error())
+	}
+
+	return strings.Join(messages, ", ")
+}
+
+// readYAMLDocuments : Decode every document of a YAML file
+func readYAMLDocuments(filePath string) ([]interface{}, error) {
+	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
+
+	return decodeYAMLDocuments(file)
+}
+
+// decodeYAMLDocuments : Decode every document of a YAML stream read from memory or a file, skipping empty ones
+func decodeYAMLDocuments(reader io.Reader) ([]interface{}, error) {
+	output := []interface{}{}
+	decoder := yaml.NewDecoder(reader)
+	for {
+		var raw interface{}
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) && len(output) > 0 {
+			return output, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if raw != nil {
+			output = append(output, raw)
+		}
+	}
+}
+
+// decodeEmbeddedCertificates : Turn a value found in a config file into PEM data, decoding it
+// from base64, or from hex or base64 encoded DER, or reading the file(s) it points to (relative to the config file)
+func decodeEmbeddedCertificates(rawCerts string, format YAMLCertFormat, filePath string) ([]byte, error) {
+	var decodedCerts []byte
+	if format == YAMLCertFormatBase64 {
+		decodedCerts = []byte{}
+		encodedCerts := strings.Split(rawCerts, "\n")
+
+		for _, encodedCert := range encodedCerts {
+			decodedCert, err := base64.StdEncoding.DecodeString(encodedCert)
+			if err != nil {
+				return nil, err
+			}
+
+			decodedCerts = append(decodedCerts, decodedCert...)
+			decodedCerts = append(decodedCerts, '\n')
+		}
+	} else if format == YAMLCertFormatHex {
+		return decodeDERCertificates(rawCerts, func(encodedCert string) ([]byte, error) {
+			return hex.DecodeString(strings.ReplaceAll(encodedCert, ":", ""))
+		})
+	} else if format == YAMLCertFormatBase64DER {
+		return decodeDERCertificates(rawCerts, base64.StdEncoding.DecodeString)
+	} else if format == YAMLCertFormatFile {
+		for _, certPath := range getEmbeddedCertificatePaths(rawCerts, filePath) {
+			data, err := readFile(certPath)
+			if err != nil {
+				return nil, err
+			}
+
+			decodedCerts = append(decodedCerts, data...)
+		}
+	}
+
+	return decodedCerts, nil
+}
+
+// getEmbeddedCertificatePaths : Resolve the paths of a "file" formatted value, relative to the file containing it
+func getEmbeddedCertificatePaths(rawCerts string, filePath string) []string {
+	output := []string{}
+	for _, certPath := range strings.Split(strings.TrimRight(rawCerts, "\n"), "\n") {
+		if !path.IsAbs(certPath) {
+			certPath = path.Join(filepath.Dir(filePath), certPath)
+		}
+		output = append(output, certPath)
+	}
+
+	return output
+}
+
+// decodeDERCertificates : Decode one DER certificate per line, turned into PEM like the other formats
+func decodeDERCertificates(rawCerts string, decode func(string) ([]byte, error)) ([]byte, error) {
+	decodedCerts := []byte{}
+	for _, encodedCert := range strings.Split(rawCerts, "\n") {
+		encodedCert = strings.TrimSpace(encodedCert)
+		if len(encodedCert) == 0 {
+			continue
+		}
+
+		der, err := decode(encodedCert)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := x509.ParseCertificate(der); err != nil {
+			return nil, err
+		}
+
+		decodedCerts = append(decodedCerts, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+
+	return decodedCerts, nil
+}
+
+// flattenYAMLEntries : Turn the result of a base path match into a list of entries,
+// nested lists (e.g. from wildcards over lists of maps) are flattened and a single map is one entry
+func flattenYAMLEntries(value interface{}) []interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		return []interface{}{value}
+	}
+
+	output := []interface{}{}
+	for _, elem := range list {
+		if _, isList := elem.([]interface{}); isList {
+			output = append(output, flattenYAMLEntries(elem)...)
+		} else {
+			output = append(output, elem)
+		}
+	}
+
+	return output
+}
+
+// matchesGuards : Tell if an entry satisfies the guards of this ref,
+// a guard which can't be evaluated on the entry is considered unsatisfied
+func (exprs *YAMLCertRef) matchesGuards(entry interface{}) bool {
+	if len(exprs.Guards) == 0 {
+		return true
+	}
+
+	for _, guard := range exprs.Guards {
+		value, err := jsonpath.Read(entry, guard.MatchSubExpr)
+		matched := err == nil && fmt.Sprintf("%v", value) == guard.Value
+
+		if matched && exprs.GuardMode == YAMLGuardModeAny {
+			return true
+		}
+		if !matched && exprs.GuardMode == YAMLGuardModeAll {
+			return false
+		}
+	}
+
+	return exprs.GuardMode == YAMLGuardModeAll
+}
+
+// readAndParseKubeSecret : Parse the certificates of a secret key, or of each of the keys of a chain
+// (e.g. "tls.crt+ca.crt") as a single bundle, so that the intermediates of a leaf are found in its other keys
+func readAndParseKubeSecret(secret *v1.Secret, key string) ([]*parsedCertificate, error) {
+	certs := []*x509.Certificate{}
+	for _, chainKey := range getChainedSecretKeys(key) {
+		// only the leaf key is required
+		if chainKey != getLeafSecretKey(key) && len(secret.Data[chainKey]) == 0 {
+			continue
+		}
+
+		keyCerts, err := parsePEM(secret.Data[chainKey])
+		if err != nil {
+			if chainKey != key {
+				return nil, fmt.Errorf("%s: %s", chainKey, err.Error())
+			}
+			return nil, err
+		}
+		certs = append(certs, keyCerts...)
+	}
 
 	output := []*parsedCertificate{}
 	for _, cert := range certs {
@@ -226,6 +737,64 @@ func readAndParseKubeSecret(secret *v1.Secret, key string) ([]*parsedCertificate
 	return output, nil
 }
 
+// readAndParseKubeSecretPath : Parse the PEM file whose path is held by a secret key,
+// relative paths being resolved from root, and paths leading outside of it rejected
+func readAndParseKubeSecretPath(secret *v1.Secret, key string, root string) ([]*parsedCertificate, error) {
+	if len(root) == 0 {
+		return nil, fmt.Errorf("no root directory allowed for certificate paths in secrets")
+	}
+
+	certPath, err := resolvePathWithin(root, strings.TrimSpace(string(secret.Data[key])))
+	if err != nil {
+		return nil, err
+	}
+
+	return readAndParsePEMFile(certPath, nil)
+}
+
+// readAndParseKubeSecretKubeconfig : Extract the certificates embedded in the kubeconfig held by a secret key
+// (e.g. by Cluster API), matched with DefaultYamlPaths; files it references can't be read and are skipped
+func readAndParseKubeSecretKubeconfig(secret *v1.Secret, key string) ([]*parsedCertificate, error) {
+	documents, err := decodeYAMLDocuments(bytes.NewReader(secret.Data[key]))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", key, err.Error())
+	}
+
+	yamlPaths := []YAMLCertRef{}
+	for _, yamlPath := range DefaultYamlPaths {
+		if yamlPath.Format != YAMLCertFormatFile {
+			yamlPaths = append(yamlPaths, yamlPath)
+		}
+	}
+
+	certs, _, err := parseYAMLDocuments(documents, "", yamlPaths)
+	return certs, err
+}
+
+// resolvePathWithin : Resolve a path from root, following symlinks, and make sure it stays inside root
+func resolvePathWithin(root string, target string) (string, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(realRoot, target)
+	}
+
+	realTarget, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return "", err
+	}
+
+	relative, err := filepath.Rel(realRoot, realTarget)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, "../") {
+		return "", fmt.Errorf("path \"%s\" is outside of \"%s\"", target, root)
+	}
+
+	return realTarget, nil
+}
+
 func readFile(file string) ([]byte, error) {
 	contents, err := os.ReadFile(file)
 	if err == nil || !os.IsNotExist(err) {
@@ -259,16 +828,41 @@ func resolveSymlink(fsys fs.FS, link string) (string, error) {
 }
 
 func parsePEM(data []byte) ([]*x509.Certificate, error) {
+	return parsePEMBlocks(data, nil)
+}
+
+// skippedPEMBlocks : Number of PEM blocks which didn't yield a certificate, by reason
+type skippedPEMBlocks map[string]int
+
+const (
+	skippedBlockNonCertType = "non-cert-type"
+	skippedBlockDecodeError = "decode-error"
+)
+
+func (skipped skippedPEMBlocks) add(reason string, count int) {
+	if skipped != nil && count > 0 {
+		skipped[reason] += count
+	}
+}
+
+// parsePEMBlocks : Parse the certificates of PEM data, counting the other blocks in skipped (if not nil);
+// pem.Decode silently moves on to the next block when one can't be decoded, so such blocks are found by counting
+// the BEGIN lines of the data it consumed
+func parsePEMBlocks(data []byte, skipped skippedPEMBlocks) ([]*x509.Certificate, error) {
 	output := []*x509.Certificate{}
 
 	for {
 		block, rest := pem.Decode(data)
 		if block == nil {
+			skipped.add(skippedBlockDecodeError, countPEMBeginLines(data))
 			break
 		}
 
+		skipped.add(skippedBlockDecodeError, countPEMBeginLines(data[:len(data)-len(rest)])-1)
 		data = rest
+		// combined files (nginx, haproxy) also hold keys and DH params: drop them, never keep or log them
 		if block.Type != "CERTIFICATE" {
+			skipped.add(skippedBlockNonCertType, 1)
 			continue
 		}
 
@@ -282,3 +876,12 @@ func parsePEM(data []byte) ([]*x509.Certificate, error) {
 
 	return output, nil
 }
+
+func countPEMBeginLines(data []byte) int {
+	count := bytes.Count(data, []byte("\n-----BEGIN "))
+	if bytes.HasPrefix(data, []byte("-----BEGIN ")) {
+		count++
+	}
+
+	return count
+}
@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestCombinedPEMFiles(t *testing.T) {
+	ca := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	intermediate := generateTestCertificate(caTemplate("intermediate", time.Now().Add(time.Hour)), ca)
+	leaf := generateTestCertificate(leafTemplate("www.example.com", time.Now().Add(time.Hour)), intermediate)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	certBlock := func(cert *testCertificate) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.cert.Raw})
+	}
+	dhParams := make([]byte, 32)
+	//nolint:errcheck
+	rand.Read(dhParams)
+
+	// nginx: leaf, chain, then the key
+	nginx := bytes.Join([][]byte{
+		certBlock(leaf),
+		certBlock(intermediate),
+		pem.EncodeToMemory(getPEMBlockForKey(leaf.key)),
+	}, nil)
+
+	// haproxy: exported from a PKCS#12 bundle with bag attributes, key first, DH params last
+	haproxy := bytes.Join([][]byte{
+		[]byte("Bag Attributes\n    localKeyID: 01 00 00 00\nKey Attributes: <No Attributes>\n"),
+		pem.EncodeToMemory(getPEMBlockForKey(rsaKey)),
+		[]byte("Bag Attributes\n    friendlyName: www.example.com\nsubject=CN = www.example.com\n"),
+		certBlock(leaf),
+		certBlock(intermediate),
+		certBlock(ca),
+		pem.EncodeToMemory(&pem.Block{Type: "DH PARAMETERS", Bytes: dhParams}),
+	}, nil)
+
+	dir := t.TempDir()
+	nginxPath := path.Join(dir, "nginx.pem")
+	haproxyPath := path.Join(dir, "haproxy.pem")
+	assert.NoError(t, os.WriteFile(nginxPath, nginx, 00600))
+	assert.NoError(t, os.WriteFile(haproxyPath, haproxy, 00600))
+
+	logs := &bytes.Buffer{}
+	log.SetOutput(logs)
+	log.SetLevel(log.DebugLevel)
+	defer log.SetOutput(os.Stderr)
+
+	testRequest(t, &Exporter{
+		Files: []string{nginxPath, haproxyPath},
+	}, func(metrics []model.MetricFamily) {
+		counts := map[string]int{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			counts[getLabelValue(metric, "filename")]++
+		}
+		assert.Equal(t, map[string]int{"nginx.pem": 2, "haproxy.pem": 3}, counts)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+
+		for index := range metrics {
+			for _, metric := range metrics[index].GetMetric() {
+				for _, label := range metric.GetLabel() {
+					assert.NotContains(t, label.GetValue(), "PRIVATE")
+				}
+			}
+		}
+	})
+
+	assert.Contains(t, logs.String(), "valid certificate(s) found")
+	assert.NotContains(t, logs.String(), "PRIVATE")
+	for _, key := range []*pem.Block{getPEMBlockForKey(leaf.key), getPEMBlockForKey(rsaKey)} {
+		encoded := strings.Split(string(pem.EncodeToMemory(key)), "\n")[1]
+		assert.NotContains(t, logs.String(), encoded)
+	}
+}
+
+func TestSkippedPEMBlocks(t *testing.T) {
+	ca := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	leaf := generateTestCertificate(leafTemplate("www.example.com", time.Now().Add(time.Hour)), ca)
+
+	mixed := bytes.Join([][]byte{
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.cert.Raw}),
+		pem.EncodeToMemory(getPEMBlockForKey(leaf.key)),
+		[]byte("-----BEGIN CERTIFICATE-----\nnot base64!\n-----END CERTIFICATE-----\n"),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "DH PARAMETERS", Bytes: []byte{0x30, 0x00}}),
+		// truncated
+		[]byte("-----BEGIN CERTIFICATE-----\nMIIB\n"),
+	}, nil)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(dir, "mixed.pem"), mixed, 00600))
+	writeTestCertificates(path.Join(dir, "clean.pem"), leaf)
+
+	testRequest(t, &Exporter{
+		Files:                   []string{path.Join(dir, "*.pem")},
+		ExposeSkippedPEMMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_not_after"), 3)
+
+		skipped := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_parse_skipped_blocks") {
+			assert.Equal(t, "mixed.pem", getLabelValue(metric, "filename"))
+			skipped[getLabelValue(metric, "reason")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"non-cert-type": 2, "decode-error": 2}, skipped)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_parse_skipped_blocks"), 0)
+	})
+}
+
+func TestKubeSecretPath(t *testing.T) {
+	dir := t.TempDir()
+	root := path.Join(dir, "root")
+	assert.NoError(t, os.MkdirAll(path.Join(root, "certs"), 0755))
+	writeTestCertificates(path.Join(root, "certs", "tls.pem"), generateTestCertificate(leafTemplate("shared", time.Now().Add(time.Hour)), nil))
+	writeTestCertificates(path.Join(dir, "outside.pem"), generateTestCertificate(leafTemplate("outside", time.Now().Add(time.Hour)), nil))
+	assert.NoError(t, os.Symlink(path.Join(dir, "outside.pem"), path.Join(root, "certs", "escape.pem")))
+
+	parseSecretPath := func(certPath string, root string) ([]*parsedCertificate, error) {
+		ref := &certificateRef{
+			path:               "k8s/default/shared-cert",
+			format:             certificateFormatKubeSecret,
+			kubeSecret:         v1.Secret{Data: map[string][]byte{"cert-path": []byte(certPath + "\n")}},
+			kubeSecretKey:      "cert-path",
+			kubeSecretIsPath:   true,
+			kubeSecretPathRoot: root,
+		}
+		err := ref.parse(context.Background())
+		return ref.certificates, err
+	}
+
+	for _, certPath := range []string{"certs/tls.pem", "./certs/../certs/tls.pem", path.Join(root, "certs", "tls.pem")} {
+		certs, err := parseSecretPath(certPath, root)
+		assert.NoError(t, err, certPath)
+		assert.Len(t, certs, 1, certPath)
+	}
+
+	for _, certPath := range []string{"../outside.pem", "certs/../../outside.pem", path.Join(dir, "outside.pem"), "certs/escape.pem"} {
+		certs, err := parseSecretPath(certPath, root)
+		assert.ErrorContains(t, err, "is outside of", certPath)
+		assert.Len(t, certs, 0, certPath)
+	}
+
+	_, err := parseSecretPath("certs/tls.pem", "")
+	assert.Error(t, err)
+
+	exporter := &Exporter{KubeSecretTypes: []string{"kubernetes.io/tls:tls.crt", "example.com/cert-path:cert-path:file"}}
+	included, err := exporter.checkHasIncludedType(&v1.Secret{Type: "example.com/cert-path", Data: map[string][]byte{"cert-path": []byte("certs/tls.pem")}})
+	assert.NoError(t, err)
+	assert.True(t, included)
+
+	exporter.KubeSecretTypes = []string{"example.com/cert-path:cert-path:pem"}
+	_, err = exporter.checkHasIncludedType(&v1.Secret{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WindowsCertStore : A Windows system certificate store (e.g. MY or ROOT) of a store location
+type WindowsCertStore struct {
+	Location string
+	Name     string
+}
+
+// Windows certificate store locations, as named by PowerShell's Cert: drive
+const (
+	WindowsCertStoreCurrentUser  = "CurrentUser"
+	WindowsCertStoreLocalMachine = "LocalMachine"
+)
+
+// ParseWindowsCertStore : Split a <location>/<store> reference, e.g. LocalMachine/MY
+func ParseWindowsCertStore(spec string) (WindowsCertStore, error) {
+	locationAndName := strings.SplitN(spec, "/", 2)
+	if len(locationAndName) != 2 || len(locationAndName[1]) == 0 {
+		return WindowsCertStore{}, fmt.Errorf("expected <location>/<store>, got \"%s\"", spec)
+	}
+
+	store := WindowsCertStore{Name: locationAndName[1]}
+	switch {
+	case strings.EqualFold(locationAndName[0], WindowsCertStoreCurrentUser):
+		store.Location = WindowsCertStoreCurrentUser
+	case strings.EqualFold(locationAndName[0], WindowsCertStoreLocalMachine):
+		store.Location = WindowsCertStoreLocalMachine
+	default:
+		return WindowsCertStore{}, fmt.Errorf("unknown store location \"%s\" in \"%s\", expected %s or %s", locationAndName[0], spec, WindowsCertStoreCurrentUser, WindowsCertStoreLocalMachine)
+	}
+
+	return store, nil
+}
+
+func (exporter *Exporter) collectWindowsCertStores() []*certificateRef {
+	output := []*certificateRef{}
+
+	for index := range exporter.WindowsCertStores {
+		store := &exporter.WindowsCertStores[index]
+		output = append(output, &certificateRef{
+			path:         fmt.Sprintf("cert:/%s/%s", store.Location, store.Name),
+			format:       certificateFormatWindowsStore,
+			windowsStore: store,
+		})
+	}
+
+	return output
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package internal
+
+import "errors"
+
+func readAndParseWindowsCertStore(_ *WindowsCertStore) ([]*parsedCertificate, error) {
+	return nil, errors.New("windows certificate stores can only be read on Windows")
+}
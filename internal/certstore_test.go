@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWindowsCertStore(t *testing.T) {
+	store, err := ParseWindowsCertStore("LocalMachine/MY")
+	assert.NoError(t, err)
+	assert.Equal(t, WindowsCertStore{Location: "LocalMachine", Name: "MY"}, store)
+
+	store, err = ParseWindowsCertStore("currentuser/Root")
+	assert.NoError(t, err)
+	assert.Equal(t, WindowsCertStore{Location: "CurrentUser", Name: "Root"}, store)
+
+	for _, invalid := range []string{"", "MY", "LocalMachine/", "Services/MY"} {
+		_, err := ParseWindowsCertStore(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"crypto/x509"
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// readAndParseWindowsCertStore : Enumerate the certificates of a system store, opened read-only
+func readAndParseWindowsCertStore(store *WindowsCertStore) ([]*parsedCertificate, error) {
+	name, err := windows.UTF16PtrFromString(store.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := uint32(windows.CERT_SYSTEM_STORE_CURRENT_USER)
+	if store.Location == WindowsCertStoreLocalMachine {
+		flags = windows.CERT_SYSTEM_STORE_LOCAL_MACHINE
+	}
+
+	handle, err := windows.CertOpenStore(
+		windows.CERT_STORE_PROV_SYSTEM_W,
+		0,
+		0,
+		flags|windows.CERT_STORE_OPEN_EXISTING_FLAG|windows.CERT_STORE_READONLY_FLAG,
+		uintptr(unsafe.Pointer(name)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer windows.CertCloseStore(handle, 0)
+
+	output := []*parsedCertificate{}
+	var context *windows.CertContext
+	for {
+		context, err = windows.CertEnumCertificatesInStore(handle, context)
+		if context == nil {
+			if errors.Is(err, windows.Errno(windows.CRYPT_E_NOT_FOUND)) || errors.Is(err, windows.ERROR_NO_MORE_FILES) {
+				break
+			}
+			return nil, err
+		}
+
+		// copied as the context memory is released by the next enumeration
+		raw := append([]byte{}, unsafe.Slice(context.EncodedCert, context.Length)...)
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			// the enumeration is stopped early, release its current context
+			windows.CertFreeCertificateContext(context)
+			return nil, err
+		}
+
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	return output, nil
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/windows"
+)
+
+const testWindowsCertStore = "x509-certificate-exporter-test"
+
+// createTestWindowsCertStore : Create a current user store holding the given certificates, deleted with the test
+func createTestWindowsCertStore(t *testing.T, certs ...*testCertificate) {
+	name, err := windows.UTF16PtrFromString(testWindowsCertStore)
+	assert.NoError(t, err)
+
+	handle, err := windows.CertOpenStore(windows.CERT_STORE_PROV_SYSTEM_W, 0, 0, windows.CERT_SYSTEM_STORE_CURRENT_USER, uintptr(unsafe.Pointer(name)))
+	assert.NoError(t, err)
+	defer windows.CertCloseStore(handle, 0)
+
+	t.Cleanup(func() {
+		//nolint:errcheck
+		windows.CertOpenStore(windows.CERT_STORE_PROV_SYSTEM_W, 0, 0, windows.CERT_SYSTEM_STORE_CURRENT_USER|windows.CERT_STORE_DELETE_FLAG, uintptr(unsafe.Pointer(name)))
+	})
+
+	for _, cert := range certs {
+		context, err := windows.CertCreateCertificateContext(windows.X509_ASN_ENCODING|windows.PKCS_7_ASN_ENCODING, &cert.cert.Raw[0], uint32(len(cert.cert.Raw)))
+		assert.NoError(t, err)
+		assert.NoError(t, windows.CertAddCertificateContextToStore(handle, context, windows.CERT_STORE_ADD_ALWAYS, nil))
+		//nolint:errcheck
+		windows.CertFreeCertificateContext(context)
+	}
+}
+
+func TestWindowsCertStore(t *testing.T) {
+	createTestWindowsCertStore(t,
+		generateTestCertificate(leafTemplate("first", time.Now().Add(time.Hour)), nil),
+		generateTestCertificate(leafTemplate("second", time.Now().Add(-time.Hour)), nil),
+	)
+
+	testRequest(t, &Exporter{
+		WindowsCertStores: []WindowsCertStore{
+			{Location: WindowsCertStoreCurrentUser, Name: testWindowsCertStore},
+			{Location: WindowsCertStoreCurrentUser, Name: "does-not-exist"},
+		},
+	}, func(metrics []model.MetricFamily) {
+		expired := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_expired") {
+			assert.Equal(t, "CurrentUser", getLabelValue(metric, "windows_store_location"))
+			assert.Equal(t, testWindowsCertStore, getLabelValue(metric, "windows_store_name"))
+			expired[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"first": 0, "second": 1}, expired)
+
+		// stores are opened without being created
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+	})
+}
@@ -0,0 +1,297 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// resolveIssuers : Find the issuer of each parsed certificate among every
+// other parsed certificate and the configured CA files
+func (exporter *Exporter) resolveIssuers(refs []*certificateRef) []error {
+	candidates := map[string][]*x509.Certificate{}
+	addCandidate := func(cert *x509.Certificate) {
+		candidates[string(cert.RawSubject)] = append(candidates[string(cert.RawSubject)], cert)
+	}
+
+	for _, ref := range refs {
+		for _, cert := range ref.certificates {
+			addCandidate(cert.cert)
+		}
+	}
+
+	cas, errs := exporter.readCAFiles()
+	for _, ca := range cas {
+		addCandidate(ca)
+	}
+
+	now := time.Now()
+	for _, ref := range refs {
+		for _, cert := range ref.certificates {
+			cert.issuer = findIssuer(cert.cert, candidates[string(cert.cert.RawIssuer)])
+
+			if !cert.cert.IsCA && !isSelfSigned(cert.cert) {
+				broken := isIssuerChainBroken(cert.issuer, candidates, now)
+				cert.chainBroken = &broken
+			}
+		}
+	}
+
+	return errs
+}
+
+// isIssuerChainBroken : Tell if no more certificates can be issued by the issuer of a certificate, as it's missing
+// or as it, or one of the issuers found above it, is expired; issuers which can't be found above the direct one
+// (e.g. roots only known to clients) aren't held against the chain
+func isIssuerChainBroken(issuer *x509.Certificate, candidates map[string][]*x509.Certificate, now time.Time) bool {
+	if issuer == nil {
+		return true
+	}
+
+	// bounded in case of cross-signed loops
+	for depth := 0; issuer != nil && depth < 16; depth++ {
+		if now.After(issuer.NotAfter) {
+			return true
+		}
+
+		issuer = findIssuer(issuer, candidates[string(issuer.RawIssuer)])
+	}
+
+	return false
+}
+
+func (exporter *Exporter) readCAFiles() ([]*x509.Certificate, []error) {
+	output := []*x509.Certificate{}
+	outputErrors := []error{}
+
+	for _, file := range exporter.CAFiles {
+		contents, err := readFile(file)
+		if err == nil {
+			var certs []*x509.Certificate
+			certs, err = parsePEM(contents)
+			output = append(output, certs...)
+		}
+
+		if err != nil {
+			outputErrors = append(outputErrors, fmt.Errorf("failed to parse CA file \"%s\": %s", file, err.Error()))
+		}
+	}
+
+	return output, outputErrors
+}
+
+// findIssuer : Return the candidate which signed the given certificate,
+// self-signed certificates have no issuer
+func findIssuer(cert *x509.Certificate, candidates []*x509.Certificate) *x509.Certificate {
+	if isSelfSigned(cert) {
+		return nil
+	}
+
+	for _, candidate := range candidates {
+		if bytes.Equal(candidate.Raw, cert.Raw) {
+			continue
+		}
+
+		if cert.CheckSignatureFrom(candidate) == nil {
+			return candidate
+		}
+	}
+
+	return nil
+}
+
+// getChainDepth : Count the certificates from a leaf up to its self-signed root inclusive, following issuers
+// among the certificates of its bundle, 0 when the bundle doesn't hold the full chain
+func getChainDepth(cert *x509.Certificate, bundle []*parsedCertificate) int {
+	candidates := []*x509.Certificate{}
+	for _, other := range bundle {
+		candidates = append(candidates, other.cert)
+	}
+
+	// bounded in case of cross-signed loops
+	for depth := 1; depth <= len(candidates); depth++ {
+		if isSelfSigned(cert) {
+			return depth
+		}
+
+		cert = findIssuer(cert, candidates)
+		if cert == nil {
+			return 0
+		}
+	}
+
+	return 0
+}
+
+// getChainOutOfOrder : Tell if a bundle holding a leaf and its chain (e.g. for a TLS server) doesn't list them leaf
+// first, each certificate being followed by its issuer, as some clients require; nil for sources which aren't bundles,
+// and bundles of CAs only or of several leaves
+func getChainOutOfOrder(ref *certificateRef) *bool {
+	switch ref.format {
+	case certificateFormatPEM, certificateFormatKubeSecret, certificateFormatEndpoint, certificateFormatAuto, certificateFormatSystemdCredential:
+	default:
+		return nil
+	}
+
+	leaves := 0
+	for _, cert := range ref.certificates {
+		if !cert.cert.IsCA {
+			leaves++
+		}
+	}
+	if len(ref.certificates) < 2 || leaves != 1 {
+		return nil
+	}
+
+	outOfOrder := ref.certificates[0].cert.IsCA
+	for index := 0; index < len(ref.certificates)-1 && !outOfOrder; index++ {
+		outOfOrder = ref.certificates[index].cert.CheckSignatureFrom(ref.certificates[index+1].cert) != nil
+	}
+
+	return &outOfOrder
+}
+
+// getCertificateType : Classify a certificate as a leaf (not a CA),
+// an intermediate (CA, not self-signed) or a root (self-signed CA)
+func getCertificateType(cert *x509.Certificate) string {
+	if !cert.IsCA {
+		return "leaf"
+	}
+	if isSelfSigned(cert) {
+		return "root"
+	}
+	return "intermediate"
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// getLeafCertificates : Keep the end-entity certificates of a bundle, i.e. the non-CA ones,
+// or when all of them are CAs, the ones which didn't issue any other certificate of the bundle
+func getLeafCertificates(certs []*parsedCertificate) []*parsedCertificate {
+	output := []*parsedCertificate{}
+	for _, cert := range certs {
+		if !cert.cert.IsCA {
+			output = append(output, cert)
+		}
+	}
+	if len(output) > 0 {
+		return output
+	}
+
+	for _, cert := range certs {
+		issuesOther := false
+		for _, other := range certs {
+			if other != cert && !bytes.Equal(other.cert.Raw, cert.cert.Raw) && bytes.Equal(other.cert.RawIssuer, cert.cert.RawSubject) {
+				issuesOther = true
+				break
+			}
+		}
+
+		if !issuesOther {
+			output = append(output, cert)
+		}
+	}
+
+	return output
+}
+
+// verifyCertificates : Check if the leaves of each source chain up to the configured trusted roots,
+// using the other certificates of their bundle as intermediates
+func (exporter *Exporter) verifyCertificates(refs []*certificateRef) []error {
+	roots, errs := exporter.loadTrustedRoots()
+	if roots == nil {
+		return errs
+	}
+
+	for _, ref := range refs {
+		intermediates := x509.NewCertPool()
+		for _, cert := range ref.certificates {
+			if cert.cert.IsCA {
+				intermediates.AddCert(cert.cert)
+			}
+		}
+
+		for _, cert := range ref.certificates {
+			if cert.cert.IsCA {
+				continue
+			}
+
+			chains, err := cert.cert.Verify(x509.VerifyOptions{
+				Roots:         roots,
+				Intermediates: intermediates,
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+			})
+			verified := err == nil
+			cert.verified = &verified
+
+			cert.verifiedRoots = nil
+			for _, chain := range chains {
+				cert.verifiedRoots = append(cert.verifiedRoots, getFingerprint(chain[len(chain)-1]))
+			}
+		}
+	}
+
+	return errs
+}
+
+// ParseFingerprint : Normalize a SHA-256 fingerprint given in hexadecimal, with or without colons
+func ParseFingerprint(spec string) (string, error) {
+	fingerprint, err := hex.DecodeString(strings.ReplaceAll(spec, ":", ""))
+	if err != nil || len(fingerprint) != sha256.Size {
+		return "", fmt.Errorf("\"%s\" is not a SHA-256 fingerprint", spec)
+	}
+
+	return hex.EncodeToString(fingerprint), nil
+}
+
+// getFingerprint : The SHA-256 fingerprint of a certificate, in lowercase hexadecimal
+func getFingerprint(cert *x509.Certificate) string {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(fingerprint[:])
+}
+
+// loadTrustedRoots : Build the pool of trusted roots from the system pool and/or
+// the configured roots files, nil when verification isn't enabled
+func (exporter *Exporter) loadTrustedRoots() (*x509.CertPool, []error) {
+	if !exporter.UseSystemRoots && len(exporter.TrustedRootFiles) == 0 {
+		return nil, nil
+	}
+
+	outputErrors := []error{}
+	roots := x509.NewCertPool()
+	if exporter.UseSystemRoots {
+		systemRoots, err := x509.SystemCertPool()
+		if err != nil {
+			outputErrors = append(outputErrors, fmt.Errorf("failed to load system roots: %s", err.Error()))
+		} else {
+			roots = systemRoots
+		}
+	}
+
+	for _, file := range exporter.TrustedRootFiles {
+		contents, err := readFile(file)
+		if err == nil {
+			var certs []*x509.Certificate
+			certs, err = parsePEM(contents)
+			for _, cert := range certs {
+				roots.AddCert(cert)
+			}
+		}
+
+		if err != nil {
+			outputErrors = append(outputErrors, fmt.Errorf("failed to parse trusted roots file \"%s\": %s", file, err.Error()))
+		}
+	}
+
+	return roots, outputErrors
+}
@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultClientCertRetention = 24 * time.Hour
+
+const defaultClientCertMaxRecords = 1000
+
+const clientCertHandshakeTimeout = 10 * time.Second
+
+// clientCertRecord : Certificates last presented by a client, identified by its IP address and leaf CN
+type clientCertRecord struct {
+	address      string
+	certificates []*x509.Certificate
+	lastSeen     time.Time
+}
+
+// startClientCertListener : Accept TLS connections on ClientCertListenAddress, recording the certificates presented
+// by clients, until Shutdown is called
+func (exporter *Exporter) startClientCertListener() error {
+	if len(exporter.ClientCertListenAddress) == 0 {
+		return nil
+	}
+
+	serverCert, err := exporter.getClientCertServerCertificate()
+	if err != nil {
+		return err
+	}
+
+	listener, err := tls.Listen("tcp", exporter.ClientCertListenAddress, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		// the presented certificates are monitored, not trusted
+		ClientAuth: tls.RequireAnyClientCert,
+		MinVersion: tls.VersionTLS12,
+	})
+	if err != nil {
+		return err
+	}
+	exporter.clientCertListener = listener
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go exporter.recordClientCertificates(conn.(*tls.Conn))
+		}
+	}()
+
+	return nil
+}
+
+// getClientCertServerCertificate : Present the metrics server certificate when there is one,
+// or a self-signed one otherwise, clients presenting their certificate regardless of the server's
+func (exporter *Exporter) getClientCertServerCertificate() (tls.Certificate, error) {
+	if len(exporter.TLSCertFile) > 0 && len(exporter.TLSKeyFile) > 0 {
+		return tls.LoadX509KeyPair(exporter.TLSCertFile, exporter.TLSKeyFile)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "x509-certificate-exporter"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{derBytes}, PrivateKey: key}, nil
+}
+
+// recordClientCertificates : Record the certificates presented by a client from one of ClientCertNetworks (any client
+// when unset), the least recently seen clients being forgotten beyond ClientCertMaxRecords
+func (exporter *Exporter) recordClientCertificates(conn *tls.Conn) {
+	defer conn.Close()
+
+	address, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		address = conn.RemoteAddr().String()
+	}
+
+	if !exporter.isClientCertNetworkAllowed(net.ParseIP(address)) {
+		log.Debugf("ignoring client certificate connection from %s, outside of the allowed networks", address)
+		return
+	}
+
+	//nolint:errcheck
+	conn.SetDeadline(time.Now().Add(clientCertHandshakeTimeout))
+	if err := conn.Handshake(); err != nil {
+		log.Debugf("client certificate handshake with %s failed: %s", conn.RemoteAddr().String(), err.Error())
+		return
+	}
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return
+	}
+
+	exporter.clientCertsMutex.Lock()
+	defer exporter.clientCertsMutex.Unlock()
+
+	if exporter.clientCerts == nil {
+		exporter.clientCerts = map[string]*clientCertRecord{}
+	}
+
+	// renewed certificates of a client replace the previous ones
+	key := fmt.Sprintf("client/%s/%s", address, certs[0].Subject.CommonName)
+	exporter.clientCerts[key] = &clientCertRecord{
+		address:      address,
+		certificates: certs,
+		lastSeen:     time.Now(),
+	}
+
+	maxRecords := exporter.ClientCertMaxRecords
+	if maxRecords == 0 {
+		maxRecords = defaultClientCertMaxRecords
+	}
+
+	for len(exporter.clientCerts) > maxRecords {
+		oldestKey := ""
+		for key, record := range exporter.clientCerts {
+			if len(oldestKey) == 0 || record.lastSeen.Before(exporter.clientCerts[oldestKey].lastSeen) {
+				oldestKey = key
+			}
+		}
+		delete(exporter.clientCerts, oldestKey)
+	}
+}
+
+func (exporter *Exporter) isClientCertNetworkAllowed(ip net.IP) bool {
+	if len(exporter.ClientCertNetworks) == 0 {
+		return true
+	}
+
+	for _, network := range exporter.ClientCertNetworks {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collectClientCertificates : Build a ref for each client seen within ClientCertRetention, older ones being forgotten
+func (exporter *Exporter) collectClientCertificates() []*certificateRef {
+	exporter.clientCertsMutex.Lock()
+	defer exporter.clientCertsMutex.Unlock()
+
+	retention := exporter.ClientCertRetention
+	if retention == 0 {
+		retention = defaultClientCertRetention
+	}
+
+	keys := []string{}
+	for key, record := range exporter.clientCerts {
+		if time.Since(record.lastSeen) > retention {
+			delete(exporter.clientCerts, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	output := []*certificateRef{}
+	for _, key := range keys {
+		output = append(output, &certificateRef{
+			path:       key,
+			format:     certificateFormatClientCert,
+			clientCert: exporter.clientCerts[key],
+		})
+	}
+
+	return output
+}
+
+func readClientCertificates(record *clientCertRecord) ([]*parsedCertificate, error) {
+	output := []*parsedCertificate{}
+	for _, cert := range record.certificates {
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	return output, nil
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func connectWithClientCertificate(t *testing.T, address string, cert *testCertificate) {
+	conn, err := tls.Dial("tcp", address, &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{cert.cert.Raw},
+			PrivateKey:  cert.key,
+		}},
+		//nolint:gosec
+		InsecureSkipVerify: true,
+	})
+	assert.NoError(t, err)
+	conn.Close()
+}
+
+func TestClientCertificates(t *testing.T) {
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	clientTemplate := leafTemplate("client", notAfter)
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	client := generateTestCertificate(clientTemplate, nil)
+
+	exporter := &Exporter{
+		ClientCertListenAddress: "127.0.0.1:0",
+		ClientCertRetention:     time.Hour,
+	}
+	assert.NoError(t, exporter.startClientCertListener())
+	defer exporter.Shutdown()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{exporter: exporter})
+
+	getExpirations := func() map[string]float64 {
+		families, err := registry.Gather()
+		assert.NoError(t, err)
+
+		expirations := map[string]float64{}
+		for _, family := range families {
+			if family.GetName() != "x509_cert_not_after" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				expirations[getLabelValue(metric, "client_address")+"/"+getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+			}
+		}
+
+		return expirations
+	}
+
+	assert.Empty(t, getExpirations())
+
+	connectWithClientCertificate(t, exporter.clientCertListener.Addr().String(), client)
+	assert.Eventually(t, func() bool {
+		return len(getExpirations()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]float64{"127.0.0.1/client": float64(notAfter.Unix())}, getExpirations())
+
+	// a renewed certificate replaces the previous one
+	renewedNotAfter := notAfter.Add(time.Hour)
+	renewedTemplate := leafTemplate("client", renewedNotAfter)
+	renewedTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	connectWithClientCertificate(t, exporter.clientCertListener.Addr().String(), generateTestCertificate(renewedTemplate, nil))
+	assert.Eventually(t, func() bool {
+		return getExpirations()["127.0.0.1/client"] == float64(renewedNotAfter.Unix())
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Len(t, getExpirations(), 1)
+
+	// clients which didn't connect within the retention are forgotten
+	exporter.clientCertsMutex.Lock()
+	for _, record := range exporter.clientCerts {
+		record.lastSeen = time.Now().Add(-2 * time.Hour)
+	}
+	exporter.clientCertsMutex.Unlock()
+	assert.Empty(t, getExpirations())
+}
+
+func TestClientCertificatesRequired(t *testing.T) {
+	exporter := &Exporter{
+		ClientCertListenAddress: "127.0.0.1:0",
+	}
+	assert.NoError(t, exporter.startClientCertListener())
+	defer exporter.Shutdown()
+
+	conn, err := tls.Dial("tcp", exporter.clientCertListener.Addr().String(), &tls.Config{
+		//nolint:gosec
+		InsecureSkipVerify: true,
+	})
+	if err == nil {
+		// with TLS 1.3, the server rejects the handshake after the client considers it complete
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	assert.Error(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+	exporter.clientCertsMutex.Lock()
+	defer exporter.clientCertsMutex.Unlock()
+	assert.Len(t, exporter.clientCerts, 0)
+}
+
+func TestClientCertificatesLimits(t *testing.T) {
+	notAfter := time.Now().Add(time.Hour)
+	exporter := &Exporter{
+		ClientCertListenAddress: "127.0.0.1:0",
+		ClientCertMaxRecords:    2,
+	}
+	assert.NoError(t, exporter.startClientCertListener())
+	defer exporter.Shutdown()
+	address := exporter.clientCertListener.Addr().String()
+
+	getClients := func() []string {
+		clients := []string{}
+		for _, ref := range exporter.collectClientCertificates() {
+			clients = append(clients, ref.path)
+		}
+		return clients
+	}
+
+	// the least recently seen clients are forgotten first
+	for _, name := range []string{"first", "second", "third"} {
+		connectWithClientCertificate(t, address, generateTestCertificate(leafTemplate(name, notAfter), nil))
+		assert.Eventually(t, func() bool {
+			return slices.Contains(getClients(), "client/127.0.0.1/"+name)
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+	assert.Equal(t, []string{"client/127.0.0.1/second", "client/127.0.0.1/third"}, getClients())
+
+	// clients outside of the allowed networks are disconnected right away
+	_, network, err := net.ParseCIDR("10.0.0.0/8")
+	assert.NoError(t, err)
+	restricted := &Exporter{
+		ClientCertListenAddress: "127.0.0.1:0",
+		ClientCertNetworks:      []*net.IPNet{network},
+	}
+	assert.NoError(t, restricted.startClientCertListener())
+	defer restricted.Shutdown()
+
+	outsider := generateTestCertificate(leafTemplate("outsider", notAfter), nil)
+	conn, err := tls.Dial("tcp", restricted.clientCertListener.Addr().String(), &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{outsider.cert.Raw}, PrivateKey: outsider.key}},
+		//nolint:gosec
+		InsecureSkipVerify: true,
+	})
+	assert.Error(t, err)
+	if conn != nil {
+		conn.Close()
+	}
+	assert.Empty(t, restricted.collectClientCertificates())
+}
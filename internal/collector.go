@@ -1,13 +1,36 @@
 package internal
 
 import (
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 )
 
+const otherIssuersLabel = "(other)"
+
+// maxLabelListLength : Number of entries kept when a list (e.g. email addresses) is exposed as a label
+const maxLabelListLength = 5
+
+// remainingLifetimeBuckets : Upper bounds (in days) of the x509_cert_remaining_lifetime_days buckets
+var remainingLifetimeBuckets = []float64{0, 7, 30, 90, 365}
+
+// expiringRatioWindows : Windows (in days) of the x509_cert_expiring_ratio gauges
+var expiringRatioWindows = []int{7, 30, 90}
+
+// collector : Registered once, and building const metrics from the sources read on each scrape, so that sources
+// coming and going (informers, service discovery...) never need series to be registered or unregistered
 type collector struct {
 	exporter *Exporter
 }
@@ -25,18 +48,181 @@ var (
 	certNotAfterHelp   = "Indicates the certificate's not after timestamp"
 	certNotAfterDesc   = prometheus.NewDesc(certNotAfterMetric, certNotAfterHelp, nil, nil)
 
-	certExpiresInMetric = "x509_cert_expires_in_seconds"
-	certExpiresInHelp   = "Indicates the remaining time before the certificate's not after timestamp"
-	certExpiresInDesc   = prometheus.NewDesc(certExpiresInMetric, certExpiresInHelp, nil, nil)
+	// suffixed with the configured unit, see getExpiresInMetric
+	certExpiresInHelp = "Indicates the remaining time before the certificate's not after timestamp"
 
 	certValidSinceMetric = "x509_cert_valid_since_seconds"
 	certValidSinceHelp   = "Indicates the elapsed time since the certificate's not before timestamp"
 	certValidSinceDesc   = prometheus.NewDesc(certValidSinceMetric, certValidSinceHelp, nil, nil)
 
+	certIssuerNotAfterMetric = "x509_cert_issuer_not_after"
+	certIssuerNotAfterHelp   = "Indicates the not after timestamp of the certificate's issuer"
+	certIssuerNotAfterDesc   = prometheus.NewDesc(certIssuerNotAfterMetric, certIssuerNotAfterHelp, nil, nil)
+
+	certIssuerChainBrokenMetric = "x509_cert_issuer_chain_broken"
+	certIssuerChainBrokenHelp   = "Indicates if the issuing chain of a currently valid leaf certificate is broken, its issuer being missing or expired (1) or not (0)"
+	certIssuerChainBrokenDesc   = prometheus.NewDesc(certIssuerChainBrokenMetric, certIssuerChainBrokenHelp, nil, nil)
+
+	certVerifiedMetric = "x509_cert_verified"
+	certVerifiedHelp   = "Indicates if the certificate chains up to the configured trusted roots, with the intermediates of its bundle (1) or not (0)"
+	certVerifiedDesc   = prometheus.NewDesc(certVerifiedMetric, certVerifiedHelp, nil, nil)
+
+	certChainsToRootMetric = "x509_cert_chains_to_root"
+	certChainsToRootHelp   = "Indicates if one of the verified chains of the leaf certificate ends at the pinned root of the root_fingerprint label (1) or not (0)"
+	certChainsToRootDesc   = prometheus.NewDesc(certChainsToRootMetric, certChainsToRootHelp, nil, nil)
+
+	certOutlivesIssuerMetric = "x509_cert_outlives_issuer"
+	certOutlivesIssuerHelp   = "Indicates if the certificate's not after timestamp is later than its issuer's (1) or not (0), which denotes a misissued certificate"
+	certOutlivesIssuerDesc   = prometheus.NewDesc(certOutlivesIssuerMetric, certOutlivesIssuerHelp, nil, nil)
+
+	certMaxPathLenMetric = "x509_cert_max_path_len"
+	certMaxPathLenHelp   = "Indicates the maximum number of intermediates allowed below a CA certificate having a path length constraint"
+	certMaxPathLenDesc   = prometheus.NewDesc(certMaxPathLenMetric, certMaxPathLenHelp, nil, nil)
+
+	certChainDepthMetric = "x509_cert_chain_depth"
+	certChainDepthHelp   = "Indicates the number of certificates from the leaf certificate to its root inclusive, when its source holds the full chain"
+	certChainDepthDesc   = prometheus.NewDesc(certChainDepthMetric, certChainDepthHelp, nil, nil)
+
+	certTypeMetric = "x509_cert_type"
+	certTypeHelp   = "A metric with a constant '1' value labeled with the certificate's type in its chain (leaf, intermediate or root)"
+	certTypeDesc   = prometheus.NewDesc(certTypeMetric, certTypeHelp, nil, nil)
+
+	certWildcardMetric = "x509_cert_wildcard"
+	certWildcardHelp   = "A metric with a constant '1' value for each wildcard DNS name of the certificate, labeled with the domain it covers"
+	certWildcardDesc   = prometheus.NewDesc(certWildcardMetric, certWildcardHelp, nil, nil)
+
+	certRevocationEndpointsMetric = "x509_cert_revocation_endpoints"
+	certRevocationEndpointsHelp   = "A metric with a constant '1' value labeled with the CRL distribution points and OCSP servers of the certificate"
+	certRevocationEndpointsDesc   = prometheus.NewDesc(certRevocationEndpointsMetric, certRevocationEndpointsHelp, nil, nil)
+
+	certNoRevocationEndpointsMetric = "x509_cert_no_revocation_endpoints"
+	certNoRevocationEndpointsHelp   = "Indicates if a certificate (not self-signed) has neither CRL distribution point nor OCSP server (1) or not (0)"
+	certNoRevocationEndpointsDesc   = prometheus.NewDesc(certNoRevocationEndpointsMetric, certNoRevocationEndpointsHelp, nil, nil)
+
+	certHasSANMetric = "x509_cert_has_san"
+	certHasSANHelp   = "Indicates if a server certificate has subject alternative names (DNS names or IP addresses), rather than relying on its CN only"
+	certHasSANDesc   = prometheus.NewDesc(certHasSANMetric, certHasSANHelp, nil, nil)
+
+	certCNNotInSANMetric = "x509_cert_cn_not_in_san"
+	certCNNotInSANHelp   = "Indicates if the CN of a server certificate isn't one of its DNS names (1) or is (0), modern clients ignoring the CN"
+	certCNNotInSANDesc   = prometheus.NewDesc(certCNNotInSANMetric, certCNNotInSANHelp, nil, nil)
+
+	certInvalidForTLSServerMetric = "x509_cert_invalid_for_tls_server"
+	certInvalidForTLSServerHelp   = "Indicates if a certificate allowed for TLS server authentication has no DNS name nor IP address it could be valid for (1) or not (0), clients rejecting it"
+	certInvalidForTLSServerDesc   = prometheus.NewDesc(certInvalidForTLSServerMetric, certInvalidForTLSServerHelp, nil, nil)
+
+	certSANCountMetric = "x509_cert_san_count"
+	certSANCountHelp   = "Indicates the number of subject alternative names of the certificate (DNS names, IP addresses, URIs and email addresses)"
+	certSANCountDesc   = prometheus.NewDesc(certSANCountMetric, certSANCountHelp, nil, nil)
+
+	certSANExpiresInMetric = "x509_cert_san_expires_in_seconds"
+	certSANExpiresInHelp   = "Indicates the remaining time before the certificate's not after timestamp, for each DNS name or IP address it covers"
+	certSANExpiresInDesc   = prometheus.NewDesc(certSANExpiresInMetric, certSANExpiresInHelp, nil, nil)
+
+	certEmailsMetric = "x509_cert_email_addresses"
+	certEmailsHelp   = "A metric with a constant '1' value labeled with the email addresses found in the certificate's subject alternative names"
+	certEmailsDesc   = prometheus.NewDesc(certEmailsMetric, certEmailsHelp, nil, nil)
+
+	certIPAddressesMetric = "x509_cert_ip_addresses"
+	certIPAddressesHelp   = "A metric with a constant '1' value labeled with the IP addresses found in the certificate's subject alternative names"
+	certIPAddressesDesc   = prometheus.NewDesc(certIPAddressesMetric, certIPAddressesHelp, nil, nil)
+
+	certSHA1FingerprintMetric = "x509_cert_insecure_sha1_fingerprint"
+	certSHA1FingerprintHelp   = "A metric with a constant '1' value labeled with the SHA-1 fingerprint of the certificate, for correlation with legacy systems only as SHA-1 isn't collision resistant"
+	certSHA1FingerprintDesc   = prometheus.NewDesc(certSHA1FingerprintMetric, certSHA1FingerprintHelp, nil, nil)
+
+	certPublicKeySharedCountMetric = "x509_cert_public_key_shared_count"
+	certPublicKeySharedCountHelp   = "Indicates the number of certificates sharing the public key of the certificate, including itself (1 for a unique key)"
+	certPublicKeySharedCountDesc   = prometheus.NewDesc(certPublicKeySharedCountMetric, certPublicKeySharedCountHelp, nil, nil)
+
+	certSignatureCompliantMetric = "x509_cert_signature_algorithm_compliant"
+	certSignatureCompliantHelp   = "Indicates if the certificate is signed with an algorithm allowed by the signature algorithm policy (1) or not (0)"
+	certSignatureCompliantDesc   = prometheus.NewDesc(certSignatureCompliantMetric, certSignatureCompliantHelp, nil, nil)
+
+	certWeakRSAKeyMetric = "x509_cert_weak_rsa_key"
+	certWeakRSAKeyHelp   = "Indicates if the RSA key of the certificate is smaller than the minimum key size (1) or not (0)"
+	certWeakRSAKeyDesc   = prometheus.NewDesc(certWeakRSAKeyMetric, certWeakRSAKeyHelp, nil, nil)
+
+	certPublicKeyPinnedMetric = "x509_cert_public_key_pinned"
+	certPublicKeyPinnedHelp   = "Indicates if the SHA-256 hash of the certificate's public key is one of the pinned ones (1) or not (0)"
+	certPublicKeyPinnedDesc   = prometheus.NewDesc(certPublicKeyPinnedMetric, certPublicKeyPinnedHelp, nil, nil)
+
+	certFarFutureMetric = "x509_cert_not_after_far_future"
+	certFarFutureHelp   = "Indicates if the certificate expires after the far future threshold (1) or not (0)"
+	certFarFutureDesc   = prometheus.NewDesc(certFarFutureMetric, certFarFutureHelp, nil, nil)
+
+	endpointTLSInfoMetric = "x509_endpoint_tls_info"
+	endpointTLSInfoHelp   = "TLS version and cipher suite negotiated by the last handshake with a watched endpoint, always 1"
+	endpointTLSInfoDesc   = prometheus.NewDesc(endpointTLSInfoMetric, endpointTLSInfoHelp, nil, nil)
+
+	chainOutOfOrderMetric = "x509_chain_out_of_order"
+	chainOutOfOrderHelp   = "Indicates if a bundle holding a leaf and its chain doesn't list them leaf first, each certificate followed by its issuer (1) or does (0)"
+	chainOutOfOrderDesc   = prometheus.NewDesc(chainOutOfOrderMetric, chainOutOfOrderHelp, nil, nil)
+
+	parseSkippedBlocksMetric = "x509_parse_skipped_blocks"
+	parseSkippedBlocksHelp   = "Number of PEM blocks of a watched file which didn't yield a certificate on its last read, by reason (non-cert-type or decode-error), only for the files which had some"
+	parseSkippedBlocksDesc   = prometheus.NewDesc(parseSkippedBlocksMetric, parseSkippedBlocksHelp, nil, nil)
+
+	certCTLoggedMetric = "x509_cert_ct_logged"
+	certCTLoggedHelp   = "Indicates if the certificate was found in Certificate Transparency logs (1) or not (0)"
+	certCTLoggedDesc   = prometheus.NewDesc(certCTLoggedMetric, certCTLoggedHelp, nil, nil)
+
+	certHostnameMatchMetric = "x509_cert_hostname_match"
+	certHostnameMatchHelp   = "Indicates if the leaf certificate is valid for the expected hostname of its source (1) or not (0)"
+	certHostnameMatchDesc   = prometheus.NewDesc(certHostnameMatchMetric, certHostnameMatchHelp, nil, nil)
+
+	certInfoMetric = "x509_cert_info"
+	certInfoHelp   = "Consolidated metric of a certificate, valued with its not after timestamp and labeled with its attributes"
+	certInfoDesc   = prometheus.NewDesc(certInfoMetric, certInfoHelp, nil, nil)
+
+	certByIssuerCountMetric = "x509_cert_by_issuer_count"
+	certByIssuerCountHelp   = "Indicates the number of certificates issued by each issuer common name"
+	certByIssuerCountDesc   = prometheus.NewDesc(certByIssuerCountMetric, certByIssuerCountHelp, []string{"issuer_CN"}, nil)
+
+	certDistinctIssuerCountMetric = "x509_cert_distinct_issuer_count"
+	certDistinctIssuerCountHelp   = "Indicates the number of distinct issuers of all certificates, identified by their name and key identifier"
+	certDistinctIssuerCountDesc   = prometheus.NewDesc(certDistinctIssuerCountMetric, certDistinctIssuerCountHelp, nil, nil)
+
+	certRemainingLifetimeMetric = "x509_cert_remaining_lifetime_days"
+	certRemainingLifetimeHelp   = "Distribution of the number of days left before the not after timestamp of all certificates, negative for expired ones"
+	certRemainingLifetimeDesc   = prometheus.NewDesc(certRemainingLifetimeMetric, certRemainingLifetimeHelp, nil, nil)
+
+	certExpiringRatioMetric = "x509_cert_expiring_ratio"
+	certExpiringRatioHelp   = "Indicates the fraction of all certificates whose not after timestamp is within the window, including expired ones"
+	certExpiringRatioDesc   = prometheus.NewDesc(certExpiringRatioMetric, certExpiringRatioHelp, []string{"window_days"}, nil)
+
+	certMaxFutureNotBeforeMetric = "x509_cert_max_future_not_before_seconds"
+	certMaxFutureNotBeforeHelp   = "Indicates how far in the future the latest not before timestamp of all certificates is, 0 if none is in the future"
+	certMaxFutureNotBeforeDesc   = prometheus.NewDesc(certMaxFutureNotBeforeMetric, certMaxFutureNotBeforeHelp, nil, nil)
+
+	servedCertMatchesMetric = "x509_service_served_cert_matches_file"
+	servedCertMatchesHelp   = "Indicates if the TLS endpoints of a service serve one of the leaf certificates of its files (1) or not (0)"
+	servedCertMatchesDesc   = prometheus.NewDesc(servedCertMatchesMetric, servedCertMatchesHelp, []string{"service"}, nil)
+
+	clockSkewSuspectedMetric = "x509_cert_clock_skew_suspected"
+	clockSkewSuspectedHelp   = "Indicates if a certificate's not before timestamp is further in the future than the clock skew threshold (1) or not (0), hinting at a wrong clock on this host or on the issuer"
+	clockSkewSuspectedDesc   = prometheus.NewDesc(clockSkewSuspectedMetric, clockSkewSuspectedHelp, nil, nil)
+
+	certRotationMetric = "x509_cert_rotation_total"
+	certRotationHelp   = "Indicates how many times the leaf certificate of a source changed since the exporter started"
+	certRotationDesc   = prometheus.NewDesc(certRotationMetric, certRotationHelp, nil, nil)
+
+	certRotationIntervalMetric = "x509_cert_last_rotation_interval_seconds"
+	certRotationIntervalHelp   = "Indicates the time between the not before timestamps of the current leaf certificate of a source and of the previous one"
+	certRotationIntervalDesc   = prometheus.NewDesc(certRotationIntervalMetric, certRotationIntervalHelp, nil, nil)
+
+	certCacheAgeMetric = "x509_cert_cache_age_seconds"
+	certCacheAgeHelp   = "Indicates the number of seconds since a source was last parsed successfully, growing while it fails"
+	certCacheAgeDesc   = prometheus.NewDesc(certCacheAgeMetric, certCacheAgeHelp, nil, nil)
+
 	certErrorMetric = "x509_cert_error"
 	certErrorHelp   = "Indicates wether the corresponding secret has read failure(s)"
 	certErrorDesc   = prometheus.NewDesc(certErrorMetric, certErrorHelp, nil, nil)
 
+	readTimeoutsMetric = "x509_read_timeouts"
+	readTimeoutsHelp   = "Indicates the number of source(s) which could not be read before the scrape timeout"
+	readTimeoutsDesc   = prometheus.NewDesc(readTimeoutsMetric, readTimeoutsHelp, nil, nil)
+
 	certErrorsMetric = "x509_read_errors"
 	certErrorsHelp   = "Indicates the number of read failure(s)"
 	certErrorsDesc   = prometheus.NewDesc(certErrorsMetric, certErrorsHelp, nil, nil)
@@ -55,51 +241,290 @@ var (
 )
 
 func (collector *collector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- certExpiredDesc
-	ch <- certNotBeforeDesc
-	ch <- certNotAfterDesc
+	if collector.exporter.ConsolidatedMetrics {
+		ch <- certInfoDesc
+	} else {
+		ch <- certExpiredDesc
+		ch <- certNotBeforeDesc
+		ch <- certNotAfterDesc
+
+		if collector.exporter.ExposePathLenMetrics {
+			ch <- certMaxPathLenDesc
+		}
+
+		if collector.exporter.ExposeChainDepthMetrics {
+			ch <- certChainDepthDesc
+		}
+
+		if collector.exporter.ExposeWildcardMetrics {
+			ch <- certWildcardDesc
+		}
+	}
+
+	ch <- certByIssuerCountDesc
+	ch <- certDistinctIssuerCountDesc
+	ch <- certRemainingLifetimeDesc
+	ch <- certExpiringRatioDesc
+	ch <- certMaxFutureNotBeforeDesc
+	ch <- clockSkewSuspectedDesc
 	ch <- certErrorsDesc
+	ch <- readTimeoutsDesc
 	ch <- infoDesc
 
 	if collector.exporter.ExposeRelativeMetrics {
-		ch <- certExpiresInDesc
+		ch <- prometheus.NewDesc(collector.exporter.getExpiresInMetric(), certExpiresInHelp, nil, nil)
 		ch <- certValidSinceDesc
 	}
 
 	if collector.exporter.ExposeErrorMetrics {
 		ch <- certErrorDesc
 	}
+
+	if collector.exporter.ExposeIssuerMetrics {
+		ch <- certIssuerNotAfterDesc
+		ch <- certOutlivesIssuerDesc
+		ch <- certIssuerChainBrokenDesc
+	}
+
+	if collector.exporter.UseSystemRoots || len(collector.exporter.TrustedRootFiles) > 0 {
+		ch <- certVerifiedDesc
+
+		if len(collector.exporter.PinnedRoots) > 0 {
+			ch <- certChainsToRootDesc
+		}
+	}
+
+	if collector.exporter.ExposeTypeMetrics {
+		ch <- certTypeDesc
+	}
+
+	if collector.exporter.ExposeSANMetrics {
+		ch <- certHasSANDesc
+		ch <- certCNNotInSANDesc
+		ch <- certInvalidForTLSServerDesc
+		ch <- certSANCountDesc
+	}
+
+	if collector.exporter.ExposeSANExpiryMetrics {
+		ch <- certSANExpiresInDesc
+	}
+
+	if collector.exporter.ExposeEmailMetrics {
+		ch <- certEmailsDesc
+	}
+
+	if collector.exporter.ExposeIPSANMetrics {
+		ch <- certIPAddressesDesc
+	}
+
+	if collector.exporter.ExposeRevocationMetrics {
+		ch <- certRevocationEndpointsDesc
+		ch <- certNoRevocationEndpointsDesc
+	}
+
+	if collector.exporter.ExposeSHA1Metrics {
+		ch <- certSHA1FingerprintDesc
+	}
+
+	if collector.exporter.ExposeKeyReuseMetrics {
+		ch <- certPublicKeySharedCountDesc
+	}
+
+	if collector.exporter.hasSignaturePolicy() {
+		ch <- certSignatureCompliantDesc
+	}
+
+	if collector.exporter.MinRSAKeySize > 0 {
+		ch <- certWeakRSAKeyDesc
+	}
+
+	if len(collector.exporter.PinnedPublicKeys) > 0 {
+		ch <- certPublicKeyPinnedDesc
+	}
+
+	if !collector.exporter.FarFutureNotAfter.IsZero() {
+		ch <- certFarFutureDesc
+	}
+
+	ch <- endpointTLSInfoDesc
+
+	if collector.exporter.ExposeChainOrderMetrics {
+		ch <- chainOutOfOrderDesc
+	}
+
+	if collector.exporter.ExposeSkippedPEMMetrics {
+		ch <- parseSkippedBlocksDesc
+	}
+
+	if collector.exporter.ExposeCTMetrics {
+		ch <- certCTLoggedDesc
+	}
+
+	if collector.exporter.ExposeHostnameMetrics || len(collector.exporter.ExpectedHostnames) > 0 {
+		ch <- certHostnameMatchDesc
+	}
+
+	if collector.exporter.ExposeRotationMetrics {
+		ch <- certRotationDesc
+		ch <- certRotationIntervalDesc
+	}
+
+	if collector.exporter.ExposeCacheAgeMetrics {
+		ch <- certCacheAgeDesc
+	}
+
+	if len(collector.exporter.ServiceFiles) > 0 && len(collector.exporter.ServiceEndpoints) > 0 {
+		ch <- servedCertMatchesDesc
+	}
 }
 
 func (collector *collector) Collect(ch chan<- prometheus.Metric) {
-	certRefs, certErrors := collector.exporter.parseAllCertificates()
+	ctx, cancel := collector.exporter.newScrapeContext()
+	defer cancel()
+	certRefs, certErrors := collector.exporter.parseAllCertificates(ctx)
+
+	var publicKeyCounts map[[sha256.Size]byte]int
+	if collector.exporter.ExposeKeyReuseMetrics {
+		publicKeyCounts = getPublicKeyCounts(certRefs)
+	}
 
 	for _, certRef := range certRefs {
 		for _, cert := range certRef.certificates {
+			if !collector.exporter.isValidityStateExported(cert.cert) {
+				continue
+			}
+
+			if collector.exporter.ConsolidatedMetrics {
+				ch <- collector.getInfoMetricForCertificate(cert, certRef)
+				continue
+			}
+
 			metrics := collector.getMetricsForCertificate(cert, certRef)
 			for _, metric := range metrics {
 				ch <- metric
 			}
+
+			if publicKeyCounts != nil {
+				labelKeys, labelValues := collector.exporter.unzipLabels(collector.exporter.getLabels(cert, certRef))
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(certPublicKeySharedCountMetric, certPublicKeySharedCountHelp, labelKeys, nil),
+					prometheus.GaugeValue,
+					float64(publicKeyCounts[sha256.Sum256(cert.cert.RawSubjectPublicKeyInfo)]),
+					labelValues...,
+				)
+			}
+		}
+
+		if certRef.format == certificateFormatEndpoint {
+			if negotiated := certRef.endpoint.getNegotiated(); negotiated != nil {
+				labelKeys, labelValues := collector.exporter.unzipLabels(collector.exporter.getBaseLabels(certRef))
+				labelKeys, labelValues = withLabel(labelKeys, labelValues, tlsVersionLabel, tls.VersionName(negotiated.version))
+				labelKeys, labelValues = withLabel(labelKeys, labelValues, cipherSuiteLabel, tls.CipherSuiteName(negotiated.cipherSuite))
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(endpointTLSInfoMetric, endpointTLSInfoHelp, labelKeys, nil),
+					prometheus.GaugeValue,
+					1,
+					labelValues...,
+				)
+			}
+		}
+
+		if certRef.chainOutOfOrder != nil {
+			outOfOrder := 0.
+			if *certRef.chainOutOfOrder {
+				outOfOrder = 1.
+			}
+
+			labelKeys, labelValues := collector.exporter.unzipLabels(collector.exporter.getBaseLabels(certRef))
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(chainOutOfOrderMetric, chainOutOfOrderHelp, labelKeys, nil),
+				prometheus.GaugeValue,
+				outOfOrder,
+				labelValues...,
+			)
+		}
+
+		for _, reason := range []string{skippedBlockNonCertType, skippedBlockDecodeError} {
+			if collector.exporter.ExposeSkippedPEMMetrics && certRef.skippedBlocks[reason] > 0 {
+				labelKeys, labelValues := collector.exporter.unzipLabels(collector.exporter.getBaseLabels(certRef))
+				labelKeys, labelValues = withLabel(labelKeys, labelValues, reasonLabel, reason)
+
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(parseSkippedBlocksMetric, parseSkippedBlocksHelp, labelKeys, nil),
+					prometheus.GaugeValue,
+					float64(certRef.skippedBlocks[reason]),
+					labelValues...,
+				)
+			}
 		}
 
 		if collector.exporter.ExposeErrorMetrics && len(certRef.certificates) > 0 {
 			labelKeys, labelValues := collector.exporter.unzipLabels(collector.exporter.getBaseLabels(certRef))
 
+			// stale certificates are served because the source currently fails
+			hasError := 0.
+			if certRef.stale {
+				hasError = 1.
+			}
+
 			ch <- prometheus.MustNewConstMetric(
 				prometheus.NewDesc(certErrorMetric, certErrorHelp, labelKeys, nil),
 				prometheus.GaugeValue,
-				0,
+				hasError,
+				labelValues...,
+			)
+		}
+	}
+
+	if collector.exporter.ExposeRotationMetrics {
+		for certRef, rotations := range collector.exporter.trackRotations(certRefs) {
+			labelKeys, labelValues := collector.exporter.unzipLabels(collector.exporter.getBaseLabels(certRef))
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(certRotationMetric, certRotationHelp, labelKeys, nil),
+				prometheus.CounterValue,
+				float64(rotations.count),
 				labelValues...,
 			)
+
+			// unknown until the leaf of the source was seen changing
+			if rotations.count > 0 {
+				ch <- prometheus.MustNewConstMetric(
+					prometheus.NewDesc(certRotationIntervalMetric, certRotationIntervalHelp, labelKeys, nil),
+					prometheus.GaugeValue,
+					rotations.interval.Seconds(),
+					labelValues...,
+				)
+			}
 		}
 	}
 
+	if collector.exporter.ExposeCacheAgeMetrics {
+		for certRef, age := range collector.exporter.trackParseAges(certRefs) {
+			labelKeys, labelValues := collector.exporter.unzipLabels(collector.exporter.getBaseLabels(certRef))
+
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(certCacheAgeMetric, certCacheAgeHelp, labelKeys, nil),
+				prometheus.GaugeValue,
+				age.Seconds(),
+				labelValues...,
+			)
+		}
+	}
+
+	timeouts := 0
 	for index, err := range certErrors {
+		if err.timeout {
+			timeouts++
+		}
+
 		if err.err != nil {
 			log.Debugf("read error %d: %+v", index+1, err.err)
 		}
 
-		if collector.exporter.ExposeErrorMetrics && err.ref != nil {
+		// already reported along with its stale certificates
+		if collector.exporter.ExposeErrorMetrics && err.ref != nil && !err.ref.stale {
 			labelKeys, labelValues := collector.exporter.unzipLabels(collector.exporter.getBaseLabels(err.ref))
 
 			ch <- prometheus.MustNewConstMetric(
@@ -111,12 +536,81 @@ func (collector *collector) Collect(ch chan<- prometheus.Metric) {
 		}
 	}
 
+	for _, comparison := range collector.exporter.compareServedCertificates(certRefs) {
+		matches := 0.
+		if comparison.matches {
+			matches = 1.
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			servedCertMatchesDesc,
+			prometheus.GaugeValue,
+			matches,
+			comparison.service,
+		)
+	}
+
+	for _, count := range collector.exporter.countCertificatesByIssuer(certRefs) {
+		ch <- prometheus.MustNewConstMetric(
+			certByIssuerCountDesc,
+			prometheus.GaugeValue,
+			float64(count.count),
+			count.issuer,
+		)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		certDistinctIssuerCountDesc,
+		prometheus.GaugeValue,
+		float64(countDistinctIssuers(certRefs)),
+	)
+
+	maxFutureNotBefore := getMaxFutureNotBefore(certRefs, time.Now())
+	ch <- prometheus.MustNewConstMetric(
+		certMaxFutureNotBeforeDesc,
+		prometheus.GaugeValue,
+		maxFutureNotBefore.Seconds(),
+	)
+
+	clockSkewSuspected := 0.
+	if maxFutureNotBefore > collector.exporter.ClockSkewThreshold {
+		clockSkewSuspected = 1.
+	}
+	ch <- prometheus.MustNewConstMetric(
+		clockSkewSuspectedDesc,
+		prometheus.GaugeValue,
+		clockSkewSuspected,
+	)
+
+	count, sum, buckets := getRemainingLifetimeHistogram(certRefs, time.Now())
+	ch <- prometheus.MustNewConstHistogram(
+		certRemainingLifetimeDesc,
+		count,
+		sum,
+		buckets,
+	)
+
+	for index, ratio := range getExpiringRatios(certRefs, time.Now()) {
+		ch <- prometheus.MustNewConstMetric(
+			certExpiringRatioDesc,
+			prometheus.GaugeValue,
+			ratio,
+			strconv.Itoa(expiringRatioWindows[index]),
+		)
+	}
+
 	ch <- prometheus.MustNewConstMetric(
 		certErrorsDesc,
 		prometheus.GaugeValue,
 		float64(len(certErrors)),
 	)
 
+	ch <- prometheus.MustNewConstMetric(
+		readTimeoutsDesc,
+		prometheus.GaugeValue,
+		float64(timeouts),
+	)
+
 	ch <- prometheus.MustNewConstMetric(
 		infoDesc,
 		prometheus.GaugeValue,
@@ -154,11 +648,44 @@ func (collector *collector) getMetricsForCertificate(certData *parsedCertificate
 		),
 	}
 
+	// parsed certificates without a path length constraint have a MaxPathLen of -1, MaxPathLenZero telling a 0 one apart
+	if collector.exporter.ExposePathLenMetrics && certData.cert.IsCA && (certData.cert.MaxPathLen > 0 || certData.cert.MaxPathLenZero) {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certMaxPathLenMetric, certMaxPathLenHelp, labelKeys, nil),
+			prometheus.GaugeValue,
+			float64(certData.cert.MaxPathLen),
+			labelValues...,
+		))
+	}
+
+	if collector.exporter.ExposeChainDepthMetrics && !certData.cert.IsCA {
+		if depth := getChainDepth(certData.cert, ref.certificates); depth > 0 {
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				prometheus.NewDesc(certChainDepthMetric, certChainDepthHelp, labelKeys, nil),
+				prometheus.GaugeValue,
+				float64(depth),
+				labelValues...,
+			))
+		}
+	}
+
+	if collector.exporter.ExposeWildcardMetrics {
+		for _, domain := range getWildcardDomains(certData.cert) {
+			wildcardLabelKeys, wildcardLabelValues := withLabel(labelKeys, labelValues, wildcardDomainLabel, domain)
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				prometheus.NewDesc(certWildcardMetric, certWildcardHelp, wildcardLabelKeys, nil),
+				prometheus.GaugeValue,
+				1,
+				wildcardLabelValues...,
+			))
+		}
+	}
+
 	if collector.exporter.ExposeRelativeMetrics {
 		metrics = append(metrics, prometheus.MustNewConstMetric(
-			prometheus.NewDesc(certExpiresInMetric, certExpiresInHelp, labelKeys, nil),
+			prometheus.NewDesc(collector.exporter.getExpiresInMetric(), certExpiresInHelp, labelKeys, nil),
 			prometheus.GaugeValue,
-			float64(time.Until(certData.cert.NotAfter).Seconds()),
+			collector.exporter.getExpiresIn(certData.cert.NotAfter),
 			labelValues...,
 		))
 
@@ -170,5 +697,536 @@ func (collector *collector) getMetricsForCertificate(certData *parsedCertificate
 		))
 	}
 
+	if collector.exporter.ExposeIssuerMetrics && certData.issuer != nil {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certIssuerNotAfterMetric, certIssuerNotAfterHelp, labelKeys, nil),
+			prometheus.GaugeValue,
+			float64(certData.issuer.NotAfter.Unix()),
+			labelValues...,
+		))
+
+		outlivesIssuer := 0.
+		if certData.cert.NotAfter.After(certData.issuer.NotAfter) {
+			outlivesIssuer = 1.
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certOutlivesIssuerMetric, certOutlivesIssuerHelp, labelKeys, nil),
+			prometheus.GaugeValue,
+			outlivesIssuer,
+			labelValues...,
+		))
+	}
+
+	// expired leaves are already reported as such
+	if certData.chainBroken != nil && expired == 0 && !time.Now().Before(certData.cert.NotBefore) {
+		chainBroken := 0.
+		if *certData.chainBroken {
+			chainBroken = 1.
+		}
+
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certIssuerChainBrokenMetric, certIssuerChainBrokenHelp, labelKeys, nil),
+			prometheus.GaugeValue,
+			chainBroken,
+			labelValues...,
+		))
+	}
+
+	if certData.verified != nil {
+		verified := 0.
+		if *certData.verified {
+			verified = 1.
+		}
+
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certVerifiedMetric, certVerifiedHelp, labelKeys, nil),
+			prometheus.GaugeValue,
+			verified,
+			labelValues...,
+		))
+
+		for _, root := range collector.exporter.PinnedRoots {
+			chainsToRoot := 0.
+			if slices.Contains(certData.verifiedRoots, root) {
+				chainsToRoot = 1.
+			}
+
+			rootLabelKeys, rootLabelValues := withLabel(labelKeys, labelValues, rootFingerprintLabel, root)
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				prometheus.NewDesc(certChainsToRootMetric, certChainsToRootHelp, rootLabelKeys, nil),
+				prometheus.GaugeValue,
+				chainsToRoot,
+				rootLabelValues...,
+			))
+		}
+	}
+
+	if collector.exporter.ExposeSANMetrics && isServerCertificate(certData.cert) {
+		hasSAN, invalidForTLSServer := 0., 1.
+		if len(certData.cert.DNSNames) > 0 || len(certData.cert.IPAddresses) > 0 {
+			hasSAN, invalidForTLSServer = 1., 0.
+		}
+
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certHasSANMetric, certHasSANHelp, labelKeys, nil),
+			prometheus.GaugeValue,
+			hasSAN,
+			labelValues...,
+		))
+
+		// clients match server names against DNS and IP SANs only, the CN being ignored since RFC 6125
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certInvalidForTLSServerMetric, certInvalidForTLSServerHelp, labelKeys, nil),
+			prometheus.GaugeValue,
+			invalidForTLSServer,
+			labelValues...,
+		))
+
+		if len(certData.cert.Subject.CommonName) > 0 {
+			cnNotInSAN := 1.
+			if isCNInSANs(certData.cert) {
+				cnNotInSAN = 0.
+			}
+
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				prometheus.NewDesc(certCNNotInSANMetric, certCNNotInSANHelp, labelKeys, nil),
+				prometheus.GaugeValue,
+				cnNotInSAN,
+				labelValues...,
+			))
+		}
+	}
+
+	if collector.exporter.ExposeSANMetrics {
+		sanCount := len(certData.cert.DNSNames) + len(certData.cert.IPAddresses) + len(certData.cert.URIs) + len(certData.cert.EmailAddresses)
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certSANCountMetric, certSANCountHelp, labelKeys, nil),
+			prometheus.GaugeValue,
+			float64(sanCount),
+			labelValues...,
+		))
+	}
+
+	if collector.exporter.ExposeSANExpiryMetrics {
+		expiresIn := time.Until(certData.cert.NotAfter).Seconds()
+		for _, san := range getServiceSANs(certData.cert, collector.exporter.SANExpiryLimit) {
+			sanLabelKeys, sanLabelValues := withLabel(labelKeys, labelValues, sanLabel, san)
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				prometheus.NewDesc(certSANExpiresInMetric, certSANExpiresInHelp, sanLabelKeys, nil),
+				prometheus.GaugeValue,
+				expiresIn,
+				sanLabelValues...,
+			))
+		}
+	}
+
+	if collector.exporter.ExposeEmailMetrics && len(certData.cert.EmailAddresses) > 0 {
+		emailLabelKeys, emailLabelValues := withLabel(labelKeys, labelValues, emailAddressesLabel, joinLabelList(certData.cert.EmailAddresses))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certEmailsMetric, certEmailsHelp, emailLabelKeys, nil),
+			prometheus.GaugeValue,
+			1,
+			emailLabelValues...,
+		))
+	}
+
+	if collector.exporter.ExposeIPSANMetrics && len(certData.cert.IPAddresses) > 0 {
+		ips := []string{}
+		for _, ip := range certData.cert.IPAddresses {
+			ips = append(ips, ip.String())
+		}
+
+		ipLabelKeys, ipLabelValues := withLabel(labelKeys, labelValues, ipAddressesLabel, joinLabelList(ips))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certIPAddressesMetric, certIPAddressesHelp, ipLabelKeys, nil),
+			prometheus.GaugeValue,
+			1,
+			ipLabelValues...,
+		))
+	}
+
+	if collector.exporter.ExposeRevocationMetrics {
+		metrics = append(metrics, collector.getRevocationMetrics(certData.cert, labelKeys, labelValues)...)
+	}
+
+	if collector.exporter.ExposeSHA1Metrics {
+		//nolint:gosec
+		fingerprint := sha1.Sum(certData.cert.Raw)
+		fingerprintLabelKeys, fingerprintLabelValues := withLabel(labelKeys, labelValues, sha1FingerprintLabel, hex.EncodeToString(fingerprint[:]))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certSHA1FingerprintMetric, certSHA1FingerprintHelp, fingerprintLabelKeys, nil),
+			prometheus.GaugeValue,
+			1,
+			fingerprintLabelValues...,
+		))
+	}
+
+	if collector.exporter.hasSignaturePolicy() {
+		compliant := 0.
+		if collector.exporter.isSignatureCompliant(certData.cert) {
+			compliant = 1.
+		}
+
+		signatureLabelKeys, signatureLabelValues := withLabel(labelKeys, labelValues, signatureAlgorithmLabel, certData.cert.SignatureAlgorithm.String())
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certSignatureCompliantMetric, certSignatureCompliantHelp, signatureLabelKeys, nil),
+			prometheus.GaugeValue,
+			compliant,
+			signatureLabelValues...,
+		))
+	}
+
+	// other key types are unaffected
+	if publicKey, isRSA := certData.cert.PublicKey.(*rsa.PublicKey); isRSA && collector.exporter.MinRSAKeySize > 0 {
+		keySize := publicKey.N.BitLen()
+		weak := 0.
+		if keySize < collector.exporter.MinRSAKeySize {
+			weak = 1.
+		}
+
+		keySizeLabelKeys, keySizeLabelValues := withLabel(labelKeys, labelValues, keySizeLabel, strconv.Itoa(keySize))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certWeakRSAKeyMetric, certWeakRSAKeyHelp, keySizeLabelKeys, nil),
+			prometheus.GaugeValue,
+			weak,
+			keySizeLabelValues...,
+		))
+	}
+
+	if len(collector.exporter.PinnedPublicKeys) > 0 {
+		pinned := 0.
+		if collector.exporter.isPublicKeyPinned(certData.cert) {
+			pinned = 1.
+		}
+
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certPublicKeyPinnedMetric, certPublicKeyPinnedHelp, labelKeys, nil),
+			prometheus.GaugeValue,
+			pinned,
+			labelValues...,
+		))
+	}
+
+	if !collector.exporter.FarFutureNotAfter.IsZero() {
+		farFuture := 0.
+		if certData.cert.NotAfter.After(collector.exporter.FarFutureNotAfter) {
+			farFuture = 1.
+		}
+
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certFarFutureMetric, certFarFutureHelp, labelKeys, nil),
+			prometheus.GaugeValue,
+			farFuture,
+			labelValues...,
+		))
+	}
+
+	// only publicly trusted leaves are expected to be logged, private CAs usually don't log their certificates
+	if collector.exporter.ExposeCTMetrics && !certData.cert.IsCA && !isSelfSigned(certData.cert) {
+		// until its first lookup completes, nothing is exposed for a certificate
+		if logged, known := collector.exporter.getCTLogged(certData.cert); known {
+			ctLogged := 0.
+			if logged {
+				ctLogged = 1.
+			}
+
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				prometheus.NewDesc(certCTLoggedMetric, certCTLoggedHelp, labelKeys, nil),
+				prometheus.GaugeValue,
+				ctLogged,
+				labelValues...,
+			))
+		}
+	}
+
+	if hostname := collector.exporter.getExpectedHostname(ref); len(hostname) > 0 && !certData.cert.IsCA {
+		match := 0.
+		if certData.cert.VerifyHostname(hostname) == nil {
+			match = 1.
+		}
+
+		hostnameLabelKeys, hostnameLabelValues := withLabel(labelKeys, labelValues, expectedHostnameLabel, hostname)
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certHostnameMatchMetric, certHostnameMatchHelp, hostnameLabelKeys, nil),
+			prometheus.GaugeValue,
+			match,
+			hostnameLabelValues...,
+		))
+	}
+
+	if collector.exporter.ExposeTypeMetrics {
+		typeLabelKeys, typeLabelValues := withLabel(labelKeys, labelValues, typeLabel, getCertificateType(certData.cert))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certTypeMetric, certTypeHelp, typeLabelKeys, nil),
+			prometheus.GaugeValue,
+			1,
+			typeLabelValues...,
+		))
+	}
+
 	return metrics
 }
+
+// isCNInSANs : Tell if the CN of a certificate is one of its DNS names, compared case-insensitively
+func isCNInSANs(cert *x509.Certificate) bool {
+	for _, name := range cert.DNSNames {
+		if strings.EqualFold(name, cert.Subject.CommonName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isServerCertificate : Tell if a certificate can be used to authenticate a TLS server
+func isServerCertificate(cert *x509.Certificate) bool {
+	if cert.IsCA {
+		return false
+	}
+
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageServerAuth || usage == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getInfoMetricForCertificate : The single metric exposed for a certificate with ConsolidatedMetrics,
+// only attributes taking a bounded set of values being added as labels (e.g. a count of SANs rather than their list)
+func (collector *collector) getInfoMetricForCertificate(certData *parsedCertificate, ref *certificateRef) prometheus.Metric {
+	cert := certData.cert
+	labelKeys, labelValues := collector.exporter.unzipLabels(collector.exporter.getLabels(certData, ref))
+
+	sanCount := len(cert.DNSNames) + len(cert.IPAddresses) + len(cert.EmailAddresses) + len(cert.URIs)
+	labelKeys, labelValues = withLabel(labelKeys, labelValues, sanCountLabel, strconv.Itoa(sanCount))
+	labelKeys, labelValues = withLabel(labelKeys, labelValues, publicKeyAlgorithmLabel, cert.PublicKeyAlgorithm.String())
+	labelKeys, labelValues = withLabel(labelKeys, labelValues, signatureAlgorithmLabel, cert.SignatureAlgorithm.String())
+	labelKeys, labelValues = withLabel(labelKeys, labelValues, isCALabel, strconv.FormatBool(cert.IsCA))
+
+	return prometheus.MustNewConstMetric(
+		prometheus.NewDesc(certInfoMetric, certInfoHelp, labelKeys, nil),
+		prometheus.GaugeValue,
+		float64(cert.NotAfter.Unix()),
+		labelValues...,
+	)
+}
+
+// withLabel : Copy label keys and values with an additional label, which is kept regardless of ExposeLabels
+func withLabel(keys []string, values []string, label labelName, value string) ([]string, []string) {
+	return append(append([]string{}, keys...), label.name), append(append([]string{}, values...), value)
+}
+
+// getServiceSANs : List the distinct DNS names and IP addresses of a certificate, keeping the first limit ones
+// (all of them if limit is 0) so that certificates covering many names don't expose as many series
+func getServiceSANs(cert *x509.Certificate, limit int) []string {
+	output := []string{}
+	seen := map[string]bool{}
+
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+
+	for _, san := range sans {
+		if limit > 0 && len(output) >= limit {
+			break
+		}
+
+		san = strings.ToLower(san)
+		if seen[san] {
+			continue
+		}
+		seen[san] = true
+		output = append(output, san)
+	}
+
+	return output
+}
+
+// getWildcardDomains : List the domains covered by the wildcard DNS names (*.example.com) of a certificate
+func getWildcardDomains(cert *x509.Certificate) []string {
+	output := []string{}
+	for _, name := range cert.DNSNames {
+		domain, isWildcard := strings.CutPrefix(name, "*.")
+		if isWildcard && !slices.Contains(output, domain) {
+			output = append(output, domain)
+		}
+	}
+
+	return output
+}
+
+// getRevocationMetrics : Expose where the revocation status of a certificate can be checked,
+// and flag certificates lacking it; self-signed ones can't be revoked by an issuer and aren't flagged
+func (collector *collector) getRevocationMetrics(cert *x509.Certificate, labelKeys []string, labelValues []string) []prometheus.Metric {
+	metrics := []prometheus.Metric{}
+	hasEndpoints := len(cert.CRLDistributionPoints) > 0 || len(cert.OCSPServer) > 0
+
+	if hasEndpoints {
+		endpointsLabelKeys, endpointsLabelValues := withLabel(labelKeys, labelValues, crlDistributionPointsLabel, joinLabelList(cert.CRLDistributionPoints))
+		endpointsLabelKeys, endpointsLabelValues = withLabel(endpointsLabelKeys, endpointsLabelValues, ocspServersLabel, joinLabelList(cert.OCSPServer))
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certRevocationEndpointsMetric, certRevocationEndpointsHelp, endpointsLabelKeys, nil),
+			prometheus.GaugeValue,
+			1,
+			endpointsLabelValues...,
+		))
+	}
+
+	if !isSelfSigned(cert) {
+		noEndpoints := 0.
+		if !hasEndpoints {
+			noEndpoints = 1.
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(certNoRevocationEndpointsMetric, certNoRevocationEndpointsHelp, labelKeys, nil),
+			prometheus.GaugeValue,
+			noEndpoints,
+			labelValues...,
+		))
+	}
+
+	return metrics
+}
+
+// joinLabelList : Build a label value from the first maxLabelListLength values of a list,
+// to keep cardinality in check with certificates listing many of them
+func joinLabelList(values []string) string {
+	if len(values) > maxLabelListLength {
+		return strings.Join(values[:maxLabelListLength], ",") + ",..."
+	}
+
+	return strings.Join(values, ",")
+}
+
+type issuerCount struct {
+	issuer string
+	count  int
+}
+
+// countCertificatesByIssuer : Group parsed certificates by issuer CN, biggest first,
+// issuers above IssuerCountLimit are collapsed into a single "(other)" count
+func (exporter *Exporter) countCertificatesByIssuer(certRefs []*certificateRef) []issuerCount {
+	counts := map[string]int{}
+	for _, certRef := range certRefs {
+		for _, cert := range certRef.certificates {
+			counts[cert.cert.Issuer.CommonName]++
+		}
+	}
+
+	output := []issuerCount{}
+	for issuer, count := range counts {
+		output = append(output, issuerCount{issuer: issuer, count: count})
+	}
+
+	sort.Slice(output, func(i, j int) bool {
+		if output[i].count != output[j].count {
+			return output[i].count > output[j].count
+		}
+		return output[i].issuer < output[j].issuer
+	})
+
+	if exporter.IssuerCountLimit > 0 && len(output) > exporter.IssuerCountLimit {
+		other := issuerCount{issuer: otherIssuersLabel}
+		for _, count := range output[exporter.IssuerCountLimit:] {
+			other.count += count.count
+		}
+		output = append(output[:exporter.IssuerCountLimit], other)
+	}
+
+	return output
+}
+
+// countDistinctIssuers : Count the issuers of parsed certificates, fingerprinted from their name and the identifier
+// of their key, so that issuers sharing a CN are told apart and renewals of an issuer's certificate aren't counted twice
+func countDistinctIssuers(certRefs []*certificateRef) int {
+	issuers := map[[sha256.Size]byte]bool{}
+	for _, certRef := range certRefs {
+		for _, cert := range certRef.certificates {
+			issuers[sha256.Sum256(append(append([]byte{}, cert.cert.RawIssuer...), cert.cert.AuthorityKeyId...))] = true
+		}
+	}
+
+	return len(issuers)
+}
+
+// getRemainingLifetimeHistogram : Count certificates by days left before their expiry, buckets being cumulative
+func getRemainingLifetimeHistogram(certRefs []*certificateRef, now time.Time) (uint64, float64, map[float64]uint64) {
+	count := uint64(0)
+	sum := 0.
+	buckets := map[float64]uint64{}
+	for _, bound := range remainingLifetimeBuckets {
+		buckets[bound] = 0
+	}
+
+	for _, certRef := range certRefs {
+		for _, cert := range certRef.certificates {
+			days := cert.cert.NotAfter.Sub(now).Hours() / 24
+			count++
+			sum += days
+
+			for _, bound := range remainingLifetimeBuckets {
+				if days <= bound {
+					buckets[bound]++
+				}
+			}
+		}
+	}
+
+	return count, sum, buckets
+}
+
+// getExpiringRatios : Fraction of certificates expiring within each of expiringRatioWindows, 0 when there are none
+func getExpiringRatios(certRefs []*certificateRef, now time.Time) []float64 {
+	total := 0
+	expiring := make([]int, len(expiringRatioWindows))
+	for _, certRef := range certRefs {
+		for _, cert := range certRef.certificates {
+			total++
+			for index, days := range expiringRatioWindows {
+				if cert.cert.NotAfter.Before(now.Add(time.Duration(days) * 24 * time.Hour)) {
+					expiring[index]++
+				}
+			}
+		}
+	}
+
+	output := make([]float64, len(expiringRatioWindows))
+	if total == 0 {
+		return output
+	}
+
+	for index, count := range expiring {
+		output[index] = float64(count) / float64(total)
+	}
+
+	return output
+}
+
+// getMaxFutureNotBefore : How far in the future the latest not before timestamp is, 0 if none is in the future
+func getMaxFutureNotBefore(certRefs []*certificateRef, now time.Time) time.Duration {
+	output := time.Duration(0)
+	for _, certRef := range certRefs {
+		for _, cert := range certRef.certificates {
+			if delta := cert.cert.NotBefore.Sub(now); delta > output {
+				output = delta
+			}
+		}
+	}
+
+	return output
+}
+
+// getPublicKeyCounts : Count the certificates using each public key, keyed by the hash of their SubjectPublicKeyInfo
+func getPublicKeyCounts(certRefs []*certificateRef) map[[sha256.Size]byte]int {
+	output := map[[sha256.Size]byte]int{}
+	for _, certRef := range certRefs {
+		for _, cert := range certRef.certificates {
+			output[sha256.Sum256(cert.cert.RawSubjectPublicKeyInfo)]++
+		}
+	}
+
+	return output
+}
@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	consul "github.com/hashicorp/consul/api"
+)
+
+// ConsulKey : A Consul KV entry containing PEM certificates, optionally base64 encoded
+type ConsulKey struct {
+	Datacenter string
+	Key        string
+	Base64     bool
+}
+
+// consulKVGetter : Subset of the Consul KV client used to read certificates
+type consulKVGetter interface {
+	Get(key string, q *consul.QueryOptions) (*consul.KVPair, *consul.QueryMeta, error)
+}
+
+const consulTimeout = 30 * time.Second
+
+// ParseConsulURL : Split a consul://[datacenter]/key[?encoding=base64] URL,
+// the datacenter of the agent is used when none is given
+func ParseConsulURL(consulURL string) (ConsulKey, error) {
+	parsed, err := url.Parse(consulURL)
+	if err != nil {
+		return ConsulKey{}, err
+	}
+
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if parsed.Scheme != "consul" || len(key) == 0 {
+		return ConsulKey{}, fmt.Errorf("expected consul://[<datacenter>]/<key>[?encoding=base64], got \"%s\"", consulURL)
+	}
+
+	output := ConsulKey{
+		Datacenter: parsed.Host,
+		Key:        key,
+	}
+
+	switch encoding := parsed.Query().Get("encoding"); encoding {
+	case "":
+	case "base64":
+		output.Base64 = true
+	default:
+		return ConsulKey{}, fmt.Errorf("unknown encoding \"%s\" in \"%s\"", encoding, consulURL)
+	}
+
+	return output, nil
+}
+
+func (exporter *Exporter) collectConsulKeys() []*certificateRef {
+	output := []*certificateRef{}
+
+	for index := range exporter.ConsulKeys {
+		key := &exporter.ConsulKeys[index]
+		output = append(output, &certificateRef{
+			path:         fmt.Sprintf("consul://%s/%s", key.Datacenter, key.Key),
+			format:       certificateFormatConsul,
+			consulKey:    key,
+			consulClient: exporter.getConsulClient,
+		})
+	}
+
+	return output
+}
+
+// getConsulClient : Lazily create the Consul client, configured by the standard CONSUL_HTTP_* environment
+// variables (address, token, TLS) unless the address or token file are given in the exporter config
+func (exporter *Exporter) getConsulClient() (consulKVGetter, error) {
+	exporter.consulMutex.Lock()
+	defer exporter.consulMutex.Unlock()
+
+	if exporter.consulClient != nil {
+		return exporter.consulClient, nil
+	}
+
+	config := consul.DefaultConfig()
+	if len(exporter.ConsulAddress) > 0 {
+		config.Address = exporter.ConsulAddress
+	}
+	if len(exporter.ConsulTokenFile) > 0 {
+		config.TokenFile = exporter.ConsulTokenFile
+	}
+
+	client, err := consul.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Consul client: %s", err.Error())
+	}
+
+	exporter.consulClient = client.KV()
+	return exporter.consulClient, nil
+}
+
+func readAndParseConsulKey(ctx context.Context, key *ConsulKey, getClient func() (consulKVGetter, error)) ([]*parsedCertificate, error) {
+	client, err := getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, consulTimeout)
+	defer cancel()
+
+	options := &consul.QueryOptions{Datacenter: key.Datacenter}
+	pair, _, err := client.Get(key.Key, options.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if pair == nil {
+		return nil, fmt.Errorf("key \"%s\" not found", key.Key)
+	}
+
+	contents := pair.Value
+	if key.Base64 {
+		contents, err = decodeEmbeddedCertificates(strings.TrimSpace(string(pair.Value)), YAMLCertFormatBase64, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	certs, err := parsePEM(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*parsedCertificate{}
+	for _, cert := range certs {
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	return output, nil
+}
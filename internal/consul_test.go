@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"testing"
+
+	consul "github.com/hashicorp/consul/api"
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockConsulClient struct {
+	values map[string][]byte
+	err    error
+}
+
+func (client *mockConsulClient) Get(key string, q *consul.QueryOptions) (*consul.KVPair, *consul.QueryMeta, error) {
+	if client.err != nil {
+		return nil, nil, client.err
+	}
+
+	value, found := client.values[q.Datacenter+"/"+key]
+	if !found {
+		return nil, &consul.QueryMeta{}, nil
+	}
+
+	return &consul.KVPair{Key: key, Value: value}, &consul.QueryMeta{}, nil
+}
+
+func TestConsulKV(t *testing.T) {
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+	double, err := os.ReadFile("../test/double.pem")
+	assert.NoError(t, err)
+
+	exporter := &Exporter{
+		ConsulKeys: []ConsulKey{
+			{Datacenter: "dc1", Key: "certs/basic"},
+			{Datacenter: "dc2", Key: "certs/double", Base64: true},
+			{Datacenter: "dc1", Key: "certs/missing"},
+			{Datacenter: "dc1", Key: "certs/not-base64", Base64: true},
+		},
+		ExposeErrorMetrics: true,
+		consulClient: &mockConsulClient{values: map[string][]byte{
+			"dc1/certs/basic":      basic,
+			"dc2/certs/double":     []byte(base64.StdEncoding.EncodeToString(double) + "\n"),
+			"dc1/certs/not-base64": basic,
+		}},
+	}
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		found := map[string]int{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			found[getLabelValue(metric, "consul_datacenter")+"/"+getLabelValue(metric, "consul_key")]++
+			assert.Empty(t, getLabelValue(metric, "filename"))
+		}
+		assert.Equal(t, map[string]int{"dc1/certs/basic": 1, "dc2/certs/double": 2}, found)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 2., errMetric[0].GetGauge().GetValue())
+
+		certErrors := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_error") {
+			certErrors[getLabelValue(metric, "consul_key")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{
+			"certs/basic":      0,
+			"certs/double":     0,
+			"certs/missing":    1,
+			"certs/not-base64": 1,
+		}, certErrors)
+	})
+}
+
+func TestConsulKVUnavailable(t *testing.T) {
+	testRequest(t, &Exporter{
+		ConsulKeys:         []ConsulKey{{Key: "certs/basic"}},
+		ExposeErrorMetrics: true,
+		consulClient:       &mockConsulClient{err: errors.New("Permission denied")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_not_after"), 0)
+
+		certErrors := getMetricsForName(metrics, "x509_cert_error")
+		assert.Len(t, certErrors, 1)
+		assert.Equal(t, 1., certErrors[0].GetGauge().GetValue())
+		assert.Equal(t, "", getLabelValue(certErrors[0], "consul_datacenter"))
+	})
+}
+
+func TestParseConsulURL(t *testing.T) {
+	key, err := ParseConsulURL("consul://dc1/certs/tls.pem")
+	assert.NoError(t, err)
+	assert.Equal(t, ConsulKey{Datacenter: "dc1", Key: "certs/tls.pem"}, key)
+
+	key, err = ParseConsulURL("consul:///certs/tls?encoding=base64")
+	assert.NoError(t, err)
+	assert.Equal(t, ConsulKey{Key: "certs/tls", Base64: true}, key)
+
+	for _, invalid := range []string{"dc1/certs/tls", "consul://dc1", "consul://dc1/", "http://dc1/certs/tls", "consul://dc1/certs/tls?encoding=hex"} {
+		_, err := ParseConsulURL(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
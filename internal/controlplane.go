@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+)
+
+// Control plane components whose serving certificates can be watched
+const (
+	ControlPlaneKubelet   = "kubelet"
+	ControlPlaneAPIServer = "kube-apiserver"
+)
+
+// ControlPlaneEndpoints : TLS endpoints of the local kubelet and API server, labeled with their component;
+// unless insecureSkipVerify is set, presented certificates must chain up to caFile (system roots if empty)
+func ControlPlaneEndpoints(kubeletAddress string, apiServerAddress string, insecureSkipVerify bool, caFile string) ([]TLSEndpoint, error) {
+	var roots *x509.CertPool
+	if !insecureSkipVerify && len(caFile) > 0 {
+		contents, err := readFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read control plane CA: %s", err.Error())
+		}
+
+		certs, err := parsePEM(contents)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse control plane CA \"%s\": %s", caFile, err.Error())
+		}
+
+		roots = x509.NewCertPool()
+		for _, cert := range certs {
+			roots.AddCert(cert)
+		}
+	}
+
+	output := []TLSEndpoint{}
+	for _, component := range []struct{ name, address string }{
+		{ControlPlaneKubelet, kubeletAddress},
+		{ControlPlaneAPIServer, apiServerAddress},
+	} {
+		if len(component.address) == 0 {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(component.address); err != nil {
+			return nil, fmt.Errorf("malformed %s address \"%s\": %s", component.name, component.address, err.Error())
+		}
+
+		output = append(output, TLSEndpoint{
+			Address:   component.address,
+			Component: component.name,
+			Verify:    !insecureSkipVerify,
+			RootCAs:   roots,
+		})
+	}
+
+	return output, nil
+}
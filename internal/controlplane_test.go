@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"net"
+	"path"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControlPlaneEndpoints(t *testing.T) {
+	root := generateTestCertificate(caTemplate("cluster-ca", time.Now().Add(time.Hour)), nil)
+	otherRoot := generateTestCertificate(caTemplate("other-ca", time.Now().Add(time.Hour)), nil)
+	template := leafTemplate("node-1", time.Now().Add(time.Hour))
+	template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	kubelet := startFakeTLSServer(t, generateTestCertificate(template, root), nil)
+
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "ca.pem"), root)
+	writeTestCertificates(path.Join(dir, "other-ca.pem"), otherRoot)
+
+	test := func(insecureSkipVerify bool, caFile string, expectedErrors float64) {
+		endpoints, err := ControlPlaneEndpoints(kubelet.address(), "", insecureSkipVerify, caFile)
+		assert.NoError(t, err)
+		assert.Len(t, endpoints, 1)
+
+		testRequest(t, &Exporter{
+			TLSEndpoints:       endpoints,
+			ExposeErrorMetrics: true,
+		}, func(metrics []model.MetricFamily) {
+			errMetric := getMetricsForName(metrics, "x509_read_errors")
+			assert.Equal(t, expectedErrors, errMetric[0].GetGauge().GetValue())
+
+			certErrors := getMetricsForName(metrics, "x509_cert_error")
+			assert.Len(t, certErrors, 1)
+			assert.Equal(t, "kubelet", getLabelValue(certErrors[0], "control_plane_component"))
+			assert.Equal(t, kubelet.address(), getLabelValue(certErrors[0], "endpoint"))
+
+			foundMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+			if expectedErrors > 0 {
+				assert.Len(t, foundMetrics, 0)
+				return
+			}
+			assert.Len(t, foundMetrics, 1)
+			assert.Equal(t, "node-1", getLabelValue(foundMetrics[0], "subject_CN"))
+			assert.Equal(t, "kubelet", getLabelValue(foundMetrics[0], "control_plane_component"))
+		})
+	}
+
+	// presented certificates aren't verified by default
+	test(true, "", 0)
+	test(false, path.Join(dir, "ca.pem"), 0)
+	test(false, path.Join(dir, "other-ca.pem"), 1)
+}
+
+func TestControlPlaneEndpointsErrors(t *testing.T) {
+	endpoints, err := ControlPlaneEndpoints("localhost:10250", "localhost:6443", true, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []TLSEndpoint{
+		{Address: "localhost:10250", Component: "kubelet"},
+		{Address: "localhost:6443", Component: "kube-apiserver"},
+	}, endpoints)
+
+	_, err = ControlPlaneEndpoints("localhost", "", true, "")
+	assert.Error(t, err)
+
+	_, err = ControlPlaneEndpoints("localhost:10250", "", false, "../test/does-not-exist.pem")
+	assert.Error(t, err)
+
+	_, err = ControlPlaneEndpoints("localhost:10250", "", false, "../test/corrupted.pem")
+	assert.Error(t, err)
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultCTLookupURL : crt.sh, which searches the certificates of all known CT logs by SHA-256 fingerprint
+const DefaultCTLookupURL = "https://crt.sh/"
+
+const ctLookupTimeout = 30 * time.Second
+
+const maxCTResponseSize = 1 << 20
+
+// ctLookupConcurrency : Maximum number of lookups running at once, CT search services being rate limited
+const ctLookupConcurrency = 4
+
+const (
+	// ctLoggedRecheckDelay : Logged certificates stay logged, they're only checked again in case the cache grows stale
+	ctLoggedRecheckDelay = 24 * time.Hour
+	// ctNotLoggedRecheckDelay : Certificates may be logged some time after being issued
+	ctNotLoggedRecheckDelay = time.Hour
+	ctFailureRetryDelay     = 5 * time.Minute
+)
+
+// ctLookup : Last known Certificate Transparency status of a certificate, known being false until a lookup succeeds
+type ctLookup struct {
+	logged    bool
+	known     bool
+	pending   bool
+	nextCheck time.Time
+}
+
+// getCTLogged : Return the cached CT status of a certificate, starting a background lookup when it's missing
+// or outdated so that scrapes never wait for the CT search service
+func (exporter *Exporter) getCTLogged(cert *x509.Certificate) (logged bool, known bool) {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	exporter.ctMutex.Lock()
+	defer exporter.ctMutex.Unlock()
+
+	if exporter.ctLookups == nil {
+		exporter.ctLookups = map[[sha256.Size]byte]*ctLookup{}
+		exporter.ctSemaphore = make(chan struct{}, ctLookupConcurrency)
+	}
+
+	lookup, found := exporter.ctLookups[fingerprint]
+	if !found {
+		lookup = &ctLookup{}
+		exporter.ctLookups[fingerprint] = lookup
+	}
+
+	if !lookup.pending && !time.Now().Before(lookup.nextCheck) {
+		lookup.pending = true
+		go exporter.refreshCTLookup(fingerprint, lookup)
+	}
+
+	return lookup.logged, lookup.known
+}
+
+func (exporter *Exporter) refreshCTLookup(fingerprint [sha256.Size]byte, lookup *ctLookup) {
+	exporter.ctSemaphore <- struct{}{}
+	logged, err := searchCTLogs(exporter.CTLookupURL, fingerprint)
+	<-exporter.ctSemaphore
+
+	exporter.ctMutex.Lock()
+	defer exporter.ctMutex.Unlock()
+
+	lookup.pending = false
+	if err != nil {
+		// the last known status stays exposed
+		log.Warnf("failed to look up certificate %s in CT logs: %s", hex.EncodeToString(fingerprint[:]), err.Error())
+		lookup.nextCheck = time.Now().Add(ctFailureRetryDelay)
+		return
+	}
+
+	lookup.logged = logged
+	lookup.known = true
+	if logged {
+		lookup.nextCheck = time.Now().Add(ctLoggedRecheckDelay)
+	} else {
+		lookup.nextCheck = time.Now().Add(ctNotLoggedRecheckDelay)
+	}
+}
+
+// searchCTLogs : Query a crt.sh compatible search API, which returns a JSON list of the log entries
+// matching a fingerprint
+func searchCTLogs(lookupURL string, fingerprint [sha256.Size]byte) (bool, error) {
+	if len(lookupURL) == 0 {
+		lookupURL = DefaultCTLookupURL
+	}
+
+	endpoint, err := url.Parse(lookupURL)
+	if err != nil {
+		return false, err
+	}
+	query := endpoint.Query()
+	query.Set("q", hex.EncodeToString(fingerprint[:]))
+	query.Set("output", "json")
+	endpoint.RawQuery = query.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ctLookupTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return false, err
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	entries := []json.RawMessage{}
+	err = json.NewDecoder(io.LimitReader(response.Body, maxCTResponseSize)).Decode(&entries)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode response: %s", err.Error())
+	}
+
+	return len(entries) > 0, nil
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCTSearch : Answers crt.sh style searches, with an entry for logged fingerprints and a failure for broken ones
+type fakeCTSearch struct {
+	mutex   sync.Mutex
+	logged  map[string]bool
+	broken  map[string]bool
+	queries map[string]int
+}
+
+func (search *fakeCTSearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	search.mutex.Lock()
+	defer search.mutex.Unlock()
+
+	fingerprint := r.URL.Query().Get("q")
+	search.queries[fingerprint]++
+
+	switch {
+	case r.URL.Query().Get("output") != "json":
+		http.Error(w, "expected json output", http.StatusBadRequest)
+	case search.broken[fingerprint]:
+		http.Error(w, "overloaded", http.StatusBadGateway)
+	case search.logged[fingerprint]:
+		//nolint:errcheck
+		w.Write([]byte(`[{"id": 1, "serial_number": "01"}]`))
+	default:
+		//nolint:errcheck
+		w.Write([]byte(`[]`))
+	}
+}
+
+func TestCTLogged(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(24 * time.Hour)
+	ca := generateTestCertificate(caTemplate("ca", notAfter), nil)
+
+	fingerprints := map[string]string{}
+	for _, name := range []string{"logged", "unlogged", "broken"} {
+		leaf := generateTestCertificate(leafTemplate(name, notAfter), ca)
+		writeTestCertificates(path.Join(dir, name+".pem"), leaf)
+		fingerprint := sha256.Sum256(leaf.cert.Raw)
+		fingerprints[name] = hex.EncodeToString(fingerprint[:])
+	}
+	// self-signed certificates aren't looked up
+	writeTestCertificates(path.Join(dir, "self-signed.pem"), generateTestCertificate(leafTemplate("self-signed", notAfter), nil))
+
+	search := &fakeCTSearch{
+		logged:  map[string]bool{fingerprints["logged"]: true},
+		broken:  map[string]bool{fingerprints["broken"]: true},
+		queries: map[string]int{},
+	}
+	server := httptest.NewServer(search)
+	defer server.Close()
+
+	exporter := &Exporter{
+		Files:           []string{path.Join(dir, "*.pem")},
+		ExposeCTMetrics: true,
+		CTLookupURL:     server.URL,
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{exporter: exporter})
+
+	getLogged := func() map[string]float64 {
+		families, err := registry.Gather()
+		assert.NoError(t, err)
+
+		logged := map[string]float64{}
+		for _, family := range families {
+			if family.GetName() != "x509_cert_ct_logged" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				logged[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+			}
+		}
+		return logged
+	}
+
+	// lookups run in the background, failing ones don't expose anything
+	assert.Eventually(t, func() bool {
+		return len(getLogged()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]float64{"logged": 1, "unlogged": 0}, getLogged())
+
+	search.mutex.Lock()
+	assert.Equal(t, map[string]int{fingerprints["logged"]: 1, fingerprints["unlogged"]: 1, fingerprints["broken"]: 1}, search.queries)
+	search.mutex.Unlock()
+
+	exporter.ExposeCTMetrics = false
+	assert.Len(t, getLogged(), 0)
+}
+
+func TestCTLookupUnreachable(t *testing.T) {
+	_, err := searchCTLogs("http://127.0.0.1:1/", sha256.Sum256([]byte("certificate")))
+	assert.Error(t, err)
+}
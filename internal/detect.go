@@ -0,0 +1,303 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// detectedFormat : Encoding of a certificate file, as sniffed from its contents
+type detectedFormat string
+
+const (
+	detectedFormatPEM    detectedFormat = "pem"
+	detectedFormatDER    detectedFormat = "der"
+	detectedFormatPKCS12 detectedFormat = "pkcs12"
+	detectedFormatJKS    detectedFormat = "jks"
+	detectedFormatGzip   detectedFormat = "gzip"
+	detectedFormatZip    detectedFormat = "zip"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+	jksMagic  = []byte{0xfe, 0xed, 0xfe, 0xed}
+)
+
+// maxDecompressedSize : Upper bound of the data read from a compressed file, or from each file of an archive
+const maxDecompressedSize = 16 << 20
+
+// maxArchiveDepth : How many archives may be nested, e.g. a gzipped PEM file in a zip archive
+const maxArchiveDepth = 2
+
+// detectFormat : Sniff the encoding of a certificate file, DER certificates and PKCS#12 bundles
+// both being DER SEQUENCEs, they're told apart by trying to parse them
+func detectFormat(data []byte) (detectedFormat, error) {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		return detectedFormatGzip, nil
+	case bytes.HasPrefix(data, zipMagic):
+		return detectedFormatZip, nil
+	case bytes.HasPrefix(data, jksMagic):
+		return detectedFormatJKS, nil
+	case bytes.Contains(data, []byte("-----BEGIN ")):
+		return detectedFormatPEM, nil
+	case len(data) > 0 && data[0] == 0x30:
+		if _, err := x509.ParseCertificates(data); err == nil {
+			return detectedFormatDER, nil
+		}
+		if isPKCS12(data) {
+			return detectedFormatPKCS12, nil
+		}
+		return "", errors.New("DER data is neither a certificate nor a PKCS#12 bundle")
+	}
+
+	return "", errors.New("unrecognized format, expected PEM, DER, PKCS#12, JKS, gzip or zip")
+}
+
+// isPKCS12 : Tell if DER data is a PKCS#12 bundle, even if it can't be decrypted
+func isPKCS12(data []byte) bool {
+	_, err := decodePKCS12(data)
+	return err == nil || errors.Is(err, pkcs12.ErrIncorrectPassword)
+}
+
+// decodePKCS12 : Read the certificates of a password-less PKCS#12 trust store or key bundle
+func decodePKCS12(data []byte) ([]*x509.Certificate, error) {
+	certs, err := pkcs12.DecodeTrustStore(data, "")
+	if err == nil || errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		return certs, err
+	}
+
+	// bundles holding a key aren't trust stores
+	_, leaf, chain, err := pkcs12.DecodeChain(data, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*x509.Certificate{leaf}, chain...), nil
+}
+
+func readAndParseAutoFile(filePath string, skipped skippedPEMBlocks) ([]*parsedCertificate, error) {
+	contents, err := readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseAutoDetectedCertificates(contents, skipped)
+}
+
+// parseAutoDetectedCertificates : Parse data of any detected format, each certificate remembering that format
+func parseAutoDetectedCertificates(data []byte, skipped skippedPEMBlocks) ([]*parsedCertificate, error) {
+	format, err := detectFormat(data)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := parseDetected(data, format, 0, skipped)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*parsedCertificate{}
+	for _, cert := range certs {
+		output = append(output, &parsedCertificate{cert: cert, detectedFormat: format})
+	}
+
+	return output, nil
+}
+
+// parseAutoDetected : Dispatch data to the reader of its detected format
+func parseAutoDetected(data []byte, depth int, skipped skippedPEMBlocks) ([]*x509.Certificate, error) {
+	format, err := detectFormat(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDetected(data, format, depth, skipped)
+}
+
+func parseDetected(data []byte, format detectedFormat, depth int, skipped skippedPEMBlocks) ([]*x509.Certificate, error) {
+	if (format == detectedFormatGzip || format == detectedFormatZip) && depth >= maxArchiveDepth {
+		return nil, fmt.Errorf("more than %d nested archives", maxArchiveDepth)
+	}
+
+	switch format {
+	case detectedFormatPEM:
+		return parsePEMBlocks(data, skipped)
+	case detectedFormatDER:
+		return x509.ParseCertificates(data)
+	case detectedFormatPKCS12:
+		certs, err := decodePKCS12(data)
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, errors.New("password protected PKCS#12 bundles aren't supported")
+		}
+		return certs, err
+	case detectedFormatJKS:
+		return parseJKS(data)
+	case detectedFormatGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		decompressed, err := readLimited(reader)
+		if err != nil {
+			return nil, err
+		}
+		return parseAutoDetected(decompressed, depth+1, skipped)
+	case detectedFormatZip:
+		return parseZip(data, depth, skipped)
+	}
+
+	return nil, nil
+}
+
+// parseZip : Gather the certificates of every file of a zip archive, files of an unrecognized format being skipped
+func parseZip(data []byte, depth int, skipped skippedPEMBlocks) ([]*x509.Certificate, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*x509.Certificate{}
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.Name, err.Error())
+		}
+		contents, err := readLimited(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.Name, err.Error())
+		}
+
+		if _, err := detectFormat(contents); err != nil {
+			continue
+		}
+
+		certs, err := parseAutoDetected(contents, depth+1, skipped)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.Name, err.Error())
+		}
+		output = append(output, certs...)
+	}
+
+	return output, nil
+}
+
+func readLimited(reader io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, maxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDecompressedSize {
+		return nil, fmt.Errorf("decompressed data is larger than %d bytes", maxDecompressedSize)
+	}
+
+	return data, nil
+}
+
+// parseJKS : Read the certificates of a Java KeyStore, from both trusted certificate entries and the chains
+// of private key entries (whose keys are skipped undecrypted); the keystore's integrity isn't checked
+func parseJKS(data []byte) ([]*x509.Certificate, error) {
+	reader := &jksReader{data: data[len(jksMagic):]}
+
+	version := reader.uint32()
+	if reader.err == nil && version != 1 && version != 2 {
+		return nil, fmt.Errorf("unsupported JKS version %d", version)
+	}
+
+	output := []*x509.Certificate{}
+	count := reader.uint32()
+	for index := uint32(0); index < count && reader.err == nil; index++ {
+		tag := reader.uint32()
+		reader.utf()    // alias
+		reader.bytes(8) // creation timestamp
+
+		certCount := uint32(1)
+		switch tag {
+		case 1:
+			reader.bytes(int(reader.uint32())) // encrypted private key
+			certCount = reader.uint32()
+		case 2:
+		default:
+			return nil, fmt.Errorf("unsupported JKS entry type %d", tag)
+		}
+
+		for certIndex := uint32(0); certIndex < certCount && reader.err == nil; certIndex++ {
+			certType := ""
+			if version == 2 {
+				certType = reader.utf()
+			}
+			der := reader.bytes(int(reader.uint32()))
+			if reader.err != nil {
+				break
+			}
+
+			if len(certType) > 0 && !strings.EqualFold(certType, "X.509") {
+				continue
+			}
+
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("tried to parse malformed x509 data, %s", err.Error())
+			}
+			output = append(output, cert)
+		}
+	}
+
+	if reader.err != nil {
+		return nil, fmt.Errorf("truncated JKS keystore")
+	}
+
+	return output, nil
+}
+
+// jksReader : Big-endian reader of the JKS structures, remembering the first out-of-bounds read
+type jksReader struct {
+	data []byte
+	err  error
+}
+
+func (reader *jksReader) bytes(length int) []byte {
+	if reader.err != nil {
+		return nil
+	}
+	if length < 0 || length > len(reader.data) {
+		reader.err = io.ErrUnexpectedEOF
+		return nil
+	}
+
+	output := reader.data[:length]
+	reader.data = reader.data[length:]
+	return output
+}
+
+func (reader *jksReader) uint32() uint32 {
+	data := reader.bytes(4)
+	if data == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(data)
+}
+
+func (reader *jksReader) utf() string {
+	data := reader.bytes(2)
+	if data == nil {
+		return ""
+	}
+	return string(reader.bytes(int(binary.BigEndian.Uint16(data))))
+}
@@ -0,0 +1,298 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// encodeJKS : Build a version 2 JKS keystore, with a private key entry (holding a fake encrypted key) for chain
+// and a trusted certificate entry for each of trusted
+func encodeJKS(chain []*x509.Certificate, trusted []*x509.Certificate) []byte {
+	out := &bytes.Buffer{}
+	writeUTF := func(value string) {
+		//nolint:errcheck
+		binary.Write(out, binary.BigEndian, uint16(len(value)))
+		out.WriteString(value)
+	}
+	writeCert := func(cert *x509.Certificate) {
+		writeUTF("X.509")
+		//nolint:errcheck
+		binary.Write(out, binary.BigEndian, uint32(len(cert.Raw)))
+		out.Write(cert.Raw)
+	}
+
+	out.Write(jksMagic)
+	//nolint:errcheck
+	binary.Write(out, binary.BigEndian, []uint32{2, uint32(len(trusted) + 1)})
+
+	//nolint:errcheck
+	binary.Write(out, binary.BigEndian, uint32(1))
+	writeUTF("server")
+	out.Write(make([]byte, 8))
+	//nolint:errcheck
+	binary.Write(out, binary.BigEndian, []uint32{4, 0xdeadbeef, uint32(len(chain))})
+	for _, cert := range chain {
+		writeCert(cert)
+	}
+
+	for _, cert := range trusted {
+		//nolint:errcheck
+		binary.Write(out, binary.BigEndian, uint32(2))
+		writeUTF(cert.Subject.CommonName)
+		out.Write(make([]byte, 8))
+		writeCert(cert)
+	}
+
+	// keystore digest
+	out.Write(make([]byte, 20))
+	return out.Bytes()
+}
+
+func encodeGzip(data []byte) []byte {
+	out := &bytes.Buffer{}
+	writer := gzip.NewWriter(out)
+	//nolint:errcheck
+	writer.Write(data)
+	writer.Close()
+	return out.Bytes()
+}
+
+func encodeZip(files map[string][]byte) []byte {
+	out := &bytes.Buffer{}
+	writer := zip.NewWriter(out)
+	for name, data := range files {
+		file, _ := writer.Create(name)
+		//nolint:errcheck
+		file.Write(data)
+	}
+	writer.Close()
+	return out.Bytes()
+}
+
+func TestDetectFormat(t *testing.T) {
+	notAfter := time.Now().Add(24 * time.Hour)
+	ca := generateTestCertificate(caTemplate("ca", notAfter), nil)
+	leaf := generateTestCertificate(leafTemplate("leaf", notAfter), ca)
+
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.cert.Raw})
+	trustStore, err := pkcs12.EncodeTrustStore(rand.Reader, []*x509.Certificate{ca.cert}, "")
+	assert.NoError(t, err)
+	keyStore, err := pkcs12.Encode(rand.Reader, leaf.key, leaf.cert, []*x509.Certificate{ca.cert}, "")
+	assert.NoError(t, err)
+	protectedStore, err := pkcs12.EncodeTrustStore(rand.Reader, []*x509.Certificate{ca.cert}, "secret")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		format   detectedFormat
+		expected []string
+	}{
+		{"pem", pemData, detectedFormatPEM, []string{"leaf"}},
+		{"der", append(append([]byte{}, leaf.cert.Raw...), ca.cert.Raw...), detectedFormatDER, []string{"leaf", "ca"}},
+		{"pkcs12 trust store", trustStore, detectedFormatPKCS12, []string{"ca"}},
+		{"pkcs12 with key", keyStore, detectedFormatPKCS12, []string{"leaf", "ca"}},
+		{"jks", encodeJKS([]*x509.Certificate{leaf.cert, ca.cert}, []*x509.Certificate{ca.cert}), detectedFormatJKS, []string{"leaf", "ca", "ca"}},
+		{"gzip", encodeGzip(leaf.cert.Raw), detectedFormatGzip, []string{"leaf"}},
+		{"zip", encodeZip(map[string][]byte{"tls.crt": pemData, "README": []byte("hello"), "ca.p12.gz": encodeGzip(trustStore)}), detectedFormatZip, []string{"leaf", "ca"}},
+	}
+
+	for _, test := range tests {
+		format, err := detectFormat(test.data)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.format, format, test.name)
+
+		certs, err := parseAutoDetected(test.data, 0, nil)
+		assert.NoError(t, err, test.name)
+		names := []string{}
+		for _, cert := range certs {
+			names = append(names, cert.Subject.CommonName)
+		}
+		assert.ElementsMatch(t, test.expected, names, test.name)
+	}
+
+	format, err := detectFormat(protectedStore)
+	assert.NoError(t, err)
+	assert.Equal(t, detectedFormatPKCS12, format)
+	_, err = parseAutoDetected(protectedStore, 0, nil)
+	assert.ErrorContains(t, err, "password protected")
+
+	for name, data := range map[string][]byte{
+		"empty":        {},
+		"text":         []byte("hello"),
+		"der garbage":  {0x30, 0x03, 0x02, 0x01, 0x01},
+		"nested gzips": encodeGzip(encodeGzip(encodeGzip(pemData))),
+		"truncated":    encodeJKS([]*x509.Certificate{leaf.cert}, nil)[:40],
+	} {
+		_, err := parseAutoDetected(data, 0, nil)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestAutoFiles(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(24 * time.Hour)
+	ca := generateTestCertificate(caTemplate("ca", notAfter), nil)
+	leaf := generateTestCertificate(leafTemplate("leaf", notAfter), ca)
+
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+	trustStore, err := pkcs12.EncodeTrustStore(rand.Reader, []*x509.Certificate{ca.cert}, "")
+	assert.NoError(t, err)
+
+	for name, data := range map[string][]byte{
+		"basic.pem":   basic,
+		"leaf.der":    leaf.cert.Raw,
+		"ca.p12":      trustStore,
+		"notes.txt":   []byte("not a certificate"),
+		"bundle.jks":  encodeJKS(nil, []*x509.Certificate{ca.cert}),
+		"leaf.der.gz": encodeGzip(leaf.cert.Raw),
+	} {
+		assert.NoError(t, os.WriteFile(path.Join(dir, name), data, 0644))
+	}
+
+	testRequest(t, &Exporter{
+		AutoFiles:          []string{path.Join(dir, "*")},
+		ExposeErrorMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		files := map[string]string{}
+		for _, metric := range foundMetrics {
+			files[getLabelValue(metric, "filename")] = getLabelValue(metric, "subject_CN")
+		}
+		assert.Equal(t, map[string]string{
+			"basic.pem":   "kubernetes",
+			"leaf.der":    "leaf",
+			"ca.p12":      "ca",
+			"bundle.jks":  "ca",
+			"leaf.der.gz": "leaf",
+		}, files)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+// writeTestConfigMapVolume : Lay out a directory the way the kubelet mounts a ConfigMap, binaryData keys
+// being written decoded like the data ones
+func writeTestConfigMapVolume(t *testing.T, dir string, keys map[string][]byte) {
+	version := "..2026_10_14_08_00_00.1234567890"
+	assert.NoError(t, os.Mkdir(path.Join(dir, version), 0755))
+	assert.NoError(t, os.Symlink(version, path.Join(dir, "..data")))
+
+	for key, data := range keys {
+		assert.NoError(t, os.WriteFile(path.Join(dir, version, key), data, 0644))
+		assert.NoError(t, os.Symlink(path.Join("..data", key), path.Join(dir, key)))
+	}
+}
+
+func TestAutoFilesConfigMapVolume(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(24 * time.Hour)
+	ca := generateTestCertificate(caTemplate("ca", notAfter), nil)
+	leaf := generateTestCertificate(leafTemplate("leaf", notAfter), ca)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+
+	writeTestConfigMapVolume(t, dir, map[string][]byte{
+		"ca.crt":   caPEM,
+		"leaf.der": leaf.cert.Raw,
+	})
+
+	for _, pattern := range []string{"*", "**"} {
+		testRequest(t, &Exporter{
+			AutoFiles: []string{path.Join(dir, pattern)},
+		}, func(metrics []model.MetricFamily) {
+			// keys are only exported once, through their visible symlink
+			files := []string{}
+			for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+				files = append(files, getLabelValue(metric, "filename")+"/"+getLabelValue(metric, "subject_CN"))
+			}
+			assert.ElementsMatch(t, []string{"ca.crt/ca", "leaf.der/leaf"}, files, pattern)
+
+			errMetric := getMetricsForName(metrics, "x509_read_errors")
+			assert.Equal(t, 0., errMetric[0].GetGauge().GetValue(), pattern)
+		})
+	}
+
+	// hidden entries can still be watched explicitly
+	testRequest(t, &Exporter{
+		AutoFiles: []string{path.Join(dir, "..data", "*")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_not_after"), 2)
+	})
+}
+
+func TestFormatLabel(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(24 * time.Hour)
+	ca := generateTestCertificate(caTemplate("ca", notAfter), nil)
+	leaf := generateTestCertificate(leafTemplate("leaf", notAfter), ca)
+
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+	trustStore, err := pkcs12.EncodeTrustStore(rand.Reader, []*x509.Certificate{ca.cert}, "")
+	assert.NoError(t, err)
+
+	autoDir := path.Join(dir, "auto")
+	assert.NoError(t, os.Mkdir(autoDir, 0755))
+	for name, data := range map[string][]byte{
+		"auto.pem":    basic,
+		"leaf.der":    leaf.cert.Raw,
+		"ca.p12":      trustStore,
+		"bundle.jks":  encodeJKS(nil, []*x509.Certificate{ca.cert}),
+		"leaf.der.gz": encodeGzip(leaf.cert.Raw),
+	} {
+		assert.NoError(t, os.WriteFile(path.Join(autoDir, name), data, 0644))
+	}
+
+	exporter := newFakeKubeExporter(newFakeSecretsClient(t))
+	exporter.Files = []string{"../test/basic.pem"}
+	exporter.YAMLs = []string{"../test/yaml-embedded.conf"}
+	exporter.YAMLPaths = DefaultYamlPaths
+	exporter.AutoFiles = []string{path.Join(autoDir, "*")}
+	exporter.ExposeFormatLabel = true
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		formats := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			source := getLabelValue(metric, "filename")
+			if len(source) == 0 {
+				source = "secret/" + getLabelValue(metric, "secret_name")
+			}
+			formats[source] = getLabelValue(metric, "format")
+		}
+
+		assert.Equal(t, map[string]string{
+			"basic.pem":          "pem",
+			"yaml-embedded.conf": "yaml",
+			"auto.pem":           "pem",
+			"leaf.der":           "der",
+			"ca.p12":             "pkcs12",
+			"bundle.jks":         "jks",
+			"leaf.der.gz":        "gzip",
+			"secret/tls":         "kube-secret",
+			"secret/opaque":      "kube-secret",
+		}, formats)
+	})
+
+	// not exposed by default
+	exporter.ExposeFormatLabel = false
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			assert.Empty(t, getLabelValue(metric, "format"))
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DotenvCertRef : Variable of a dotenv file holding certificates, encoded the same way as in YAML files
+type DotenvCertRef struct {
+	Key    string
+	Format YAMLCertFormat
+}
+
+var dotenvKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// ParseDotenvCertRef : Parse a key[:base64|base64-der|hex|file] reference, base64 being the default format
+func ParseDotenvCertRef(spec string) (DotenvCertRef, error) {
+	ref := DotenvCertRef{Format: YAMLCertFormatBase64}
+
+	keyAndFormat := strings.SplitN(spec, ":", 2)
+	if len(keyAndFormat) == 2 {
+		switch keyAndFormat[1] {
+		case "base64":
+			ref.Format = YAMLCertFormatBase64
+		case "file":
+			ref.Format = YAMLCertFormatFile
+		case "hex":
+			ref.Format = YAMLCertFormatHex
+		case "base64-der":
+			ref.Format = YAMLCertFormatBase64DER
+		default:
+			return DotenvCertRef{}, fmt.Errorf("unknown format \"%s\" in \"%s\"", keyAndFormat[1], spec)
+		}
+	}
+
+	ref.Key = keyAndFormat[0]
+	if !dotenvKeyPattern.MatchString(ref.Key) {
+		return DotenvCertRef{}, fmt.Errorf("expected <key>[:base64|base64-der|hex|file], got \"%s\"", spec)
+	}
+
+	return ref, nil
+}
+
+func readAndParseDotenvFile(filePath string, dotenvKeys []DotenvCertRef) ([]*parsedCertificate, error) {
+	contents, err := readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := parseDotenv(string(contents))
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*parsedCertificate{}
+	for _, ref := range dotenvKeys {
+		value, found := values[ref.Key]
+		if !found {
+			continue
+		}
+
+		decodedCerts, err := decodeEmbeddedCertificates(value, ref.Format, filePath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", ref.Key, err.Error())
+		}
+
+		certs, err := parsePEM(decodedCerts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", ref.Key, err.Error())
+		}
+
+		for certIndex, cert := range certs {
+			displayName := ref.Key
+			if len(certs) > 1 {
+				displayName = fmt.Sprintf("%s(%d)", displayName, certIndex)
+			}
+			output = append(output, &parsedCertificate{
+				cert:   cert,
+				userID: displayName,
+			})
+		}
+	}
+
+	return output, nil
+}
+
+// parseDotenv : Read the KEY=value lines of a dotenv file, optionally prefixed with "export".
+// Single quoted values are literal, double quoted ones support \n, \r, \t, \" and \\ escapes,
+// and both may span multiple lines; unquoted values end at a " #" comment. The last definition wins.
+func parseDotenv(contents string) (map[string]string, error) {
+	output := map[string]string{}
+	rest := strings.ReplaceAll(contents, "\r\n", "\n")
+	lineNumber := 0
+
+	for len(rest) > 0 {
+		var line string
+		line, rest, _ = strings.Cut(rest, "\n")
+		lineNumber++
+
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !found || !dotenvKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNumber)
+		}
+
+		value = strings.TrimLeft(value, " \t")
+		if len(value) == 0 || (value[0] != '\'' && value[0] != '"') {
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = value[:comment]
+			}
+			output[key] = strings.TrimSpace(value)
+			continue
+		}
+
+		// quoted values may go on over the next lines
+		quote := value[0]
+		value = value[1:] + "\n" + rest
+		end := findClosingQuote(value, quote)
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: unterminated quoted value of %s", lineNumber, key)
+		}
+
+		lineNumber += strings.Count(value[:end], "\n")
+		rest = value[end+1:]
+		if remainder, _, _ := strings.Cut(rest, "\n"); len(strings.TrimSpace(remainder)) > 0 && !strings.HasPrefix(strings.TrimSpace(remainder), "#") {
+			return nil, fmt.Errorf("line %d: unexpected characters after the quoted value of %s", lineNumber, key)
+		}
+		_, rest, _ = strings.Cut(rest, "\n")
+
+		if quote == '"' {
+			output[key] = dotenvEscapes.Replace(value[:end])
+		} else {
+			output[key] = value[:end]
+		}
+	}
+
+	return output, nil
+}
+
+// findClosingQuote : Index of the quote ending a value, skipping escaped ones in double quoted values
+func findClosingQuote(value string, quote byte) int {
+	for index := 0; index < len(value); index++ {
+		switch {
+		case value[index] == '\\' && quote == '"':
+			index++
+		case value[index] == quote:
+			return index
+		}
+	}
+
+	return -1
+}
+
+var dotenvEscapes = strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`)
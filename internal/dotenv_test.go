@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"testing"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDotenvFile(t *testing.T) {
+	testRequest(t, &Exporter{
+		DotenvFiles: []string{"../test/dotenv/app.env"},
+		DotenvKeys: []DotenvCertRef{
+			{Key: "TLS_CERT", Format: YAMLCertFormatBase64},
+			{Key: "QUOTED_CERT", Format: YAMLCertFormatBase64},
+			{Key: "MULTILINE_CERT", Format: YAMLCertFormatBase64},
+			{Key: "CA_FILE", Format: YAMLCertFormatFile},
+			{Key: "MISSING_CERT", Format: YAMLCertFormatBase64},
+		},
+	}, func(metrics []model.MetricFamily) {
+		keys := []string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			assert.Equal(t, "app.env", getLabelValue(metric, "filename"))
+			assert.Equal(t, "kubernetes", getLabelValue(metric, "subject_CN"))
+			keys = append(keys, getLabelValue(metric, "embedded_key"))
+		}
+		assert.ElementsMatch(t, []string{"TLS_CERT", "QUOTED_CERT", "MULTILINE_CERT", "CA_FILE"}, keys)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+func TestParseDotenv(t *testing.T) {
+	values, err := parseDotenv("# comment\nexport A=1\nB = two words # comment\r\nC='single\n# quoted'\nD=\"tab\\tquote\\\"\\nline\" # comment\nE=\nA=override\n")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"A": "override",
+		"B": "two words",
+		"C": "single\n# quoted",
+		"D": "tab\tquote\"\nline",
+		"E": "",
+	}, values)
+
+	for _, invalid := range []string{"no separator", "1A=value", "A=\"unterminated\nB=2", "A='quoted' trailing"} {
+		_, err := parseDotenv(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseDotenvCertRef(t *testing.T) {
+	ref, err := ParseDotenvCertRef("TLS_CERT")
+	assert.NoError(t, err)
+	assert.Equal(t, DotenvCertRef{Key: "TLS_CERT", Format: YAMLCertFormatBase64}, ref)
+
+	ref, err = ParseDotenvCertRef("CA_FILE:file")
+	assert.NoError(t, err)
+	assert.Equal(t, DotenvCertRef{Key: "CA_FILE", Format: YAMLCertFormatFile}, ref)
+
+	for _, invalid := range []string{"", "1CERT", "CERT:pem", "MY-CERT"} {
+		_, err := ParseDotenvCertRef(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
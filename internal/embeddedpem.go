@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// embeddedPEMBlock : A certificate block anywhere in a text, even indented, quoted or within a line
+var embeddedPEMBlock = regexp.MustCompile(`(?s)-----BEGIN CERTIFICATE-----(.*?)-----END CERTIFICATE-----`)
+
+// embeddedPEMEscapes : Escape sequences of quoted strings, removed before anything but base64 characters
+var embeddedPEMEscapes = strings.NewReplacer(`\n`, "", `\r`, "", `\t`, "")
+
+func readAndParseEmbeddedPEMFile(filePath string) ([]*parsedCertificate, error) {
+	contents, err := readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseEmbeddedPEM(contents)
+}
+
+// parseEmbeddedPEM : Leniently extract every certificate block of an arbitrary text (e.g. an OpenSSL config),
+// whatever surrounds it; indentation, comment markers, quotes and escaped newlines are dropped from the bodies
+func parseEmbeddedPEM(data []byte) ([]*parsedCertificate, error) {
+	output := []*parsedCertificate{}
+
+	for index, match := range embeddedPEMBlock.FindAllSubmatch(data, -1) {
+		body := strings.Map(func(r rune) rune {
+			if (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '+' || r == '/' || r == '=' {
+				return r
+			}
+			return -1
+		}, embeddedPEMEscapes.Replace(string(match[1])))
+
+		der, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("certificate block n°%d: %s", index+1, err.Error())
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("certificate block n°%d: tried to parse malformed x509 data, %s", index+1, err.Error())
+		}
+
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	return output, nil
+}
@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// TLSEndpoint : A network address serving TLS, whose presented certificates are monitored,
+// and only accepted when they chain up to RootCAs (system roots if nil) if Verify is set
+type TLSEndpoint struct {
+	Address   string
+	Component string
+	Verify    bool
+	RootCAs   *x509.CertPool
+}
+
+const defaultEndpointTimeout = 10 * time.Second
+
+// maxRefresherSleep : Upper bound between two checks of the refresher, so new endpoints get picked up
+const maxRefresherSleep = time.Second
+
+// endpointState : Last observed certificates of an endpoint, refreshed in the background
+// when a refresh interval is configured, and fetched on each parse otherwise
+type endpointState struct {
+	mutex        sync.Mutex
+	endpoint     TLSEndpoint
+	interval     time.Duration
+	jitter       time.Duration
+	timeout      time.Duration
+	certificates []*parsedCertificate
+	negotiated   *endpointNegotiated
+	err          error
+	fetched      bool
+	refreshing   bool
+	nextRefresh  time.Time
+}
+
+// endpointNegotiated : TLS version and cipher suite of the last successful handshake with an endpoint
+type endpointNegotiated struct {
+	version     uint16
+	cipherSuite uint16
+}
+
+func (exporter *Exporter) collectEndpoints() []*certificateRef {
+	output := []*certificateRef{}
+
+	for _, endpoint := range exporter.TLSEndpoints {
+		output = append(output, &certificateRef{
+			path:     fmt.Sprintf("endpoint/%s", endpoint.Address),
+			format:   certificateFormatEndpoint,
+			endpoint: exporter.getEndpointState(endpoint),
+		})
+	}
+
+	return output
+}
+
+// getEndpointState : Return the cache entry of an endpoint, creating it on first use
+func (exporter *Exporter) getEndpointState(endpoint TLSEndpoint) *endpointState {
+	exporter.endpointsMutex.Lock()
+	defer exporter.endpointsMutex.Unlock()
+
+	if exporter.endpointStates == nil {
+		exporter.endpointStates = map[string]*endpointState{}
+	}
+
+	state, found := exporter.endpointStates[endpoint.Address]
+	if !found {
+		timeout := exporter.EndpointTimeout
+		if timeout == 0 {
+			timeout = defaultEndpointTimeout
+		}
+
+		state = &endpointState{
+			endpoint: endpoint,
+			interval: exporter.EndpointRefreshInterval,
+			jitter:   exporter.EndpointRefreshJitter,
+			timeout:  timeout,
+		}
+		exporter.endpointStates[endpoint.Address] = state
+	}
+
+	return state
+}
+
+// pruneEndpointStates : Forget the endpoints none of the refs read by a scrape points to anymore
+// (e.g. declared by a deleted certificate monitor), so that the refresher stops connecting to them
+func (exporter *Exporter) pruneEndpointStates(refs []*certificateRef) {
+	present := map[string]bool{}
+	for _, ref := range refs {
+		if ref.endpoint != nil {
+			present[ref.endpoint.endpoint.Address] = true
+		}
+	}
+
+	exporter.endpointsMutex.Lock()
+	defer exporter.endpointsMutex.Unlock()
+
+	for address := range exporter.endpointStates {
+		if !present[address] {
+			delete(exporter.endpointStates, address)
+		}
+	}
+}
+
+// startEndpointRefresher : Refresh endpoints in the background until Shutdown is called
+func (exporter *Exporter) startEndpointRefresher() {
+	exporter.monitorsMutex.Lock()
+	watchingMonitors := exporter.monitors != nil
+	exporter.monitorsMutex.Unlock()
+
+	// certificate monitors and service discovery may declare endpoints later on
+	if (len(exporter.TLSEndpoints) == 0 && !watchingMonitors && len(exporter.HTTPSDURL) == 0) || exporter.EndpointRefreshInterval == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exporter.stopEndpointRefresher = cancel
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(exporter.refreshDueEndpoints()):
+			}
+		}
+	}()
+}
+
+// refreshDueEndpoints : Start a refresh for every endpoint past its schedule,
+// and return how long to wait before the next one is due
+func (exporter *Exporter) refreshDueEndpoints() time.Duration {
+	exporter.endpointsMutex.Lock()
+	states := make([]*endpointState, 0, len(exporter.endpointStates))
+	for _, state := range exporter.endpointStates {
+		states = append(states, state)
+	}
+	exporter.endpointsMutex.Unlock()
+
+	wait := maxRefresherSleep
+	now := time.Now()
+
+	for _, state := range states {
+		state.mutex.Lock()
+		due := state.fetched && !state.refreshing && !now.Before(state.nextRefresh)
+		if due {
+			state.refreshing = true
+		} else if state.fetched && !state.refreshing && state.nextRefresh.Sub(now) < wait {
+			wait = state.nextRefresh.Sub(now)
+		}
+		state.mutex.Unlock()
+
+		if due {
+			go func(state *endpointState) {
+				certs, negotiated, err := fetchEndpointCertificates(context.Background(), &state.endpoint, state.timeout)
+
+				state.mutex.Lock()
+				state.store(certs, negotiated, err)
+				state.refreshing = false
+				state.mutex.Unlock()
+			}(state)
+		}
+	}
+
+	return wait
+}
+
+// store : Save a fetch result and schedule the next refresh, must be called with the lock held
+func (state *endpointState) store(certs []*parsedCertificate, negotiated *endpointNegotiated, err error) {
+	state.certificates = certs
+	state.negotiated = negotiated
+	state.err = err
+	state.fetched = true
+
+	delay := state.interval
+	if state.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(state.jitter)))
+	}
+	state.nextRefresh = time.Now().Add(delay)
+}
+
+func readAndParseEndpoint(ctx context.Context, state *endpointState) ([]*parsedCertificate, error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	// without a refresh interval there is no cache: always fetch
+	if !state.fetched || state.interval == 0 {
+		certs, negotiated, err := fetchEndpointCertificates(ctx, &state.endpoint, state.timeout)
+		if ctx.Err() != nil {
+			// the scrape gave up, don't cache its failure
+			return nil, err
+		}
+		state.store(certs, negotiated, err)
+	}
+
+	return copyParsedCertificates(state.certificates), state.err
+}
+
+// getNegotiated : Last negotiated TLS parameters of an endpoint, nil until a handshake succeeded
+func (state *endpointState) getNegotiated() *endpointNegotiated {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	return state.negotiated
+}
+
+func fetchEndpointCertificates(ctx context.Context, endpoint *TLSEndpoint, timeout time.Duration) ([]*parsedCertificate, *endpointNegotiated, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config: &tls.Config{
+			// the presented certificates are monitored, not trusted, unless asked to
+			//nolint:gosec
+			InsecureSkipVerify: !endpoint.Verify,
+			RootCAs:            endpoint.RootCAs,
+			// legacy endpoints are still monitored, their negotiated version is exposed
+			//nolint:gosec
+			MinVersion: tls.VersionTLS10,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	connState := conn.(*tls.Conn).ConnectionState()
+	output := []*parsedCertificate{}
+	for _, cert := range connState.PeerCertificates {
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	return output, &endpointNegotiated{version: connState.Version, cipherSuite: connState.CipherSuite}, nil
+}
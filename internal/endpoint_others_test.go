@@ -0,0 +1,47 @@
+//go:build !windows
+
+package internal
+
+import (
+	"os"
+	"path"
+	"syscall"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrapeTimeoutHungFirstSource(t *testing.T) {
+	// opening a named pipe blocks until a writer opens it, as a read from a hung NFS mount would
+	dir := t.TempDir()
+	fifo := path.Join(dir, "hung.pem")
+	assert.NoError(t, syscall.Mkfifo(fifo, 0644))
+	defer func() {
+		// releases the readers left running in the background
+		if writer, err := os.OpenFile(fifo, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			writer.Close()
+		}
+	}()
+
+	server := startFakeTLSServer(t, generateTestCertificate(leafTemplate("server", time.Now().Add(time.Hour)), nil), nil)
+
+	exporter := &Exporter{
+		Files:              []string{fifo, "../test/basic.pem"},
+		TLSEndpoints:       []TLSEndpoint{{Address: server.address()}},
+		ScrapeTimeout:      300 * time.Millisecond,
+		ExposeErrorMetrics: true,
+	}
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		found := []string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			found = append(found, getLabelValue(metric, "subject_CN"))
+		}
+		assert.ElementsMatch(t, []string{"kubernetes", "server"}, found)
+
+		timeoutMetric := getMetricsForName(metrics, "x509_read_timeouts")
+		assert.Equal(t, 1., timeoutMetric[0].GetGauge().GetValue())
+	})
+}
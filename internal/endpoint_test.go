@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTLSServer struct {
+	listener   net.Listener
+	cert       atomic.Pointer[testCertificate]
+	handshakes atomic.Int32
+}
+
+// startFakeTLSServer : Serve the given certificate over TLS on a random local port,
+// it can be swapped later on through server.cert
+func startFakeTLSServer(t *testing.T, cert *testCertificate, config *tls.Config) *fakeTLSServer {
+	server := &fakeTLSServer{}
+	server.cert.Store(cert)
+
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		server.handshakes.Add(1)
+		current := server.cert.Load()
+		return &tls.Certificate{
+			Certificate: [][]byte{current.cert.Raw},
+			PrivateKey:  current.key,
+		}, nil
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	assert.NoError(t, err)
+	server.listener = listener
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				//nolint:errcheck
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	return server
+}
+
+func (server *fakeTLSServer) address() string {
+	return server.listener.Addr().String()
+}
+
+func getEndpointCommonNames(t *testing.T, exporter *Exporter) []string {
+	refs, errs := exporter.parseAllCertificates(context.Background())
+	assert.Len(t, errs, 0)
+
+	names := []string{}
+	for _, ref := range refs {
+		for _, cert := range ref.certificates {
+			names = append(names, cert.cert.Subject.CommonName)
+		}
+	}
+
+	return names
+}
+
+func TestTLSEndpoint(t *testing.T) {
+	server := startFakeTLSServer(t, generateTestCertificate(leafTemplate("endpoint", time.Now().Add(time.Hour)), nil), nil)
+
+	testRequest(t, &Exporter{
+		TLSEndpoints: []TLSEndpoint{{Address: server.address()}},
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		assert.Len(t, foundMetrics, 1)
+		assert.Equal(t, server.address(), getLabelValue(foundMetrics[0], "endpoint"))
+		assert.Equal(t, "endpoint", getLabelValue(foundMetrics[0], "subject_CN"))
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+func TestTLSEndpointUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	testRequest(t, &Exporter{
+		TLSEndpoints:       []TLSEndpoint{{Address: address}},
+		ExposeErrorMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_not_after"), 0)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+
+		certErrMetric := getMetricsForName(metrics, "x509_cert_error")
+		assert.Len(t, certErrMetric, 1)
+		assert.Equal(t, address, getLabelValue(certErrMetric[0], "endpoint"))
+	})
+}
+
+func TestTLSEndpointWithoutCache(t *testing.T) {
+	server := startFakeTLSServer(t, generateTestCertificate(leafTemplate("first", time.Now().Add(time.Hour)), nil), nil)
+	exporter := &Exporter{
+		TLSEndpoints: []TLSEndpoint{{Address: server.address()}},
+	}
+
+	assert.Equal(t, []string{"first"}, getEndpointCommonNames(t, exporter))
+	server.cert.Store(generateTestCertificate(leafTemplate("second", time.Now().Add(time.Hour)), nil))
+	assert.Equal(t, []string{"second"}, getEndpointCommonNames(t, exporter))
+	assert.Equal(t, int32(2), server.handshakes.Load())
+}
+
+func TestTLSEndpointCache(t *testing.T) {
+	server := startFakeTLSServer(t, generateTestCertificate(leafTemplate("first", time.Now().Add(time.Hour)), nil), nil)
+	exporter := &Exporter{
+		TLSEndpoints:            []TLSEndpoint{{Address: server.address()}},
+		EndpointRefreshInterval: 300 * time.Millisecond,
+		EndpointRefreshJitter:   50 * time.Millisecond,
+	}
+
+	exporter.DiscoverCertificates()
+	exporter.startEndpointRefresher()
+	defer exporter.Shutdown()
+	assert.Equal(t, int32(1), server.handshakes.Load())
+
+	// scrapes are served from cache until the next refresh
+	server.cert.Store(generateTestCertificate(leafTemplate("second", time.Now().Add(time.Hour)), nil))
+	assert.Equal(t, []string{"first"}, getEndpointCommonNames(t, exporter))
+	assert.Equal(t, []string{"first"}, getEndpointCommonNames(t, exporter))
+	assert.Equal(t, int32(1), server.handshakes.Load())
+
+	assert.Eventually(t, func() bool {
+		names := getEndpointCommonNames(t, exporter)
+		return len(names) == 1 && names[0] == "second"
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, int32(2), server.handshakes.Load())
+
+	// the refresher stops with the exporter
+	assert.NoError(t, exporter.Shutdown())
+	handshakes := server.handshakes.Load()
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, handshakes, server.handshakes.Load())
+}
+
+func TestScrapeTimeout(t *testing.T) {
+	// accepts connections but never completes the TLS handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	hung := make(chan struct{})
+	defer close(hung)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				<-hung
+				conn.Close()
+			}()
+		}
+	}()
+
+	exporter := &Exporter{
+		Files:              []string{"../test/basic.pem"},
+		TLSEndpoints:       []TLSEndpoint{{Address: listener.Addr().String()}},
+		EndpointTimeout:    time.Minute,
+		ScrapeTimeout:      300 * time.Millisecond,
+		ExposeErrorMetrics: true,
+	}
+
+	start := time.Now()
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		assert.Len(t, foundMetrics, 1)
+		assert.Equal(t, "basic.pem", getLabelValue(foundMetrics[0], "filename"))
+
+		timeoutMetric := getMetricsForName(metrics, "x509_read_timeouts")
+		assert.Equal(t, 1., timeoutMetric[0].GetGauge().GetValue())
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+
+		certErrors := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_error") {
+			certErrors[getLabelValue(metric, "filename")+getLabelValue(metric, "endpoint")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"basic.pem": 0, listener.Addr().String(): 1}, certErrors)
+	})
+
+	// discovery and scrape both give up on the endpoint
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// without a timeout, sources are read without being watched
+	exporter.ScrapeTimeout = 0
+	ctx, cancel := exporter.newScrapeContext()
+	defer cancel()
+	assert.Nil(t, ctx.Done())
+}
+
+func TestTLSEndpointNegotiated(t *testing.T) {
+	legacy := startFakeTLSServer(t, generateTestCertificate(leafTemplate("legacy", time.Now().Add(time.Hour)), nil), &tls.Config{
+		MinVersion:   tls.VersionTLS11,
+		MaxVersion:   tls.VersionTLS11,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA},
+	})
+	modern := startFakeTLSServer(t, generateTestCertificate(leafTemplate("modern", time.Now().Add(time.Hour)), nil), &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+	})
+
+	testRequest(t, &Exporter{
+		TLSEndpoints: []TLSEndpoint{{Address: legacy.address()}, {Address: modern.address()}},
+	}, func(metrics []model.MetricFamily) {
+		negotiated := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_endpoint_tls_info") {
+			assert.Equal(t, 1., metric.GetGauge().GetValue())
+			negotiated[getLabelValue(metric, "endpoint")] = getLabelValue(metric, "tls_version") + "/" + getLabelValue(metric, "cipher_suite")
+		}
+		assert.Equal(t, map[string]string{
+			legacy.address(): "TLS 1.1/TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+			modern.address(): "TLS 1.2/TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+		}, negotiated)
+	})
+}
+
+func TestTLSEndpointConcurrentScrapes(t *testing.T) {
+	server := startFakeTLSServer(t, generateTestCertificate(leafTemplate("endpoint", time.Now().Add(time.Hour)), nil), nil)
+	exporter := &Exporter{
+		TLSEndpoints:            []TLSEndpoint{{Address: server.address()}},
+		EndpointRefreshInterval: time.Hour,
+		ExposeIssuerMetrics:     true,
+	}
+
+	exporter.DiscoverCertificates()
+	parseConcurrently(exporter)
+
+	// each scrape resolves issuers on its own copy of the cached certificates
+	first, _ := exporter.parseAllCertificates(context.Background())
+	second, _ := exporter.parseAllCertificates(context.Background())
+	assert.NotSame(t, first[0].certificates[0], second[0].certificates[0])
+	assert.Same(t, first[0].certificates[0].cert, second[0].certificates[0].cert)
+	assert.Equal(t, int32(1), server.handshakes.Load())
+}
@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// EtcdKey : An etcd v3 key containing PEM certificates
+type EtcdKey struct {
+	Endpoints []string
+	Key       string
+	Username  string
+	Password  string
+}
+
+const etcdTimeout = 30 * time.Second
+
+const etcdDialTimeout = 5 * time.Second
+
+// ParseEtcdURL : Split a etcd://[user:password@]host:port[,host:port...]/key URL,
+// the key being the whole path as etcd keys usually start with a slash
+func ParseEtcdURL(etcdURL string) (EtcdKey, error) {
+	parsed, err := url.Parse(etcdURL)
+	if err != nil {
+		return EtcdKey{}, err
+	}
+
+	key := parsed.Path
+	if parsed.Scheme != "etcd" || len(parsed.Host) == 0 || len(strings.TrimPrefix(key, "/")) == 0 {
+		return EtcdKey{}, fmt.Errorf("expected etcd://[<user>:<password>@]<endpoints>/<key>, got \"%s\"", redactURL(etcdURL))
+	}
+
+	output := EtcdKey{
+		Endpoints: strings.Split(parsed.Host, ","),
+		Key:       key,
+	}
+	if parsed.User != nil {
+		output.Username = parsed.User.Username()
+		output.Password, _ = parsed.User.Password()
+	}
+
+	return output, nil
+}
+
+// redactURL : Remove the password of a URL, to be logged
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "<malformed url>"
+	}
+
+	return parsed.Redacted()
+}
+
+func (exporter *Exporter) collectEtcdKeys() []*certificateRef {
+	output := []*certificateRef{}
+
+	for index := range exporter.EtcdKeys {
+		key := &exporter.EtcdKeys[index]
+		output = append(output, &certificateRef{
+			path:       fmt.Sprintf("etcd://%s%s", strings.Join(key.Endpoints, ","), key.Key),
+			format:     certificateFormatEtcd,
+			etcdKey:    key,
+			etcdClient: exporter.getEtcdClient,
+		})
+	}
+
+	return output
+}
+
+// getEtcdClient : Lazily create one client per endpoints and credentials, using TLS when
+// a CA or client certificate is configured
+func (exporter *Exporter) getEtcdClient(key *EtcdKey) (clientv3.KV, error) {
+	exporter.etcdMutex.Lock()
+	defer exporter.etcdMutex.Unlock()
+
+	if exporter.etcdClients == nil {
+		exporter.etcdClients = map[string]clientv3.KV{}
+	}
+
+	clientKey := fmt.Sprintf("%s@%s", key.Username, strings.Join(key.Endpoints, ","))
+	if client, found := exporter.etcdClients[clientKey]; found {
+		return client, nil
+	}
+
+	tlsConfig, err := exporter.loadEtcdTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   key.Endpoints,
+		DialTimeout: etcdDialTimeout,
+		TLS:         tlsConfig,
+		Username:    key.Username,
+		Password:    key.Password,
+		// failures are reported through read errors
+		Logger: zap.NewNop(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd client: %s", err.Error())
+	}
+
+	exporter.etcdClients[clientKey] = client
+	return client, nil
+}
+
+func (exporter *Exporter) loadEtcdTLSConfig() (*tls.Config, error) {
+	if len(exporter.EtcdCAFile) == 0 && len(exporter.EtcdCertFile) == 0 {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if len(exporter.EtcdCAFile) > 0 {
+		contents, err := os.ReadFile(exporter.EtcdCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read etcd CA file: %s", err.Error())
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(contents) {
+			return nil, fmt.Errorf("no certificate found in etcd CA file \"%s\"", exporter.EtcdCAFile)
+		}
+	}
+
+	if len(exporter.EtcdCertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(exporter.EtcdCertFile, exporter.EtcdKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load etcd client certificate: %s", err.Error())
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+func readAndParseEtcdKey(ctx context.Context, key *EtcdKey, getClient func(*EtcdKey) (clientv3.KV, error)) ([]*parsedCertificate, error) {
+	client, err := getClient(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	defer cancel()
+
+	response, err := client.Get(ctx, key.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Kvs) == 0 {
+		return nil, fmt.Errorf("key \"%s\" not found", key.Key)
+	}
+
+	certs, err := parsePEM(response.Kvs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*parsedCertificate{}
+	for _, cert := range certs {
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	return output, nil
+}
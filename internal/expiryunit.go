@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExpiryUnit : Unit of the remaining time before expiration, suffixing the name of its metric
+type ExpiryUnit string
+
+const (
+	ExpiryUnitSeconds ExpiryUnit = "seconds"
+	ExpiryUnitHours   ExpiryUnit = "hours"
+	ExpiryUnitDays    ExpiryUnit = "days"
+)
+
+var expiryUnitDurations = map[ExpiryUnit]time.Duration{
+	ExpiryUnitSeconds: time.Second,
+	ExpiryUnitHours:   time.Hour,
+	ExpiryUnitDays:    24 * time.Hour,
+}
+
+// ParseExpiryUnit : Check that a unit is one of seconds, hours or days
+func ParseExpiryUnit(name string) (ExpiryUnit, error) {
+	unit := ExpiryUnit(name)
+	if _, found := expiryUnitDurations[unit]; !found {
+		return "", fmt.Errorf("unknown unit \"%s\", expected seconds, hours or days", name)
+	}
+
+	return unit, nil
+}
+
+// getExpiresInUnit : Configured unit of the x509_cert_expires_in metric, seconds by default
+func (exporter *Exporter) getExpiresInUnit() ExpiryUnit {
+	if len(exporter.ExpiresInUnit) == 0 {
+		return ExpiryUnitSeconds
+	}
+
+	return exporter.ExpiresInUnit
+}
+
+// getExpiresInMetric : Name of the x509_cert_expires_in metric, suffixed with its unit
+func (exporter *Exporter) getExpiresInMetric() string {
+	return fmt.Sprintf("x509_cert_expires_in_%s", exporter.getExpiresInUnit())
+}
+
+// getExpiresIn : Remaining time before the expiration of a certificate, in the configured unit
+func (exporter *Exporter) getExpiresIn(notAfter time.Time) float64 {
+	return notAfter.Sub(exporter.now()).Seconds() / expiryUnitDurations[exporter.getExpiresInUnit()].Seconds()
+}
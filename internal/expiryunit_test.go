@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiresInUnit(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	certPath := path.Join(t.TempDir(), "tls.pem")
+	writeTestCertificates(certPath, generateTestCertificate(leafTemplate("tls", now.Add(36*time.Hour)), nil))
+
+	for unit, expected := range map[ExpiryUnit]float64{
+		"":                129600,
+		ExpiryUnitSeconds: 129600,
+		ExpiryUnitHours:   36,
+		ExpiryUnitDays:    1.5,
+	} {
+		exporter := &Exporter{
+			Files:                 []string{certPath},
+			ExposeRelativeMetrics: true,
+			ExpiresInUnit:         unit,
+			clock: func() time.Time {
+				return now
+			},
+		}
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(&collector{exporter: exporter})
+
+		metrics, err := registry.Gather()
+		assert.NoError(t, err)
+
+		values := map[string]float64{}
+		for _, family := range metrics {
+			if strings.HasPrefix(family.GetName(), "x509_cert_expires_in_") {
+				values[family.GetName()] = family.GetMetric()[0].GetGauge().GetValue()
+			}
+		}
+
+		name := "x509_cert_expires_in_seconds"
+		if len(unit) > 0 {
+			name = "x509_cert_expires_in_" + string(unit)
+		}
+		assert.Equal(t, map[string]float64{name: expected}, values, unit)
+	}
+
+	_, err := ParseExpiryUnit("weeks")
+	assert.Error(t, err)
+}
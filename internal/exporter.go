@@ -2,58 +2,280 @@ package internal
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"crypto/x509/pkix"
+	"database/sql"
 	"errors"
 	"fmt"
 	"io/fs"
+	"math"
 	"net"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"cloud.google.com/go/storage"
 	"github.com/patrickmn/go-cache"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
 
 	"github.com/bmatcuk/doublestar/v4"
 	"github.com/prometheus/common/promlog"
 	"github.com/prometheus/exporter-toolkit/web"
 	log "github.com/sirupsen/logrus"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/kubernetes"
+	kubecache "k8s.io/client-go/tools/cache"
 )
 
+// ErrExpiredCertificates : Returned by Push, once metrics are pushed, when FailOnExpired is set and expired certificates were found
+var ErrExpiredCertificates = errors.New("expired certificates found")
+
+// DefaultFarFutureNotAfter : Last second of signed 32-bit Unix timestamps (2038-01-19), which later expiry dates
+// overflow on 32-bit platforms
+var DefaultFarFutureNotAfter = time.Unix(math.MaxInt32, 0).UTC()
+
+// sourceParseConcurrency : Maximum number of sources read at once by a scrape, a hung source
+// (e.g. a file on an unresponsive NFS mount) holding its slot until the scrape deadline
+const sourceParseConcurrency = 32
+
 // Exporter : Configuration (from command-line)
 type Exporter struct {
-	ListenAddress         string
-	SystemdSocket         bool
-	ConfigFile            string
-	Files                 []string
-	Directories           []string
-	YAMLs                 []string
-	YAMLPaths             []YAMLCertRef
-	TrimPathComponents    int
-	MaxCacheDuration      time.Duration
-	ExposeRelativeMetrics bool
-	ExposeErrorMetrics    bool
-	ExposeLabels          []string
-	KubeSecretTypes       []string
-	KubeIncludeNamespaces []string
-	KubeExcludeNamespaces []string
-	KubeIncludeLabels     []string
-	KubeExcludeLabels     []string
-
-	kubeClient   *kubernetes.Clientset
+	ListenAddress           string
+	SystemdSocket           bool
+	ConfigFile              string
+	TLSCertFile             string
+	TLSKeyFile              string
+	TLSClientCAFile         string
+	Files                   []string
+	Directories             []string
+	HashedDirectories       []string
+	YAMLs                   []string
+	YAMLPaths               []YAMLCertRef
+	INIs                    []string
+	AutoFiles               []string
+	EmbeddedPEMFiles        []string
+	SDSFiles                []string
+	INIKeys                 []INICertRef
+	DotenvFiles             []string
+	DotenvKeys              []DotenvCertRef
+	LeafOnlySources         []string
+	SQLSources              []SQLSource
+	TLSEndpoints            []TLSEndpoint
+	GCSObjects              []GCSObject
+	GitFiles                []GitFile
+	GitRefreshInterval      time.Duration
+	GitTokenFile            string
+	GitSSHKeyFile           string
+	AzureCertificates       []AzureKeyVaultCertificate
+	ConsulKeys              []ConsulKey
+	ConsulAddress           string
+	ConsulTokenFile         string
+	EtcdKeys                []EtcdKey
+	EtcdCAFile              string
+	EtcdCertFile            string
+	EtcdKeyFile             string
+	LDAPSearches            []LDAPSearch
+	LDAPBindDN              string
+	LDAPBindPasswordFile    string
+	LDAPCAFile              string
+	LDAPStartTLS            bool
+	FTPFiles                []FTPFile
+	FTPUser                 string
+	FTPPasswordFile         string
+	FTPCAFile               string
+	FTPExplicitTLS          bool
+	FTPDisableEPSV          bool
+	WindowsCertStores       []WindowsCertStore
+	NamedPipes              []string
+	SystemdCredentials      []string
+	EndpointRefreshInterval time.Duration
+	EndpointRefreshJitter   time.Duration
+	EndpointTimeout         time.Duration
+	HTTPSDURL               string
+	HTTPSDRefreshInterval   time.Duration
+	HTTPSDCAFile            string
+	TrimPathComponents      int
+	MaxCacheDuration        time.Duration
+	KubeInformerResync      time.Duration
+	ScrapeTimeout           time.Duration
+	StaleTolerance          time.Duration
+	CircuitBreakerFailures  int
+	CircuitBreakerBackoff   time.Duration
+	OTLPEndpoint            string
+	OTLPHeaders             map[string]string
+	OTLPInterval            time.Duration
+	ClientCertListenAddress string
+	ClientCertRetention     time.Duration
+	ClientCertMaxRecords    int
+	ClientCertNetworks      []*net.IPNet
+	IngestListenAddress     string
+	IngestClientCAFile      string
+	IngestRetention         time.Duration
+	ClockSkewThreshold      time.Duration
+	ReadinessMinLifetime    time.Duration
+	ReadinessSelectors      []ReadinessSelector
+	FailOnExpired           bool
+	ExposeRelativeMetrics   bool
+	ExpiresInUnit           ExpiryUnit
+	ExposeErrorMetrics      bool
+	ExposeIssuerMetrics     bool
+	ExposeTypeMetrics       bool
+	ExposeSANMetrics        bool
+	ExposeSANExpiryMetrics  bool
+	SANExpiryLimit          int
+	ExposeEmailMetrics      bool
+	ExposeIPSANMetrics      bool
+	ExposeRevocationMetrics bool
+	ExposeRotationMetrics   bool
+	ExposeCacheAgeMetrics   bool
+	ExposeSHA1Metrics       bool
+	ExposeKeyReuseMetrics   bool
+	ExposePathLenMetrics    bool
+	ExposeChainDepthMetrics bool
+	ExposeWildcardMetrics   bool
+	ExposeChainOrderMetrics bool
+	ExposeSkippedPEMMetrics bool
+	ExposeHostnameMetrics   bool
+	ExposeCTMetrics         bool
+	CTLookupURL             string
+	ConsolidatedMetrics     bool
+	ExpectedHostnames       []ExpectedHostname
+	ServiceFiles            []ServiceSource
+	ServiceEndpoints        []ServiceSource
+	ExposeLabels            []string
+	ExposeFormatLabel       bool
+	LabelMappingsFile       string
+	CAFiles                 []string
+	TrustedRootFiles        []string
+	UseSystemRoots          bool
+	PinnedRoots             []string
+	AllowedSigAlgorithms    []x509.SignatureAlgorithm
+	DeniedSigAlgorithms     []x509.SignatureAlgorithm
+	IncludeExtKeyUsages     []x509.ExtKeyUsage
+	ExcludeExtKeyUsages     []x509.ExtKeyUsage
+	ValidityStates          []ValidityState
+	IssuerCountLimit        int
+	MinRSAKeySize           int
+	PinnedPublicKeys        [][sha256.Size]byte
+	FarFutureNotAfter       time.Time
+	KubeSecretTypes         []string
+	KubeSecretPathRoot      string
+	KubeIncludeNamespaces   []string
+	KubeExcludeNamespaces   []string
+	KubeIncludeLabels       []string
+	KubeExcludeLabels       []string
+	KubeSecretOwnerLabels   bool
+
+	kubeClient   kubernetes.Interface
 	listener     net.Listener
 	server       *http.Server
+	tlsConfig    *tls.Config
+	collector    *collector
 	isDiscovery  bool
 	secretsCache *cache.Cache
+
+	// the key pair presented through tlsConfig
+	servingKeyPair *servingKeyPair
+
+	endpointsMutex        sync.Mutex
+	endpointStates        map[string]*endpointState
+	stopEndpointRefresher context.CancelFunc
+	stopOTLPExporter      context.CancelFunc
+
+	httpSDMutex   sync.Mutex
+	httpSDTargets []string
+	httpSDFetched time.Time
+
+	gcsMutex        sync.Mutex
+	gcsClient       *storage.Client
+	gcsObjectStates map[GCSObject]*gcsObjectState
+
+	gitMutex      sync.Mutex
+	gitFileStates map[GitFile]*gitFileState
+
+	sqlMutex sync.Mutex
+	sqlDBs   map[SQLSource]*sql.DB
+
+	azureMutex   sync.Mutex
+	azureClients map[string]azureCertificateGetter
+
+	consulMutex  sync.Mutex
+	consulClient consulKVGetter
+
+	etcdMutex   sync.Mutex
+	etcdClients map[string]clientv3.KV
+
+	ldapDial func(string, *tls.Config) (ldapConn, error)
+
+	staleMutex   sync.Mutex
+	lastGoodRefs map[string]*lastGoodRef
+
+	breakersMutex sync.Mutex
+	breakers      map[string]*circuitBreaker
+
+	ctMutex     sync.Mutex
+	ctLookups   map[[sha256.Size]byte]*ctLookup
+	ctSemaphore chan struct{}
+
+	clientCertsMutex   sync.Mutex
+	clientCerts        map[string]*clientCertRecord
+	clientCertListener net.Listener
+
+	ingestedMutex  sync.Mutex
+	ingested       map[string]*ingestedRecord
+	ingestServer   *grpc.Server
+	ingestListener net.Listener
+
+	lastParsedMutex  sync.Mutex
+	lastParsedRefs   []*certificateRef
+	lastParsedErrors int
+
+	labelMappingsMutex   sync.Mutex
+	labelMappings        []LabelMapping
+	labelMappingsModTime time.Time
+
+	caBundlesListers []kubecache.GenericLister
+
+	ingressesClient kubernetes.Interface
+	ingressesLister kubecache.GenericLister
+
+	monitorsMutex      sync.Mutex
+	monitorsKubeClient kubernetes.Interface
+	monitors           map[string]*certificateMonitor
+
+	kubeInformerMutex   sync.Mutex
+	kubeInformerFactory dynamicinformer.DynamicSharedInformerFactory
+	kubeInformerStop    chan struct{}
+
+	yamlCachesMutex sync.Mutex
+	yamlCaches      map[string]*yamlFileCache
+
+	rotationsMutex sync.Mutex
+	rotations      map[string]*sourceRotations
+
+	parseTimesMutex sync.Mutex
+	parseTimes      map[string]time.Time
+
+	clock func() time.Time
 }
 
 // ListenAndServe : Convenience function to start exporter
 func (exporter *Exporter) ListenAndServe() error {
+	// fail fast on a broken TLS setup, before the potentially long discovery
+	if _, err := exporter.loadServerTLSConfig(); err != nil {
+		return err
+	}
+
 	exporter.DiscoverCertificates()
 
 	if err := exporter.Listen(); err != nil {
@@ -65,11 +287,18 @@ func (exporter *Exporter) ListenAndServe() error {
 
 // Listen : Listen for requests
 func (exporter *Exporter) Listen() error {
-	err := prometheus.Register(&collector{exporter: exporter})
+	tlsConfig, err := exporter.loadServerTLSConfig()
+	if err != nil {
+		return err
+	}
+	exporter.tlsConfig = tlsConfig
+
+	exporter.collector = &collector{exporter: exporter}
+	err = prometheus.Register(exporter.collector)
 	if err != nil {
 		if registered, ok := err.(prometheus.AlreadyRegisteredError); ok {
 			prometheus.Unregister(registered.ExistingCollector)
-			prometheus.MustRegister(&collector{exporter: exporter})
+			prometheus.MustRegister(exporter.collector)
 		} else {
 			return err
 		}
@@ -81,6 +310,20 @@ func (exporter *Exporter) Listen() error {
 	}
 
 	exporter.listener = listener
+	err = exporter.startClientCertListener()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	err = exporter.startIngestServer()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	exporter.startEndpointRefresher()
+	exporter.startOTLPExporter()
 	return nil
 }
 
@@ -88,11 +331,18 @@ func (exporter *Exporter) Listen() error {
 func (exporter *Exporter) Serve() error {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/readyz", exporter.handleReadiness)
+	mux.HandleFunc("/", exporter.handleSummary)
 
 	exporter.server = &http.Server{
 		Handler: mux,
 	}
 
+	if exporter.tlsConfig != nil {
+		exporter.server.TLSConfig = exporter.tlsConfig
+		return exporter.server.Serve(tls.NewListener(exporter.listener, exporter.tlsConfig))
+	}
+
 	toolkitFlags := web.FlagConfig{
 		WebListenAddresses: &[]string{exporter.ListenAddress},
 		WebSystemdSocket:   &exporter.SystemdSocket,
@@ -107,6 +357,35 @@ func (exporter *Exporter) Serve() error {
 
 // Shutdown : Properly tear down server
 func (exporter *Exporter) Shutdown() error {
+	if exporter.stopEndpointRefresher != nil {
+		exporter.stopEndpointRefresher()
+		exporter.stopEndpointRefresher = nil
+	}
+
+	if exporter.stopOTLPExporter != nil {
+		exporter.stopOTLPExporter()
+		exporter.stopOTLPExporter = nil
+	}
+
+	if exporter.clientCertListener != nil {
+		exporter.clientCertListener.Close()
+		exporter.clientCertListener = nil
+	}
+
+	if exporter.ingestServer != nil {
+		exporter.ingestServer.Stop()
+		exporter.ingestServer = nil
+		exporter.ingestListener = nil
+	}
+
+	exporter.stopKubeInformers()
+	exporter.closeSQLDBs()
+
+	if exporter.collector != nil {
+		prometheus.Unregister(exporter.collector)
+		exporter.collector = nil
+	}
+
 	if exporter.server != nil {
 		return exporter.server.Shutdown(context.Background())
 	}
@@ -114,11 +393,53 @@ func (exporter *Exporter) Shutdown() error {
 	return nil
 }
 
+// Push : Parse all certs once and push the resulting metrics to a Pushgateway
+func (exporter *Exporter) Push(gatewayURL string, job string, grouping map[string]string) error {
+	exporter.secretsCache = cache.New(exporter.MaxCacheDuration, 5*time.Minute)
+	pusher := push.New(gatewayURL, job).Collector(&collector{exporter: exporter})
+
+	for name, value := range grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	if err := pusher.Push(); err != nil {
+		return err
+	}
+
+	if exporter.FailOnExpired {
+		if count := exporter.countExpiredCertificates(); count > 0 {
+			return fmt.Errorf("%w: %d certificate(s) past their not after date", ErrExpiredCertificates, count)
+		}
+	}
+
+	return nil
+}
+
+// countExpiredCertificates : Number of expired certificates found by the last parse
+func (exporter *Exporter) countExpiredCertificates() int {
+	exporter.lastParsedMutex.Lock()
+	defer exporter.lastParsedMutex.Unlock()
+
+	count := 0
+	now := time.Now()
+	for _, ref := range exporter.lastParsedRefs {
+		for _, cert := range ref.certificates {
+			if now.After(cert.cert.NotAfter) {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
 // DiscoverCertificates : Parse all certs in a dry run with verbose logging
 func (exporter *Exporter) DiscoverCertificates() {
 	exporter.secretsCache = cache.New(exporter.MaxCacheDuration, 5*time.Minute)
 	exporter.isDiscovery = true
-	certs, errs := exporter.parseAllCertificates()
+	ctx, cancel := exporter.newScrapeContext()
+	defer cancel()
+	certs, errs := exporter.parseAllCertificates(ctx)
 
 	certCount := 0
 	for _, cert := range certs {
@@ -129,7 +450,38 @@ func (exporter *Exporter) DiscoverCertificates() {
 	exporter.isDiscovery = false
 }
 
-func (exporter *Exporter) parseAllCertificates() ([]*certificateRef, []*certificateError) {
+// newScrapeContext : Context bounding the time spent reading sources, if ScrapeTimeout is set; without it the context
+// can't be done, so that sources are read without watching them
+func (exporter *Exporter) newScrapeContext() (context.Context, context.CancelFunc) {
+	if exporter.ScrapeTimeout == 0 {
+		return context.Background(), func() {}
+	}
+
+	return context.WithTimeout(context.Background(), exporter.ScrapeTimeout)
+}
+
+// parseRefs : Parse refs concurrently, so that a hung source doesn't use up the scrape deadline of the ones after it,
+// returning their errors in the same order
+func (exporter *Exporter) parseRefs(ctx context.Context, refs []*certificateRef) []error {
+	errs := make([]error, len(refs))
+	semaphore := make(chan struct{}, sourceParseConcurrency)
+	wg := sync.WaitGroup{}
+
+	for index, ref := range refs {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-semaphore }()
+			defer wg.Done()
+			errs[index] = exporter.parseThroughBreaker(ctx, ref)
+		}()
+	}
+
+	wg.Wait()
+	return errs
+}
+
+func (exporter *Exporter) parseAllCertificates(ctx context.Context) ([]*certificateRef, []*certificateError) {
 	output := []*certificateRef{}
 	outputErrors := []*certificateError{}
 	raiseError := func(err *certificateError) {
@@ -139,6 +491,12 @@ func (exporter *Exporter) parseAllCertificates() ([]*certificateRef, []*certific
 		}
 	}
 
+	if err := exporter.reloadLabelMappings(); err != nil {
+		raiseError(&certificateError{
+			err: err,
+		})
+	}
+
 	for _, file := range exporter.Files {
 		refs, errs := exporter.collectMatchingPaths(file, certificateFormatPEM, false)
 
@@ -163,6 +521,66 @@ func (exporter *Exporter) parseAllCertificates() ([]*certificateRef, []*certific
 		output = append(output, refs...)
 	}
 
+	for _, file := range exporter.INIs {
+		refs, errs := exporter.collectMatchingPaths(file, certificateFormatINI, false)
+
+		for _, err := range errs {
+			raiseError(&certificateError{
+				err: fmt.Errorf("failed to parse \"%s\": %s", file, err.Error()),
+			})
+		}
+
+		output = append(output, refs...)
+	}
+
+	for _, file := range exporter.DotenvFiles {
+		refs, errs := exporter.collectMatchingPaths(file, certificateFormatDotenv, false)
+
+		for _, err := range errs {
+			raiseError(&certificateError{
+				err: fmt.Errorf("failed to parse \"%s\": %s", file, err.Error()),
+			})
+		}
+
+		output = append(output, refs...)
+	}
+
+	for _, file := range exporter.AutoFiles {
+		refs, errs := exporter.collectMatchingPaths(file, certificateFormatAuto, false)
+
+		for _, err := range errs {
+			raiseError(&certificateError{
+				err: fmt.Errorf("failed to parse \"%s\": %s", file, err.Error()),
+			})
+		}
+
+		output = append(output, refs...)
+	}
+
+	for _, file := range exporter.EmbeddedPEMFiles {
+		refs, errs := exporter.collectMatchingPaths(file, certificateFormatEmbeddedPEM, false)
+
+		for _, err := range errs {
+			raiseError(&certificateError{
+				err: fmt.Errorf("failed to parse \"%s\": %s", file, err.Error()),
+			})
+		}
+
+		output = append(output, refs...)
+	}
+
+	for _, file := range exporter.SDSFiles {
+		refs, errs := exporter.collectMatchingPaths(file, certificateFormatSDS, false)
+
+		for _, err := range errs {
+			raiseError(&certificateError{
+				err: fmt.Errorf("failed to parse \"%s\": %s", file, err.Error()),
+			})
+		}
+
+		output = append(output, refs...)
+	}
+
 	for _, dir := range exporter.Directories {
 		refs, errs := exporter.collectMatchingPaths(dir, certificateFormatYAML, true)
 
@@ -175,8 +593,71 @@ func (exporter *Exporter) parseAllCertificates() ([]*certificateRef, []*certific
 		output = append(output, refs...)
 	}
 
+	for _, dir := range exporter.HashedDirectories {
+		refs, errs := exporter.collectHashedDirectories(dir)
+
+		for _, err := range errs {
+			raiseError(&certificateError{
+				err: fmt.Errorf("failed to parse \"%s\": %s", dir, err.Error()),
+			})
+		}
+
+		output = append(output, refs...)
+	}
+
+	output = append(output, exporter.collectSQLSources()...)
+	output = append(output, exporter.collectEndpoints()...)
+	output = append(output, exporter.collectGCSObjects()...)
+	output = append(output, exporter.collectGitFiles()...)
+	output = append(output, exporter.collectAzureCertificates()...)
+	output = append(output, exporter.collectConsulKeys()...)
+	output = append(output, exporter.collectEtcdKeys()...)
+	output = append(output, exporter.collectLDAPSearches()...)
+	output = append(output, exporter.collectFTPFiles()...)
+	output = append(output, exporter.collectWindowsCertStores()...)
+	output = append(output, exporter.collectNamedPipes()...)
+	output = append(output, exporter.collectClientCertificates()...)
+	output = append(output, exporter.collectIngestedCertificates()...)
+	output = append(output, exporter.collectServingCertificate()...)
+
+	credentialRefs, credentialErrs := exporter.collectSystemdCredentials()
+	output = append(output, credentialRefs...)
+	for _, err := range credentialErrs {
+		raiseError(&certificateError{
+			err: err,
+		})
+	}
+
+	sdRefs, sdErrs := exporter.collectHTTPSDEndpoints(ctx)
+	output = append(output, sdRefs...)
+	for _, err := range sdErrs {
+		raiseError(&certificateError{
+			err: err,
+		})
+	}
+
 	if exporter.kubeClient != nil {
-		certs, errs := exporter.parseAllKubeSecrets()
+		certs, errs := exporter.parseAllKubeSecrets(ctx)
+		output = append(output, certs...)
+		for _, err := range errs {
+			raiseError(&certificateError{
+				err: err,
+			})
+		}
+	}
+
+	if exporter.ingressesClient != nil {
+		certs, errs := exporter.collectKubeIngresses(ctx)
+		output = append(output, certs...)
+		for _, err := range errs {
+			raiseError(&certificateError{
+				err: err,
+			})
+		}
+	}
+
+	if exporter.caBundlesListers != nil {
+		certs, errs := exporter.collectCABundles()
 		output = append(output, certs...)
 		for _, err := range errs {
 			raiseError(&certificateError{
@@ -185,11 +666,54 @@ func (exporter *Exporter) parseAllCertificates() ([]*certificateRef, []*certific
 		}
 	}
 
+	// after the secrets watched with flags, which keep their labels when also declared by a monitor
+	monitorRefs, monitorErrs := exporter.collectCertificateMonitors(ctx)
+	output = append(output, monitorRefs...)
+	for _, err := range monitorErrs {
+		raiseError(&certificateError{
+			err: err,
+		})
+	}
+
 	output = unique(output)
-	for _, cert := range output {
-		err := cert.parse()
+	exporter.pruneEndpointStates(output)
+	exporter.pruneBreakers(output)
+	parseErrors := exporter.parseRefs(ctx, output)
+	failed := []*certificateRef{}
+	for index, cert := range output {
+		err := parseErrors[index]
+
+		var warnings readWarnings
+		if errors.As(err, &warnings) {
+			for _, warning := range warnings {
+				raiseError(&certificateError{
+					err: fmt.Errorf("partially parsed \"%s\", %s", cert.path, warning.Error()),
+				})
+			}
+			err = nil
+		}
+
+		// before any certificate gets filtered out
+		if err == nil && exporter.ExposeChainOrderMetrics {
+			cert.chainOutOfOrder = getChainOutOfOrder(cert)
+		}
+
+		if err == nil && exporter.isLeafOnly(cert) {
+			cert.certificates = getLeafCertificates(cert.certificates)
+		}
 
 		if err != nil || len(cert.certificates) == 0 {
+			failed = append(failed, cert)
+
+			if errors.Is(err, context.DeadlineExceeded) {
+				raiseError(&certificateError{
+					err:     fmt.Errorf("timed out parsing \"%s\"", cert.path),
+					ref:     cert,
+					timeout: true,
+				})
+				continue
+			}
+
 			if err != nil {
 				err = fmt.Errorf("failed to parse \"%s\", %s", cert.path, err.Error())
 			} else {
@@ -204,6 +728,9 @@ func (exporter *Exporter) parseAllCertificates() ([]*certificateRef, []*certific
 			log.Infof("%d valid certificate(s) found in \"%s\"", len(cert.certificates), cert.path)
 		}
 
+		// after checking the source isn't empty, as it's not an error for none of its certificates to match
+		cert.certificates = exporter.filterExtKeyUsages(cert.certificates)
+
 		for _, existingCertRef := range output {
 		checkCertificatePair:
 			for existingParsedCertIndex, existingParsedCert := range existingCertRef.certificates {
@@ -232,15 +759,64 @@ func (exporter *Exporter) parseAllCertificates() ([]*certificateRef, []*certific
 		}
 	}
 
+	if exporter.StaleTolerance > 0 {
+		output = exporter.keepStaleCertificates(output, failed)
+	}
+
+	if exporter.ExposeIssuerMetrics {
+		for _, err := range exporter.resolveIssuers(output) {
+			raiseError(&certificateError{
+				err: err,
+			})
+		}
+	}
+
+	for _, err := range exporter.verifyCertificates(output) {
+		raiseError(&certificateError{
+			err: err,
+		})
+	}
+
+	exporter.storeLastParsed(output, outputErrors)
 	return output, outputErrors
 }
 
+// isLeafOnly : Tell if only the leaf of this source's bundle must be exported,
+// patterns are matched against source paths as shown in logs (e.g. "/etc/ssl/*.pem" or "k8s/default/*")
+func (exporter *Exporter) isLeafOnly(ref *certificateRef) bool {
+	for _, pattern := range exporter.LeafOnlySources {
+		if matched, _ := doublestar.Match(pattern, ref.path); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isAtomicWriterPath : Tell if a path goes through the hidden entries of a volume written by the kubelet,
+// ConfigMap and secret volumes exposing their keys as symlinks to a "..data" symlink to a "..<timestamp>" directory
+func isAtomicWriterPath(filepath string) bool {
+	for _, component := range strings.Split(filepath, "/") {
+		if strings.HasPrefix(component, "..") && component != ".." {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (exporter *Exporter) collectMatchingPaths(pattern string, format certificateFormat, directories bool) ([]*certificateRef, []error) {
 	output := []*certificateRef{}
 	outputErrors := []error{}
 	basepath, match := doublestar.SplitPattern(pattern)
+	// unless explicitly asked for, e.g. with "..data/*"
+	skipAtomicWriterPaths := !strings.Contains(match, "..")
 
 	walk := func(filepath string, entry fs.DirEntry) error {
+		if skipAtomicWriterPaths && isAtomicWriterPath(filepath) {
+			return nil
+		}
+
 		if directories {
 			if !entry.IsDir() {
 				return nil
@@ -254,7 +830,7 @@ func (exporter *Exporter) collectMatchingPaths(pattern string, format certificat
 			}
 
 			for _, file := range files {
-				if file.IsDir() {
+				if file.IsDir() || (skipAtomicWriterPaths && isAtomicWriterPath(file.Name())) {
 					continue
 				}
 
@@ -264,11 +840,18 @@ func (exporter *Exporter) collectMatchingPaths(pattern string, format certificat
 				})
 			}
 		} else {
-			output = append(output, &certificateRef{
-				path:      path.Clean(path.Join(basepath, filepath)),
-				format:    format,
-				yamlPaths: exporter.YAMLPaths,
-			})
+			ref := &certificateRef{
+				path:       path.Clean(path.Join(basepath, filepath)),
+				format:     format,
+				yamlPaths:  exporter.YAMLPaths,
+				iniKeys:    exporter.INIKeys,
+				dotenvKeys: exporter.DotenvKeys,
+			}
+			if format == certificateFormatYAML {
+				ref.yamlCache = exporter.getYAMLFileCache(ref.path)
+			}
+
+			output = append(output, ref)
 		}
 
 		return nil
@@ -330,11 +913,8 @@ func (exporter *Exporter) compareCertificates(
 	if filepath.Base(leftRef.path) != filepath.Base(rightRef.path) {
 		return false
 	}
-	if leftRef.format != certificateFormatKubeSecret {
-		if trimComponents(leftRef.path, exporter.TrimPathComponents) != trimComponents(rightRef.path, exporter.TrimPathComponents) {
-			return false
-		}
-	} else {
+	switch leftRef.format {
+	case certificateFormatKubeSecret:
 		if leftRef.kubeSecretKey != rightRef.kubeSecretKey {
 			return false
 		}
@@ -342,6 +922,14 @@ func (exporter *Exporter) compareCertificates(
 		if strings.Split(leftRef.path, "/")[1] != strings.Split(rightRef.path, "/")[1] {
 			return false
 		}
+	case certificateFormatSQL, certificateFormatEndpoint, certificateFormatGCS, certificateFormatAzureKeyVault, certificateFormatConsul, certificateFormatEtcd, certificateFormatWindowsStore, certificateFormatCABundle, certificateFormatClientCert, certificateFormatGit, certificateFormatLDAP, certificateFormatIngest, certificateFormatKubeIngress, certificateFormatFTP, certificateFormatNamedPipe, certificateFormatSystemdCredential:
+		if leftRef.path != rightRef.path {
+			return false
+		}
+	default:
+		if trimComponents(leftRef.path, exporter.TrimPathComponents) != trimComponents(rightRef.path, exporter.TrimPathComponents) {
+			return false
+		}
 	}
 
 	// non-base labels
@@ -355,15 +943,15 @@ func (exporter *Exporter) compareCertificates(
 		return false
 	}
 
-	if leftRef.format == certificateFormatYAML {
-		// embedded_kind
-		if strings.TrimRight(strings.Split(leftCert.yqMatchExpr, ".")[1], "s") != strings.TrimRight(strings.Split(rightCert.yqMatchExpr, ".")[1], "s") {
-			return false
-		}
+	if getEmbeddedKind(leftCert) != getEmbeddedKind(rightCert) {
+		return false
 	}
 	if leftCert.userID != rightCert.userID {
 		return false
 	}
+	if leftCert.sdsSecret != rightCert.sdsSecret {
+		return false
+	}
 
 	return true
 }
@@ -398,22 +986,53 @@ func first[T any](arr []T) T {
 	return arr[0]
 }
 
+// getEmbeddedKind : Kind of the entry a certificate was found in by a YAML path (e.g. "cluster" or "user"),
+// empty for certificates not read from YAML
+func getEmbeddedKind(certData *parsedCertificate) string {
+	if len(certData.yqMatchExpr) == 0 {
+		return ""
+	}
+
+	return strings.TrimRight(strings.Split(certData.yqMatchExpr, ".")[1], "s")
+}
+
 // getLabels : Generate metrics labels for a given certificate
 // WARNING! If you update this function, please make sure that the `compareCertificates` function is updated accordingly.
 func (exporter *Exporter) getLabels(certData *parsedCertificate, ref *certificateRef) map[string]string {
 	labels := exporter.getBaseLabels(ref)
 
-	labels["serial_number"] = certData.cert.SerialNumber.String()
-	fillLabelsFromName(&certData.cert.Issuer, "issuer", labels)
-	fillLabelsFromName(&certData.cert.Subject, "subject", labels)
+	labels[serialNumberLabel.name] = certData.cert.SerialNumber.String()
+	fillLabelsFromName(&certData.cert.Issuer, issuerLabels, labels)
+	fillLabelsFromName(&certData.cert.Subject, subjectLabels, labels)
 
-	if ref.format == certificateFormatYAML {
-		kind := strings.Split(certData.yqMatchExpr, ".")[1]
-		labels["embedded_kind"] = strings.TrimRight(kind, "s")
+	if kind := getEmbeddedKind(certData); len(kind) > 0 {
+		labels[embeddedKindLabel.name] = kind
 	}
 
 	if len(certData.userID) > 0 {
-		labels["embedded_key"] = certData.userID
+		labels[embeddedKeyLabel.name] = certData.userID
+	}
+
+	if len(certData.sdsSecret) > 0 {
+		labels[sdsSecretLabel.name] = certData.sdsSecret
+	}
+
+	if exporter.ExposeFormatLabel {
+		labels[formatLabel.name] = getFormatName(certData, ref)
+	}
+
+	// built-in labels can't be overridden
+	for key, value := range exporter.getMappedLabels(certData, ref) {
+		if _, found := labels[key]; !found {
+			labels[key] = value
+		}
+	}
+	if ref.ingested != nil {
+		for key, value := range ref.ingested.labels {
+			if _, found := labels[key]; !found {
+				labels[key] = value
+			}
+		}
 	}
 
 	return labels
@@ -435,13 +1054,80 @@ func trimComponents(filepath string, trimCount int) string {
 func (exporter *Exporter) getBaseLabels(ref *certificateRef) map[string]string {
 	labels := map[string]string{}
 
-	if ref.format != certificateFormatKubeSecret {
-		labels["filename"] = filepath.Base(ref.path)
-		labels["filepath"] = trimComponents(ref.path, exporter.TrimPathComponents)
-	} else {
-		labels["secret_name"] = filepath.Base(ref.path)
-		labels["secret_namespace"] = strings.Split(ref.path, "/")[1]
-		labels["secret_key"] = ref.kubeSecretKey
+	switch ref.format {
+	case certificateFormatKubeSecret:
+		labels[secretNameLabel.name] = filepath.Base(ref.path)
+		labels[secretNamespaceLabel.name] = strings.Split(ref.path, "/")[1]
+		labels[secretKeyLabel.name] = ref.kubeSecretKey
+		if exporter.KubeSecretOwnerLabels {
+			if owner := getSecretOwner(&ref.kubeSecret); owner != nil {
+				labels[secretOwnerKindLabel.name] = owner.Kind
+				labels[secretOwnerNameLabel.name] = owner.Name
+			}
+		}
+	case certificateFormatSQL:
+		labels[sqlSourceLabel.name] = strings.TrimPrefix(ref.path, "sql/")
+	case certificateFormatEndpoint:
+		labels[endpointLabel.name] = ref.endpoint.endpoint.Address
+		if len(ref.endpoint.endpoint.Component) > 0 {
+			labels[controlPlaneComponentLabel.name] = ref.endpoint.endpoint.Component
+		}
+	case certificateFormatGCS:
+		labels[gcsBucketLabel.name] = ref.gcsObject.object.Bucket
+		labels[gcsObjectLabel.name] = ref.gcsObject.object.Object
+	case certificateFormatGit:
+		labels[gitRepositoryLabel.name] = ref.gitFile.file.Repository
+		labels[gitRefLabel.name] = ref.gitFile.file.Ref
+		labels[gitPathLabel.name] = ref.gitFile.file.Path
+	case certificateFormatAzureKeyVault:
+		labels[azureVaultLabel.name] = strings.TrimPrefix(ref.azureCert.VaultURL, "https://")
+		labels[azureCertificateLabel.name] = ref.azureCert.Name
+	case certificateFormatConsul:
+		labels[consulDatacenterLabel.name] = ref.consulKey.Datacenter
+		labels[consulKeyLabel.name] = ref.consulKey.Key
+	case certificateFormatEtcd:
+		labels[etcdEndpointsLabel.name] = strings.Join(ref.etcdKey.Endpoints, ",")
+		labels[etcdKeyLabel.name] = ref.etcdKey.Key
+	case certificateFormatLDAP:
+		labels[ldapServerLabel.name] = ref.ldapSearch.URL
+		labels[ldapBaseDNLabel.name] = ref.ldapSearch.BaseDN
+	case certificateFormatFTP:
+		labels[ftpServerLabel.name] = ref.ftpFile.URL
+		labels[ftpPathLabel.name] = ref.ftpFile.Path
+	case certificateFormatWindowsStore:
+		labels[windowsStoreLocationLabel.name] = ref.windowsStore.Location
+		labels[windowsStoreNameLabel.name] = ref.windowsStore.Name
+	case certificateFormatNamedPipe:
+		labels[namedPipeLabel.name] = ref.path
+	case certificateFormatSystemdCredential:
+		labels[systemdCredentialLabel.name] = path.Base(ref.path)
+	case certificateFormatCABundle:
+		labels[caBundleKindLabel.name] = ref.caBundle.kind
+		labels[caBundleObjectLabel.name] = ref.caBundle.name
+		if len(ref.caBundle.webhook) > 0 {
+			labels[caBundleWebhookLabel.name] = ref.caBundle.webhook
+		}
+	case certificateFormatClientCert:
+		labels[clientAddressLabel.name] = ref.clientCert.address
+	case certificateFormatKubeIngress:
+		getKubeIngressLabels(ref.kubeIngress, labels)
+	case certificateFormatIngest:
+		labels[ingestAgentLabel.name] = ref.ingested.agent
+		labels[ingestSourceLabel.name] = ref.ingested.source
+	case certificateFormatSelf:
+		labels[selfLabel.name] = "true"
+		labels[filenameLabel.name] = filepath.Base(ref.path)
+		labels[filepathLabel.name] = trimComponents(ref.path, exporter.TrimPathComponents)
+	default:
+		labels[filenameLabel.name] = filepath.Base(ref.path)
+		labels[filepathLabel.name] = trimComponents(ref.path, exporter.TrimPathComponents)
+		if len(ref.subjectHash) > 0 {
+			labels[subjectHashLabel.name] = ref.subjectHash
+		}
+	}
+
+	if len(ref.certificateMonitor) > 0 {
+		labels[certificateMonitorLabel.name] = ref.certificateMonitor
 	}
 
 	return labels
@@ -469,29 +1155,29 @@ func (exporter *Exporter) unzipLabels(labels map[string]string) ([]string, []str
 	return labelKeys, labelValues
 }
 
-func fillLabelsFromName(name *pkix.Name, prefix string, output map[string]string) {
+func fillLabelsFromName(name *pkix.Name, labels nameLabels, output map[string]string) {
 	if len(name.Country) > 0 {
-		output[prefix+"_C"] = name.Country[0]
+		output[labels.country.name] = name.Country[0]
 	}
 
 	if len(name.StreetAddress) > 0 {
-		output[prefix+"_ST"] = name.StreetAddress[0]
+		output[labels.streetAddress.name] = name.StreetAddress[0]
 	}
 
 	if len(name.Locality) > 0 {
-		output[prefix+"_L"] = name.Locality[0]
+		output[labels.locality.name] = name.Locality[0]
 	}
 
 	if len(name.Organization) > 0 {
-		output[prefix+"_O"] = name.Organization[0]
+		output[labels.organization.name] = name.Organization[0]
 	}
 
 	if len(name.OrganizationalUnit) > 0 {
-		output[prefix+"_OU"] = name.OrganizationalUnit[0]
+		output[labels.organizationalUnit.name] = name.OrganizationalUnit[0]
 	}
 
 	if len(name.CommonName) > 0 {
-		output[prefix+"_CN"] = name.CommonName
+		output[labels.commonName.name] = name.CommonName
 	}
 }
 
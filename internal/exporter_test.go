@@ -2,23 +2,35 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -232,6 +244,35 @@ func TestYAMLMixed(t *testing.T) {
 	})
 }
 
+func TestYAMLMissingFileReference(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	mixed, err := os.ReadFile(path.Join(filepath.Dir(filename), "../test/yaml-mixed.conf"))
+	assert.NoError(t, err)
+
+	// a base64 CA, a missing file and an existing one
+	kubeconfig := strings.ReplaceAll(string(mixed), "client-certificate: ./basic.pem", "client-certificate: ./does-not-exist.pem")
+	kubeconfig += fmt.Sprintf("- name: other-auth\n  user:\n    client-certificate: %s\n", path.Join(filepath.Dir(filename), "../test/basic.pem"))
+	kubeconfigPath := path.Join(t.TempDir(), "kubeconfig")
+	assert.NoError(t, os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0600))
+
+	testRequest(t, &Exporter{
+		YAMLs:     []string{kubeconfigPath},
+		YAMLPaths: DefaultYamlPaths,
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		keys := []string{}
+		for _, metric := range foundMetrics {
+			keys = append(keys, getLabelValue(metric, "embedded_key"))
+		}
+		sort.Strings(keys)
+		assert.Equal(t, []string{"default-cluster", "other-auth"}, keys)
+
+		errorMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
+		assert.Equal(t, 1., errorMetric[0].GetGauge().GetValue(), "invalid x509_read_errors value")
+	})
+}
+
 func TestNonExistentPEMFile(t *testing.T) {
 	testRequest(t, &Exporter{
 		Files: []string{"./does-not-exists.pem"},
@@ -297,14 +338,16 @@ func TestNonExistentYAMLPath(t *testing.T) {
 		YAMLs:     []string{path.Join(filepath.Dir(filename), "../test/yaml-paths-error.conf")},
 		YAMLPaths: DefaultYamlPaths,
 	}, func(metrics []model.MetricFamily) {
+		// the client certificate is still read
 		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
-		assert.Len(t, foundMetrics, 0, "missing x509_cert_expired metric(s)")
+		assert.Len(t, foundMetrics, 1, "missing x509_cert_expired metric(s)")
 
 		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
-		assert.Len(t, foundNbMetrics, 0, "missing x509_cert_not_before metric(s)")
+		assert.Len(t, foundNbMetrics, 1, "missing x509_cert_not_before metric(s)")
 
 		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
-		assert.Len(t, foundNaMetrics, 0, "missing x509_cert_not_after metric(s)")
+		assert.Len(t, foundNaMetrics, 1, "missing x509_cert_not_after metric(s)")
+		assert.Equal(t, "default-auth", getLabelValue(foundNaMetrics[0], "embedded_key"))
 
 		errorMetric := getMetricsForName(metrics, "x509_read_errors")
 		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
@@ -453,126 +496,1575 @@ func TestInvalidYAML(t *testing.T) {
 		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
 		assert.Len(t, foundMetrics, 0, "extra x509_cert_expired metric(s)")
 
-		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
-		assert.Len(t, foundNbMetrics, 0, "extra x509_cert_not_before metric(s)")
+		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
+		assert.Len(t, foundNbMetrics, 0, "extra x509_cert_not_before metric(s)")
+
+		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		assert.Len(t, foundNaMetrics, 0, "extra x509_cert_not_after metric(s)")
+
+		errorMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
+		assert.Equal(t, errorMetric[0].GetGauge().GetValue(), 1., "invalid x509_read_errors value")
+	})
+
+	testRequest(t, &Exporter{
+		YAMLs: []string{path.Join(filepath.Dir(filename), "../test/invalid-yaml.conf")},
+		YAMLPaths: []YAMLCertRef{
+			{
+				BasePathMatchExpr: "$.clusters",
+				CertMatchSubExpr:  "$.badarrayelem",
+				IDMatchSubExpr:    "$.name",
+				Format:            YAMLCertFormatBase64,
+			},
+		},
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
+		assert.Len(t, foundMetrics, 0, "extra x509_cert_expired metric(s)")
+
+		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
+		assert.Len(t, foundNbMetrics, 0, "extra x509_cert_not_before metric(s)")
+
+		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		assert.Len(t, foundNaMetrics, 0, "extra x509_cert_not_after metric(s)")
+
+		errorMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
+		assert.Equal(t, errorMetric[0].GetGauge().GetValue(), 1., "invalid x509_read_errors value")
+	})
+}
+
+func TestInvalidYAMLMatchExpr(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+
+	testRequest(t, &Exporter{
+		YAMLs: []string{path.Join(filepath.Dir(filename), "../test/yaml-embedded.conf")},
+		YAMLPaths: []YAMLCertRef{
+			{
+				BasePathMatchExpr: "invalid",
+				CertMatchSubExpr:  "invalid",
+				IDMatchSubExpr:    "$.clusters[].name",
+				Format:            YAMLCertFormatBase64,
+			},
+		},
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
+		assert.Len(t, foundMetrics, 0, "extra x509_cert_expired metric(s)")
+
+		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
+		assert.Len(t, foundNbMetrics, 0, "extra x509_cert_not_before metric(s)")
+
+		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		assert.Len(t, foundNaMetrics, 0, "extra x509_cert_not_after metric(s)")
+
+		errorMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
+		assert.Equal(t, errorMetric[0].GetGauge().GetValue(), 1., "invalid x509_read_errors value")
+	})
+
+	testRequest(t, &Exporter{
+		YAMLs: []string{path.Join(filepath.Dir(filename), "../test/yaml-embedded.conf")},
+		YAMLPaths: []YAMLCertRef{
+			{
+				BasePathMatchExpr: "$.clusters",
+				CertMatchSubExpr:  "$.cluster[\"certificate-authority-data\"]",
+				IDMatchSubExpr:    "$[0]",
+				Format:            YAMLCertFormatBase64,
+			},
+		},
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
+		assert.Len(t, foundMetrics, 0, "extra x509_cert_expired metric(s)")
+
+		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
+		assert.Len(t, foundNbMetrics, 0, "extra x509_cert_not_before metric(s)")
+
+		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		assert.Len(t, foundNaMetrics, 0, "extra x509_cert_not_after metric(s)")
+
+		errorMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
+		assert.Equal(t, errorMetric[0].GetGauge().GetValue(), 1., "invalid x509_read_errors value")
+	})
+}
+
+func TestMultipleCerts(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+
+	testRequest(t, &Exporter{
+		YAMLs: []string{path.Join(filepath.Dir(filename), "../test/yaml-multi-certs.conf")},
+		YAMLPaths: []YAMLCertRef{
+			{
+				BasePathMatchExpr: "$.clusters",
+				CertMatchSubExpr:  "$.cluster[\"certificate-authority-data\"]",
+				IDMatchSubExpr:    "$.name",
+				Format:            YAMLCertFormatBase64,
+			},
+		},
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
+		assert.Len(t, foundMetrics, 2, "extra x509_cert_expired metric(s)")
+
+		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
+		assert.Len(t, foundNbMetrics, 2, "extra x509_cert_not_before metric(s)")
+
+		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		assert.Len(t, foundNaMetrics, 2, "extra x509_cert_not_after metric(s)")
+
+		errorMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
+		assert.Equal(t, errorMetric[0].GetGauge().GetValue(), 0., "invalid x509_read_errors value")
+	})
+}
+
+func TestYAMLGuards(t *testing.T) {
+	test := func(guards []YAMLGuard, mode YAMLGuardMode, expected []string) {
+		testRequest(t, &Exporter{
+			YAMLs: []string{"../test/yaml/guards.yaml"},
+			YAMLPaths: []YAMLCertRef{
+				{
+					BasePathMatchExpr: "$.clusters",
+					CertMatchSubExpr:  "$.cluster[\"certificate-authority-data\"]",
+					IDMatchSubExpr:    "$.name",
+					Format:            YAMLCertFormatBase64,
+					Guards:            guards,
+					GuardMode:         mode,
+				},
+			},
+		}, func(metrics []model.MetricFamily) {
+			foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
+			assert.Len(t, foundMetrics, len(expected))
+
+			keys := []string{}
+			for _, metric := range foundMetrics {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "embedded_key" {
+						keys = append(keys, label.GetValue())
+					}
+				}
+			}
+			assert.ElementsMatch(t, expected, keys)
+
+			// a file with no matching certificate is a read error
+			expectedErrors := 0.
+			if len(expected) == 0 {
+				expectedErrors = 1.
+			}
+			errMetric := getMetricsForName(metrics, "x509_read_errors")
+			assert.Equal(t, expectedErrors, errMetric[0].GetGauge().GetValue())
+		})
+	}
+
+	// no guard
+	test(nil, YAMLGuardModeAll, []string{"production", "staging"})
+
+	// a single guard suppresses the staging cluster CA
+	test([]YAMLGuard{
+		{MatchSubExpr: "$.cluster.server", Value: "https://prod.example.com:6443"},
+	}, YAMLGuardModeAll, []string{"production"})
+
+	// AND: both guards have to match
+	test([]YAMLGuard{
+		{MatchSubExpr: "$.cluster.server", Value: "https://prod.example.com:6443"},
+		{MatchSubExpr: "$.cluster[\"insecure-skip-tls-verify\"]", Value: "true"},
+	}, YAMLGuardModeAll, []string{})
+
+	// OR: any guard can match
+	test([]YAMLGuard{
+		{MatchSubExpr: "$.cluster.server", Value: "https://prod.example.com:6443"},
+		{MatchSubExpr: "$.cluster[\"insecure-skip-tls-verify\"]", Value: "true"},
+	}, YAMLGuardModeAny, []string{"production", "staging"})
+}
+
+func TestYAMLNestedValues(t *testing.T) {
+	yamlPaths, err := LoadYAMLPaths("../test/yaml/values-paths.yaml")
+	assert.NoError(t, err)
+	assert.Len(t, yamlPaths, 2)
+
+	testRequest(t, &Exporter{
+		YAMLs:     []string{"../test/yaml/values.yaml"},
+		YAMLPaths: yamlPaths,
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
+		assert.Len(t, foundMetrics, 4)
+
+		keys := []string{}
+		for _, metric := range foundMetrics {
+			keys = append(keys, getLabelValue(metric, "embedded_key"))
+		}
+		assert.ElementsMatch(t, []string{"ingress", "api", "web-a", "web-b"}, keys)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+func TestYAMLMultipleDocuments(t *testing.T) {
+	encode := func(cert *testCertificate) string {
+		return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.cert.Raw}))
+	}
+
+	// two documents with certificates, an empty one and one without the base path
+	yamlPath := path.Join(t.TempDir(), "stream.yaml")
+	err := os.WriteFile(yamlPath, []byte(fmt.Sprintf(`clusters:
+- name: first
+  cluster:
+    certificate-authority-data: %s
+---
+---
+kind: ConfigMap
+---
+clusters:
+- name: second
+  cluster:
+    certificate-authority-data: %s
+`,
+		encode(generateTestCertificate(caTemplate("first", time.Now().Add(time.Hour)), nil)),
+		encode(generateTestCertificate(caTemplate("second", time.Now().Add(time.Hour)), nil)),
+	)), 0644)
+	assert.NoError(t, err)
+
+	test := func(idExpr string, expected map[string]string) {
+		testRequest(t, &Exporter{
+			YAMLs: []string{yamlPath},
+			YAMLPaths: []YAMLCertRef{
+				{
+					BasePathMatchExpr: "$.clusters",
+					CertMatchSubExpr:  "$.cluster[\"certificate-authority-data\"]",
+					IDMatchSubExpr:    idExpr,
+					Format:            YAMLCertFormatBase64,
+				},
+			},
+		}, func(metrics []model.MetricFamily) {
+			found := map[string]string{}
+			for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+				found[getLabelValue(metric, "embedded_key")] = getLabelValue(metric, "subject_CN")
+			}
+			assert.Equal(t, expected, found)
+
+			errMetric := getMetricsForName(metrics, "x509_read_errors")
+			assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+		})
+	}
+
+	test("$.name", map[string]string{"first": "first", "second": "second"})
+
+	// default IDs are numbered across documents
+	test("", map[string]string{"0": "first", "1": "second"})
+}
+
+func TestYAMLHexFormat(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	leaf := generateTestCertificate(leafTemplate("leaf", time.Now().Add(time.Hour)), root)
+
+	// colon separated bytes are accepted too
+	colonHex := strings.ToUpper(hex.EncodeToString(leaf.cert.Raw))
+	colonHex = strings.Join(regexp.MustCompile("..").FindAllString(colonHex, -1), ":")
+
+	yamlPath := path.Join(t.TempDir(), "hex.yaml")
+	err := os.WriteFile(yamlPath, []byte(fmt.Sprintf(`tls:
+- name: chain
+  der: |
+    %s
+    %s
+- name: broken
+  der: "zz"
+`, colonHex, hex.EncodeToString(root.cert.Raw))), 0644)
+	assert.NoError(t, err)
+
+	yamlPaths := []YAMLCertRef{
+		{
+			BasePathMatchExpr: "$.tls",
+			CertMatchSubExpr:  "$.der",
+			IDMatchSubExpr:    "$.name",
+			Format:            YAMLCertFormatHex,
+			Guards:            []YAMLGuard{{MatchSubExpr: "$.name", Value: "chain"}},
+		},
+	}
+
+	testRequest(t, &Exporter{
+		YAMLs:     []string{yamlPath},
+		YAMLPaths: yamlPaths,
+	}, func(metrics []model.MetricFamily) {
+		found := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			found[getLabelValue(metric, "embedded_key")] = getLabelValue(metric, "subject_CN")
+		}
+		assert.Equal(t, map[string]string{"chain(0)": "leaf", "chain(1)": "root"}, found)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+	})
+
+	// invalid hex is a read error
+	yamlPaths[0].Guards = nil
+	testRequest(t, &Exporter{
+		YAMLs:     []string{yamlPath},
+		YAMLPaths: yamlPaths,
+	}, func(metrics []model.MetricFamily) {
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+func TestJSONBase64DERArray(t *testing.T) {
+	first := generateTestCertificate(caTemplate("first", time.Now().Add(time.Hour)), nil)
+	second := generateTestCertificate(caTemplate("second", time.Now().Add(time.Hour)), nil)
+
+	jsonPath := path.Join(t.TempDir(), "certs.json")
+	err := os.WriteFile(jsonPath, []byte(fmt.Sprintf(`{"certs": ["%s", "%s"]}`,
+		base64.StdEncoding.EncodeToString(first.cert.Raw),
+		base64.StdEncoding.EncodeToString(second.cert.Raw),
+	)), 0644)
+	assert.NoError(t, err)
+
+	testRequest(t, &Exporter{
+		YAMLs: []string{jsonPath},
+		YAMLPaths: []YAMLCertRef{
+			{
+				BasePathMatchExpr: "$.certs",
+				CertMatchSubExpr:  "$",
+				Format:            YAMLCertFormatBase64DER,
+			},
+		},
+	}, func(metrics []model.MetricFamily) {
+		found := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			found[getLabelValue(metric, "embedded_key")] = getLabelValue(metric, "subject_CN")
+		}
+		assert.Equal(t, map[string]string{"0": "first", "1": "second"}, found)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+func TestLoadYAMLPathsErrors(t *testing.T) {
+	_, err := LoadYAMLPaths("../test/yaml/does-not-exist.yaml")
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	for _, contents := range []string{
+		"not: a list",
+		"- cert: $.crt",
+		"- {base: $.tls, cert: $.crt, format: der}",
+		"- {base: $.tls, cert: $.crt, guardMode: none}",
+	} {
+		err := os.WriteFile(path.Join(dir, "paths.yaml"), []byte(contents), 0644)
+		assert.NoError(t, err)
+		_, err = LoadYAMLPaths(path.Join(dir, "paths.yaml"))
+		assert.Error(t, err, contents)
+	}
+}
+
+func TestIssuerNotAfter(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	leaf := generateTestCertificate(leafTemplate("leaf", time.Now().Add(24*time.Hour)), root)
+
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "bundle.pem"), leaf, root)
+	writeTestCertificates(path.Join(dir, "leaf.pem"), leaf)
+	writeTestCertificates(path.Join(dir, "root.pem"), root)
+
+	// issuer in the same bundle
+	testRequest(t, &Exporter{
+		Files:               []string{path.Join(dir, "bundle.pem")},
+		ExposeIssuerMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_issuer_not_after")
+		assert.Len(t, foundMetrics, 1)
+		assert.Equal(t, "leaf", getLabelValue(foundMetrics[0], "subject_CN"))
+		assert.Equal(t, float64(root.cert.NotAfter.Unix()), foundMetrics[0].GetGauge().GetValue())
+		assert.Less(t, foundMetrics[0].GetGauge().GetValue(), float64(leaf.cert.NotAfter.Unix()))
+	})
+
+	// issuer in a CA file, which isn't exported itself
+	testRequest(t, &Exporter{
+		Files:               []string{path.Join(dir, "leaf.pem")},
+		CAFiles:             []string{path.Join(dir, "root.pem")},
+		ExposeIssuerMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_expired"), 1)
+		foundMetrics := getMetricsForName(metrics, "x509_cert_issuer_not_after")
+		assert.Len(t, foundMetrics, 1)
+		assert.Equal(t, float64(root.cert.NotAfter.Unix()), foundMetrics[0].GetGauge().GetValue())
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+	})
+
+	// unknown issuer, unreadable CA file
+	testRequest(t, &Exporter{
+		Files:               []string{path.Join(dir, "leaf.pem")},
+		CAFiles:             []string{path.Join(dir, "does-not-exist.pem")},
+		ExposeIssuerMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_issuer_not_after"), 0)
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+func TestOutlivesIssuer(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(2*time.Hour)), nil)
+	intermediate := generateTestCertificate(caTemplate("intermediate", time.Now().Add(time.Hour)), root)
+	misissued := generateTestCertificate(leafTemplate("misissued", time.Now().Add(24*time.Hour)), intermediate)
+	valid := generateTestCertificate(leafTemplate("valid", time.Now().Add(30*time.Minute)), intermediate)
+
+	certPath := path.Join(t.TempDir(), "bundle.pem")
+	writeTestCertificates(certPath, misissued, valid, intermediate, root)
+
+	testRequest(t, &Exporter{
+		Files:               []string{certPath},
+		ExposeIssuerMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		outlives := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_outlives_issuer") {
+			outlives[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+
+		// the self-signed root has no issuer
+		assert.Equal(t, map[string]float64{"misissued": 1, "valid": 0, "intermediate": 0}, outlives)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{certPath},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_outlives_issuer"), 0)
+	})
+}
+
+func TestIssuerChainBroken(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	expiredRoot := generateTestCertificate(caTemplate("expired-root", time.Now().Add(-30*time.Minute)), nil)
+	unknownRoot := generateTestCertificate(caTemplate("unknown-root", time.Now().Add(time.Hour)), nil)
+	intermediate := generateTestCertificate(caTemplate("intermediate", time.Now().Add(time.Hour)), root)
+	expiredIntermediate := generateTestCertificate(caTemplate("expired-intermediate", time.Now().Add(-30*time.Minute)), root)
+	underExpiredRoot := generateTestCertificate(caTemplate("under-expired-root", time.Now().Add(time.Hour)), expiredRoot)
+
+	certPath := path.Join(t.TempDir(), "bundle.pem")
+	writeTestCertificates(certPath,
+		generateTestCertificate(leafTemplate("healthy", time.Now().Add(time.Hour)), intermediate),
+		generateTestCertificate(leafTemplate("expired-issuer", time.Now().Add(time.Hour)), expiredIntermediate),
+		generateTestCertificate(leafTemplate("expired-ancestor", time.Now().Add(time.Hour)), underExpiredRoot),
+		generateTestCertificate(leafTemplate("missing-issuer", time.Now().Add(time.Hour)), unknownRoot),
+		generateTestCertificate(leafTemplate("expired", time.Now().Add(-time.Minute)), expiredIntermediate),
+		intermediate, expiredIntermediate, underExpiredRoot, root, expiredRoot,
+	)
+
+	testRequest(t, &Exporter{
+		Files:               []string{certPath},
+		ExposeIssuerMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		broken := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_issuer_chain_broken") {
+			broken[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+
+		// CAs and expired leaves aren't reported
+		assert.Equal(t, map[string]float64{
+			"healthy":          0,
+			"expired-issuer":   1,
+			"expired-ancestor": 1,
+			"missing-issuer":   1,
+		}, broken)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{certPath},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_issuer_chain_broken"), 0)
+	})
+}
+
+func TestVerified(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	intermediate := generateTestCertificate(caTemplate("intermediate", time.Now().Add(time.Hour)), root)
+	otherRoot := generateTestCertificate(caTemplate("other-root", time.Now().Add(time.Hour)), nil)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(path.Join(dir, "roots"), 0755))
+	writeTestCertificates(path.Join(dir, "roots", "root.pem"), root)
+	writeTestCertificates(path.Join(dir, "complete.pem"), generateTestCertificate(leafTemplate("complete", time.Now().Add(time.Hour)), intermediate), intermediate)
+	writeTestCertificates(path.Join(dir, "incomplete.pem"), generateTestCertificate(leafTemplate("incomplete", time.Now().Add(time.Hour)), intermediate))
+	writeTestCertificates(path.Join(dir, "untrusted.pem"), generateTestCertificate(leafTemplate("untrusted", time.Now().Add(time.Hour)), otherRoot))
+
+	getVerified := func(metrics []model.MetricFamily) map[string]float64 {
+		verified := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_verified") {
+			verified[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+		return verified
+	}
+
+	testRequest(t, &Exporter{
+		Files:            []string{path.Join(dir, "*.pem")},
+		TrustedRootFiles: []string{path.Join(dir, "roots", "root.pem")},
+	}, func(metrics []model.MetricFamily) {
+		// intermediates aren't verified, only leaves
+		assert.Equal(t, map[string]float64{"complete": 1, "incomplete": 0, "untrusted": 0}, getVerified(metrics))
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+	})
+
+	// system roots are combined with the additional ones
+	testRequest(t, &Exporter{
+		Files:            []string{path.Join(dir, "complete.pem")},
+		TrustedRootFiles: []string{path.Join(dir, "roots", "root.pem"), path.Join(dir, "roots", "missing.pem")},
+		UseSystemRoots:   true,
+	}, func(metrics []model.MetricFamily) {
+		assert.Equal(t, map[string]float64{"complete": 1}, getVerified(metrics))
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_verified"), 0)
+	})
+}
+
+func TestChainsToRoot(t *testing.T) {
+	oldRoot := generateTestCertificate(caTemplate("old-root", time.Now().Add(time.Hour)), nil)
+	newRoot := generateTestCertificate(caTemplate("new-root", time.Now().Add(time.Hour)), nil)
+	oldIntermediate := generateTestCertificate(caTemplate("old-intermediate", time.Now().Add(time.Hour)), oldRoot)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(path.Join(dir, "roots"), 0755))
+	writeTestCertificates(path.Join(dir, "roots", "roots.pem"), oldRoot, newRoot)
+	writeTestCertificates(path.Join(dir, "legacy.pem"), generateTestCertificate(leafTemplate("legacy", time.Now().Add(time.Hour)), oldIntermediate), oldIntermediate)
+	writeTestCertificates(path.Join(dir, "migrated.pem"), generateTestCertificate(leafTemplate("migrated", time.Now().Add(time.Hour)), newRoot))
+	writeTestCertificates(path.Join(dir, "incomplete.pem"), generateTestCertificate(leafTemplate("incomplete", time.Now().Add(time.Hour)), oldIntermediate))
+
+	oldFingerprint := sha256.Sum256(oldRoot.cert.Raw)
+	pinned, err := ParseFingerprint(strings.ToUpper(hex.EncodeToString(oldFingerprint[:])))
+	assert.NoError(t, err)
+
+	testRequest(t, &Exporter{
+		Files:            []string{path.Join(dir, "*.pem")},
+		TrustedRootFiles: []string{path.Join(dir, "roots", "roots.pem")},
+		PinnedRoots:      []string{pinned},
+	}, func(metrics []model.MetricFamily) {
+		chainsToRoot := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_chains_to_root") {
+			assert.Equal(t, hex.EncodeToString(oldFingerprint[:]), getLabelValue(metric, "root_fingerprint"))
+			chainsToRoot[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+
+		// unverified leaves don't chain to any root
+		assert.Equal(t, map[string]float64{"legacy": 1, "migrated": 0, "incomplete": 0}, chainsToRoot)
+	})
+
+	testRequest(t, &Exporter{
+		Files:            []string{path.Join(dir, "*.pem")},
+		TrustedRootFiles: []string{path.Join(dir, "roots", "roots.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_chains_to_root"), 0)
+	})
+
+	for _, invalid := range []string{"", "abcd", strings.Repeat("zz", sha256.Size)} {
+		_, err := ParseFingerprint(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestWeakRSAKey(t *testing.T) {
+	dir := t.TempDir()
+	for _, bits := range []int{1024, 2048} {
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		assert.NoError(t, err)
+		template := caTemplate(fmt.Sprintf("rsa-%d", bits), time.Now().Add(time.Hour))
+		template.SerialNumber = big.NewInt(1)
+		derBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		assert.NoError(t, err)
+		cert, err := x509.ParseCertificate(derBytes)
+		assert.NoError(t, err)
+		writeTestCertificates(path.Join(dir, fmt.Sprintf("rsa-%d.pem", bits)), &testCertificate{cert: cert})
+	}
+	writeTestCertificates(path.Join(dir, "ecdsa.pem"), generateTestCertificate(caTemplate("ecdsa", time.Now().Add(time.Hour)), nil))
+
+	testRequest(t, &Exporter{
+		Files:         []string{path.Join(dir, "*.pem")},
+		MinRSAKeySize: 2048,
+	}, func(metrics []model.MetricFamily) {
+		weak := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_weak_rsa_key") {
+			weak[getLabelValue(metric, "subject_CN")+"/"+getLabelValue(metric, "key_size")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"rsa-1024/1024": 1, "rsa-2048/2048": 0}, weak)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_weak_rsa_key"), 0)
+	})
+}
+
+func TestFarFutureNotAfter(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "2040.pem"), generateTestCertificate(caTemplate("year-2040", time.Date(2040, time.January, 1, 0, 0, 0, 0, time.UTC)), nil))
+	writeTestCertificates(path.Join(dir, "soon.pem"), generateTestCertificate(caTemplate("soon", time.Now().Add(time.Hour)), nil))
+
+	testRequest(t, &Exporter{
+		Files:             []string{path.Join(dir, "*.pem")},
+		FarFutureNotAfter: DefaultFarFutureNotAfter,
+	}, func(metrics []model.MetricFamily) {
+		farFuture := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after_far_future") {
+			farFuture[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"year-2040": 1, "soon": 0}, farFuture)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_not_after_far_future"), 0)
+	})
+}
+
+func TestSANExpiry(t *testing.T) {
+	template := leafTemplate("multi", time.Now().Add(time.Hour))
+	template.DNSNames = []string{"api.example.com", "www.example.com", "WWW.example.com", "admin.example.com"}
+	template.IPAddresses = []net.IP{net.ParseIP("192.0.2.1")}
+	template.EmailAddresses = []string{"admin@example.com"}
+
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "multi.pem"), generateTestCertificate(template, nil))
+
+	getSANs := func(metrics []model.MetricFamily) []string {
+		sans := []string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_san_expires_in_seconds") {
+			assert.Equal(t, "multi", getLabelValue(metric, "subject_CN"))
+			assert.InDelta(t, time.Hour.Seconds(), metric.GetGauge().GetValue(), 60)
+			sans = append(sans, getLabelValue(metric, "san"))
+		}
+		sort.Strings(sans)
+		return sans
+	}
+
+	testRequest(t, &Exporter{
+		Files:                  []string{path.Join(dir, "multi.pem")},
+		ExposeSANExpiryMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		assert.Equal(t, []string{"192.0.2.1", "admin.example.com", "api.example.com", "www.example.com"}, getSANs(metrics))
+	})
+
+	testRequest(t, &Exporter{
+		Files:                  []string{path.Join(dir, "multi.pem")},
+		ExposeSANExpiryMetrics: true,
+		SANExpiryLimit:         2,
+	}, func(metrics []model.MetricFamily) {
+		assert.Equal(t, []string{"api.example.com", "www.example.com"}, getSANs(metrics))
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "multi.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getSANs(metrics), 0)
+	})
+}
+
+func TestChainDepth(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	intermediate := generateTestCertificate(caTemplate("intermediate", time.Now().Add(time.Hour)), root)
+
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "two.pem"), generateTestCertificate(leafTemplate("two", time.Now().Add(time.Hour)), root), root)
+	writeTestCertificates(path.Join(dir, "three.pem"), generateTestCertificate(leafTemplate("three", time.Now().Add(time.Hour)), intermediate), intermediate, root)
+	writeTestCertificates(path.Join(dir, "incomplete.pem"), generateTestCertificate(leafTemplate("incomplete", time.Now().Add(time.Hour)), intermediate), intermediate)
+
+	testRequest(t, &Exporter{
+		Files:                   []string{path.Join(dir, "*.pem")},
+		ExposeChainDepthMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		depths := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_chain_depth") {
+			depths[getLabelValue(metric, "filename")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"two.pem": 2, "three.pem": 3}, depths)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_chain_depth"), 0)
+	})
+}
+
+func TestChainOutOfOrder(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	intermediate := generateTestCertificate(caTemplate("intermediate", time.Now().Add(time.Hour)), root)
+	leaf := generateTestCertificate(leafTemplate("leaf", time.Now().Add(time.Hour)), intermediate)
+
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "ordered.pem"), leaf, intermediate, root)
+	writeTestCertificates(path.Join(dir, "reversed.pem"), root, intermediate, leaf)
+	writeTestCertificates(path.Join(dir, "swapped.pem"), leaf, root, intermediate)
+	// not chains
+	writeTestCertificates(path.Join(dir, "leaf.pem"), leaf)
+	writeTestCertificates(path.Join(dir, "cas.pem"), intermediate, root)
+
+	testRequest(t, &Exporter{
+		Files:                   []string{path.Join(dir, "*.pem")},
+		ExposeChainOrderMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		outOfOrder := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_chain_out_of_order") {
+			outOfOrder[getLabelValue(metric, "filename")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"ordered.pem": 0, "reversed.pem": 1, "swapped.pem": 1}, outOfOrder)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_chain_out_of_order"), 0)
+	})
+}
+
+func TestMaxPathLen(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+
+	intermediateTemplate := caTemplate("intermediate", time.Now().Add(time.Hour))
+	intermediateTemplate.MaxPathLen = 1
+	intermediate := generateTestCertificate(intermediateTemplate, root)
+
+	issuingTemplate := caTemplate("issuing", time.Now().Add(time.Hour))
+	issuingTemplate.MaxPathLenZero = true
+	issuing := generateTestCertificate(issuingTemplate, intermediate)
+
+	leaf := generateTestCertificate(leafTemplate("leaf", time.Now().Add(time.Hour)), issuing)
+
+	certPath := path.Join(t.TempDir(), "chain.pem")
+	writeTestCertificates(certPath, leaf, issuing, intermediate, root)
+
+	testRequest(t, &Exporter{
+		Files:                []string{certPath},
+		ExposePathLenMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_expired"), 4)
+
+		foundMetrics := getMetricsForName(metrics, "x509_cert_max_path_len")
+		assert.Len(t, foundMetrics, 2)
+
+		values := map[string]float64{}
+		for _, metric := range foundMetrics {
+			values[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"intermediate": 1, "issuing": 0}, values)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{certPath},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_max_path_len"), 0)
+	})
+}
+
+func TestCertificateType(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	intermediate := generateTestCertificate(caTemplate("intermediate", time.Now().Add(time.Hour)), root)
+	leaf := generateTestCertificate(leafTemplate("leaf", time.Now().Add(time.Hour)), intermediate)
+
+	certPath := path.Join(t.TempDir(), "chain.pem")
+	writeTestCertificates(certPath, leaf, intermediate, root)
+
+	testRequest(t, &Exporter{
+		Files:             []string{certPath},
+		ExposeTypeMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_type")
+		assert.Len(t, foundMetrics, 3)
+
+		types := map[string]string{}
+		for _, metric := range foundMetrics {
+			assert.Equal(t, 1., metric.GetGauge().GetValue())
+			types[getLabelValue(metric, "subject_CN")] = getLabelValue(metric, "type")
+		}
+		assert.Equal(t, map[string]string{"leaf": "leaf", "intermediate": "intermediate", "root": "root"}, types)
+
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			assert.Empty(t, getLabelValue(metric, "type"))
+		}
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{certPath},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_type"), 0)
+	})
+}
+
+func TestLeafOnly(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	intermediate := generateTestCertificate(caTemplate("intermediate", time.Now().Add(time.Hour)), root)
+	leaf := generateTestCertificate(leafTemplate("leaf", time.Now().Add(time.Hour)), intermediate)
+
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "bundle.pem"), leaf, intermediate, root)
+	writeTestCertificates(path.Join(dir, "cas.pem"), root, intermediate)
+	writeTestCertificates(path.Join(dir, "full.pem"), leaf, intermediate, root)
+
+	testRequest(t, &Exporter{
+		Files:           []string{path.Join(dir, "*.pem")},
+		LeafOnlySources: []string{path.Join(dir, "bundle.pem"), path.Join(dir, "cas.pem")},
+	}, func(metrics []model.MetricFamily) {
+		found := map[string][]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			filename := getLabelValue(metric, "filename")
+			found[filename] = append(found[filename], getLabelValue(metric, "subject_CN"))
+		}
+
+		assert.Equal(t, []string{"leaf"}, found["bundle.pem"])
+		// CA only bundle: the intermediate is the one not issuing any other certificate
+		assert.Equal(t, []string{"intermediate"}, found["cas.pem"])
+		assert.ElementsMatch(t, []string{"leaf", "intermediate", "root"}, found["full.pem"])
+	})
+}
+
+func TestWildcard(t *testing.T) {
+	wildcardTemplate := leafTemplate("wildcard", time.Now().Add(time.Hour))
+	wildcardTemplate.DNSNames = []string{"example.com", "*.example.com", "*.internal.example.com", "*.example.com"}
+	plainTemplate := leafTemplate("plain", time.Now().Add(time.Hour))
+	plainTemplate.DNSNames = []string{"www.example.com"}
+
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "wildcard.pem"), generateTestCertificate(wildcardTemplate, nil))
+	writeTestCertificates(path.Join(dir, "plain.pem"), generateTestCertificate(plainTemplate, nil))
+
+	testRequest(t, &Exporter{
+		Files:                 []string{path.Join(dir, "*.pem")},
+		ExposeLabels:          []string{"subject_CN"},
+		ExposeWildcardMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_wildcard")
+		assert.Len(t, foundMetrics, 2)
+
+		domains := []string{}
+		for _, metric := range foundMetrics {
+			assert.Equal(t, 1., metric.GetGauge().GetValue())
+			assert.Equal(t, "wildcard", getLabelValue(metric, "subject_CN"))
+			domains = append(domains, getLabelValue(metric, "wildcard_domain"))
+		}
+		assert.ElementsMatch(t, []string{"example.com", "internal.example.com"}, domains)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_wildcard"), 0)
+	})
+}
+
+func TestHasSAN(t *testing.T) {
+	withSANTemplate := leafTemplate("with-san", time.Now().Add(time.Hour))
+	withSANTemplate.DNSNames = []string{"www.example.com"}
+	withIPTemplate := leafTemplate("with-ip", time.Now().Add(time.Hour))
+	withIPTemplate.IPAddresses = []net.IP{net.ParseIP("10.0.0.1")}
+	clientTemplate := leafTemplate("client", time.Now().Add(time.Hour))
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+
+	certPath := path.Join(t.TempDir(), "certs.pem")
+	writeTestCertificates(certPath,
+		generateTestCertificate(withSANTemplate, nil),
+		generateTestCertificate(withIPTemplate, nil),
+		generateTestCertificate(leafTemplate("cn-only", time.Now().Add(time.Hour)), nil),
+		generateTestCertificate(clientTemplate, nil),
+		generateTestCertificate(caTemplate("ca", time.Now().Add(time.Hour)), nil),
+	)
+
+	testRequest(t, &Exporter{
+		Files:            []string{certPath},
+		ExposeSANMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		values := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_has_san") {
+			values[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"with-san": 1, "with-ip": 1, "cn-only": 0}, values)
+	})
+}
+
+func TestInvalidForTLSServer(t *testing.T) {
+	withSANTemplate := leafTemplate("with-san", time.Now().Add(time.Hour))
+	withSANTemplate.DNSNames = []string{"www.example.com"}
+	uriOnlyTemplate := leafTemplate("uri-only", time.Now().Add(time.Hour))
+	uriOnlyTemplate.URIs = []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/service"}}
+	clientTemplate := leafTemplate("client", time.Now().Add(time.Hour))
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+
+	certPath := path.Join(t.TempDir(), "certs.pem")
+	writeTestCertificates(certPath,
+		generateTestCertificate(withSANTemplate, nil),
+		generateTestCertificate(uriOnlyTemplate, nil),
+		generateTestCertificate(leafTemplate("cn-only", time.Now().Add(time.Hour)), nil),
+		generateTestCertificate(clientTemplate, nil),
+	)
+
+	testRequest(t, &Exporter{
+		Files:            []string{certPath},
+		ExposeSANMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		values := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_invalid_for_tls_server") {
+			values[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"with-san": 0, "uri-only": 1, "cn-only": 1}, values)
+	})
+}
+
+func TestRemainingLifetimeHistogram(t *testing.T) {
+	dir := t.TempDir()
+	for index, days := range []int{-1, 3, 20, 25, 200, 1000} {
+		notAfter := time.Now().Add(time.Duration(days)*24*time.Hour + time.Hour)
+		writeTestCertificates(path.Join(dir, fmt.Sprintf("%d.pem", index)), generateTestCertificate(caTemplate(fmt.Sprintf("cert-%d", index), notAfter), nil))
+	}
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_remaining_lifetime_days")
+		assert.Len(t, foundMetrics, 1)
+
+		histogram := foundMetrics[0].GetHistogram()
+		assert.Equal(t, uint64(6), histogram.GetSampleCount())
+		assert.InDelta(t, 1247.25, histogram.GetSampleSum(), 0.01)
+
+		buckets := map[float64]uint64{}
+		for _, bucket := range histogram.GetBucket() {
+			buckets[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+		}
+		assert.Equal(t, map[float64]uint64{0: 1, 7: 2, 30: 4, 90: 4, 365: 5, math.Inf(1): 6}, buckets)
+	})
+}
+
+func TestExpiringRatio(t *testing.T) {
+	dir := t.TempDir()
+	for index, days := range []int{-1, 3, 20, 25, 60, 200, 1000, 1000} {
+		notAfter := time.Now().Add(time.Duration(days)*24*time.Hour + time.Hour)
+		writeTestCertificates(path.Join(dir, fmt.Sprintf("%d.pem", index)), generateTestCertificate(caTemplate(fmt.Sprintf("cert-%d", index), notAfter), nil))
+	}
+
+	getRatios := func(metrics []model.MetricFamily) map[string]float64 {
+		ratios := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_expiring_ratio") {
+			ratios[getLabelValue(metric, "window_days")] = metric.GetGauge().GetValue()
+		}
+		return ratios
+	}
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Equal(t, map[string]float64{"7": 0.25, "30": 0.5, "90": 0.625}, getRatios(metrics))
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "none-*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Equal(t, map[string]float64{"7": 0, "30": 0, "90": 0}, getRatios(metrics))
+	})
+}
+
+func TestPublicKeySharedCount(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(24 * time.Hour)
+
+	original := generateTestCertificate(caTemplate("original", notAfter), nil)
+	writeTestCertificates(path.Join(dir, "original.pem"), original)
+
+	// renewed with the same key
+	template := caTemplate("renewed", notAfter)
+	template.SerialNumber = big.NewInt(2)
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, &original.key.PublicKey, original.key)
+	assert.NoError(t, err)
+	renewed, err := x509.ParseCertificate(derBytes)
+	assert.NoError(t, err)
+	writeTestCertificates(path.Join(dir, "renewed.pem"), &testCertificate{cert: renewed, key: original.key})
+
+	writeTestCertificates(path.Join(dir, "unique.pem"), generateTestCertificate(caTemplate("unique", notAfter), nil))
+
+	testRequest(t, &Exporter{
+		Files:                 []string{path.Join(dir, "*.pem")},
+		ExposeKeyReuseMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		counts := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_public_key_shared_count") {
+			counts[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"original": 2, "renewed": 2, "unique": 1}, counts)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_public_key_shared_count"), 0)
+	})
+}
+
+func TestConsolidatedMetrics(t *testing.T) {
+	testRequest(t, &Exporter{
+		Files:               []string{"../test/basic.pem"},
+		ConsolidatedMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_not_after"), 0)
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_expired"), 0)
+
+		foundMetrics := getMetricsForName(metrics, "x509_cert_info")
+		assert.Len(t, foundMetrics, 1)
+		metric := foundMetrics[0]
+		assert.Equal(t, float64(time.Date(2028, time.December, 23, 13, 27, 34, 0, time.UTC).Unix()), metric.GetGauge().GetValue())
+
+		labels := map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		assert.Equal(t, map[string]string{
+			"filename":             "basic.pem",
+			"filepath":             "../test/basic.pem",
+			"serial_number":        "0",
+			"issuer_CN":            "kubernetes",
+			"subject_CN":           "kubernetes",
+			"san_count":            "0",
+			"public_key_algorithm": "RSA",
+			"signature_algorithm":  "SHA256-RSA",
+			"is_ca":                "true",
+		}, labels)
+	})
+
+	dir := t.TempDir()
+	template := leafTemplate("leaf", time.Now().Add(24*time.Hour))
+	template.DNSNames = []string{"a.example.com", "b.example.com"}
+	template.IPAddresses = []net.IP{net.ParseIP("10.0.0.1")}
+	writeTestCertificates(path.Join(dir, "leaf.pem"), generateTestCertificate(template, nil))
+
+	testRequest(t, &Exporter{
+		Files:               []string{path.Join(dir, "leaf.pem")},
+		ExposeLabels:        []string{"subject_CN"},
+		ConsolidatedMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_info")
+		assert.Len(t, foundMetrics, 1)
+		assert.Len(t, foundMetrics[0].GetLabel(), 5)
+		assert.Equal(t, "leaf", getLabelValue(foundMetrics[0], "subject_CN"))
+		assert.Equal(t, "3", getLabelValue(foundMetrics[0], "san_count"))
+		assert.Equal(t, "false", getLabelValue(foundMetrics[0], "is_ca"))
+	})
+}
+
+func TestFutureNotBefore(t *testing.T) {
+	dir := t.TempDir()
+	for index, delta := range []time.Duration{-time.Hour, 10 * time.Minute, 2 * time.Hour} {
+		template := caTemplate(fmt.Sprintf("cert-%d", index), time.Now().Add(24*time.Hour))
+		template.NotBefore = time.Now().Add(delta)
+		writeTestCertificates(path.Join(dir, fmt.Sprintf("%d.pem", index)), generateTestCertificate(template, nil))
+	}
+
+	testRequest(t, &Exporter{
+		Files:              []string{path.Join(dir, "0.pem")},
+		ClockSkewThreshold: 5 * time.Minute,
+	}, func(metrics []model.MetricFamily) {
+		assert.Equal(t, 0., getMetricsForName(metrics, "x509_cert_max_future_not_before_seconds")[0].GetGauge().GetValue())
+		assert.Equal(t, 0., getMetricsForName(metrics, "x509_cert_clock_skew_suspected")[0].GetGauge().GetValue())
+	})
+
+	testRequest(t, &Exporter{
+		Files:              []string{path.Join(dir, "*.pem")},
+		ClockSkewThreshold: 5 * time.Minute,
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_max_future_not_before_seconds")
+		assert.Len(t, foundMetrics, 1)
+		assert.InDelta(t, 7200, foundMetrics[0].GetGauge().GetValue(), 60)
+		assert.Equal(t, 1., getMetricsForName(metrics, "x509_cert_clock_skew_suspected")[0].GetGauge().GetValue())
+	})
+
+	testRequest(t, &Exporter{
+		Files:              []string{path.Join(dir, "*.pem")},
+		ClockSkewThreshold: 3 * time.Hour,
+	}, func(metrics []model.MetricFamily) {
+		assert.Equal(t, 0., getMetricsForName(metrics, "x509_cert_clock_skew_suspected")[0].GetGauge().GetValue())
+	})
+}
+
+func TestSHA1Fingerprint(t *testing.T) {
+	testRequest(t, &Exporter{
+		Files:             []string{"../test/basic.pem"},
+		ExposeSHA1Metrics: true,
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_insecure_sha1_fingerprint")
+		assert.Len(t, foundMetrics, 1)
+		// openssl x509 -in test/basic.pem -noout -fingerprint -sha1
+		assert.Equal(t, "6acc82204c491615561c4e4f13c7aeb74fbe42b0", getLabelValue(foundMetrics[0], "sha1_fingerprint"))
+		assert.Equal(t, "basic.pem", getLabelValue(foundMetrics[0], "filename"))
+		assert.Equal(t, 1., foundMetrics[0].GetGauge().GetValue())
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{"../test/basic.pem"},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_insecure_sha1_fingerprint"), 0)
+	})
+}
+
+func TestSANCount(t *testing.T) {
+	multiTemplate := leafTemplate("multi", time.Now().Add(time.Hour))
+	multiTemplate.DNSNames = []string{"example.com", "www.example.com", "*.api.example.com"}
+	multiTemplate.IPAddresses = []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")}
+	spiffeID, err := url.Parse("spiffe://example.com/ns/default/sa/web")
+	assert.NoError(t, err)
+	multiTemplate.URIs = []*url.URL{spiffeID}
+	multiTemplate.EmailAddresses = []string{"admin@example.com"}
+
+	certPath := path.Join(t.TempDir(), "certs.pem")
+	writeTestCertificates(certPath,
+		generateTestCertificate(multiTemplate, nil),
+		generateTestCertificate(leafTemplate("cn-only", time.Now().Add(time.Hour)), nil),
+		generateTestCertificate(caTemplate("ca", time.Now().Add(time.Hour)), nil),
+	)
+
+	testRequest(t, &Exporter{
+		Files:            []string{certPath},
+		ExposeSANMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		counts := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_san_count") {
+			counts[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"multi": 7, "cn-only": 0, "ca": 0}, counts)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{certPath},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_san_count"), 0)
+	})
+}
+
+func TestCNNotInSAN(t *testing.T) {
+	matchingTemplate := leafTemplate("www.example.com", time.Now().Add(time.Hour))
+	matchingTemplate.DNSNames = []string{"example.com", "WWW.example.com"}
+	mismatchedTemplate := leafTemplate("legacy.example.com", time.Now().Add(time.Hour))
+	mismatchedTemplate.DNSNames = []string{"www.example.com"}
+	noCNTemplate := leafTemplate("", time.Now().Add(time.Hour))
+	noCNTemplate.DNSNames = []string{"www.example.com"}
+
+	certPath := path.Join(t.TempDir(), "certs.pem")
+	writeTestCertificates(certPath,
+		generateTestCertificate(matchingTemplate, nil),
+		generateTestCertificate(mismatchedTemplate, nil),
+		generateTestCertificate(noCNTemplate, nil),
+		generateTestCertificate(caTemplate("ca", time.Now().Add(time.Hour)), nil),
+	)
+
+	testRequest(t, &Exporter{
+		Files:            []string{certPath},
+		ExposeSANMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		mismatches := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_cn_not_in_san") {
+			mismatches[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+
+		// certificates without CN and CAs aren't reported
+		assert.Equal(t, map[string]float64{"www.example.com": 0, "legacy.example.com": 1}, mismatches)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{certPath},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_cn_not_in_san"), 0)
+	})
+}
+
+func TestEmailAddresses(t *testing.T) {
+	clientTemplate := leafTemplate("client", time.Now().Add(time.Hour))
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageEmailProtection}
+	clientTemplate.EmailAddresses = []string{"alice@example.com", "alice.smith@example.org"}
+	manyTemplate := leafTemplate("many", time.Now().Add(time.Hour))
+	manyTemplate.EmailAddresses = []string{"1@example.com", "2@example.com", "3@example.com", "4@example.com", "5@example.com", "6@example.com"}
+
+	certPath := path.Join(t.TempDir(), "certs.pem")
+	writeTestCertificates(certPath,
+		generateTestCertificate(clientTemplate, nil),
+		generateTestCertificate(manyTemplate, nil),
+		generateTestCertificate(leafTemplate("server", time.Now().Add(time.Hour)), nil),
+	)
+
+	testRequest(t, &Exporter{
+		Files:              []string{certPath},
+		ExposeEmailMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		emails := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_email_addresses") {
+			assert.Equal(t, 1., metric.GetGauge().GetValue())
+			emails[getLabelValue(metric, "subject_CN")] = getLabelValue(metric, "email_addresses")
+		}
+		assert.Equal(t, map[string]string{
+			"client": "alice@example.com,alice.smith@example.org",
+			"many":   "1@example.com,2@example.com,3@example.com,4@example.com,5@example.com,...",
+		}, emails)
+
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			assert.Empty(t, getLabelValue(metric, "email_addresses"))
+		}
+	})
+}
+
+func TestIPAddressesMetric(t *testing.T) {
+	pinnedTemplate := leafTemplate("pinned", time.Now().Add(time.Hour))
+	pinnedTemplate.IPAddresses = []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")}
+	pinnedTemplate.DNSNames = []string{"node.example.com"}
 
-		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
-		assert.Len(t, foundNaMetrics, 0, "extra x509_cert_not_after metric(s)")
+	certPath := path.Join(t.TempDir(), "certs.pem")
+	writeTestCertificates(certPath,
+		generateTestCertificate(pinnedTemplate, nil),
+		generateTestCertificate(leafTemplate("named", time.Now().Add(time.Hour)), nil),
+	)
 
-		errorMetric := getMetricsForName(metrics, "x509_read_errors")
-		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
-		assert.Equal(t, errorMetric[0].GetGauge().GetValue(), 1., "invalid x509_read_errors value")
+	testRequest(t, &Exporter{
+		Files:              []string{certPath},
+		ExposeIPSANMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		ips := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_ip_addresses") {
+			assert.Equal(t, 1., metric.GetGauge().GetValue())
+			ips[getLabelValue(metric, "subject_CN")] = getLabelValue(metric, "ip_addresses")
+		}
+		assert.Equal(t, map[string]string{"pinned": "10.0.0.1,2001:db8::1"}, ips)
 	})
 
 	testRequest(t, &Exporter{
-		YAMLs: []string{path.Join(filepath.Dir(filename), "../test/invalid-yaml.conf")},
-		YAMLPaths: []YAMLCertRef{
-			{
-				BasePathMatchExpr: "$.clusters",
-				CertMatchSubExpr:  "$.badarrayelem",
-				IDMatchSubExpr:    "$.name",
-				Format:            YAMLCertFormatBase64,
-			},
-		},
+		Files: []string{certPath},
 	}, func(metrics []model.MetricFamily) {
-		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
-		assert.Len(t, foundMetrics, 0, "extra x509_cert_expired metric(s)")
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_ip_addresses"), 0)
+	})
+}
 
-		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
-		assert.Len(t, foundNbMetrics, 0, "extra x509_cert_not_before metric(s)")
+func TestRevocationEndpoints(t *testing.T) {
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	bothTemplate := leafTemplate("both", time.Now().Add(time.Hour))
+	bothTemplate.CRLDistributionPoints = []string{"http://crl.example.com/ca.crl", "ldap://ldap.example.com/cn=ca"}
+	bothTemplate.OCSPServer = []string{"http://ocsp.example.com"}
 
-		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
-		assert.Len(t, foundNaMetrics, 0, "extra x509_cert_not_after metric(s)")
+	certPath := path.Join(t.TempDir(), "certs.pem")
+	writeTestCertificates(certPath,
+		generateTestCertificate(bothTemplate, root),
+		generateTestCertificate(leafTemplate("neither", time.Now().Add(time.Hour)), root),
+		root,
+	)
 
-		errorMetric := getMetricsForName(metrics, "x509_read_errors")
-		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
-		assert.Equal(t, errorMetric[0].GetGauge().GetValue(), 1., "invalid x509_read_errors value")
+	testRequest(t, &Exporter{
+		Files:                   []string{certPath},
+		ExposeRevocationMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_revocation_endpoints")
+		assert.Len(t, foundMetrics, 1)
+		assert.Equal(t, "both", getLabelValue(foundMetrics[0], "subject_CN"))
+		assert.Equal(t, "http://crl.example.com/ca.crl,ldap://ldap.example.com/cn=ca", getLabelValue(foundMetrics[0], "crl_distribution_points"))
+		assert.Equal(t, "http://ocsp.example.com", getLabelValue(foundMetrics[0], "ocsp_servers"))
+
+		// the self-signed root isn't flagged
+		missing := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_no_revocation_endpoints") {
+			missing[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"both": 0, "neither": 1}, missing)
 	})
-}
-
-func TestInvalidYAMLMatchExpr(t *testing.T) {
-	_, filename, _, _ := runtime.Caller(0)
 
 	testRequest(t, &Exporter{
-		YAMLs: []string{path.Join(filepath.Dir(filename), "../test/yaml-embedded.conf")},
-		YAMLPaths: []YAMLCertRef{
-			{
-				BasePathMatchExpr: "invalid",
-				CertMatchSubExpr:  "invalid",
-				IDMatchSubExpr:    "$.clusters[].name",
-				Format:            YAMLCertFormatBase64,
-			},
-		},
+		Files: []string{certPath},
 	}, func(metrics []model.MetricFamily) {
-		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
-		assert.Len(t, foundMetrics, 0, "extra x509_cert_expired metric(s)")
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_revocation_endpoints"), 0)
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_no_revocation_endpoints"), 0)
+	})
+}
 
-		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
-		assert.Len(t, foundNbMetrics, 0, "extra x509_cert_not_before metric(s)")
+func TestCertificatesByIssuer(t *testing.T) {
+	dir := t.TempDir()
+	issuers := []*testCertificate{
+		generateTestCertificate(caTemplate("ca-a", time.Now().Add(time.Hour)), nil),
+		generateTestCertificate(caTemplate("ca-b", time.Now().Add(time.Hour)), nil),
+		generateTestCertificate(caTemplate("ca-c", time.Now().Add(time.Hour)), nil),
+	}
 
-		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
-		assert.Len(t, foundNaMetrics, 0, "extra x509_cert_not_after metric(s)")
+	files := []string{}
+	for index, leafCount := range []int{3, 2, 1} {
+		for i := 0; i < leafCount; i++ {
+			leaf := generateTestCertificate(leafTemplate(fmt.Sprintf("leaf-%d", i), time.Now().Add(time.Hour)), issuers[index])
+			file := path.Join(dir, fmt.Sprintf("%d-%d.pem", index, i))
+			writeTestCertificates(file, leaf)
+			files = append(files, file)
+		}
+	}
 
-		errorMetric := getMetricsForName(metrics, "x509_read_errors")
-		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
-		assert.Equal(t, errorMetric[0].GetGauge().GetValue(), 1., "invalid x509_read_errors value")
+	getCounts := func(metrics []model.MetricFamily) map[string]float64 {
+		counts := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_by_issuer_count") {
+			counts[getLabelValue(metric, "issuer_CN")] = metric.GetGauge().GetValue()
+		}
+		return counts
+	}
+
+	testRequest(t, &Exporter{
+		Files: files,
+	}, func(metrics []model.MetricFamily) {
+		assert.Equal(t, map[string]float64{"ca-a": 3, "ca-b": 2, "ca-c": 1}, getCounts(metrics))
 	})
 
 	testRequest(t, &Exporter{
-		YAMLs: []string{path.Join(filepath.Dir(filename), "../test/yaml-embedded.conf")},
-		YAMLPaths: []YAMLCertRef{
-			{
-				BasePathMatchExpr: "$.clusters",
-				CertMatchSubExpr:  "$.cluster[\"certificate-authority-data\"]",
-				IDMatchSubExpr:    "$[0]",
-				Format:            YAMLCertFormatBase64,
-			},
-		},
+		Files:            files,
+		IssuerCountLimit: 1,
 	}, func(metrics []model.MetricFamily) {
-		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
-		assert.Len(t, foundMetrics, 0, "extra x509_cert_expired metric(s)")
+		assert.Equal(t, map[string]float64{"ca-a": 3, "(other)": 3}, getCounts(metrics))
+	})
+}
 
-		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
-		assert.Len(t, foundNbMetrics, 0, "extra x509_cert_not_before metric(s)")
+func TestDistinctIssuerCount(t *testing.T) {
+	dir := t.TempDir()
+	// issuers sharing a CN are still told apart by their key
+	issuers := []*testCertificate{
+		generateTestCertificate(caTemplate("ca", time.Now().Add(time.Hour)), nil),
+		generateTestCertificate(caTemplate("ca", time.Now().Add(time.Hour)), nil),
+		generateTestCertificate(caTemplate("other-ca", time.Now().Add(time.Hour)), nil),
+	}
 
-		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
-		assert.Len(t, foundNaMetrics, 0, "extra x509_cert_not_after metric(s)")
+	files := []string{}
+	for index, leafCount := range []int{2, 1, 1} {
+		for i := 0; i < leafCount; i++ {
+			leaf := generateTestCertificate(leafTemplate(fmt.Sprintf("leaf-%d", i), time.Now().Add(time.Hour)), issuers[index])
+			file := path.Join(dir, fmt.Sprintf("%d-%d.pem", index, i))
+			writeTestCertificates(file, leaf)
+			files = append(files, file)
+		}
+	}
 
-		errorMetric := getMetricsForName(metrics, "x509_read_errors")
-		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
-		assert.Equal(t, errorMetric[0].GetGauge().GetValue(), 1., "invalid x509_read_errors value")
+	testRequest(t, &Exporter{
+		Files: files,
+	}, func(metrics []model.MetricFamily) {
+		metric := getMetricsForName(metrics, "x509_cert_distinct_issuer_count")
+		assert.Len(t, metric, 1)
+		assert.Equal(t, 3., metric[0].GetGauge().GetValue())
 	})
 }
 
-func TestMultipleCerts(t *testing.T) {
-	_, filename, _, _ := runtime.Caller(0)
+func TestServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := generateTestCertificate(caTemplate("ca", time.Now().Add(time.Hour)), nil)
+	serverTemplate := leafTemplate("server", time.Now().Add(time.Hour))
+	serverTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	server := generateTestCertificate(serverTemplate, ca)
+	writeTestCertificates(path.Join(dir, "server.pem"), server)
+	writeTestKey(path.Join(dir, "server.key"), server)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	testTLSRequest(t, &Exporter{
+		Files:       []string{"../test/basic.pem"},
+		TLSCertFile: path.Join(dir, "server.pem"),
+		TLSKeyFile:  path.Join(dir, "server.key"),
+	}, func() {
+		res, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/metrics", port))
+		assert.NoError(t, err)
+		if err == nil {
+			res.Body.Close()
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+		}
 
+		// plain HTTP clients are answered with a 400 by the TLS server
+		res, err = http.Get(fmt.Sprintf("http://127.0.0.1:%d/metrics", port))
+		assert.NoError(t, err)
+		if err == nil {
+			res.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		}
+	})
+}
+
+func TestServerTLSSelf(t *testing.T) {
+	dir := t.TempDir()
+	ca := generateTestCertificate(caTemplate("ca", time.Now().Add(time.Hour)), nil)
+	serverTemplate := leafTemplate("server", time.Now().Add(time.Hour).Truncate(time.Second))
+	serverTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	server := generateTestCertificate(serverTemplate, ca)
+	writeTestCertificates(path.Join(dir, "server.pem"), server)
+	writeTestKey(path.Join(dir, "server.key"), server)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	testTLSRequest(t, &Exporter{
+		Files:       []string{"../test/basic.pem"},
+		TLSCertFile: path.Join(dir, "server.pem"),
+		TLSKeyFile:  path.Join(dir, "server.key"),
+	}, func() {
+		res, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/metrics", port))
+		assert.NoError(t, err)
+		if err != nil {
+			return
+		}
+		defer res.Body.Close()
+
+		metrics := map[string]*model.MetricFamily{}
+		decoder := expfmt.NewDecoder(res.Body, expfmt.NewFormat(expfmt.TypeProtoText))
+		for {
+			metric := &model.MetricFamily{}
+			if err := decoder.Decode(metric); err != nil {
+				break
+			}
+			metrics[metric.GetName()] = metric
+		}
+
+		self := map[string]string{}
+		for _, metric := range metrics["x509_cert_not_after"].GetMetric() {
+			self[getLabelValue(metric, "subject_CN")] = getLabelValue(metric, "self")
+			if getLabelValue(metric, "self") == "true" {
+				assert.Equal(t, "server.pem", getLabelValue(metric, "filename"))
+				assert.Equal(t, float64(server.cert.NotAfter.Unix()), metric.GetGauge().GetValue())
+			}
+		}
+		assert.Equal(t, map[string]string{"server": "true", "kubernetes": ""}, self)
+	})
+
+	// not served over TLS, no self series
 	testRequest(t, &Exporter{
-		YAMLs: []string{path.Join(filepath.Dir(filename), "../test/yaml-multi-certs.conf")},
-		YAMLPaths: []YAMLCertRef{
-			{
-				BasePathMatchExpr: "$.clusters",
-				CertMatchSubExpr:  "$.cluster[\"certificate-authority-data\"]",
-				IDMatchSubExpr:    "$.name",
-				Format:            YAMLCertFormatBase64,
-			},
-		},
+		Files: []string{"../test/basic.pem"},
 	}, func(metrics []model.MetricFamily) {
-		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
-		assert.Len(t, foundMetrics, 2, "extra x509_cert_expired metric(s)")
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			assert.Empty(t, getLabelValue(metric, "self"))
+		}
+	})
+}
 
-		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
-		assert.Len(t, foundNbMetrics, 2, "extra x509_cert_not_before metric(s)")
+func TestServerTLSRotation(t *testing.T) {
+	dir := t.TempDir()
+	ca := generateTestCertificate(caTemplate("ca", time.Now().Add(time.Hour)), nil)
+	serverTemplate := leafTemplate("server", time.Now().Add(time.Hour))
+	serverTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	server := generateTestCertificate(serverTemplate, ca)
+	writeTestCertificates(path.Join(dir, "server.pem"), server)
+	writeTestKey(path.Join(dir, "server.key"), server)
+
+	rotatedTemplate := leafTemplate("rotated", time.Now().Add(2*time.Hour))
+	rotatedTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	rotated := generateTestCertificate(rotatedTemplate, ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool},
+		DisableKeepAlives: true,
+	}}
+
+	getServedCN := func() string {
+		res, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/metrics", port))
+		assert.NoError(t, err)
+		if err != nil {
+			return ""
+		}
+		res.Body.Close()
+		return res.TLS.PeerCertificates[0].Subject.CommonName
+	}
 
-		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
-		assert.Len(t, foundNaMetrics, 2, "extra x509_cert_not_after metric(s)")
+	// files are given a later modification time, as rewrites may happen within the filesystem granularity
+	touch := func(later time.Duration) {
+		for _, file := range []string{"server.pem", "server.key"} {
+			assert.NoError(t, os.Chtimes(path.Join(dir, file), time.Now().Add(later), time.Now().Add(later)))
+		}
+	}
 
-		errorMetric := getMetricsForName(metrics, "x509_read_errors")
-		assert.Len(t, errorMetric, 1, "missing x509_read_errors metric")
-		assert.Equal(t, errorMetric[0].GetGauge().GetValue(), 0., "invalid x509_read_errors value")
+	exporter := &Exporter{
+		Files:       []string{"../test/basic.pem"},
+		TLSCertFile: path.Join(dir, "server.pem"),
+		TLSKeyFile:  path.Join(dir, "server.key"),
+	}
+	testTLSRequest(t, exporter, func() {
+		assert.Equal(t, "server", getServedCN())
+
+		writeTestCertificates(path.Join(dir, "server.pem"), rotated)
+		writeTestKey(path.Join(dir, "server.key"), rotated)
+		touch(time.Minute)
+		assert.Equal(t, "rotated", getServedCN())
+
+		certs, err := readServingCertificate(exporter.servingKeyPair)
+		assert.NoError(t, err)
+		assert.Equal(t, "rotated", certs[0].cert.Subject.CommonName)
+
+		// a certificate not matching the key is not loaded, the previous pair is still served
+		writeTestCertificates(path.Join(dir, "server.pem"), server)
+		touch(2 * time.Minute)
+		assert.Equal(t, "rotated", getServedCN())
+
+		writeTestKey(path.Join(dir, "server.key"), server)
+		touch(3 * time.Minute)
+		assert.Equal(t, "server", getServedCN())
+	})
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := generateTestCertificate(caTemplate("ca", time.Now().Add(time.Hour)), nil)
+	serverTemplate := leafTemplate("server", time.Now().Add(time.Hour))
+	serverTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	server := generateTestCertificate(serverTemplate, ca)
+	writeTestCertificates(path.Join(dir, "server.pem"), server)
+	writeTestKey(path.Join(dir, "server.key"), server)
+	writeTestCertificates(path.Join(dir, "ca.pem"), ca)
+
+	clientTemplate := leafTemplate("prometheus", time.Now().Add(time.Hour))
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	clientCert := generateTestCertificate(clientTemplate, ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	anonymousClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	authenticatedClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs: pool,
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{clientCert.cert.Raw},
+			PrivateKey:  clientCert.key,
+		}},
+	}}}
+
+	testTLSRequest(t, &Exporter{
+		Files:           []string{"../test/basic.pem"},
+		TLSCertFile:     path.Join(dir, "server.pem"),
+		TLSKeyFile:      path.Join(dir, "server.key"),
+		TLSClientCAFile: path.Join(dir, "ca.pem"),
+	}, func() {
+		res, err := authenticatedClient.Get(fmt.Sprintf("https://127.0.0.1:%d/metrics", port))
+		assert.NoError(t, err)
+		if err == nil {
+			res.Body.Close()
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+		}
+
+		_, err = anonymousClient.Get(fmt.Sprintf("https://127.0.0.1:%d/metrics", port))
+		assert.Error(t, err)
 	})
 }
 
+func TestServerTLSMisconfigured(t *testing.T) {
+	dir := t.TempDir()
+	cert := generateTestCertificate(leafTemplate("server", time.Now().Add(time.Hour)), nil)
+	writeTestCertificates(path.Join(dir, "server.pem"), cert)
+	writeTestKey(path.Join(dir, "server.key"), cert)
+
+	for _, exporter := range []*Exporter{
+		{TLSCertFile: path.Join(dir, "server.pem")},
+		{TLSClientCAFile: path.Join(dir, "server.pem")},
+		{TLSCertFile: path.Join(dir, "server.pem"), TLSKeyFile: path.Join(dir, "does-not-exist.key")},
+		{TLSCertFile: path.Join(dir, "server.key"), TLSKeyFile: path.Join(dir, "server.key")},
+		{TLSCertFile: path.Join(dir, "server.pem"), TLSKeyFile: path.Join(dir, "server.key"), TLSClientCAFile: path.Join(dir, "server.key")},
+	} {
+		exporter.ListenAddress = listenAddress
+		assert.Error(t, exporter.ListenAndServe())
+	}
+}
+
 func TestMultipleErrors(t *testing.T) {
 	testRequest(t, &Exporter{
 		Files:       []string{"does", "not", "exist"},
@@ -642,7 +2134,7 @@ func BenchmarkParsingCertificates(b *testing.B) {
 	exporter := Exporter{Files: filenames}
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, errs := exporter.parseAllCertificates()
+		_, errs := exporter.parseAllCertificates(context.Background())
 		if len(errs) != 0 {
 			b.Fatalf("unexpected errors: %v", errs)
 		}
@@ -823,11 +2315,11 @@ func TestYamlGlobbing(t *testing.T) {
 		YAMLPaths: DefaultYamlPaths,
 	}, func(metrics []model.MetricFamily) {
 		foundMetrics := getMetricsForName(metrics, "x509_cert_expired")
-		assert.Len(t, foundMetrics, 10)
+		assert.Len(t, foundMetrics, 11)
 		foundNbMetrics := getMetricsForName(metrics, "x509_cert_not_before")
-		assert.Len(t, foundNbMetrics, 10)
+		assert.Len(t, foundNbMetrics, 11)
 		foundNaMetrics := getMetricsForName(metrics, "x509_cert_not_after")
-		assert.Len(t, foundNaMetrics, 10)
+		assert.Len(t, foundNaMetrics, 11)
 		errMetric := getMetricsForName(metrics, "x509_read_errors")
 		assert.Equal(t, 6., errMetric[0].GetGauge().GetValue())
 	})
@@ -891,6 +2383,70 @@ func TestDirectoryGlobbing(t *testing.T) {
 	})
 }
 
+func TestPush(t *testing.T) {
+	requests := []string{}
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	exporter := &Exporter{Files: []string{"../test/basic.pem"}}
+	err := exporter.Push(server.URL, "cert-rotation", map[string]string{"instance": "cronjob"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"PUT /metrics/job/cert-rotation/instance/cronjob"}, requests)
+	assert.Contains(t, bodies[0], "x509_cert_not_after")
+	assert.Contains(t, bodies[0], "x509_read_errors")
+}
+
+func TestPushFailOnExpired(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "valid.pem"), generateTestCertificate(caTemplate("valid", time.Now().Add(time.Hour)), nil))
+	writeTestCertificates(path.Join(dir, "expired.pem"), generateTestCertificate(caTemplate("expired", time.Now().Add(-time.Minute)), nil))
+
+	// valid certificates only
+	exporter := &Exporter{Files: []string{path.Join(dir, "valid.pem")}, FailOnExpired: true}
+	err := exporter.Push(server.URL, "cert-rotation", nil)
+	assert.NoError(t, err)
+
+	// metrics are still pushed along with the error
+	exporter = &Exporter{Files: []string{path.Join(dir, "*.pem")}, FailOnExpired: true}
+	err = exporter.Push(server.URL, "cert-rotation", nil)
+	assert.ErrorIs(t, err, ErrExpiredCertificates)
+	assert.Equal(t, 2, requests)
+
+	// only reported in the metrics by default
+	exporter = &Exporter{Files: []string{path.Join(dir, "*.pem")}}
+	err = exporter.Push(server.URL, "cert-rotation", nil)
+	assert.NoError(t, err)
+}
+
+func TestPushFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	exporter := &Exporter{Files: []string{"../test/basic.pem"}}
+	err := exporter.Push(server.URL, "cert-rotation", nil)
+	assert.Error(t, err)
+
+	server.Close()
+	err = exporter.Push(server.URL, "cert-rotation", nil)
+	assert.Error(t, err)
+}
+
 func TestListenError(t *testing.T) {
 	exporter := &Exporter{ListenAddress: "127.0.0.1:4242"}
 	err := exporter.Listen()
@@ -988,6 +2544,60 @@ func checkLabels(t *testing.T, labels []*model.LabelPair, path string, isKube bo
 	}
 }
 
+func TestCollectDynamicSources(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(time.Hour)
+	writeTestCertificates(path.Join(dir, "first.pem"), generateTestCertificate(leafTemplate("first", notAfter), nil))
+
+	exporter := &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{exporter: exporter})
+
+	getFilenames := func() []string {
+		metrics, err := registry.Gather()
+		assert.NoError(t, err)
+
+		filenames := []string{}
+		for index := range metrics {
+			if metrics[index].GetName() == "x509_cert_not_after" {
+				for _, metric := range metrics[index].GetMetric() {
+					filenames = append(filenames, getLabelValue(metric, "filename"))
+				}
+			}
+		}
+		return filenames
+	}
+
+	assert.ElementsMatch(t, []string{"first.pem"}, getFilenames())
+
+	writeTestCertificates(path.Join(dir, "second.pem"), generateTestCertificate(leafTemplate("second", notAfter), nil))
+	assert.ElementsMatch(t, []string{"first.pem", "second.pem"}, getFilenames())
+
+	assert.NoError(t, os.Remove(path.Join(dir, "first.pem")))
+	assert.ElementsMatch(t, []string{"second.pem"}, getFilenames())
+
+	// another certificate whose exposed labels are the same as the ones of second.pem fails the scrape
+	writeTestCertificates(path.Join(dir, "third.pem"), generateTestCertificate(leafTemplate("second", notAfter), nil))
+	exporter.ExposeLabels = []string{"subject_CN"}
+	_, err := registry.Gather()
+	assert.Error(t, err)
+}
+
+// parseConcurrently : Run a few scrapes at once, for the race detector to catch state they would share
+func parseConcurrently(exporter *Exporter) {
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exporter.parseAllCertificates(context.Background())
+		}()
+	}
+	wg.Wait()
+}
+
 func testRequest(t *testing.T, exporter *Exporter, cb func(metrics []model.MetricFamily)) {
 	exporter.ListenAddress = listenAddress
 	if exporter.KubeSecretTypes == nil {
@@ -1032,6 +2642,25 @@ func testRequest(t *testing.T, exporter *Exporter, cb func(metrics []model.Metri
 	exporter.Serve()
 }
 
+func testTLSRequest(t *testing.T, exporter *Exporter, cb func()) {
+	exporter.ListenAddress = listenAddress
+	exporter.DiscoverCertificates()
+
+	err := exporter.Listen()
+	assert.NoError(t, err)
+	if err != nil {
+		return
+	}
+
+	go func() {
+		cb()
+		//nolint:errcheck
+		exporter.Shutdown()
+	}()
+	//nolint:errcheck
+	exporter.Serve()
+}
+
 func getMetricsForName(metrics []model.MetricFamily, name string) []*model.Metric {
 	//nolint:govet
 	for _, metric := range metrics {
@@ -1094,6 +2723,91 @@ func generateCertificate(path string, notBefore time.Time) {
 	os.WriteFile(path+".key", out.Bytes(), 00644)
 }
 
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// generateTestCertificate : Create a certificate from template, signed by issuer (self-signed if nil)
+func generateTestCertificate(template *x509.Certificate, issuer *testCertificate) *testCertificate {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if template.SerialNumber == nil {
+		template.SerialNumber = big.NewInt(1)
+	}
+
+	parent, parentKey := template, priv
+	if issuer != nil {
+		parent, parentKey = issuer.cert, issuer.key
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, parent, &priv.PublicKey, parentKey)
+	if err != nil {
+		log.Fatalf("Failed to create certificate: %s", err)
+	}
+
+	cert, err := x509.ParseCertificate(derBytes)
+	if err != nil {
+		log.Fatalf("Failed to parse certificate: %s", err)
+	}
+
+	return &testCertificate{cert: cert, key: priv}
+}
+
+func caTemplate(commonName string, notAfter time.Time) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+}
+
+func leafTemplate(commonName string, notAfter time.Time) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+}
+
+func writeTestCertificates(path string, certs ...*testCertificate) {
+	out := &bytes.Buffer{}
+	for _, cert := range certs {
+		//nolint:errcheck
+		pem.Encode(out, &pem.Block{Type: "CERTIFICATE", Bytes: cert.cert.Raw})
+	}
+
+	//nolint:errcheck
+	os.WriteFile(path, out.Bytes(), 00644)
+}
+
+func writeTestKey(path string, cert *testCertificate) {
+	out := &bytes.Buffer{}
+	//nolint:errcheck
+	pem.Encode(out, getPEMBlockForKey(cert.key))
+	//nolint:errcheck
+	os.WriteFile(path, out.Bytes(), 00600)
+}
+
+func getLabelValue(metric *model.Metric, name string) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+
+	return ""
+}
+
 func removeGeneratedCertificate(path string) {
 	os.Remove(path)
 	os.Remove(path + ".key")
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// extKeyUsageNames : Extended key usages by their RFC 5280 short name, as shown by OpenSSL
+var extKeyUsageNames = map[string]x509.ExtKeyUsage{
+	"any":             x509.ExtKeyUsageAny,
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"ipsecEndSystem":  x509.ExtKeyUsageIPSECEndSystem,
+	"ipsecTunnel":     x509.ExtKeyUsageIPSECTunnel,
+	"ipsecUser":       x509.ExtKeyUsageIPSECUser,
+	"timeStamping":    x509.ExtKeyUsageTimeStamping,
+	"OCSPSigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+// ParseExtKeyUsage : Find an extended key usage by its short name (e.g. "serverAuth", "clientAuth"), ignoring case
+func ParseExtKeyUsage(name string) (x509.ExtKeyUsage, error) {
+	for usageName, usage := range extKeyUsageNames {
+		if strings.EqualFold(usageName, name) {
+			return usage, nil
+		}
+	}
+
+	return x509.ExtKeyUsageAny, fmt.Errorf("unknown extended key usage \"%s\"", name)
+}
+
+// isExtKeyUsageIncluded : Tell if a certificate lists one of the included extended key usages (any, when none is
+// listed) and none of the excluded ones; certificates without the extension, such as most CAs, list no usage
+func (exporter *Exporter) isExtKeyUsageIncluded(cert *x509.Certificate) bool {
+	if len(exporter.IncludeExtKeyUsages) > 0 && !slices.ContainsFunc(cert.ExtKeyUsage, func(usage x509.ExtKeyUsage) bool {
+		return slices.Contains(exporter.IncludeExtKeyUsages, usage)
+	}) {
+		return false
+	}
+
+	return !slices.ContainsFunc(cert.ExtKeyUsage, func(usage x509.ExtKeyUsage) bool {
+		return slices.Contains(exporter.ExcludeExtKeyUsages, usage)
+	})
+}
+
+// filterExtKeyUsages : Drop the certificates not passing the extended key usage filters
+func (exporter *Exporter) filterExtKeyUsages(certs []*parsedCertificate) []*parsedCertificate {
+	if len(exporter.IncludeExtKeyUsages) == 0 && len(exporter.ExcludeExtKeyUsages) == 0 {
+		return certs
+	}
+
+	output := []*parsedCertificate{}
+	for _, cert := range certs {
+		if exporter.isExtKeyUsageIncluded(cert.cert) {
+			output = append(output, cert)
+		}
+	}
+
+	return output
+}
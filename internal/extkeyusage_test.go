@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"crypto/x509"
+	"path"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtKeyUsageFilters(t *testing.T) {
+	ca := generateTestCertificate(caTemplate("ca", time.Now().Add(time.Hour)), nil)
+	server := generateTestCertificate(leafTemplate("server", time.Now().Add(time.Hour)), ca)
+	clientTemplate := leafTemplate("client", time.Now().Add(time.Hour))
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	client := generateTestCertificate(clientTemplate, ca)
+	bothTemplate := leafTemplate("both", time.Now().Add(time.Hour))
+	bothTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	both := generateTestCertificate(bothTemplate, ca)
+
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "bundle.pem"), server, client, both, ca)
+	writeTestCertificates(path.Join(dir, "client.pem"), client)
+
+	test := func(include []x509.ExtKeyUsage, exclude []x509.ExtKeyUsage, expected []string) {
+		testRequest(t, &Exporter{
+			Files:               []string{path.Join(dir, "*.pem")},
+			IncludeExtKeyUsages: include,
+			ExcludeExtKeyUsages: exclude,
+			ExposeErrorMetrics:  true,
+		}, func(metrics []model.MetricFamily) {
+			found := []string{}
+			for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+				found = append(found, getLabelValue(metric, "filename")+"/"+getLabelValue(metric, "subject_CN"))
+			}
+			assert.ElementsMatch(t, expected, found)
+
+			// sources having no matching certificate aren't failing
+			for _, metric := range getMetricsForName(metrics, "x509_read_errors") {
+				assert.Equal(t, 0., metric.GetGauge().GetValue())
+			}
+		})
+	}
+
+	test([]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, nil, []string{"bundle.pem/server", "bundle.pem/both"})
+	test([]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, []string{"bundle.pem/server"})
+	test(nil, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, []string{"bundle.pem/server", "bundle.pem/ca"})
+}
+
+func TestParseExtKeyUsage(t *testing.T) {
+	usage, err := ParseExtKeyUsage("serverauth")
+	assert.NoError(t, err)
+	assert.Equal(t, x509.ExtKeyUsageServerAuth, usage)
+
+	usage, err = ParseExtKeyUsage("OCSPSigning")
+	assert.NoError(t, err)
+	assert.Equal(t, x509.ExtKeyUsageOCSPSigning, usage)
+
+	for _, invalid := range []string{"", "server", "1.3.6.1.5.5.7.3.1"} {
+		_, err := ParseExtKeyUsage(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// FTPFile : A file holding certificates on an FTP server, TLS being implicit for ftps:// URLs
+type FTPFile struct {
+	URL  string
+	Path string
+}
+
+const ftpTimeout = 30 * time.Second
+
+const defaultFTPUser = "anonymous"
+
+var ftpDefaultPorts = map[string]string{
+	"ftp":  "21",
+	"ftps": "990",
+}
+
+// ParseFTPURL : Split a ftp[s]://<host>[:<port>]/<path> URL, the port defaulting to 21 (or 990 for ftps://)
+func ParseFTPURL(ftpURL string) (FTPFile, error) {
+	parsed, err := url.Parse(ftpURL)
+	if err != nil {
+		return FTPFile{}, err
+	}
+
+	defaultPort, found := ftpDefaultPorts[parsed.Scheme]
+	if !found || len(parsed.Hostname()) == 0 || len(strings.TrimPrefix(parsed.Path, "/")) == 0 || parsed.User != nil {
+		return FTPFile{}, fmt.Errorf("expected ftp[s]://<host>[:<port>]/<path>, got \"%s\"", ftpURL)
+	}
+
+	port := parsed.Port()
+	if len(port) == 0 {
+		port = defaultPort
+	}
+
+	return FTPFile{
+		URL:  fmt.Sprintf("%s://%s", parsed.Scheme, net.JoinHostPort(parsed.Hostname(), port)),
+		Path: parsed.Path,
+	}, nil
+}
+
+func (exporter *Exporter) collectFTPFiles() []*certificateRef {
+	output := []*certificateRef{}
+
+	for index := range exporter.FTPFiles {
+		file := &exporter.FTPFiles[index]
+		output = append(output, &certificateRef{
+			path:      file.URL + file.Path,
+			format:    certificateFormatFTP,
+			ftpFile:   file,
+			ftpClient: exporter.connectFTP,
+		})
+	}
+
+	return output
+}
+
+// connectFTP : Open a passive mode connection to the server of a file, over TLS for ftps:// URLs (or when
+// upgrading ftp:// ones with AUTH TLS), and log in as FTPUser (anonymously when unset)
+func (exporter *Exporter) connectFTP(ctx context.Context, file *FTPFile) (*ftp.ServerConn, error) {
+	scheme, address, _ := strings.Cut(file.URL, "://")
+	options := []ftp.DialOption{
+		ftp.DialWithContext(ctx),
+		ftp.DialWithTimeout(ftpTimeout),
+		ftp.DialWithDisabledEPSV(exporter.FTPDisableEPSV),
+	}
+
+	if scheme == "ftps" || exporter.FTPExplicitTLS {
+		tlsConfig, err := exporter.loadFTPTLSConfig(address)
+		if err != nil {
+			return nil, err
+		}
+
+		if scheme == "ftps" {
+			options = append(options, ftp.DialWithTLS(tlsConfig))
+		} else {
+			options = append(options, ftp.DialWithExplicitTLS(tlsConfig))
+		}
+	}
+
+	conn, err := ftp.Dial(address, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	user := exporter.FTPUser
+	password := []byte{}
+	if len(user) == 0 {
+		user = defaultFTPUser
+	} else if len(exporter.FTPPasswordFile) > 0 {
+		password, err = os.ReadFile(exporter.FTPPasswordFile)
+		if err != nil {
+			conn.Quit()
+			return nil, fmt.Errorf("failed to read FTP password: %s", err.Error())
+		}
+	}
+
+	if err := conn.Login(user, strings.TrimSpace(string(password))); err != nil {
+		conn.Quit()
+		return nil, fmt.Errorf("FTP login as \"%s\" failed: %s", user, err.Error())
+	}
+
+	return conn, nil
+}
+
+func (exporter *Exporter) loadFTPTLSConfig(address string) (*tls.Config, error) {
+	host, _, _ := net.SplitHostPort(address)
+	config := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+
+	if len(exporter.FTPCAFile) > 0 {
+		contents, err := os.ReadFile(exporter.FTPCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read FTP CA file: %s", err.Error())
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(contents) {
+			return nil, fmt.Errorf("no certificate found in FTP CA file \"%s\"", exporter.FTPCAFile)
+		}
+	}
+
+	return config, nil
+}
+
+func readAndParseFTPFile(ctx context.Context, file *FTPFile, connect func(context.Context, *FTPFile) (*ftp.ServerConn, error)) ([]*parsedCertificate, error) {
+	ctx, cancel := context.WithTimeout(ctx, ftpTimeout)
+	defer cancel()
+
+	conn, err := connect(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	//nolint:errcheck
+	defer conn.Quit()
+
+	response, err := conn.Retr(file.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file.Path, err.Error())
+	}
+	defer response.Close()
+
+	//nolint:errcheck
+	response.SetDeadline(time.Now().Add(ftpTimeout))
+	contents, err := io.ReadAll(response)
+	if err != nil {
+		return nil, err
+	}
+
+	// appliances don't always serve PEM
+	return parseAutoDetectedCertificates(contents, nil)
+}
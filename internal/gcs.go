@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// GCSObject : A Google Cloud Storage object containing PEM certificates
+type GCSObject struct {
+	Bucket string
+	Object string
+}
+
+const gcsTimeout = 30 * time.Second
+
+// gcsObjectState : Last parsed version of an object, to skip downloading unchanged objects
+type gcsObjectState struct {
+	mutex          sync.Mutex
+	object         GCSObject
+	getClient      func() (*storage.Client, error)
+	generation     int64
+	metageneration int64
+	certificates   []*parsedCertificate
+}
+
+// ParseGCSURL : Split a gs://bucket/object URL
+func ParseGCSURL(gcsURL string) (GCSObject, error) {
+	bucketAndObject := strings.SplitN(strings.TrimPrefix(gcsURL, "gs://"), "/", 2)
+	if !strings.HasPrefix(gcsURL, "gs://") || len(bucketAndObject) != 2 || len(bucketAndObject[0]) == 0 || len(bucketAndObject[1]) == 0 {
+		return GCSObject{}, fmt.Errorf("expected gs://<bucket>/<object>, got \"%s\"", gcsURL)
+	}
+
+	return GCSObject{
+		Bucket: bucketAndObject[0],
+		Object: bucketAndObject[1],
+	}, nil
+}
+
+func (exporter *Exporter) collectGCSObjects() []*certificateRef {
+	output := []*certificateRef{}
+
+	for _, object := range exporter.GCSObjects {
+		output = append(output, &certificateRef{
+			path:      fmt.Sprintf("gs://%s/%s", object.Bucket, object.Object),
+			format:    certificateFormatGCS,
+			gcsObject: exporter.getGCSObjectState(object),
+		})
+	}
+
+	return output
+}
+
+func (exporter *Exporter) getGCSObjectState(object GCSObject) *gcsObjectState {
+	exporter.gcsMutex.Lock()
+	defer exporter.gcsMutex.Unlock()
+
+	if exporter.gcsObjectStates == nil {
+		exporter.gcsObjectStates = map[GCSObject]*gcsObjectState{}
+	}
+
+	state, found := exporter.gcsObjectStates[object]
+	if !found {
+		state = &gcsObjectState{object: object, getClient: exporter.getGCSClient}
+		exporter.gcsObjectStates[object] = state
+	}
+
+	return state
+}
+
+// getGCSClient : Lazily create the GCS client, using Application Default Credentials
+func (exporter *Exporter) getGCSClient() (*storage.Client, error) {
+	exporter.gcsMutex.Lock()
+	defer exporter.gcsMutex.Unlock()
+
+	if exporter.gcsClient == nil {
+		// not bound to a read timeout, as credentials may keep using this context
+		client, err := storage.NewClient(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCS client: %s", err.Error())
+		}
+		exporter.gcsClient = client
+	}
+
+	return exporter.gcsClient, nil
+}
+
+func readAndParseGCSObject(ctx context.Context, state *gcsObjectState) ([]*parsedCertificate, error) {
+	client, err := state.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, gcsTimeout)
+	defer cancel()
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	handle := client.Bucket(state.object.Bucket).Object(state.object.Object)
+	attrs, err := handle.Attrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.certificates != nil && attrs.Generation == state.generation && attrs.Metageneration == state.metageneration {
+		return copyParsedCertificates(state.certificates), nil
+	}
+
+	reader, err := handle.Generation(attrs.Generation).NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	contents, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := parsePEM(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*parsedCertificate{}
+	for _, cert := range certs {
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	state.generation = attrs.Generation
+	state.metageneration = attrs.Metageneration
+	state.certificates = output
+
+	return copyParsedCertificates(output), nil
+}
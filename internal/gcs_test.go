@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeGCSObject struct {
+	data       []byte
+	generation int64
+}
+
+// fakeGCSServer : Minimal GCS emulator for the metadata (JSON API) and media download endpoints
+type fakeGCSServer struct {
+	mutex     sync.Mutex
+	objects   map[string]*fakeGCSObject
+	downloads int
+}
+
+func (server *fakeGCSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/storage/v1/b/") {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/storage/v1/b/"), "/o/", 2)
+		object, found := server.objects[strings.Join(parts, "/")]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		//nolint:errcheck
+		json.NewEncoder(w).Encode(map[string]string{
+			"bucket":         parts[0],
+			"name":           parts[1],
+			"generation":     fmt.Sprintf("%d", object.generation),
+			"metageneration": "1",
+			"size":           fmt.Sprintf("%d", len(object.data)),
+		})
+		return
+	}
+
+	object, found := server.objects[strings.TrimPrefix(r.URL.Path, "/")]
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	server.downloads++
+	w.Header().Set("X-Goog-Generation", fmt.Sprintf("%d", object.generation))
+	//nolint:errcheck
+	w.Write(object.data)
+}
+
+func TestGCSObject(t *testing.T) {
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+	double, err := os.ReadFile("../test/double.pem")
+	assert.NoError(t, err)
+
+	fake := &fakeGCSServer{objects: map[string]*fakeGCSObject{
+		"certs/tls.pem": {data: basic, generation: 1},
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	t.Setenv("STORAGE_EMULATOR_HOST", server.URL)
+
+	exporter := &Exporter{
+		GCSObjects:         []GCSObject{{Bucket: "certs", Object: "tls.pem"}, {Bucket: "certs", Object: "missing.pem"}},
+		ExposeErrorMetrics: true,
+	}
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		assert.Len(t, foundMetrics, 1)
+		assert.Equal(t, "certs", getLabelValue(foundMetrics[0], "gcs_bucket"))
+		assert.Equal(t, "tls.pem", getLabelValue(foundMetrics[0], "gcs_object"))
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+
+		certErrors := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_error") {
+			certErrors[getLabelValue(metric, "gcs_object")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"tls.pem": 0, "missing.pem": 1}, certErrors)
+	})
+
+	// discovery and scrape: the unchanged object is only downloaded once
+	assert.Equal(t, 1, fake.downloads)
+
+	fake.mutex.Lock()
+	fake.objects["certs/tls.pem"] = &fakeGCSObject{data: double, generation: 2}
+	fake.mutex.Unlock()
+
+	refs, _ := exporter.parseAllCertificates(context.Background())
+	certCount := 0
+	for _, ref := range refs {
+		certCount += len(ref.certificates)
+	}
+	assert.Equal(t, 2, certCount)
+	assert.Equal(t, 2, fake.downloads)
+
+	// concurrent scrapes served from cache each resolve issuers on their own copies
+	exporter.ExposeIssuerMetrics = true
+	parseConcurrently(exporter)
+	cached, _ := exporter.parseAllCertificates(context.Background())
+	assert.NotSame(t, refs[0].certificates[0], cached[0].certificates[0])
+	assert.Equal(t, 2, fake.downloads)
+}
+
+func TestParseGCSURL(t *testing.T) {
+	object, err := ParseGCSURL("gs://bucket/path/to/cert.pem")
+	assert.NoError(t, err)
+	assert.Equal(t, GCSObject{Bucket: "bucket", Object: "path/to/cert.pem"}, object)
+
+	for _, invalid := range []string{"bucket/cert.pem", "gs://bucket", "gs://bucket/", "gs:///cert.pem", "s3://bucket/cert.pem"} {
+		_, err := ParseGCSURL(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
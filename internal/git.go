@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// GitFile : A file of a Git repository containing PEM certificates, at the tip of a branch or tag
+// (the default branch if Ref is empty)
+type GitFile struct {
+	Repository string
+	Ref        string
+	Path       string
+}
+
+const gitTimeout = 60 * time.Second
+
+const defaultGitRefreshInterval = 5 * time.Minute
+
+// gitFileState : Last parsed version of a file, only fetched again once the refresh interval elapsed
+type gitFileState struct {
+	mutex        sync.Mutex
+	file         GitFile
+	getAuth      func(string) (transport.AuthMethod, error)
+	interval     time.Duration
+	fetched      time.Time
+	certificates []*parsedCertificate
+}
+
+// ParseGitFileURL : Split a <repository URL>#[<ref>:]<path> specification, e.g. https://example.com/pki.git#main:ca.pem
+func ParseGitFileURL(spec string) (GitFile, error) {
+	separator := strings.LastIndex(spec, "#")
+	if separator <= 0 || separator == len(spec)-1 {
+		return GitFile{}, fmt.Errorf("expected <repository>#[<ref>:]<path>, got \"%s\"", spec)
+	}
+
+	file := GitFile{Repository: spec[:separator], Path: spec[separator+1:]}
+	if ref, filePath, found := strings.Cut(file.Path, ":"); found {
+		file.Ref = ref
+		file.Path = filePath
+	}
+
+	file.Path = strings.TrimPrefix(file.Path, "/")
+	if len(file.Path) == 0 {
+		return GitFile{}, fmt.Errorf("expected <repository>#[<ref>:]<path>, got \"%s\"", spec)
+	}
+
+	return file, nil
+}
+
+func (exporter *Exporter) collectGitFiles() []*certificateRef {
+	output := []*certificateRef{}
+
+	for _, file := range exporter.GitFiles {
+		ref := file.Ref
+		if len(ref) == 0 {
+			ref = "HEAD"
+		}
+
+		output = append(output, &certificateRef{
+			path:    fmt.Sprintf("git/%s#%s:%s", file.Repository, ref, file.Path),
+			format:  certificateFormatGit,
+			gitFile: exporter.getGitFileState(file),
+		})
+	}
+
+	return output
+}
+
+func (exporter *Exporter) getGitFileState(file GitFile) *gitFileState {
+	exporter.gitMutex.Lock()
+	defer exporter.gitMutex.Unlock()
+
+	if exporter.gitFileStates == nil {
+		exporter.gitFileStates = map[GitFile]*gitFileState{}
+	}
+
+	state, found := exporter.gitFileStates[file]
+	if !found {
+		interval := exporter.GitRefreshInterval
+		if interval == 0 {
+			interval = defaultGitRefreshInterval
+		}
+
+		state = &gitFileState{file: file, getAuth: exporter.getGitAuth, interval: interval}
+		exporter.gitFileStates[file] = state
+	}
+
+	return state
+}
+
+// getGitAuth : Authenticate with GitTokenFile over HTTP(S), and with GitSSHKeyFile
+// (checked against the user's known_hosts) over SSH, none being used when unset
+func (exporter *Exporter) getGitAuth(repository string) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(repository)
+	if err != nil {
+		return nil, err
+	}
+
+	switch endpoint.Protocol {
+	case "http", "https":
+		if len(exporter.GitTokenFile) == 0 {
+			return nil, nil
+		}
+
+		token, err := os.ReadFile(exporter.GitTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read git token: %s", err.Error())
+		}
+
+		// the username is ignored by forges when a token is given, but must not be empty
+		return &githttp.BasicAuth{Username: "x-access-token", Password: strings.TrimSpace(string(token))}, nil
+	case "ssh":
+		if len(exporter.GitSSHKeyFile) == 0 {
+			return nil, nil
+		}
+
+		user := endpoint.User
+		if len(user) == 0 {
+			user = "git"
+		}
+
+		auth, err := gitssh.NewPublicKeysFromFile(user, exporter.GitSSHKeyFile, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read git SSH key: %s", err.Error())
+		}
+		return auth, nil
+	}
+
+	return nil, nil
+}
+
+func readAndParseGitFile(ctx context.Context, state *gitFileState) ([]*parsedCertificate, error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if state.certificates != nil && time.Since(state.fetched) < state.interval {
+		return copyParsedCertificates(state.certificates), nil
+	}
+
+	auth, err := state.getAuth(state.file.Repository)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	contents, err := fetchGitFile(ctx, state.file, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := parsePEM(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*parsedCertificate{}
+	for _, cert := range certs {
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	state.fetched = time.Now()
+	state.certificates = output
+
+	return copyParsedCertificates(output), nil
+}
+
+// fetchGitFile : Shallow clone the ref of a repository in memory, without checking out any file,
+// and read a file from the tree of its tip; refs are tried as branches, then as tags
+func fetchGitFile(ctx context.Context, file GitFile, auth transport.AuthMethod) ([]byte, error) {
+	refNames := []plumbing.ReferenceName{plumbing.HEAD}
+	if strings.HasPrefix(file.Ref, "refs/") {
+		refNames = []plumbing.ReferenceName{plumbing.ReferenceName(file.Ref)}
+	} else if len(file.Ref) > 0 {
+		refNames = []plumbing.ReferenceName{plumbing.NewBranchReferenceName(file.Ref), plumbing.NewTagReferenceName(file.Ref)}
+	}
+
+	var repository *git.Repository
+	var err error
+	for _, refName := range refNames {
+		options := &git.CloneOptions{
+			URL:          file.Repository,
+			Auth:         auth,
+			SingleBranch: true,
+			Depth:        1,
+			Tags:         git.NoTags,
+		}
+		if refName != plumbing.HEAD {
+			options.ReferenceName = refName
+		}
+
+		repository, err = git.CloneContext(ctx, memory.NewStorage(), nil, options)
+		if !errors.Is(err, plumbing.ErrReferenceNotFound) && !isNoMatchingRefSpecError(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := repository.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repository.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	gitFile, err := commit.File(file.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file.Path, err.Error())
+	}
+
+	contents, err := gitFile.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(contents), nil
+}
+
+func isNoMatchingRefSpecError(err error) bool {
+	var noMatching git.NoMatchingRefSpecError
+	return errors.As(err, &noMatching)
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// commitTestCertificate : Write certificates to a file of the worktree and commit it
+func commitTestCertificate(t *testing.T, repository *git.Repository, dir string, name string, certs ...*testCertificate) plumbing.Hash {
+	writeTestCertificates(path.Join(dir, name), certs...)
+
+	worktree, err := repository.Worktree()
+	assert.NoError(t, err)
+	_, err = worktree.Add(name)
+	assert.NoError(t, err)
+
+	hash, err := worktree.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	assert.NoError(t, err)
+	return hash
+}
+
+func TestGitFile(t *testing.T) {
+	dir := t.TempDir()
+	repository, err := git.PlainInit(dir, false)
+	assert.NoError(t, err)
+
+	notAfter := time.Now().Add(time.Hour)
+	tagged := commitTestCertificate(t, repository, dir, "ca.pem", generateTestCertificate(caTemplate("tagged", notAfter), nil))
+	_, err = repository.CreateTag("v1", tagged, nil)
+	assert.NoError(t, err)
+	commitTestCertificate(t, repository, dir, "ca.pem", generateTestCertificate(caTemplate("latest", notAfter), nil))
+
+	exporter := &Exporter{
+		GitFiles: []GitFile{
+			{Repository: "file://" + dir, Path: "ca.pem"},
+			{Repository: "file://" + dir, Ref: "v1", Path: "ca.pem"},
+			{Repository: "file://" + dir, Ref: "master", Path: "missing.pem"},
+		},
+		GitRefreshInterval: time.Hour,
+	}
+
+	getSubjects := func() (map[string]string, int) {
+		refs, errs := exporter.parseAllCertificates(context.Background())
+		subjects := map[string]string{}
+		for _, ref := range refs {
+			for _, cert := range ref.certificates {
+				labels := exporter.getBaseLabels(ref)
+				assert.Equal(t, "file://"+dir, labels["git_repository"])
+				assert.Equal(t, "ca.pem", labels["git_path"])
+				subjects[labels["git_ref"]] = cert.cert.Subject.CommonName
+			}
+		}
+		return subjects, len(errs)
+	}
+
+	subjects, errCount := getSubjects()
+	assert.Equal(t, map[string]string{"": "latest", "v1": "tagged"}, subjects)
+	assert.Equal(t, 1, errCount)
+
+	// not fetched again before the refresh interval
+	commitTestCertificate(t, repository, dir, "ca.pem", generateTestCertificate(caTemplate("renewed", notAfter), nil))
+	subjects, _ = getSubjects()
+	assert.Equal(t, "latest", subjects[""])
+
+	// concurrent scrapes served from cache each resolve issuers on their own copies
+	exporter.ExposeIssuerMetrics = true
+	parseConcurrently(exporter)
+	first, _ := exporter.parseAllCertificates(context.Background())
+	second, _ := exporter.parseAllCertificates(context.Background())
+	assert.NotSame(t, first[0].certificates[0], second[0].certificates[0])
+
+	for _, state := range exporter.gitFileStates {
+		state.fetched = time.Time{}
+	}
+	subjects, _ = getSubjects()
+	assert.Equal(t, "renewed", subjects[""])
+}
+
+func TestGitAuth(t *testing.T) {
+	tokenFile := path.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("secret\n"), 0600))
+
+	exporter := &Exporter{GitTokenFile: tokenFile}
+	auth, err := exporter.getGitAuth("https://example.com/pki.git")
+	assert.NoError(t, err)
+	assert.Equal(t, &githttp.BasicAuth{Username: "x-access-token", Password: "secret"}, auth)
+
+	// tokens aren't sent over SSH
+	auth, err = exporter.getGitAuth("git@example.com:pki.git")
+	assert.NoError(t, err)
+	assert.Nil(t, auth)
+
+	exporter.GitSSHKeyFile = path.Join(t.TempDir(), "missing")
+	_, err = exporter.getGitAuth("ssh://git@example.com/pki.git")
+	assert.ErrorContains(t, err, "failed to read git SSH key")
+}
+
+func TestParseGitFileURL(t *testing.T) {
+	file, err := ParseGitFileURL("https://example.com/pki.git#main:certs/ca.pem")
+	assert.NoError(t, err)
+	assert.Equal(t, GitFile{Repository: "https://example.com/pki.git", Ref: "main", Path: "certs/ca.pem"}, file)
+
+	file, err = ParseGitFileURL("git@example.com:pki.git#/ca.pem")
+	assert.NoError(t, err)
+	assert.Equal(t, GitFile{Repository: "git@example.com:pki.git", Path: "ca.pem"}, file)
+
+	for _, invalid := range []string{"", "https://example.com/pki.git", "https://example.com/pki.git#", "#ca.pem", "https://example.com/pki.git#main:"} {
+		_, err := ParseGitFileURL(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"path"
+	"regexp"
+)
+
+// hashedEntryRegexp : Names of the certificate entries of c_rehash directories, <subject hash>.<collision index>,
+// CRLs being stored as <hash>.r<index> entries
+var hashedEntryRegexp = regexp.MustCompile(`^([0-9a-f]{8})\.[0-9]+$`)
+
+// collectHashedDirectories : List the hashed entries of OpenSSL c_rehash directories (e.g. /etc/ssl/certs), usually
+// symlinks to certificate files of the same directory, which are ignored so that each certificate is read once
+func (exporter *Exporter) collectHashedDirectories(pattern string) ([]*certificateRef, []error) {
+	refs, errs := exporter.collectMatchingPaths(pattern, certificateFormatPEM, true)
+
+	output := []*certificateRef{}
+	for _, ref := range refs {
+		match := hashedEntryRegexp.FindStringSubmatch(path.Base(ref.path))
+		if match == nil {
+			continue
+		}
+
+		ref.subjectHash = match[1]
+		output = append(output, ref)
+	}
+
+	return output, errs
+}
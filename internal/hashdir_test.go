@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "first-root.pem"), generateTestCertificate(caTemplate("first-root", time.Now().Add(time.Hour)), nil))
+	writeTestCertificates(path.Join(dir, "second-root.pem"), generateTestCertificate(caTemplate("second-root", time.Now().Add(time.Hour)), nil))
+	writeTestCertificates(path.Join(dir, "colliding-root.pem"), generateTestCertificate(caTemplate("colliding-root", time.Now().Add(time.Hour)), nil))
+
+	// c_rehash layout: hashed symlinks next to the certificate files, a CRL and unrelated files
+	assert.NoError(t, os.Symlink("first-root.pem", path.Join(dir, "5ad8a5d6.0")))
+	assert.NoError(t, os.Symlink("second-root.pem", path.Join(dir, "9d04f354.0")))
+	assert.NoError(t, os.Symlink("colliding-root.pem", path.Join(dir, "9d04f354.1")))
+	assert.NoError(t, os.WriteFile(path.Join(dir, "9d04f354.r0"), []byte("not a certificate"), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(dir, "README"), []byte("not a certificate"), 0644))
+	assert.NoError(t, os.Mkdir(path.Join(dir, "0badc0de.0"), 0755))
+
+	testRequest(t, &Exporter{
+		HashedDirectories:  []string{dir},
+		ExposeErrorMetrics: true,
+	}, func(metrics []model.MetricFamily) {
+		found := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			found[getLabelValue(metric, "filename")] = getLabelValue(metric, "subject_hash") + "/" + getLabelValue(metric, "subject_CN")
+		}
+
+		assert.Equal(t, map[string]string{
+			"5ad8a5d6.0": "5ad8a5d6/first-root",
+			"9d04f354.0": "9d04f354/second-root",
+			"9d04f354.1": "9d04f354/colliding-root",
+		}, found)
+		assert.Equal(t, 0., getMetricsForName(metrics, "x509_read_errors")[0].GetGauge().GetValue())
+	})
+
+	testRequest(t, &Exporter{
+		HashedDirectories: []string{path.Join(dir, "missing")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Equal(t, 1., getMetricsForName(metrics, "x509_read_errors")[0].GetGauge().GetValue())
+	})
+}
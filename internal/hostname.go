@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ExpectedHostname : Hostname the leaf certificates of the sources matching a path pattern must be valid for
+type ExpectedHostname struct {
+	Path     string
+	Hostname string
+}
+
+// ParseExpectedHostname : Split a <path pattern>=<hostname> specification
+func ParseExpectedHostname(spec string) (ExpectedHostname, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return ExpectedHostname{}, fmt.Errorf("expected <path pattern>=<hostname>, got \"%s\"", spec)
+	}
+
+	if !doublestar.ValidatePattern(parts[0]) {
+		return ExpectedHostname{}, fmt.Errorf("invalid path pattern \"%s\"", parts[0])
+	}
+
+	return ExpectedHostname{Path: parts[0], Hostname: parts[1]}, nil
+}
+
+// getExpectedHostname : The hostname the certificates of a source must match, the one dialed for TLS endpoints,
+// empty if there's none to check
+func (exporter *Exporter) getExpectedHostname(ref *certificateRef) string {
+	switch ref.format {
+	case certificateFormatEndpoint:
+		if !exporter.ExposeHostnameMetrics {
+			return ""
+		}
+
+		host, _, err := net.SplitHostPort(ref.endpoint.endpoint.Address)
+		if err != nil {
+			return ""
+		}
+		return host
+	case certificateFormatPEM, certificateFormatYAML, certificateFormatINI, certificateFormatDotenv, certificateFormatEmbeddedPEM, certificateFormatSDS:
+		for _, expected := range exporter.ExpectedHostnames {
+			if matched, _ := doublestar.Match(expected.Path, ref.path); matched {
+				return expected.Hostname
+			}
+		}
+	}
+
+	return ""
+}
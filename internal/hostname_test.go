@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"net"
+	"path"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostnameMatch(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(24 * time.Hour)
+
+	ca := generateTestCertificate(caTemplate("ca", notAfter), nil)
+	template := leafTemplate("api", notAfter)
+	template.DNSNames = []string{"api.example.com", "*.api.example.com"}
+	template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	writeTestCertificates(path.Join(dir, "api.pem"), generateTestCertificate(template, ca), ca)
+	writeTestCertificates(path.Join(dir, "web.pem"), generateTestCertificate(template, ca))
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+		ExpectedHostnames: []ExpectedHostname{
+			{Path: "**/api.pem", Hostname: "v1.api.example.com"},
+			{Path: "**/web.pem", Hostname: "www.example.com"},
+		},
+	}, func(metrics []model.MetricFamily) {
+		// CA certificates aren't checked
+		foundMetrics := getMetricsForName(metrics, "x509_cert_hostname_match")
+		assert.Len(t, foundMetrics, 2)
+
+		matches := map[string]float64{}
+		for _, metric := range foundMetrics {
+			matches[getLabelValue(metric, "expected_hostname")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"v1.api.example.com": 1, "www.example.com": 0}, matches)
+	})
+
+	server := startFakeTLSServer(t, generateTestCertificate(template, ca), nil)
+	test := func(exposeHostnameMetrics bool, expected map[string]float64) {
+		testRequest(t, &Exporter{
+			TLSEndpoints:          []TLSEndpoint{{Address: server.address()}},
+			ExposeHostnameMetrics: exposeHostnameMetrics,
+		}, func(metrics []model.MetricFamily) {
+			matches := map[string]float64{}
+			for _, metric := range getMetricsForName(metrics, "x509_cert_hostname_match") {
+				matches[getLabelValue(metric, "expected_hostname")] = metric.GetGauge().GetValue()
+			}
+			assert.Equal(t, expected, matches)
+		})
+	}
+
+	test(true, map[string]float64{"127.0.0.1": 1})
+	test(false, map[string]float64{})
+}
+
+func TestParseExpectedHostname(t *testing.T) {
+	expected, err := ParseExpectedHostname("/etc/certs/*.pem=api.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, ExpectedHostname{Path: "/etc/certs/*.pem", Hostname: "api.example.com"}, expected)
+
+	for _, invalid := range []string{"", "/etc/certs/api.pem", "=api.example.com", "/etc/certs/api.pem=", "/etc/[certs=api.example.com"} {
+		_, err := ParseExpectedHostname(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const defaultHTTPSDRefreshInterval = time.Minute
+
+const httpSDTimeout = 30 * time.Second
+
+// maxHTTPSDResponseSize : Upper bound of a service discovery response
+const maxHTTPSDResponseSize = 16 << 20
+
+// httpSDTargetGroup : Entry of a Prometheus HTTP service discovery response, whose labels are ignored
+type httpSDTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// collectHTTPSDEndpoints : Build an endpoint ref for each discovered target, the target list being
+// fetched again once older than HTTPSDRefreshInterval; the previous list is kept when this fails
+func (exporter *Exporter) collectHTTPSDEndpoints(ctx context.Context) ([]*certificateRef, []error) {
+	if len(exporter.HTTPSDURL) == 0 {
+		return nil, nil
+	}
+
+	exporter.httpSDMutex.Lock()
+	defer exporter.httpSDMutex.Unlock()
+
+	interval := exporter.HTTPSDRefreshInterval
+	if interval == 0 {
+		interval = defaultHTTPSDRefreshInterval
+	}
+
+	outputErrors := []error{}
+	if exporter.httpSDFetched.IsZero() || time.Since(exporter.httpSDFetched) >= interval {
+		targets, errs := exporter.fetchHTTPSDTargets(ctx, interval)
+		outputErrors = append(outputErrors, errs...)
+
+		// the states of removed targets are pruned along with the other sources which are gone
+		if targets != nil {
+			exporter.httpSDTargets = targets
+		}
+		exporter.httpSDFetched = time.Now()
+	}
+
+	output := []*certificateRef{}
+	for _, target := range exporter.httpSDTargets {
+		output = append(output, &certificateRef{
+			path:     fmt.Sprintf("endpoint/%s", target),
+			format:   certificateFormatEndpoint,
+			endpoint: exporter.getEndpointState(TLSEndpoint{Address: target}),
+		})
+	}
+
+	return output, outputErrors
+}
+
+// getHTTPSDClient : HTTP client trusting the CA certificates of HTTPSDCAFile (the system roots when unset)
+// to fetch the targets list, unrelated to the certificates monitored on the targets
+func (exporter *Exporter) getHTTPSDClient() (*http.Client, error) {
+	if len(exporter.HTTPSDCAFile) == 0 {
+		return http.DefaultClient, nil
+	}
+
+	contents, err := os.ReadFile(exporter.HTTPSDCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTTP SD CA file: %s", err.Error())
+	}
+
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(contents) {
+		return nil, fmt.Errorf("no certificate found in HTTP SD CA file \"%s\"", exporter.HTTPSDCAFile)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: rootCAs}
+	return &http.Client{Transport: transport}, nil
+}
+
+// fetchHTTPSDTargets : Read the host:port targets of all groups, malformed ones being skipped and reported,
+// nil when the list couldn't be fetched
+func (exporter *Exporter) fetchHTTPSDTargets(ctx context.Context, interval time.Duration) ([]string, []error) {
+	url := exporter.HTTPSDURL
+	ctx, cancel := context.WithTimeout(ctx, httpSDTimeout)
+	defer cancel()
+
+	client, err := exporter.getHTTPSDClient()
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to discover targets from %s: %s", url, err.Error())}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to discover targets from %s: %s", url, err.Error())}
+	}
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("X-Prometheus-Refresh-Interval-Seconds", strconv.Itoa(int(interval.Seconds())))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to discover targets from %s: %s", url, err.Error())}
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, []error{fmt.Errorf("failed to discover targets from %s: unexpected status %s", url, response.Status)}
+	}
+
+	groups := []httpSDTargetGroup{}
+	err = json.NewDecoder(io.LimitReader(response.Body, maxHTTPSDResponseSize)).Decode(&groups)
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to decode targets from %s: %s", url, err.Error())}
+	}
+
+	output := []string{}
+	outputErrors := []error{}
+	for _, group := range groups {
+		for _, target := range group.Targets {
+			if _, _, err := net.SplitHostPort(target); err != nil {
+				outputErrors = append(outputErrors, fmt.Errorf("malformed target \"%s\" discovered from %s: %s", target, url, err.Error()))
+				continue
+			}
+
+			if !slices.Contains(output, target) {
+				output = append(output, target)
+			}
+		}
+	}
+
+	return output, outputErrors
+}
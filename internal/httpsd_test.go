@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeHTTPSD : Serves the configured target groups, or an error when groups is nil
+type fakeHTTPSD struct {
+	mutex    sync.Mutex
+	groups   []httpSDTargetGroup
+	requests int
+}
+
+func (sd *fakeHTTPSD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sd.mutex.Lock()
+	defer sd.mutex.Unlock()
+	sd.requests++
+
+	if sd.groups == nil {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	//nolint:errcheck
+	json.NewEncoder(w).Encode(sd.groups)
+}
+
+func (sd *fakeHTTPSD) set(groups []httpSDTargetGroup) {
+	sd.mutex.Lock()
+	defer sd.mutex.Unlock()
+	sd.groups = groups
+}
+
+func getDiscoveredEndpoints(exporter *Exporter) ([]string, int) {
+	refs, errs := exporter.parseAllCertificates(context.Background())
+	endpoints := []string{}
+	for _, ref := range refs {
+		for _, cert := range ref.certificates {
+			endpoints = append(endpoints, exporter.getBaseLabels(ref)["endpoint"]+"="+cert.cert.Subject.CommonName)
+		}
+	}
+
+	return endpoints, len(errs)
+}
+
+func TestHTTPSD(t *testing.T) {
+	first := startFakeTLSServer(t, generateTestCertificate(leafTemplate("first", time.Now().Add(time.Hour)), nil), nil)
+	second := startFakeTLSServer(t, generateTestCertificate(leafTemplate("second", time.Now().Add(time.Hour)), nil), nil)
+
+	sd := &fakeHTTPSD{groups: []httpSDTargetGroup{
+		{Targets: []string{first.address()}, Labels: map[string]string{"env": "test"}},
+	}}
+	server := httptest.NewServer(sd)
+	defer server.Close()
+
+	exporter := &Exporter{
+		HTTPSDURL:             server.URL,
+		HTTPSDRefreshInterval: time.Nanosecond,
+	}
+
+	endpoints, errCount := getDiscoveredEndpoints(exporter)
+	assert.Equal(t, []string{first.address() + "=first"}, endpoints)
+	assert.Equal(t, 0, errCount)
+
+	// targets are added, and malformed ones reported
+	sd.set([]httpSDTargetGroup{
+		{Targets: []string{first.address(), second.address()}},
+		{Targets: []string{"missing-port", second.address()}},
+	})
+	endpoints, errCount = getDiscoveredEndpoints(exporter)
+	assert.ElementsMatch(t, []string{first.address() + "=first", second.address() + "=second"}, endpoints)
+	assert.Equal(t, 1, errCount)
+
+	// removed targets drop their series and cached state
+	sd.set([]httpSDTargetGroup{{Targets: []string{second.address()}}})
+	endpoints, errCount = getDiscoveredEndpoints(exporter)
+	assert.Equal(t, []string{second.address() + "=second"}, endpoints)
+	assert.Equal(t, 0, errCount)
+	assert.NotContains(t, exporter.endpointStates, first.address())
+
+	// the last known targets are kept while discovery fails
+	sd.set(nil)
+	endpoints, errCount = getDiscoveredEndpoints(exporter)
+	assert.Equal(t, []string{second.address() + "=second"}, endpoints)
+	assert.Equal(t, 1, errCount)
+
+	// targets aren't fetched again before the refresh interval
+	sd.set([]httpSDTargetGroup{{Targets: []string{first.address()}}})
+	endpoints, _ = getDiscoveredEndpoints(exporter)
+	assert.Equal(t, []string{first.address() + "=first"}, endpoints)
+
+	exporter.HTTPSDRefreshInterval = time.Hour
+	sd.set([]httpSDTargetGroup{{Targets: []string{second.address()}}})
+	requests := sd.requests
+	endpoints, _ = getDiscoveredEndpoints(exporter)
+	assert.Equal(t, []string{first.address() + "=first"}, endpoints)
+	assert.Equal(t, requests, sd.requests)
+}
+
+func TestHTTPSDTargetDeclaredByMonitor(t *testing.T) {
+	target := startFakeTLSServer(t, generateTestCertificate(leafTemplate("target", time.Now().Add(time.Hour)), nil), nil)
+
+	sd := &fakeHTTPSD{groups: []httpSDTargetGroup{{Targets: []string{target.address()}}}}
+	server := httptest.NewServer(sd)
+	defer server.Close()
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{CertificateMonitorResource: "CertificateMonitorList"},
+		newCertificateMonitor("app", "app", map[string]interface{}{
+			"endpoints": []interface{}{target.address()},
+		}),
+	)
+
+	exporter := &Exporter{
+		HTTPSDURL:             server.URL,
+		HTTPSDRefreshInterval: time.Nanosecond,
+	}
+	assert.NoError(t, exporter.startCertificateMonitorInformer(dynamicClient, fake.NewSimpleClientset()))
+	defer exporter.Shutdown()
+
+	assert.Eventually(t, func() bool {
+		endpoints, _ := getDiscoveredEndpoints(exporter)
+		return len(endpoints) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	state := exporter.getEndpointState(TLSEndpoint{Address: target.address()})
+
+	// no longer discovered, but the monitor still watches it
+	sd.set([]httpSDTargetGroup{})
+	endpoints, _ := getDiscoveredEndpoints(exporter)
+	assert.Equal(t, []string{target.address() + "=target"}, endpoints)
+	assert.Same(t, state, exporter.getEndpointState(TLSEndpoint{Address: target.address()}))
+}
+
+func TestHTTPSDCAFile(t *testing.T) {
+	target := startFakeTLSServer(t, generateTestCertificate(leafTemplate("target", time.Now().Add(time.Hour)), nil), nil)
+
+	// served with a certificate of its own, unknown to the system roots
+	server := httptest.NewTLSServer(&fakeHTTPSD{groups: []httpSDTargetGroup{{Targets: []string{target.address()}}}})
+	defer server.Close()
+	caFile := path.Join(t.TempDir(), "ca.pem")
+	writeTestCertificates(caFile, &testCertificate{cert: server.Certificate()})
+
+	exporter := &Exporter{HTTPSDURL: server.URL}
+	endpoints, errCount := getDiscoveredEndpoints(exporter)
+	assert.Empty(t, endpoints)
+	assert.Equal(t, 1, errCount)
+
+	exporter = &Exporter{HTTPSDURL: server.URL, HTTPSDCAFile: caFile}
+	endpoints, errCount = getDiscoveredEndpoints(exporter)
+	assert.Equal(t, []string{target.address() + "=target"}, endpoints)
+	assert.Equal(t, 0, errCount)
+
+	exporter = &Exporter{HTTPSDURL: server.URL, HTTPSDCAFile: path.Join(t.TempDir(), "missing.pem")}
+	_, errCount = getDiscoveredEndpoints(exporter)
+	assert.Equal(t, 1, errCount)
+}
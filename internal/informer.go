@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// MinKubeInformerResync : Shortest resync period allowed, as each resync replays every cached object to the
+// handlers (the API server isn't called again, watches keeping the cache up-to-date meanwhile)
+const MinKubeInformerResync = time.Minute
+
+// getKubeInformerFactory : The informer factory shared by every watcher of the cluster, so that a resource
+// watched twice is only listed and watched once; created on first use with KubeInformerResync
+func (exporter *Exporter) getKubeInformerFactory(dynamicClient dynamic.Interface) (dynamicinformer.DynamicSharedInformerFactory, chan struct{}) {
+	exporter.kubeInformerMutex.Lock()
+	defer exporter.kubeInformerMutex.Unlock()
+
+	if exporter.kubeInformerFactory == nil {
+		exporter.kubeInformerFactory = dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, exporter.KubeInformerResync)
+		exporter.kubeInformerStop = make(chan struct{})
+	}
+
+	return exporter.kubeInformerFactory, exporter.kubeInformerStop
+}
+
+// kubeInformerSyncTimeout : Longest wait for the initial list of a watched resource
+const kubeInformerSyncTimeout = time.Minute
+
+// startKubeLister : Watch a resource through the shared factory, and return its lister once the initial list is synced,
+// scrapes then reading the cache of the informer instead of listing the resource again
+func (exporter *Exporter) startKubeLister(dynamicClient dynamic.Interface, resource schema.GroupVersionResource) (cache.GenericLister, error) {
+	factory, stop := exporter.getKubeInformerFactory(dynamicClient)
+	informer := factory.ForResource(resource)
+
+	// only starts the informers which aren't running yet
+	factory.Start(stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), kubeInformerSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+		return nil, fmt.Errorf("failed to list %s resources", resource.String())
+	}
+
+	return informer.Lister(), nil
+}
+
+// stopKubeInformers : Stop the informers of the shared factory, and wait for them to return
+func (exporter *Exporter) stopKubeInformers() {
+	exporter.kubeInformerMutex.Lock()
+	defer exporter.kubeInformerMutex.Unlock()
+
+	if exporter.kubeInformerFactory == nil {
+		return
+	}
+
+	close(exporter.kubeInformerStop)
+	exporter.kubeInformerFactory.Shutdown()
+	exporter.kubeInformerFactory = nil
+	exporter.kubeInformerStop = nil
+}
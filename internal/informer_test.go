@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubeInformerFactory(t *testing.T) {
+	resync := func(period time.Duration) (*Exporter, func() *certificateMonitor) {
+		dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+			runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{CertificateMonitorResource: "CertificateMonitorList"},
+			newCertificateMonitor("app", "app", map[string]interface{}{"endpoints": []interface{}{"app.example.com:443"}}),
+		)
+
+		exporter := &Exporter{KubeInformerResync: period}
+		assert.NoError(t, exporter.startCertificateMonitorInformer(dynamicClient, fake.NewSimpleClientset()))
+		t.Cleanup(exporter.stopKubeInformers)
+
+		// another watcher gets the same factory, and the informers are shared
+		factory, _ := exporter.getKubeInformerFactory(dynamicClient)
+		assert.Same(t, exporter.kubeInformerFactory, factory)
+		assert.True(t, factory.ForResource(CertificateMonitorResource).Informer().HasSynced())
+
+		return exporter, func() *certificateMonitor {
+			exporter.monitorsMutex.Lock()
+			defer exporter.monitorsMutex.Unlock()
+			return exporter.monitors["app/app"]
+		}
+	}
+
+	// resyncs reconcile unchanged resources again
+	_, getMonitor := resync(100 * time.Millisecond)
+	reconciled := getMonitor()
+	assert.NotNil(t, reconciled)
+	assert.Eventually(t, func() bool { return getMonitor() != reconciled }, 5*time.Second, 50*time.Millisecond)
+
+	_, getMonitor = resync(0)
+	reconciled = getMonitor()
+	time.Sleep(300 * time.Millisecond)
+	assert.Same(t, reconciled, getMonitor())
+
+	// stopped informers release the factory, a new one is then created
+	exporter, _ := resync(0)
+	exporter.stopKubeInformers()
+	assert.Nil(t, exporter.kubeInformerFactory)
+}
+
+func TestKubeInformerFactorySharedByWatchers(t *testing.T) {
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, networkingv1.AddToScheme(scheme))
+	listKinds := map[schema.GroupVersionResource]string{
+		CertificateMonitorResource: "CertificateMonitorList",
+		kubeIngressesResource:      "IngressList",
+	}
+	for _, resource := range caBundleResources {
+		listKinds[resource.resource] = resource.kind + "List"
+	}
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, listKinds,
+		newCertificateMonitor("default", "shop", map[string]interface{}{
+			"secrets": []interface{}{map[string]interface{}{"name": "shop-tls"}},
+		}),
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "default"},
+			Spec: networkingv1.IngressSpec{TLS: []networkingv1.IngressTLS{
+				{Hosts: []string{"shop.example.com"}, SecretName: "shop-tls"},
+			}},
+		},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apiregistration.k8s.io/v1",
+			"kind":       "APIService",
+			"metadata":   map[string]interface{}{"name": "v1beta1.metrics.k8s.io"},
+			"spec":       map[string]interface{}{"caBundle": base64.StdEncoding.EncodeToString(basic)},
+		}},
+	)
+	kubeClient := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop-tls", Namespace: "default"},
+		Type:       v1.SecretTypeTLS,
+		Data:       map[string][]byte{"tls.crt": basic},
+	})
+
+	exporter := &Exporter{}
+	defer exporter.stopKubeInformers()
+	assert.NoError(t, exporter.startCertificateMonitorInformer(dynamicClient, kubeClient))
+	factory := exporter.kubeInformerFactory
+	assert.NoError(t, exporter.startKubeIngressLister(dynamicClient, kubeClient))
+	assert.NoError(t, exporter.startCABundleListers(dynamicClient))
+	assert.Same(t, factory, exporter.kubeInformerFactory)
+
+	formats := map[certificateFormat]int{}
+	for i := 0; i < 3; i++ {
+		refs, _ := exporter.parseAllCertificates(context.Background())
+		formats = map[certificateFormat]int{}
+		for _, ref := range refs {
+			formats[ref.format] += len(ref.certificates)
+		}
+	}
+	assert.Equal(t, 1, formats[certificateFormatKubeSecret])
+	assert.Equal(t, 1, formats[certificateFormatKubeIngress])
+	assert.Equal(t, 1, formats[certificateFormatCABundle])
+
+	// each resource was listed once by its informer, scrapes reading their caches
+	lists := map[string]int{}
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "list" {
+			lists[action.GetResource().Resource]++
+		}
+	}
+	assert.Equal(t, map[string]int{
+		"certificatemonitors":             1,
+		"ingresses":                       1,
+		"validatingwebhookconfigurations": 1,
+		"mutatingwebhookconfigurations":   1,
+		"apiservices":                     1,
+	}, lists)
+}
@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/enix/x509-certificate-exporter/v3/internal/ingestpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const defaultIngestRetention = 24 * time.Hour
+
+var ingestLabelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ingestedRecord : Certificates last reported by an agent for one of its sources
+type ingestedRecord struct {
+	agent        string
+	source       string
+	labels       map[string]string
+	certificates []*x509.Certificate
+	lastSeen     time.Time
+}
+
+// ingestServer : Implementation of the CertificateIngestion service, agents being identified by the CN
+// of their verified client certificate
+type ingestServer struct {
+	ingestpb.UnimplementedCertificateIngestionServer
+	exporter *Exporter
+}
+
+// startIngestServer : Serve the CertificateIngestion gRPC service on IngestListenAddress, over TLS with the metrics
+// server certificate, and only to agents presenting a client certificate issued by IngestClientCAFile
+func (exporter *Exporter) startIngestServer() error {
+	if len(exporter.IngestListenAddress) == 0 {
+		return nil
+	}
+
+	if len(exporter.TLSCertFile) == 0 || len(exporter.TLSKeyFile) == 0 || len(exporter.IngestClientCAFile) == 0 {
+		return errors.New("certificate ingestion requires a server certificate and key, and a client CA file")
+	}
+
+	serverCert, err := tls.LoadX509KeyPair(exporter.TLSCertFile, exporter.TLSKeyFile)
+	if err != nil {
+		return err
+	}
+
+	contents, err := os.ReadFile(exporter.IngestClientCAFile)
+	if err != nil {
+		return fmt.Errorf("failed to read ingestion client CA file: %s", err.Error())
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(contents) {
+		return fmt.Errorf("no certificate found in ingestion client CA file \"%s\"", exporter.IngestClientCAFile)
+	}
+
+	listener, err := net.Listen("tcp", exporter.IngestListenAddress)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	})))
+	ingestpb.RegisterCertificateIngestionServer(server, &ingestServer{exporter: exporter})
+	exporter.ingestServer = server
+	exporter.ingestListener = listener
+
+	//nolint:errcheck
+	go server.Serve(listener)
+
+	return nil
+}
+
+// Report : Replace the certificates previously reported by the calling agent for a source,
+// an empty request forgetting them
+func (server *ingestServer) Report(ctx context.Context, request *ingestpb.ReportRequest) (*ingestpb.ReportResponse, error) {
+	agent, err := getIngestAgent(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(request.GetSource()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing source")
+	}
+
+	for name := range request.GetLabels() {
+		if !ingestLabelNameRegexp.MatchString(name) || !isValidLabelName(name) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid label name \"%s\"", name)
+		}
+		if isReservedLabelName(name) {
+			return nil, status.Errorf(codes.InvalidArgument, "label name \"%s\" is reserved", name)
+		}
+	}
+
+	certs, err := parseIngestedCertificates(request.GetCertificates())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	exporter := server.exporter
+	exporter.ingestedMutex.Lock()
+	defer exporter.ingestedMutex.Unlock()
+
+	if exporter.ingested == nil {
+		exporter.ingested = map[string]*ingestedRecord{}
+	}
+
+	key := fmt.Sprintf("ingest/%s/%s", agent, request.GetSource())
+	if len(certs) == 0 {
+		delete(exporter.ingested, key)
+		return &ingestpb.ReportResponse{}, nil
+	}
+
+	exporter.ingested[key] = &ingestedRecord{
+		agent:        agent,
+		source:       request.GetSource(),
+		labels:       request.GetLabels(),
+		certificates: certs,
+		lastSeen:     exporter.now(),
+	}
+
+	return &ingestpb.ReportResponse{Accepted: uint32(len(certs))}, nil
+}
+
+func getIngestAgent(ctx context.Context) (string, error) {
+	client, found := peer.FromContext(ctx)
+	if !found {
+		return "", status.Error(codes.Unauthenticated, "unknown peer")
+	}
+
+	info, ok := client.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", status.Error(codes.Unauthenticated, "no verified client certificate")
+	}
+
+	agent := info.State.VerifiedChains[0][0].Subject.CommonName
+	if len(agent) == 0 {
+		return "", status.Error(codes.Unauthenticated, "client certificate without a common name")
+	}
+
+	return agent, nil
+}
+
+// parseIngestedCertificates : Parse PEM certificates, or concatenated DER ones
+func parseIngestedCertificates(data []byte) ([]*x509.Certificate, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		certs, err := parsePEM(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(certs) == 0 {
+			return nil, errors.New("no certificate found in PEM data")
+		}
+		return certs, nil
+	}
+
+	return x509.ParseCertificates(data)
+}
+
+// collectIngestedCertificates : Build a ref for each source reported within IngestRetention, older ones being forgotten
+func (exporter *Exporter) collectIngestedCertificates() []*certificateRef {
+	exporter.ingestedMutex.Lock()
+	defer exporter.ingestedMutex.Unlock()
+
+	retention := exporter.IngestRetention
+	if retention == 0 {
+		retention = defaultIngestRetention
+	}
+
+	keys := []string{}
+	for key, record := range exporter.ingested {
+		if exporter.now().Sub(record.lastSeen) > retention {
+			delete(exporter.ingested, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	output := []*certificateRef{}
+	for _, key := range keys {
+		output = append(output, &certificateRef{
+			path:     key,
+			format:   certificateFormatIngest,
+			ingested: exporter.ingested[key],
+		})
+	}
+
+	return output
+}
+
+func readIngestedCertificates(record *ingestedRecord) ([]*parsedCertificate, error) {
+	output := []*parsedCertificate{}
+	for _, cert := range record.certificates {
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	return output, nil
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/enix/x509-certificate-exporter/v3/internal/ingestpb"
+	"github.com/prometheus/client_golang/prometheus"
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+func newIngestClient(t *testing.T, address string, ca *testCertificate, client *testCertificate) ingestpb.CertificateIngestionClient {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	config := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	if client != nil {
+		config.Certificates = []tls.Certificate{{Certificate: [][]byte{client.cert.Raw}, PrivateKey: client.key}}
+	}
+
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return ingestpb.NewCertificateIngestionClient(conn)
+}
+
+func TestIngestion(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	ca := generateTestCertificate(caTemplate("ingest-ca", notAfter), nil)
+	serverTemplate := leafTemplate("exporter", notAfter)
+	serverTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	server := generateTestCertificate(serverTemplate, ca)
+	agentTemplate := leafTemplate("agent-1", notAfter)
+	agentTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	agent := generateTestCertificate(agentTemplate, ca)
+
+	writeTestCertificates(path.Join(dir, "ca.pem"), ca)
+	writeTestCertificates(path.Join(dir, "server.pem"), server)
+	writeTestKey(path.Join(dir, "server.key"), server)
+
+	now := time.Now()
+	exporter := &Exporter{
+		TLSCertFile:         path.Join(dir, "server.pem"),
+		TLSKeyFile:          path.Join(dir, "server.key"),
+		IngestListenAddress: "127.0.0.1:0",
+		IngestClientCAFile:  path.Join(dir, "ca.pem"),
+		IngestRetention:     time.Hour,
+		clock:               func() time.Time { return now },
+	}
+	assert.NoError(t, exporter.startIngestServer())
+	defer exporter.Shutdown()
+	address := exporter.ingestListener.Addr().String()
+
+	fixture := generateTestCertificate(leafTemplate("remote.example.com", notAfter), nil)
+	client := newIngestClient(t, address, ca, agent)
+	response, err := client.Report(context.Background(), &ingestpb.ReportRequest{
+		Source:       "/etc/ssl/remote.pem",
+		Certificates: fixture.cert.Raw,
+		Labels:       map[string]string{"datacenter": "eu-west"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), response.GetAccepted())
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{exporter: exporter})
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	notAfterMetrics := []*model.Metric{}
+	for _, family := range families {
+		if family.GetName() == "x509_cert_not_after" {
+			notAfterMetrics = family.GetMetric()
+		}
+	}
+	assert.Len(t, notAfterMetrics, 1)
+	metric := notAfterMetrics[0]
+	assert.Equal(t, float64(notAfter.Unix()), metric.GetGauge().GetValue())
+	assert.Equal(t, "agent-1", getLabelValue(metric, "ingest_agent"))
+	assert.Equal(t, "/etc/ssl/remote.pem", getLabelValue(metric, "ingest_source"))
+	assert.Equal(t, "eu-west", getLabelValue(metric, "datacenter"))
+	assert.Equal(t, "remote.example.com", getLabelValue(metric, "subject_CN"))
+
+	_, err = client.Report(context.Background(), &ingestpb.ReportRequest{Source: "bad", Certificates: []byte("garbage")})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	// labels which can't be given to metrics, or which certificates or some of their metrics already have
+	for _, name := range []string{"not-a-label", "__name__", "subject_CN", "ingest_agent", "wildcard_domain", "san", "reason"} {
+		_, err = client.Report(context.Background(), &ingestpb.ReportRequest{Source: "bad", Certificates: fixture.cert.Raw, Labels: map[string]string{name: "x"}})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), name)
+	}
+
+	// agents without a client certificate signed by the CA are rejected
+	_, err = newIngestClient(t, address, ca, nil).Report(context.Background(), &ingestpb.ReportRequest{Source: "anonymous", Certificates: fixture.cert.Raw})
+	assert.Error(t, err)
+	rogue := generateTestCertificate(agentTemplate, nil)
+	_, err = newIngestClient(t, address, ca, rogue).Report(context.Background(), &ingestpb.ReportRequest{Source: "rogue", Certificates: fixture.cert.Raw})
+	assert.Error(t, err)
+	assert.Len(t, exporter.collectIngestedCertificates(), 1)
+
+	// sources not reported within the retention are forgotten
+	now = now.Add(2 * time.Hour)
+	assert.Empty(t, exporter.collectIngestedCertificates())
+}
@@ -0,0 +1,4 @@
+// Package ingestpb : gRPC service through which remote agents report certificates to the exporter
+package ingestpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative ingest.proto
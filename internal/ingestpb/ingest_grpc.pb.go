@@ -0,0 +1,116 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v5.27.1
+// source: ingest.proto
+
+package ingestpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	CertificateIngestion_Report_FullMethodName = "/x509_certificate_exporter.ingest.v1.CertificateIngestion/Report"
+)
+
+// CertificateIngestionClient is the client API for CertificateIngestion service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// CertificateIngestion : Accept certificates read by remote agents, so that their metrics are exposed centrally
+type CertificateIngestionClient interface {
+	// Report : Replace the certificates previously reported by the calling agent for a source
+	Report(ctx context.Context, in *ReportRequest, opts ...grpc.CallOption) (*ReportResponse, error)
+}
+
+type certificateIngestionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCertificateIngestionClient(cc grpc.ClientConnInterface) CertificateIngestionClient {
+	return &certificateIngestionClient{cc}
+}
+
+func (c *certificateIngestionClient) Report(ctx context.Context, in *ReportRequest, opts ...grpc.CallOption) (*ReportResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportResponse)
+	err := c.cc.Invoke(ctx, CertificateIngestion_Report_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CertificateIngestionServer is the server API for CertificateIngestion service.
+// All implementations must embed UnimplementedCertificateIngestionServer
+// for forward compatibility
+//
+// CertificateIngestion : Accept certificates read by remote agents, so that their metrics are exposed centrally
+type CertificateIngestionServer interface {
+	// Report : Replace the certificates previously reported by the calling agent for a source
+	Report(context.Context, *ReportRequest) (*ReportResponse, error)
+	mustEmbedUnimplementedCertificateIngestionServer()
+}
+
+// UnimplementedCertificateIngestionServer must be embedded to have forward compatible implementations.
+type UnimplementedCertificateIngestionServer struct {
+}
+
+func (UnimplementedCertificateIngestionServer) Report(context.Context, *ReportRequest) (*ReportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Report not implemented")
+}
+func (UnimplementedCertificateIngestionServer) mustEmbedUnimplementedCertificateIngestionServer() {}
+
+// UnsafeCertificateIngestionServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CertificateIngestionServer will
+// result in compilation errors.
+type UnsafeCertificateIngestionServer interface {
+	mustEmbedUnimplementedCertificateIngestionServer()
+}
+
+func RegisterCertificateIngestionServer(s grpc.ServiceRegistrar, srv CertificateIngestionServer) {
+	s.RegisterService(&CertificateIngestion_ServiceDesc, srv)
+}
+
+func _CertificateIngestion_Report_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CertificateIngestionServer).Report(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CertificateIngestion_Report_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CertificateIngestionServer).Report(ctx, req.(*ReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CertificateIngestion_ServiceDesc is the grpc.ServiceDesc for CertificateIngestion service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CertificateIngestion_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "x509_certificate_exporter.ingest.v1.CertificateIngestion",
+	HandlerType: (*CertificateIngestionServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Report",
+			Handler:    _CertificateIngestion_Report_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "ingest.proto",
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// kubeIngressTLS : A TLS entry of an Ingress, along with the secret it references (or the error fetching it)
+type kubeIngressTLS struct {
+	namespace  string
+	name       string
+	hosts      []string
+	secretName string
+	secret     *v1.Secret
+	err        error
+}
+
+// kubeIngressesResource : The Ingress API, watched through the shared informer factory
+var kubeIngressesResource = schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}
+
+// ConnectToKubeIngresses : Connect to a cluster like ConnectToKubernetesCluster, to watch the secrets referenced
+// by the TLS entries of Ingresses
+func (exporter *Exporter) ConnectToKubeIngresses(path string, rateLimiter flowcontrol.RateLimiter) error {
+	config, err := parseKubeConfig(path)
+	if err != nil {
+		return err
+	}
+
+	if rateLimiter != nil {
+		config.RateLimiter = rateLimiter
+	}
+
+	kubeClient, err := getKubeClient(config)
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	return exporter.startKubeIngressLister(dynamicClient, kubeClient)
+}
+
+// startKubeIngressLister : Watch Ingresses through the shared informer factory, their secrets being fetched with kubeClient
+func (exporter *Exporter) startKubeIngressLister(dynamicClient dynamic.Interface, kubeClient kubernetes.Interface) error {
+	lister, err := exporter.startKubeLister(dynamicClient, kubeIngressesResource)
+	if err != nil {
+		return err
+	}
+
+	exporter.ingressesClient = kubeClient
+	exporter.ingressesLister = lister
+	return nil
+}
+
+// collectKubeIngresses : List the watched Ingresses of the watched namespaces, a ref being created for each TLS entry
+// naming a secret; secrets which can't be fetched (e.g. not created yet) are read errors of their ref
+func (exporter *Exporter) collectKubeIngresses(ctx context.Context) ([]*certificateRef, []error) {
+	output := []*certificateRef{}
+	outputErrors := []error{}
+
+	namespaces := exporter.KubeIncludeNamespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	for _, namespace := range namespaces {
+		if slices.Contains(exporter.KubeExcludeNamespaces, namespace) {
+			continue
+		}
+
+		objects, err := exporter.ingressesLister.ByNamespace(namespace).List(labels.Everything())
+		if err != nil {
+			outputErrors = append(outputErrors, fmt.Errorf("failed to list ingresses of namespace \"%s\": %s", namespace, err.Error()))
+			continue
+		}
+
+		for _, object := range objects {
+			unstructuredIngress, ok := object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+
+			ingress := networkingv1.Ingress{}
+			err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredIngress.Object, &ingress)
+			if err != nil {
+				outputErrors = append(outputErrors, fmt.Errorf("failed to read ingress \"%s/%s\": %s", unstructuredIngress.GetNamespace(), unstructuredIngress.GetName(), err.Error()))
+				continue
+			}
+
+			if slices.Contains(exporter.KubeExcludeNamespaces, ingress.Namespace) {
+				continue
+			}
+
+			for _, tls := range ingress.Spec.TLS {
+				// served with the default certificate of the ingress controller
+				if len(tls.SecretName) == 0 {
+					continue
+				}
+
+				entry := &kubeIngressTLS{
+					namespace:  ingress.Namespace,
+					name:       ingress.Name,
+					hosts:      tls.Hosts,
+					secretName: tls.SecretName,
+				}
+
+				secret, err := exporter.getIngressSecret(ctx, ingress.Namespace, tls.SecretName)
+				if apierrors.IsNotFound(err) {
+					entry.err = fmt.Errorf("secret \"%s\" referenced by ingress \"%s/%s\" not found", tls.SecretName, ingress.Namespace, ingress.Name)
+				} else if err != nil {
+					entry.err = fmt.Errorf("failed to fetch secret \"%s\" referenced by ingress \"%s/%s\": %s", tls.SecretName, ingress.Namespace, ingress.Name, err.Error())
+				} else {
+					entry.secret = secret
+				}
+
+				output = append(output, &certificateRef{
+					path:        fmt.Sprintf("ingress/%s/%s/%s", ingress.Namespace, ingress.Name, tls.SecretName),
+					format:      certificateFormatKubeIngress,
+					kubeIngress: entry,
+				})
+			}
+		}
+	}
+
+	return output, outputErrors
+}
+
+// getIngressSecret : Fetch a secret referenced by an Ingress, kept in the secrets cache like the watched secrets
+// when MaxCacheDuration is set, so that the API server isn't asked for every TLS entry on each scrape
+func (exporter *Exporter) getIngressSecret(ctx context.Context, namespace string, name string) (*v1.Secret, error) {
+	key := fmt.Sprintf("ingress/%s/%s", namespace, name)
+	if exporter.MaxCacheDuration > 0 {
+		if cachedSecret, cached := exporter.secretsCache.Get(key); cached {
+			return cachedSecret.(*v1.Secret), nil
+		}
+	}
+
+	secret, err := exporter.ingressesClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// only the certificate is read
+	shrinkedSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secret.Name, Namespace: secret.Namespace},
+		Type:       secret.Type,
+		Data:       map[string][]byte{v1.TLSCertKey: secret.Data[v1.TLSCertKey]},
+	}
+	if exporter.MaxCacheDuration > 0 {
+		exporter.secretsCache.Set(key, shrinkedSecret, exporter.getSecretsCacheDuration())
+	}
+	return shrinkedSecret, nil
+}
+
+func readAndParseKubeIngressTLS(entry *kubeIngressTLS) ([]*parsedCertificate, error) {
+	if entry.err != nil {
+		return nil, entry.err
+	}
+
+	return readAndParseKubeSecret(entry.secret, v1.TLSCertKey)
+}
+
+func getKubeIngressLabels(entry *kubeIngressTLS, labels map[string]string) {
+	labels[ingressNamespaceLabel.name] = entry.namespace
+	labels[ingressNameLabel.name] = entry.name
+	labels[ingressHostsLabel.name] = strings.Join(entry.hosts, ",")
+	labels[secretNameLabel.name] = entry.secretName
+}
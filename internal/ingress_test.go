@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newFakeIngressesClient : Dynamic client serving the given Ingresses
+func newFakeIngressesClient(ingresses ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme,
+		map[schema.GroupVersionResource]string{kubeIngressesResource: "IngressList"}, ingresses...)
+}
+
+func TestKubeIngresses(t *testing.T) {
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+
+	dynamicClient := newFakeIngressesClient(
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "default"},
+			Spec: networkingv1.IngressSpec{TLS: []networkingv1.IngressTLS{
+				{Hosts: []string{"shop.example.com", "www.example.com"}, SecretName: "shop-tls"},
+				{Hosts: []string{"api.example.com"}, SecretName: "missing-tls"},
+				// served with the controller's default certificate
+				{Hosts: []string{"default.example.com"}},
+			}},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "internal", Namespace: "kube-system"},
+			Spec: networkingv1.IngressSpec{TLS: []networkingv1.IngressTLS{
+				{Hosts: []string{"internal.example.com"}, SecretName: "internal-tls"},
+			}},
+		},
+	)
+	client := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop-tls", Namespace: "default"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": basic},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "internal-tls", Namespace: "kube-system"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": basic},
+		},
+	)
+
+	exporter := &Exporter{
+		KubeExcludeNamespaces: []string{"kube-system"},
+		ExposeErrorMetrics:    true,
+	}
+	assert.NoError(t, exporter.startKubeIngressLister(dynamicClient, client))
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		notAfterMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		assert.Len(t, notAfterMetrics, 1)
+		metric := notAfterMetrics[0]
+		assert.Equal(t, "default", getLabelValue(metric, "ingress_namespace"))
+		assert.Equal(t, "shop", getLabelValue(metric, "ingress_name"))
+		assert.Equal(t, "shop.example.com,www.example.com", getLabelValue(metric, "ingress_hosts"))
+		assert.Equal(t, "shop-tls", getLabelValue(metric, "secret_name"))
+
+		// missing secrets are read errors, the other entries still being exported
+		failing := map[string]float64{}
+		for _, errorMetric := range getMetricsForName(metrics, "x509_cert_error") {
+			failing[getLabelValue(errorMetric, "secret_name")] = errorMetric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"shop-tls": 0, "missing-tls": 1}, failing)
+
+		readErrors := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., readErrors[0].GetGauge().GetValue())
+	})
+}
+
+func TestKubeIngressSecretsCache(t *testing.T) {
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+
+	dynamicClient := newFakeIngressesClient(
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "default"},
+			Spec: networkingv1.IngressSpec{TLS: []networkingv1.IngressTLS{
+				{Hosts: []string{"shop.example.com"}, SecretName: "shop-tls"},
+			}},
+		},
+	)
+	client := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop-tls", Namespace: "default"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": basic, "tls.key": []byte("secret")},
+		},
+	)
+
+	countSecretGets := func() int {
+		count := 0
+		for _, action := range client.Actions() {
+			if action.GetVerb() == "get" && action.GetResource().Resource == "secrets" {
+				count++
+			}
+		}
+		return count
+	}
+
+	exporter := &Exporter{
+		MaxCacheDuration: time.Hour,
+	}
+	assert.NoError(t, exporter.startKubeIngressLister(dynamicClient, client))
+
+	// discovery, then two scrapes
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_not_after"), 1)
+	})
+	exporter.parseAllCertificates(context.Background())
+	assert.Equal(t, 1, countSecretGets())
+
+	// without a cache duration, secrets are fetched on each scrape
+	exporter.MaxCacheDuration = 0
+	exporter.parseAllCertificates(context.Background())
+	exporter.parseAllCertificates(context.Background())
+	assert.Equal(t, 3, countSecretGets())
+}
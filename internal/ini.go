@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// INICertRef : Key of an INI file holding certificates, encoded the same way as in YAML files
+type INICertRef struct {
+	Section string
+	Key     string
+	Format  YAMLCertFormat
+}
+
+// ParseINICertRef : Parse a section.key[:base64|base64-der|hex|file] reference, keys outside
+// of any section are given without section; base64 is the default format
+func ParseINICertRef(spec string) (INICertRef, error) {
+	ref := INICertRef{Format: YAMLCertFormatBase64}
+
+	keyAndFormat := strings.SplitN(spec, ":", 2)
+	if len(keyAndFormat) == 2 {
+		switch keyAndFormat[1] {
+		case "base64":
+			ref.Format = YAMLCertFormatBase64
+		case "file":
+			ref.Format = YAMLCertFormatFile
+		case "hex":
+			ref.Format = YAMLCertFormatHex
+		case "base64-der":
+			ref.Format = YAMLCertFormatBase64DER
+		default:
+			return INICertRef{}, fmt.Errorf("unknown format \"%s\" in \"%s\"", keyAndFormat[1], spec)
+		}
+	}
+
+	// section names may contain dots, keys usually don't
+	separator := strings.LastIndex(keyAndFormat[0], ".")
+	ref.Section = ini.DefaultSection
+	ref.Key = keyAndFormat[0]
+	if separator >= 0 {
+		ref.Section = keyAndFormat[0][:separator]
+		ref.Key = keyAndFormat[0][separator+1:]
+	}
+
+	if len(ref.Section) == 0 || len(ref.Key) == 0 {
+		return INICertRef{}, fmt.Errorf("expected <section>.<key>[:base64|base64-der|hex|file], got \"%s\"", spec)
+	}
+
+	return ref, nil
+}
+
+func (ref *INICertRef) String() string {
+	if ref.Section == ini.DefaultSection {
+		return ref.Key
+	}
+
+	return fmt.Sprintf("%s.%s", ref.Section, ref.Key)
+}
+
+func readAndParseINIFile(filePath string, iniKeys []INICertRef) ([]*parsedCertificate, error) {
+	contents, err := readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ini.Load(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*parsedCertificate{}
+	for index := range iniKeys {
+		ref := &iniKeys[index]
+
+		section, err := file.GetSection(ref.Section)
+		if err != nil {
+			continue
+		}
+		key, err := section.GetKey(ref.Key)
+		if err != nil {
+			continue
+		}
+
+		decodedCerts, err := decodeEmbeddedCertificates(key.String(), ref.Format, filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		certs, err := parsePEM(decodedCerts)
+		if err != nil {
+			return nil, err
+		}
+
+		for certIndex, cert := range certs {
+			displayName := ref.String()
+			if len(certs) > 1 {
+				displayName = fmt.Sprintf("%s(%d)", displayName, certIndex)
+			}
+			output = append(output, &parsedCertificate{
+				cert:   cert,
+				userID: displayName,
+			})
+		}
+	}
+
+	return output, nil
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"testing"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestINIFile(t *testing.T) {
+	testRequest(t, &Exporter{
+		INIs: []string{"../test/ini/legacy.ini"},
+		INIKeys: []INICertRef{
+			{Section: "server", Key: "certificate", Format: YAMLCertFormatBase64},
+			{Section: "client", Key: "certificate_file", Format: YAMLCertFormatFile},
+			{Section: "upstream.tls", Key: "ca", Format: YAMLCertFormatBase64},
+			{Section: "missing", Key: "certificate", Format: YAMLCertFormatBase64},
+		},
+	}, func(metrics []model.MetricFamily) {
+		keys := []string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			assert.Equal(t, "legacy.ini", getLabelValue(metric, "filename"))
+			assert.Equal(t, "kubernetes", getLabelValue(metric, "subject_CN"))
+			keys = append(keys, getLabelValue(metric, "embedded_key"))
+		}
+		assert.ElementsMatch(t, []string{"server.certificate", "client.certificate_file", "upstream.tls.ca"}, keys)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+func TestINIBadBase64(t *testing.T) {
+	testRequest(t, &Exporter{
+		INIs:    []string{"../test/ini/legacy.ini"},
+		INIKeys: []INICertRef{{Section: "server", Key: "listen", Format: YAMLCertFormatBase64}},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_cert_not_after"), 0)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+func TestParseINICertRef(t *testing.T) {
+	ref, err := ParseINICertRef("server.certificate")
+	assert.NoError(t, err)
+	assert.Equal(t, INICertRef{Section: "server", Key: "certificate", Format: YAMLCertFormatBase64}, ref)
+
+	ref, err = ParseINICertRef("upstream.tls.ca:file")
+	assert.NoError(t, err)
+	assert.Equal(t, INICertRef{Section: "upstream.tls", Key: "ca", Format: YAMLCertFormatFile}, ref)
+
+	ref, err = ParseINICertRef("server.der:hex")
+	assert.NoError(t, err)
+	assert.Equal(t, INICertRef{Section: "server", Key: "der", Format: YAMLCertFormatHex}, ref)
+
+	ref, err = ParseINICertRef("server.der:base64-der")
+	assert.NoError(t, err)
+	assert.Equal(t, INICertRef{Section: "server", Key: "der", Format: YAMLCertFormatBase64DER}, ref)
+
+	ref, err = ParseINICertRef("certificate")
+	assert.NoError(t, err)
+	assert.Equal(t, "DEFAULT", ref.Section)
+	assert.Equal(t, "certificate", ref.String())
+
+	for _, invalid := range []string{"", "server.", ".certificate", "server.certificate:pem"} {
+		_, err := ParseINICertRef(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
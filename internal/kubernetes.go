@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"slices"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -21,23 +25,28 @@ import (
 // ConnectToKubernetesCluster : Try connect to a cluster from inside if path is empty,
 // otherwise try loading the kubeconfig at path "path"
 func (exporter *Exporter) ConnectToKubernetesCluster(path string, rateLimiter flowcontrol.RateLimiter) error {
-	var err error
-	exporter.kubeClient, err = connectToKubernetesCluster(path, false, rateLimiter)
-	return err
+	kubeClient, err := connectToKubernetesCluster(path, false, rateLimiter)
+	if err != nil {
+		return err
+	}
+
+	// not assigned on failure, a nil *Clientset would make a non-nil interface
+	exporter.kubeClient = kubeClient
+	return nil
 }
 
-func (exporter *Exporter) parseAllKubeSecrets() ([]*certificateRef, []error) {
+func (exporter *Exporter) parseAllKubeSecrets(ctx context.Context) ([]*certificateRef, []error) {
 	output := []*certificateRef{}
 	outputErrors := []error{}
 
-	namespaces, err := exporter.listNamespacesToWatch()
+	namespaces, err := exporter.listNamespacesToWatch(ctx)
 	if err != nil {
 		outputErrors = append(outputErrors, fmt.Errorf("failed to list namespaces: %s", err.Error()))
 		return output, outputErrors
 	}
 
 	for _, namespace := range namespaces {
-		secrets, err := exporter.getWatchedSecrets(namespace)
+		secrets, err := exporter.getWatchedSecrets(ctx, namespace)
 		if err != nil {
 			outputErrors = append(outputErrors, fmt.Errorf("failed to fetch secrets from namespace \"%s\": %s", namespace, err.Error()))
 			continue
@@ -46,14 +55,32 @@ func (exporter *Exporter) parseAllKubeSecrets() ([]*certificateRef, []error) {
 		for _, secret := range secrets {
 			for _, secretType := range exporter.KubeSecretTypes {
 				typeAndKey := strings.Split(secretType, ":")
+				key, keyErr := resolveSecretKey(typeAndKey[1], &secret)
 
-				if secret.Type == v1.SecretType(typeAndKey[0]) && len(secret.Data[typeAndKey[1]]) > 0 {
-					output = append(output, &certificateRef{
+				// failing templates are read errors of the secret, reported by the error metric
+				if secret.Type == v1.SecretType(typeAndKey[0]) && (keyErr != nil || len(secret.Data[getLeafSecretKey(key)]) > 0) {
+					ref := &certificateRef{
 						path:          fmt.Sprintf("k8s/%s/%s", namespace, secret.GetName()),
 						format:        certificateFormatKubeSecret,
 						kubeSecret:    secret,
-						kubeSecretKey: typeAndKey[1],
-					})
+						kubeSecretKey: key,
+					}
+
+					if keyErr != nil {
+						ref.kubeSecretKey = typeAndKey[1]
+						ref.kubeSecretKeyErr = keyErr
+					}
+
+					if isSecretPathType(typeAndKey) {
+						ref.kubeSecretIsPath = true
+						ref.kubeSecretPathRoot = exporter.KubeSecretPathRoot
+					}
+
+					if isSecretKubeconfigType(typeAndKey) {
+						ref.kubeSecretIsConfig = true
+					}
+
+					output = append(output, ref)
 				}
 			}
 		}
@@ -62,11 +89,11 @@ func (exporter *Exporter) parseAllKubeSecrets() ([]*certificateRef, []error) {
 	return output, outputErrors
 }
 
-func (exporter *Exporter) listNamespacesToWatch() ([]string, error) {
+func (exporter *Exporter) listNamespacesToWatch(ctx context.Context) ([]string, error) {
 	includedNamespaces := exporter.KubeIncludeNamespaces
 
 	if len(includedNamespaces) < 1 {
-		allNamespaces, err := exporter.kubeClient.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+		allNamespaces, err := exporter.kubeClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 		if err != nil {
 			return nil, err
 		}
@@ -95,7 +122,7 @@ func (exporter *Exporter) listNamespacesToWatch() ([]string, error) {
 	return namespaces, nil
 }
 
-func (exporter *Exporter) getWatchedSecrets(namespace string) ([]v1.Secret, error) {
+func (exporter *Exporter) getWatchedSecrets(ctx context.Context, namespace string) ([]v1.Secret, error) {
 	cachedSecrets, cached := exporter.secretsCache.Get(namespace)
 	if cached {
 		return cachedSecrets.([]v1.Secret), nil
@@ -124,14 +151,12 @@ func (exporter *Exporter) getWatchedSecrets(namespace string) ([]v1.Secret, erro
 	}
 
 	labelSelector := metav1.LabelSelector{MatchLabels: includedLabelsWithValue}
-	secrets, err := exporter.kubeClient.CoreV1().Secrets(namespace).List(context.Background(), metav1.ListOptions{
-		LabelSelector: labels.Set(labelSelector.MatchLabels).String(),
-	})
+	secrets, err := exporter.listSecrets(ctx, namespace, labels.Set(labelSelector.MatchLabels).String())
 	if err != nil {
 		return nil, err
 	}
 
-	filteredSecrets, err := exporter.filterSecrets(secrets.Items, includedLabelsWithoutValue, excludedLabelsWithoutValue, excludedLabelsWithValue)
+	filteredSecrets, err := exporter.filterSecrets(secrets, includedLabelsWithoutValue, excludedLabelsWithoutValue, excludedLabelsWithValue)
 	if err != nil {
 		return nil, err
 	}
@@ -141,10 +166,54 @@ func (exporter *Exporter) getWatchedSecrets(namespace string) ([]v1.Secret, erro
 		shrinkedSecrets = append(shrinkedSecrets, exporter.shrinkSecret(secret))
 	}
 
+	exporter.secretsCache.Set(namespace, shrinkedSecrets, exporter.getSecretsCacheDuration())
+	return shrinkedSecrets, nil
+}
+
+// getSecretsCacheDuration : Random duration between half and all of MaxCacheDuration,
+// so that cached entries don't all expire at once
+func (exporter *Exporter) getSecretsCacheDuration() time.Duration {
 	halfDuration := float64(exporter.MaxCacheDuration.Nanoseconds()) / 2
 	cacheDuration := halfDuration*float64(rand.Float64()) + halfDuration
-	exporter.secretsCache.Set(namespace, shrinkedSecrets, time.Duration(cacheDuration))
-	return shrinkedSecrets, nil
+	return time.Duration(cacheDuration)
+}
+
+// listSecrets : List the secrets of the watched types, letting the API server filter them by type
+// with one request per type; if field selectors are rejected, all secrets are listed and filtered afterwards
+func (exporter *Exporter) listSecrets(ctx context.Context, namespace string, labelSelector string) ([]v1.Secret, error) {
+	secretTypes := []string{}
+	for _, secretType := range exporter.KubeSecretTypes {
+		typeAndKey := strings.Split(secretType, ":")
+		if !slices.Contains(secretTypes, typeAndKey[0]) {
+			secretTypes = append(secretTypes, typeAndKey[0])
+		}
+	}
+
+	output := []v1.Secret{}
+	for _, secretType := range secretTypes {
+		secrets, err := exporter.kubeClient.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labelSelector,
+			FieldSelector: fields.OneTermEqualSelector("type", secretType).String(),
+		})
+		if apierrors.IsBadRequest(err) {
+			log.Debugf("field selectors not supported when listing secrets of namespace \"%s\", filtering them locally: %s", namespace, err.Error())
+			secrets, err = exporter.kubeClient.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{
+				LabelSelector: labelSelector,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return secrets.Items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		output = append(output, secrets.Items...)
+	}
+
+	return output, nil
 }
 
 func (exporter *Exporter) filterSecrets(secrets []v1.Secret, includedLabels, excludedLabels []string, excludedLabelsWithValue map[string]string) ([]v1.Secret, error) {
@@ -201,11 +270,24 @@ func (exporter *Exporter) checkHasIncludedType(secret *v1.Secret) (bool, error)
 	for _, secretType := range exporter.KubeSecretTypes {
 		typeAndKey := strings.Split(secretType, ":")
 
-		if len(typeAndKey) != 2 {
+		if len(typeAndKey) != 2 && !isSecretPathType(typeAndKey) && !isSecretKubeconfigType(typeAndKey) {
 			return false, fmt.Errorf("malformed kube secret type: \"%s\"", secretType)
 		}
 
-		if secret.Type == v1.SecretType(typeAndKey[0]) && len(secret.Data[typeAndKey[1]]) > 0 {
+		if isSecretPathType(typeAndKey) && strings.Contains(typeAndKey[1], "+") {
+			return false, fmt.Errorf("malformed kube secret type: \"%s\", chained keys can't hold file paths", secretType)
+		}
+
+		if isSecretKubeconfigType(typeAndKey) && strings.Contains(typeAndKey[1], "+") {
+			return false, fmt.Errorf("malformed kube secret type: \"%s\", chained keys can't hold kubeconfigs", secretType)
+		}
+
+		if secret.Type != v1.SecretType(typeAndKey[0]) {
+			continue
+		}
+
+		key, err := resolveSecretKey(typeAndKey[1], secret)
+		if err != nil || len(secret.Data[getLeafSecretKey(key)]) > 0 {
 			return true, nil
 		}
 	}
@@ -213,6 +295,88 @@ func (exporter *Exporter) checkHasIncludedType(secret *v1.Secret) (bool, error)
 	return false, nil
 }
 
+// secretKeyTemplateData : Metadata of a secret exposed to the templates of secret keys
+type secretKeyTemplateData struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+}
+
+// resolveSecretKey : Evaluate a key given as a Go template (e.g. "{{ .Name }}.crt") against the metadata of a secret,
+// keys without template actions being returned as is; each of the keys of a chain (e.g. "tls.crt+ca.crt") is evaluated
+func resolveSecretKey(key string, secret *v1.Secret) (string, error) {
+	if strings.Contains(key, "+") {
+		keys := []string{}
+		for _, chainKey := range getChainedSecretKeys(key) {
+			resolvedKey, err := resolveSecretKey(chainKey, secret)
+			if err != nil {
+				return "", err
+			}
+			keys = append(keys, resolvedKey)
+		}
+
+		return strings.Join(keys, "+"), nil
+	}
+
+	if !strings.Contains(key, "{{") {
+		return key, nil
+	}
+
+	keyTemplate, err := template.New("secret-key").Option("missingkey=error").Parse(key)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret key template \"%s\": %s", key, err.Error())
+	}
+
+	output := &strings.Builder{}
+	err = keyTemplate.Execute(output, &secretKeyTemplateData{
+		Name:      secret.Name,
+		Namespace: secret.Namespace,
+		Labels:    secret.Labels,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate secret key template \"%s\": %s", key, err.Error())
+	}
+
+	return output.String(), nil
+}
+
+// getChainedSecretKeys : Split a <leaf key>+<chain key>... specification, listing the keys whose certificates
+// are read as a single bundle, e.g. a leaf and the intermediates stored next to it
+func getChainedSecretKeys(key string) []string {
+	return strings.Split(key, "+")
+}
+
+// getLeafSecretKey : The first key of a chain, the secret being watched when it's set, the other keys being optional
+func getLeafSecretKey(key string) string {
+	return getChainedSecretKeys(key)[0]
+}
+
+// isSecretPathType : Tell if a split secret type is given as <type>:<key>:file,
+// where the key holds the path of a PEM file rather than the certificates
+func isSecretPathType(typeAndKey []string) bool {
+	return len(typeAndKey) == 3 && typeAndKey[2] == "file"
+}
+
+// isSecretKubeconfigType : Tell if a split secret type is given as <type>:<key>:kubeconfig,
+// where the key holds a whole kubeconfig embedding certificates
+func isSecretKubeconfigType(typeAndKey []string) bool {
+	return len(typeAndKey) == 3 && typeAndKey[2] == "kubeconfig"
+}
+
+// getSecretOwner : The controller of a secret (e.g. the cert-manager Certificate which issued it),
+// or its first owner when none is a controller
+func getSecretOwner(secret *v1.Secret) *metav1.OwnerReference {
+	if owner := metav1.GetControllerOfNoCopy(secret); owner != nil {
+		return owner
+	}
+
+	if len(secret.OwnerReferences) > 0 {
+		return &secret.OwnerReferences[0]
+	}
+
+	return nil
+}
+
 func (exporter *Exporter) shrinkSecret(secret v1.Secret) v1.Secret {
 	result := v1.Secret{
 		Type: secret.Type,
@@ -220,13 +384,28 @@ func (exporter *Exporter) shrinkSecret(secret v1.Secret) v1.Secret {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      secret.Name,
 			Namespace: secret.Namespace,
+			// evaluated by key templates
+			Labels: secret.Labels,
+			// exposed as labels
+			OwnerReferences: secret.OwnerReferences,
 		},
 	}
 
 	for _, secretType := range exporter.KubeSecretTypes {
 		typeAndKey := strings.Split(secretType, ":")
-		if secret.Type == v1.SecretType(typeAndKey[0]) && len(secret.Data[typeAndKey[1]]) > 0 {
-			result.Data[typeAndKey[1]] = secret.Data[typeAndKey[1]]
+		if secret.Type != v1.SecretType(typeAndKey[0]) {
+			continue
+		}
+
+		key, err := resolveSecretKey(typeAndKey[1], &secret)
+		if err != nil || len(secret.Data[getLeafSecretKey(key)]) == 0 {
+			continue
+		}
+
+		for _, chainKey := range getChainedSecretKeys(key) {
+			if len(secret.Data[chainKey]) > 0 {
+				result.Data[chainKey] = secret.Data[chainKey]
+			}
 		}
 	}
 
@@ -0,0 +1,344 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newFakeSecretsClient(t *testing.T) *fake.Clientset {
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+
+	return fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "default"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": basic},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "opaque", Namespace: "default"},
+			Type:       v1.SecretTypeOpaque,
+			Data:       map[string][]byte{"ca.crt": basic},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "default"},
+			Type:       v1.SecretTypeServiceAccountToken,
+			Data:       map[string][]byte{"token": []byte("secret")},
+		},
+	)
+}
+
+// recordSecretLists : Record the field selector of each secrets list request,
+// the fake clientset doesn't apply them itself
+func recordSecretLists(client *fake.Clientset, reactor func(fieldSelector string) (bool, runtime.Object, error)) *[]string {
+	mutex := sync.Mutex{}
+	selectors := []string{}
+
+	client.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		fieldSelector := action.(k8stesting.ListAction).GetListRestrictions().Fields.String()
+
+		mutex.Lock()
+		selectors = append(selectors, fieldSelector)
+		mutex.Unlock()
+
+		return reactor(fieldSelector)
+	})
+
+	return &selectors
+}
+
+func newFakeKubeExporter(client *fake.Clientset) *Exporter {
+	return &Exporter{
+		KubeSecretTypes:       []string{"kubernetes.io/tls:tls.crt", "Opaque:ca.crt"},
+		KubeIncludeNamespaces: []string{"default"},
+		kubeClient:            client,
+		secretsCache:          cache.New(0, time.Minute),
+	}
+}
+
+func getKubeSecretNames(refs []*certificateRef) []string {
+	names := []string{}
+	for _, ref := range refs {
+		names = append(names, ref.kubeSecret.Name)
+	}
+	return names
+}
+
+func TestKubeSecretsFieldSelector(t *testing.T) {
+	client := newFakeSecretsClient(t)
+	selectors := recordSecretLists(client, func(fieldSelector string) (bool, runtime.Object, error) {
+		secretType := v1.SecretType(fieldSelector[len("type="):])
+		list := &v1.SecretList{}
+		for _, name := range []string{"tls", "opaque", "token"} {
+			secret, err := client.Tracker().Get(v1.SchemeGroupVersion.WithResource("secrets"), "default", name)
+			if err == nil && secret.(*v1.Secret).Type == secretType {
+				list.Items = append(list.Items, *secret.(*v1.Secret))
+			}
+		}
+		return true, list, nil
+	})
+
+	refs, errs := newFakeKubeExporter(client).parseAllKubeSecrets(context.Background())
+	assert.Len(t, errs, 0)
+	assert.ElementsMatch(t, []string{"tls", "opaque"}, getKubeSecretNames(refs))
+	assert.Equal(t, []string{"type=kubernetes.io/tls", "type=Opaque"}, *selectors)
+}
+
+func TestKubeSecretsFieldSelectorFallback(t *testing.T) {
+	client := newFakeSecretsClient(t)
+	selectors := recordSecretLists(client, func(fieldSelector string) (bool, runtime.Object, error) {
+		if len(fieldSelector) > 0 {
+			return true, nil, apierrors.NewBadRequest("field label not supported: type")
+		}
+
+		// let the default reactor list every secret
+		return false, nil, nil
+	})
+
+	refs, errs := newFakeKubeExporter(client).parseAllKubeSecrets(context.Background())
+	assert.Len(t, errs, 0)
+	assert.ElementsMatch(t, []string{"tls", "opaque"}, getKubeSecretNames(refs))
+	assert.Equal(t, []string{"type=kubernetes.io/tls", ""}, *selectors)
+}
+
+func TestKubeSecretKeyTemplate(t *testing.T) {
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+
+	client := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Type:       v1.SecretTypeOpaque,
+			Data:       map[string][]byte{"web.crt": basic, "api.crt": []byte("not a certificate")},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"},
+			Type:       v1.SecretTypeOpaque,
+			Data:       map[string][]byte{"web.crt": basic},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "labeled", Namespace: "default", Labels: map[string]string{"cert": "server"}},
+			Type:       "example.com/cert",
+			Data:       map[string][]byte{"server.pem": basic},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "unlabeled", Namespace: "default"},
+			Type:       "example.com/cert",
+			Data:       map[string][]byte{"server.pem": basic},
+		},
+	)
+
+	exporter := newFakeKubeExporter(client)
+	exporter.KubeSecretTypes = []string{"Opaque:{{ .Name }}.crt", "example.com/cert:{{ .Labels.cert }}.pem"}
+	exporter.ExposeErrorMetrics = true
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		keys := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			keys[getLabelValue(metric, "secret_name")] = getLabelValue(metric, "secret_key")
+		}
+		assert.Equal(t, map[string]string{"web": "web.crt", "labeled": "server.pem"}, keys)
+
+		failing := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_error") {
+			if metric.GetGauge().GetValue() == 1 {
+				failing[getLabelValue(metric, "secret_name")] = getLabelValue(metric, "secret_key")
+			}
+		}
+		assert.Equal(t, map[string]string{"unlabeled": "{{ .Labels.cert }}.pem"}, failing)
+	})
+
+	_, err = resolveSecretKey("{{ .Name", &v1.Secret{})
+	assert.ErrorContains(t, err, "invalid secret key template")
+}
+
+func TestKubeSecretChainedKeys(t *testing.T) {
+	notAfter := time.Now().Add(time.Hour)
+	root := generateTestCertificate(caTemplate("root", notAfter), nil)
+	intermediate := generateTestCertificate(caTemplate("intermediate", notAfter), root)
+
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "root.pem"), root)
+	writeTestCertificates(path.Join(dir, "split.pem"), generateTestCertificate(leafTemplate("split", notAfter), intermediate))
+	writeTestCertificates(path.Join(dir, "unchained.pem"), generateTestCertificate(leafTemplate("unchained", notAfter), intermediate))
+	writeTestCertificates(path.Join(dir, "intermediate.pem"), intermediate)
+	readPEM := func(name string) []byte {
+		contents, err := os.ReadFile(path.Join(dir, name))
+		assert.NoError(t, err)
+		return contents
+	}
+
+	client := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "split", Namespace: "default"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": readPEM("split.pem"), "chain.crt": readPEM("intermediate.pem")},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "unchained", Namespace: "default"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": readPEM("unchained.pem")},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "chain-only", Namespace: "default"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{"chain.crt": readPEM("intermediate.pem")},
+		},
+	)
+
+	exporter := newFakeKubeExporter(client)
+	exporter.KubeSecretTypes = []string{"kubernetes.io/tls:tls.crt+chain.crt"}
+	exporter.TrustedRootFiles = []string{path.Join(dir, "root.pem")}
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		// the intermediate of the chain key still has its own metrics
+		certs := []string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			assert.Equal(t, "tls.crt+chain.crt", getLabelValue(metric, "secret_key"))
+			certs = append(certs, getLabelValue(metric, "secret_name")+"/"+getLabelValue(metric, "subject_CN"))
+		}
+		assert.ElementsMatch(t, []string{"split/split", "split/intermediate", "unchained/unchained"}, certs)
+
+		verified := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_verified") {
+			verified[getLabelValue(metric, "secret_name")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"split": 1, "unchained": 0}, verified)
+	})
+
+	exporter = newFakeKubeExporter(client)
+	exporter.KubeSecretTypes = []string{"kubernetes.io/tls:tls.crt+chain.crt:file"}
+	_, errs := exporter.parseAllKubeSecrets(context.Background())
+	assert.Len(t, errs, 1)
+}
+
+func TestKubeSecretOwnerLabels(t *testing.T) {
+	certPath := path.Join(t.TempDir(), "tls.pem")
+	writeTestCertificates(certPath, generateTestCertificate(leafTemplate("leaf", time.Now().Add(time.Hour)), nil))
+	tlsCert, err := os.ReadFile(certPath)
+	assert.NoError(t, err)
+
+	controller := true
+	client := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "issued", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "other-owner"},
+				{APIVersion: "cert-manager.io/v1", Kind: "Certificate", Name: "web", Controller: &controller},
+			}},
+			Type: v1.SecretTypeTLS,
+			Data: map[string][]byte{"tls.crt": tlsCert},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "default", OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Name: "api"},
+			}},
+			Type: v1.SecretTypeTLS,
+			Data: map[string][]byte{"tls.crt": tlsCert},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "orphan", Namespace: "default"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": tlsCert},
+		},
+	)
+
+	getOwners := func(exporter *Exporter) map[string]string {
+		owners := map[string]string{}
+		testRequest(t, exporter, func(metrics []model.MetricFamily) {
+			for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+				owners[getLabelValue(metric, "secret_name")] = getLabelValue(metric, "secret_owner_kind") + "/" + getLabelValue(metric, "secret_owner_name")
+			}
+		})
+		return owners
+	}
+
+	exporter := newFakeKubeExporter(client)
+	exporter.KubeSecretOwnerLabels = true
+	// the controller wins over other owners
+	assert.Equal(t, map[string]string{"issued": "Certificate/web", "shared": "Ingress/api", "orphan": "/"}, getOwners(exporter))
+
+	assert.Equal(t, map[string]string{"issued": "/", "shared": "/", "orphan": "/"}, getOwners(newFakeKubeExporter(client)))
+}
+
+func TestKubeSecretKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(time.Hour)
+	writeTestCertificates(path.Join(dir, "ca.pem"), generateTestCertificate(caTemplate("workload-ca", notAfter), nil))
+	writeTestCertificates(path.Join(dir, "admin.pem"), generateTestCertificate(leafTemplate("workload-admin", notAfter), nil))
+	readBase64 := func(name string) string {
+		contents, err := os.ReadFile(path.Join(dir, name))
+		assert.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(contents)
+	}
+
+	// as written by Cluster API, along with a file reference meaningless to the exporter
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: workload
+  cluster:
+    server: https://workload.example.com:6443
+    certificate-authority-data: %s
+users:
+- name: workload-admin
+  user:
+    client-certificate-data: %s
+- name: other
+  user:
+    client-certificate: /etc/kubernetes/pki/other.crt
+`, readBase64("ca.pem"), readBase64("admin.pem"))
+
+	client := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "workload-kubeconfig", Namespace: "default"},
+			Type:       "cluster.x-k8s.io/secret",
+			Data:       map[string][]byte{"value": []byte(kubeconfig)},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "broken-kubeconfig", Namespace: "default"},
+			Type:       "cluster.x-k8s.io/secret",
+			Data:       map[string][]byte{"value": []byte("clusters: [")},
+		},
+	)
+
+	exporter := newFakeKubeExporter(client)
+	exporter.KubeSecretTypes = []string{"cluster.x-k8s.io/secret:value:kubeconfig"}
+	exporter.ExposeErrorMetrics = true
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		certs := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			assert.Equal(t, "workload-kubeconfig", getLabelValue(metric, "secret_name"))
+			assert.Equal(t, "value", getLabelValue(metric, "secret_key"))
+			certs[getLabelValue(metric, "embedded_key")] = getLabelValue(metric, "subject_CN")
+		}
+		assert.Equal(t, map[string]string{"workload": "workload-ca", "workload-admin": "workload-admin"}, certs)
+
+		errors := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_error") {
+			errors[getLabelValue(metric, "secret_name")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"workload-kubeconfig": 0, "broken-kubeconfig": 1}, errors)
+	})
+
+	exporter = newFakeKubeExporter(client)
+	exporter.KubeSecretTypes = []string{"cluster.x-k8s.io/secret:value+ca.crt:kubeconfig"}
+	_, errs := exporter.parseAllKubeSecrets(context.Background())
+	assert.Len(t, errs, 1)
+}
@@ -0,0 +1,295 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+// LabelMapping : Extra labels added to the metrics of the certificates matching
+// either a SHA-256 fingerprint or a source path pattern
+type LabelMapping struct {
+	Fingerprint string            `yaml:"fingerprint"`
+	Path        string            `yaml:"path"`
+	Labels      map[string]string `yaml:"labels"`
+}
+
+// labelName : Name of a label given by the exporter, declared with reserveLabel so that the labels
+// which can't be mapped or ingested are listed from the declarations themselves
+type labelName struct {
+	name string
+}
+
+// reservedLabelNames : Names of all the labels declared with reserveLabel
+var reservedLabelNames = map[string]bool{}
+
+// reserveLabel : Declare a label given by the exporter, it must only be called to initialize package variables
+func reserveLabel(name string) labelName {
+	reservedLabelNames[name] = true
+	return labelName{name: name}
+}
+
+// nameLabels : Labels of the attributes of an issuer or subject distinguished name
+type nameLabels struct {
+	country            labelName
+	streetAddress      labelName
+	locality           labelName
+	organization       labelName
+	organizationalUnit labelName
+	commonName         labelName
+}
+
+func reserveNameLabels(prefix string) nameLabels {
+	return nameLabels{
+		country:            reserveLabel(prefix + "_C"),
+		streetAddress:      reserveLabel(prefix + "_ST"),
+		locality:           reserveLabel(prefix + "_L"),
+		organization:       reserveLabel(prefix + "_O"),
+		organizationalUnit: reserveLabel(prefix + "_OU"),
+		commonName:         reserveLabel(prefix + "_CN"),
+	}
+}
+
+// labels of certificates, whatever their source
+var (
+	serialNumberLabel = reserveLabel("serial_number")
+	issuerLabels      = reserveNameLabels("issuer")
+	subjectLabels     = reserveNameLabels("subject")
+	embeddedKindLabel = reserveLabel("embedded_kind")
+	embeddedKeyLabel  = reserveLabel("embedded_key")
+	sdsSecretLabel    = reserveLabel("sds_secret")
+	formatLabel       = reserveLabel("format")
+)
+
+// labels identifying the source of certificates
+var (
+	filenameLabel              = reserveLabel("filename")
+	filepathLabel              = reserveLabel("filepath")
+	subjectHashLabel           = reserveLabel("subject_hash")
+	secretNameLabel            = reserveLabel("secret_name")
+	secretNamespaceLabel       = reserveLabel("secret_namespace")
+	secretKeyLabel             = reserveLabel("secret_key")
+	secretOwnerKindLabel       = reserveLabel("secret_owner_kind")
+	secretOwnerNameLabel       = reserveLabel("secret_owner_name")
+	sqlSourceLabel             = reserveLabel("sql_source")
+	endpointLabel              = reserveLabel("endpoint")
+	controlPlaneComponentLabel = reserveLabel("control_plane_component")
+	gcsBucketLabel             = reserveLabel("gcs_bucket")
+	gcsObjectLabel             = reserveLabel("gcs_object")
+	gitRepositoryLabel         = reserveLabel("git_repository")
+	gitRefLabel                = reserveLabel("git_ref")
+	gitPathLabel               = reserveLabel("git_path")
+	azureVaultLabel            = reserveLabel("azure_vault")
+	azureCertificateLabel      = reserveLabel("azure_certificate")
+	consulDatacenterLabel      = reserveLabel("consul_datacenter")
+	consulKeyLabel             = reserveLabel("consul_key")
+	etcdEndpointsLabel         = reserveLabel("etcd_endpoints")
+	etcdKeyLabel               = reserveLabel("etcd_key")
+	ldapServerLabel            = reserveLabel("ldap_server")
+	ldapBaseDNLabel            = reserveLabel("ldap_base_dn")
+	ftpServerLabel             = reserveLabel("ftp_server")
+	ftpPathLabel               = reserveLabel("ftp_path")
+	windowsStoreLocationLabel  = reserveLabel("windows_store_location")
+	windowsStoreNameLabel      = reserveLabel("windows_store_name")
+	namedPipeLabel             = reserveLabel("named_pipe")
+	systemdCredentialLabel     = reserveLabel("systemd_credential")
+	caBundleKindLabel          = reserveLabel("ca_bundle_kind")
+	caBundleObjectLabel        = reserveLabel("ca_bundle_object")
+	caBundleWebhookLabel       = reserveLabel("ca_bundle_webhook")
+	clientAddressLabel         = reserveLabel("client_address")
+	ingressNamespaceLabel      = reserveLabel("ingress_namespace")
+	ingressNameLabel           = reserveLabel("ingress_name")
+	ingressHostsLabel          = reserveLabel("ingress_hosts")
+	ingestAgentLabel           = reserveLabel("ingest_agent")
+	ingestSourceLabel          = reserveLabel("ingest_source")
+	selfLabel                  = reserveLabel("self")
+	certificateMonitorLabel    = reserveLabel("certificate_monitor")
+)
+
+// labels some metrics add to the ones of certificates
+var (
+	tlsVersionLabel            = reserveLabel("tls_version")
+	cipherSuiteLabel           = reserveLabel("cipher_suite")
+	reasonLabel                = reserveLabel("reason")
+	wildcardDomainLabel        = reserveLabel("wildcard_domain")
+	rootFingerprintLabel       = reserveLabel("root_fingerprint")
+	sanLabel                   = reserveLabel("san")
+	emailAddressesLabel        = reserveLabel("email_addresses")
+	ipAddressesLabel           = reserveLabel("ip_addresses")
+	sha1FingerprintLabel       = reserveLabel("sha1_fingerprint")
+	signatureAlgorithmLabel    = reserveLabel("signature_algorithm")
+	keySizeLabel               = reserveLabel("key_size")
+	expectedHostnameLabel      = reserveLabel("expected_hostname")
+	typeLabel                  = reserveLabel("type")
+	sanCountLabel              = reserveLabel("san_count")
+	publicKeyAlgorithmLabel    = reserveLabel("public_key_algorithm")
+	isCALabel                  = reserveLabel("is_ca")
+	crlDistributionPointsLabel = reserveLabel("crl_distribution_points")
+	ocspServersLabel           = reserveLabel("ocsp_servers")
+)
+
+// isReservedLabelName : Tell if a label can't be mapped or ingested, as certificates or some of their metrics
+// may already be labeled with it
+func isReservedLabelName(name string) bool {
+	return reservedLabelNames[name]
+}
+
+// isValidLabelName : Tell if a label name can be given to metrics, names starting with "__" being reserved by Prometheus
+func isValidLabelName(name string) bool {
+	return model.LabelName(name).IsValid() && !strings.HasPrefix(name, "__")
+}
+
+// LoadLabelMappings : Read label mappings from a CSV file (with fingerprint and/or path columns,
+// the other columns being labels) or from a YAML file containing a list of {fingerprint, path, labels} objects
+func LoadLabelMappings(file string) ([]LabelMapping, error) {
+	contents, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := []LabelMapping{}
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		mappings, err = parseCSVLabelMappings(contents)
+	} else {
+		err = yaml.Unmarshal(contents, &mappings)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for index := range mappings {
+		mapping := &mappings[index]
+		if (len(mapping.Fingerprint) == 0) == (len(mapping.Path) == 0) {
+			return nil, fmt.Errorf("label mapping n°%d: exactly one of \"fingerprint\" and \"path\" is required", index+1)
+		}
+
+		if len(mapping.Fingerprint) > 0 {
+			fingerprint, err := hex.DecodeString(strings.ReplaceAll(mapping.Fingerprint, ":", ""))
+			if err != nil || len(fingerprint) != sha256.Size {
+				return nil, fmt.Errorf("label mapping n°%d: \"%s\" is not a SHA-256 fingerprint", index+1, mapping.Fingerprint)
+			}
+			mapping.Fingerprint = hex.EncodeToString(fingerprint)
+		}
+
+		if !doublestar.ValidatePattern(mapping.Path) {
+			return nil, fmt.Errorf("label mapping n°%d: invalid path pattern \"%s\"", index+1, mapping.Path)
+		}
+
+		for key := range mapping.Labels {
+			if !isValidLabelName(key) {
+				return nil, fmt.Errorf("label mapping n°%d: invalid label name \"%s\"", index+1, key)
+			}
+			if isReservedLabelName(key) {
+				return nil, fmt.Errorf("label mapping n°%d: label name \"%s\" is reserved", index+1, key)
+			}
+		}
+	}
+
+	return mappings, nil
+}
+
+func parseCSVLabelMappings(contents []byte) ([]LabelMapping, error) {
+	reader := csv.NewReader(bytes.NewReader(contents))
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	output := []LabelMapping{}
+	for _, record := range records[1:] {
+		mapping := LabelMapping{Labels: map[string]string{}}
+		for index, value := range record {
+			if len(value) == 0 {
+				continue
+			}
+
+			switch header[index] {
+			case "fingerprint":
+				mapping.Fingerprint = value
+			case "path":
+				mapping.Path = value
+			default:
+				mapping.Labels[header[index]] = value
+			}
+		}
+
+		output = append(output, mapping)
+	}
+
+	return output, nil
+}
+
+// reloadLabelMappings : Load the label mappings file again when it was modified since the last load,
+// the previous mappings are kept on failure
+func (exporter *Exporter) reloadLabelMappings() error {
+	if len(exporter.LabelMappingsFile) == 0 {
+		return nil
+	}
+
+	info, err := os.Stat(exporter.LabelMappingsFile)
+	if err != nil {
+		return fmt.Errorf("failed to load label mappings: %s", err.Error())
+	}
+
+	exporter.labelMappingsMutex.Lock()
+	defer exporter.labelMappingsMutex.Unlock()
+
+	if exporter.labelMappingsModTime.Equal(info.ModTime()) {
+		return nil
+	}
+
+	mappings, err := LoadLabelMappings(exporter.LabelMappingsFile)
+	if err != nil {
+		return fmt.Errorf("failed to load label mappings from \"%s\": %s", exporter.LabelMappingsFile, err.Error())
+	}
+
+	exporter.labelMappings = mappings
+	exporter.labelMappingsModTime = info.ModTime()
+	return nil
+}
+
+// getMappedLabels : Merge the labels of every mapping matching a certificate,
+// later mappings taking precedence over earlier ones
+func (exporter *Exporter) getMappedLabels(certData *parsedCertificate, ref *certificateRef) map[string]string {
+	exporter.labelMappingsMutex.Lock()
+	mappings := exporter.labelMappings
+	exporter.labelMappingsMutex.Unlock()
+
+	output := map[string]string{}
+	fingerprint := ""
+	for _, mapping := range mappings {
+		if len(mapping.Fingerprint) > 0 {
+			if len(fingerprint) == 0 {
+				sum := sha256.Sum256(certData.cert.Raw)
+				fingerprint = hex.EncodeToString(sum[:])
+			}
+
+			if mapping.Fingerprint != fingerprint {
+				continue
+			}
+		} else if matched, _ := doublestar.Match(mapping.Path, ref.path); !matched {
+			continue
+		}
+
+		for key, value := range mapping.Labels {
+			output[key] = value
+		}
+	}
+
+	return output
+}
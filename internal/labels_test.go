@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelMappings(t *testing.T) {
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+	certs, err := parsePEM(basic)
+	assert.NoError(t, err)
+	sum := sha256.Sum256(certs[0].Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	mappingsPath := path.Join(t.TempDir(), "labels.yaml")
+	writeMappings := func(contents string, modTime time.Time) {
+		assert.NoError(t, os.WriteFile(mappingsPath, []byte(contents), 0644))
+		assert.NoError(t, os.Chtimes(mappingsPath, modTime, modTime))
+	}
+	writeMappings(fmt.Sprintf(`
+- path: "../test/*.pem"
+  labels:
+    environment: staging
+    owner: nobody
+- fingerprint: "%s"
+  labels:
+    team: platform
+    environment: production
+`, fingerprint), time.Now().Add(-time.Hour))
+
+	exporter := &Exporter{
+		Files:             []string{"../test/basic.pem"},
+		LabelMappingsFile: mappingsPath,
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{exporter: exporter})
+
+	getLabels := func() map[string]string {
+		metrics, err := registry.Gather()
+		assert.NoError(t, err)
+
+		for index := range metrics {
+			if metrics[index].GetName() == "x509_cert_not_after" {
+				assert.Len(t, metrics[index].GetMetric(), 1)
+				labels := map[string]string{}
+				for _, name := range []string{"team", "environment", "owner", "filename"} {
+					labels[name] = getLabelValue(metrics[index].GetMetric()[0], name)
+				}
+				return labels
+			}
+		}
+
+		t.Fatal("x509_cert_not_after not found")
+		return nil
+	}
+
+	assert.Equal(t, map[string]string{
+		"team":        "platform",
+		"environment": "production",
+		"owner":       "nobody",
+		"filename":    "basic.pem",
+	}, getLabels())
+
+	// modified mappings are picked up on the next scrape
+	writeMappings(fmt.Sprintf("- fingerprint: \"%s\"\n  labels:\n    team: security\n", fingerprint), time.Now())
+	assert.Equal(t, map[string]string{
+		"team":        "security",
+		"environment": "",
+		"owner":       "",
+		"filename":    "basic.pem",
+	}, getLabels())
+
+	// broken mappings are reported and the previous ones are kept
+	writeMappings("- labels:\n    team: nobody\n", time.Now().Add(time.Hour))
+	assert.Equal(t, "security", getLabels()["team"])
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+func TestLoadCSVLabelMappings(t *testing.T) {
+	mappingsPath := path.Join(t.TempDir(), "labels.csv")
+	err := os.WriteFile(mappingsPath, []byte(`fingerprint,path,team,owner
+AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89:AB:CD:EF:01:23:45:67:89,,platform,
+,k8s/default/*,apps,alice
+`), 0644)
+	assert.NoError(t, err)
+
+	mappings, err := LoadLabelMappings(mappingsPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []LabelMapping{
+		{Fingerprint: "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789", Labels: map[string]string{"team": "platform"}},
+		{Path: "k8s/default/*", Labels: map[string]string{"team": "apps", "owner": "alice"}},
+	}, mappings)
+
+	for _, invalid := range []string{
+		"fingerprint,team\nabcdef,platform\n",
+		"fingerprint,path,team\n,,platform\n",
+		"path,team-name\n*.pem,platform\n",
+		"path,team\n*.pem\n",
+		"path,wildcard_domain\n*.pem,example.com\n",
+		"path,type\n*.pem,database\n",
+		"path,__team\n*.pem,platform\n",
+		"path,filename\n*.pem,other.pem\n",
+		"path,subject_CN\n*.pem,example.com\n",
+		"path,secret_namespace\n*.pem,default\n",
+		"fingerprint,serial_number\nabcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789,1\n",
+	} {
+		assert.NoError(t, os.WriteFile(mappingsPath, []byte(invalid), 0644))
+		_, err := LoadLabelMappings(mappingsPath)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestReservedLabelNames(t *testing.T) {
+	// labels must be declared with reserveLabel rather than written with literal names
+	sources, err := filepath.Glob("*.go")
+	assert.NoError(t, err)
+	literalLabel := regexp.MustCompile(`(labels|output)\[("|prefix)`)
+	for _, source := range sources {
+		if strings.HasSuffix(source, "_test.go") {
+			continue
+		}
+
+		contents, err := os.ReadFile(source)
+		assert.NoError(t, err)
+		assert.False(t, literalLabel.Match(contents), source)
+	}
+
+	for _, name := range []string{"serial_number", "issuer_O", "subject_CN", "filename", "secret_name", "sql_source", "ingress_hosts", "ingest_agent", "wildcard_domain", "ocsp_servers"} {
+		assert.True(t, isReservedLabelName(name), name)
+	}
+	assert.False(t, isReservedLabelName("team"))
+}
@@ -0,0 +1,254 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPSearch : An LDAP search returning entries with DER certificates in some of their attributes
+type LDAPSearch struct {
+	URL        string
+	BaseDN     string
+	Scope      int
+	Filter     string
+	Attributes []string
+}
+
+// ldapConn : Subset of the LDAP connection used to read certificates
+type ldapConn interface {
+	StartTLS(config *tls.Config) error
+	Bind(username, password string) error
+	SearchWithPaging(request *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error)
+	Close() error
+}
+
+const ldapTimeout = 30 * time.Second
+
+const ldapPagingSize = 500
+
+const defaultLDAPAttribute = "userCertificate;binary"
+
+var ldapScopes = map[string]int{
+	"base": ldap.ScopeBaseObject,
+	"one":  ldap.ScopeSingleLevel,
+	"sub":  ldap.ScopeWholeSubtree,
+}
+
+// ParseLDAPURL : Split a ldap[s]://<host>[:<port>]/<base DN>[?<attributes>[?<scope>[?<filter>]]] URL (RFC 4516),
+// attributes defaulting to userCertificate;binary, the scope to base and the filter to (objectClass=*)
+func ParseLDAPURL(ldapURL string) (LDAPSearch, error) {
+	parsed, err := url.Parse(ldapURL)
+	if err != nil {
+		return LDAPSearch{}, err
+	}
+
+	if (parsed.Scheme != "ldap" && parsed.Scheme != "ldaps") || len(parsed.Host) == 0 || len(strings.TrimPrefix(parsed.Path, "/")) == 0 {
+		return LDAPSearch{}, fmt.Errorf("expected ldap[s]://<host>/<base DN>[?<attributes>[?<scope>[?<filter>]]], got \"%s\"", ldapURL)
+	}
+
+	search := LDAPSearch{
+		URL:        fmt.Sprintf("%s://%s", parsed.Scheme, parsed.Host),
+		BaseDN:     strings.TrimPrefix(parsed.Path, "/"),
+		Scope:      ldap.ScopeBaseObject,
+		Filter:     "(objectClass=*)",
+		Attributes: []string{defaultLDAPAttribute},
+	}
+
+	parts := strings.Split(parsed.RawQuery, "?")
+	if len(parts) > 3 {
+		return LDAPSearch{}, fmt.Errorf("unexpected extensions in \"%s\"", ldapURL)
+	}
+	for index, part := range parts {
+		part, err = url.QueryUnescape(part)
+		if err != nil {
+			return LDAPSearch{}, err
+		}
+		if len(part) == 0 {
+			continue
+		}
+
+		switch index {
+		case 0:
+			search.Attributes = strings.Split(part, ",")
+		case 1:
+			scope, found := ldapScopes[strings.ToLower(part)]
+			if !found {
+				return LDAPSearch{}, fmt.Errorf("unknown LDAP scope \"%s\", expected base, one or sub", part)
+			}
+			search.Scope = scope
+		case 2:
+			if _, err := ldap.CompileFilter(part); err != nil {
+				return LDAPSearch{}, fmt.Errorf("malformed LDAP filter \"%s\": %s", part, err.Error())
+			}
+			search.Filter = part
+		}
+	}
+
+	return search, nil
+}
+
+func (exporter *Exporter) collectLDAPSearches() []*certificateRef {
+	output := []*certificateRef{}
+
+	for index := range exporter.LDAPSearches {
+		search := &exporter.LDAPSearches[index]
+		output = append(output, &certificateRef{
+			path:       fmt.Sprintf("%s/%s", search.URL, search.BaseDN),
+			format:     certificateFormatLDAP,
+			ldapSearch: search,
+			ldapClient: exporter.connectLDAP,
+		})
+	}
+
+	return output
+}
+
+// connectLDAP : Open a connection to the server of a search, upgraded with StartTLS when asked to,
+// and bound with the configured DN (anonymously when unset)
+func (exporter *Exporter) connectLDAP(search *LDAPSearch) (ldapConn, error) {
+	tlsConfig, err := exporter.loadLDAPTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dial := exporter.ldapDial
+	if dial == nil {
+		dial = dialLDAP
+	}
+
+	conn, err := dial(search.URL, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if exporter.LDAPStartTLS && strings.HasPrefix(search.URL, "ldap://") {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP StartTLS failed: %s", err.Error())
+		}
+	}
+
+	if len(exporter.LDAPBindDN) > 0 {
+		password := []byte{}
+		if len(exporter.LDAPBindPasswordFile) > 0 {
+			password, err = os.ReadFile(exporter.LDAPBindPasswordFile)
+			if err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("failed to read LDAP bind password: %s", err.Error())
+			}
+		}
+
+		if err := conn.Bind(exporter.LDAPBindDN, strings.TrimSpace(string(password))); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP bind as \"%s\" failed: %s", exporter.LDAPBindDN, err.Error())
+		}
+	}
+
+	return conn, nil
+}
+
+func dialLDAP(ldapURL string, tlsConfig *tls.Config) (ldapConn, error) {
+	conn, err := ldap.DialURL(ldapURL, ldap.DialWithTLSConfig(tlsConfig), ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetTimeout(ldapTimeout)
+	return conn, nil
+}
+
+func (exporter *Exporter) loadLDAPTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if len(exporter.LDAPCAFile) > 0 {
+		contents, err := os.ReadFile(exporter.LDAPCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LDAP CA file: %s", err.Error())
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(contents) {
+			return nil, fmt.Errorf("no certificate found in LDAP CA file \"%s\"", exporter.LDAPCAFile)
+		}
+	}
+
+	return config, nil
+}
+
+func readAndParseLDAPSearch(ctx context.Context, search *LDAPSearch, connect func(*LDAPSearch) (ldapConn, error)) ([]*parsedCertificate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	conn, err := connect(search)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	result, err := conn.SearchWithPaging(ldap.NewSearchRequest(
+		search.BaseDN,
+		search.Scope,
+		ldap.NeverDerefAliases,
+		0,
+		int(ldapTimeout.Seconds()),
+		false,
+		search.Filter,
+		search.Attributes,
+		nil,
+	), ldapPagingSize)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*parsedCertificate{}
+	for _, entry := range result.Entries {
+		values := [][]byte{}
+		for _, attribute := range entry.Attributes {
+			if isLDAPAttributeSearched(attribute.Name, search.Attributes) {
+				values = append(values, attribute.ByteValues...)
+			}
+		}
+
+		for index, value := range values {
+			cert, err := x509.ParseCertificate(value)
+			if err != nil {
+				return nil, fmt.Errorf("entry \"%s\": %s", entry.DN, err.Error())
+			}
+
+			displayName := entry.DN
+			if len(values) > 1 {
+				displayName = fmt.Sprintf("%s(%d)", entry.DN, index)
+			}
+			output = append(output, &parsedCertificate{
+				cert:   cert,
+				userID: displayName,
+			})
+		}
+	}
+
+	return output, nil
+}
+
+// isLDAPAttributeSearched : Tell if an attribute returned by the server is one of the searched ones,
+// names being case-insensitive and servers not always echoing options such as ;binary
+func isLDAPAttributeSearched(name string, attributes []string) bool {
+	name, _, _ = strings.Cut(name, ";")
+	for _, attribute := range attributes {
+		attribute, _, _ = strings.Cut(attribute, ";")
+		if strings.EqualFold(name, attribute) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockLDAPConn struct {
+	entries  []*ldap.Entry
+	startTLS bool
+	bindDN   string
+	password string
+	request  *ldap.SearchRequest
+	closed   bool
+}
+
+func (conn *mockLDAPConn) StartTLS(_ *tls.Config) error {
+	conn.startTLS = true
+	return nil
+}
+
+func (conn *mockLDAPConn) Bind(username, password string) error {
+	if password != "secret" {
+		return errors.New("invalid credentials")
+	}
+
+	conn.bindDN = username
+	conn.password = password
+	return nil
+}
+
+func (conn *mockLDAPConn) SearchWithPaging(request *ldap.SearchRequest, _ uint32) (*ldap.SearchResult, error) {
+	conn.request = request
+	return &ldap.SearchResult{Entries: conn.entries}, nil
+}
+
+func (conn *mockLDAPConn) Close() error {
+	conn.closed = true
+	return nil
+}
+
+func TestLDAPSearch(t *testing.T) {
+	alice := generateTestCertificate(leafTemplate("alice", time.Now().Add(time.Hour)), nil)
+	bobOld := generateTestCertificate(leafTemplate("bob-old", time.Now().Add(time.Hour)), nil)
+	bobNew := generateTestCertificate(leafTemplate("bob-new", time.Now().Add(time.Hour)), nil)
+
+	conn := &mockLDAPConn{entries: []*ldap.Entry{
+		{DN: "cn=alice,ou=people,dc=example,dc=com", Attributes: []*ldap.EntryAttribute{
+			{Name: "userCertificate;binary", ByteValues: [][]byte{alice.cert.Raw}},
+		}},
+		// servers may leave the ;binary option out
+		{DN: "cn=bob,ou=people,dc=example,dc=com", Attributes: []*ldap.EntryAttribute{
+			{Name: "userCertificate", ByteValues: [][]byte{bobOld.cert.Raw, bobNew.cert.Raw}},
+			{Name: "mail", ByteValues: [][]byte{[]byte("bob@example.com")}},
+		}},
+		{DN: "cn=carol,ou=people,dc=example,dc=com"},
+	}}
+
+	passwordFile := path.Join(t.TempDir(), "password")
+	assert.NoError(t, os.WriteFile(passwordFile, []byte("secret\n"), 0600))
+
+	search, err := ParseLDAPURL("ldap://ldap.example.com/ou=people,dc=example,dc=com?userCertificate;binary?sub?(objectClass=person)")
+	assert.NoError(t, err)
+
+	dialedURL := ""
+	exporter := &Exporter{
+		LDAPSearches:         []LDAPSearch{search},
+		LDAPBindDN:           "cn=exporter,dc=example,dc=com",
+		LDAPBindPasswordFile: passwordFile,
+		LDAPStartTLS:         true,
+		ldapDial: func(ldapURL string, _ *tls.Config) (ldapConn, error) {
+			dialedURL = ldapURL
+			return conn, nil
+		},
+	}
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		found := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			assert.Equal(t, "ldap://ldap.example.com", getLabelValue(metric, "ldap_server"))
+			assert.Equal(t, "ou=people,dc=example,dc=com", getLabelValue(metric, "ldap_base_dn"))
+			found[getLabelValue(metric, "embedded_key")] = getLabelValue(metric, "subject_CN")
+		}
+
+		assert.Equal(t, map[string]string{
+			"cn=alice,ou=people,dc=example,dc=com":  "alice",
+			"cn=bob,ou=people,dc=example,dc=com(0)": "bob-old",
+			"cn=bob,ou=people,dc=example,dc=com(1)": "bob-new",
+		}, found)
+	})
+
+	assert.Equal(t, "ldap://ldap.example.com", dialedURL)
+	assert.True(t, conn.startTLS)
+	assert.Equal(t, "cn=exporter,dc=example,dc=com", conn.bindDN)
+	assert.Equal(t, "secret", conn.password)
+	assert.Equal(t, "ou=people,dc=example,dc=com", conn.request.BaseDN)
+	assert.Equal(t, ldap.ScopeWholeSubtree, conn.request.Scope)
+	assert.Equal(t, "(objectClass=person)", conn.request.Filter)
+	assert.Equal(t, []string{"userCertificate;binary"}, conn.request.Attributes)
+	assert.True(t, conn.closed)
+}
+
+func TestLDAPSearchErrors(t *testing.T) {
+	conn := &mockLDAPConn{entries: []*ldap.Entry{
+		{DN: "cn=broken,dc=example,dc=com", Attributes: []*ldap.EntryAttribute{
+			{Name: "userCertificate;binary", ByteValues: [][]byte{[]byte("garbage")}},
+		}},
+	}}
+	search, err := ParseLDAPURL("ldaps://ldap.example.com/dc=example,dc=com")
+	assert.NoError(t, err)
+
+	exporter := &Exporter{
+		ldapDial: func(string, *tls.Config) (ldapConn, error) {
+			return conn, nil
+		},
+	}
+	_, err = readAndParseLDAPSearch(context.Background(), &search, exporter.connectLDAP)
+	assert.ErrorContains(t, err, "cn=broken,dc=example,dc=com")
+
+	// StartTLS only applies to plain connections
+	exporter.LDAPStartTLS = true
+	_, _ = readAndParseLDAPSearch(context.Background(), &search, exporter.connectLDAP)
+	assert.False(t, conn.startTLS)
+
+	passwordFile := path.Join(t.TempDir(), "password")
+	assert.NoError(t, os.WriteFile(passwordFile, []byte("wrong"), 0600))
+	exporter.LDAPBindDN = "cn=exporter,dc=example,dc=com"
+	exporter.LDAPBindPasswordFile = passwordFile
+	conn.closed = false
+	_, err = readAndParseLDAPSearch(context.Background(), &search, exporter.connectLDAP)
+	assert.ErrorContains(t, err, "LDAP bind as \"cn=exporter,dc=example,dc=com\" failed")
+	assert.True(t, conn.closed)
+}
+
+func TestParseLDAPURL(t *testing.T) {
+	search, err := ParseLDAPURL("ldaps://ldap.example.com:636/ou=people,dc=example,dc=com")
+	assert.NoError(t, err)
+	assert.Equal(t, LDAPSearch{
+		URL:        "ldaps://ldap.example.com:636",
+		BaseDN:     "ou=people,dc=example,dc=com",
+		Scope:      ldap.ScopeBaseObject,
+		Filter:     "(objectClass=*)",
+		Attributes: []string{"userCertificate;binary"},
+	}, search)
+
+	search, err = ParseLDAPURL("ldap://ldap.example.com/dc=example,dc=com?cACertificate;binary,userCertificate;binary?ONE?(cn=ca%20*)")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cACertificate;binary", "userCertificate;binary"}, search.Attributes)
+	assert.Equal(t, ldap.ScopeSingleLevel, search.Scope)
+	assert.Equal(t, "(cn=ca *)", search.Filter)
+
+	for _, invalid := range []string{"", "ldap://ldap.example.com", "ldap:///dc=example", "http://ldap.example.com/dc=example",
+		"ldap://ldap.example.com/dc=example??tree", "ldap://ldap.example.com/dc=example???(cn=", "ldap://ldap.example.com/dc=example????ext"} {
+		_, err := ParseLDAPURL(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,237 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// CertificateMonitorResource : The CertificateMonitor custom resource, see deploy/crds
+var CertificateMonitorResource = schema.GroupVersionResource{
+	Group:    "x509-certificate-exporter.enix.io",
+	Version:  "v1alpha1",
+	Resource: "certificatemonitors",
+}
+
+const defaultCertificateMonitorSecretKey = "tls.crt"
+
+const certificateMonitorSyncTimeout = time.Minute
+
+// CertificateMonitorSpec : Sources declared by a CertificateMonitor, secrets being looked up in its own namespace
+type CertificateMonitorSpec struct {
+	Secrets   []CertificateMonitorSecret `json:"secrets,omitempty"`
+	Endpoints []string                   `json:"endpoints,omitempty"`
+}
+
+// CertificateMonitorSecret : A secret holding PEM certificates in one or more keys (tls.crt by default)
+type CertificateMonitorSecret struct {
+	Name string   `json:"name"`
+	Keys []string `json:"keys,omitempty"`
+}
+
+// certificateMonitor : Reconciled state of a CertificateMonitor resource
+type certificateMonitor struct {
+	namespace string
+	name      string
+	spec      CertificateMonitorSpec
+}
+
+// WatchCertificateMonitors : Connect to a cluster like ConnectToKubernetesCluster, and keep the sources
+// declared by CertificateMonitor resources of all namespaces up-to-date until Shutdown is called
+func (exporter *Exporter) WatchCertificateMonitors(path string, rateLimiter flowcontrol.RateLimiter) error {
+	config, err := parseKubeConfig(path)
+	if err != nil {
+		return err
+	}
+
+	if rateLimiter != nil {
+		config.RateLimiter = rateLimiter
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	return exporter.startCertificateMonitorInformer(dynamicClient, kubeClient)
+}
+
+// startCertificateMonitorInformer : Watch CertificateMonitor resources, and wait for the initial list
+func (exporter *Exporter) startCertificateMonitorInformer(dynamicClient dynamic.Interface, kubeClient kubernetes.Interface) error {
+	exporter.monitorsMutex.Lock()
+	exporter.monitorsKubeClient = kubeClient
+	exporter.monitors = map[string]*certificateMonitor{}
+	exporter.monitorsMutex.Unlock()
+
+	factory, stop := exporter.getKubeInformerFactory(dynamicClient)
+	informer := factory.ForResource(CertificateMonitorResource).Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: exporter.reconcileCertificateMonitor,
+		UpdateFunc: func(_, obj interface{}) {
+			exporter.reconcileCertificateMonitor(obj)
+		},
+		DeleteFunc: exporter.deleteCertificateMonitor,
+	})
+	if err != nil {
+		return err
+	}
+
+	// only starts the informers which aren't running yet
+	factory.Start(stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), certificateMonitorSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		exporter.stopKubeInformers()
+		return fmt.Errorf("failed to list %s resources", CertificateMonitorResource.String())
+	}
+
+	return nil
+}
+
+// reconcileCertificateMonitor : Replace the sources of a created or updated CertificateMonitor,
+// an invalid resource is dropped until it gets fixed
+func (exporter *Exporter) reconcileCertificateMonitor(obj interface{}) {
+	object, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s", object.GetNamespace(), object.GetName())
+	monitor, err := parseCertificateMonitor(object)
+
+	exporter.monitorsMutex.Lock()
+	defer exporter.monitorsMutex.Unlock()
+
+	if err != nil {
+		log.Warnf("ignoring certificate monitor \"%s\": %s", key, err.Error())
+		delete(exporter.monitors, key)
+		return
+	}
+
+	exporter.monitors[key] = monitor
+}
+
+func (exporter *Exporter) deleteCertificateMonitor(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	object, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	exporter.monitorsMutex.Lock()
+	defer exporter.monitorsMutex.Unlock()
+	delete(exporter.monitors, fmt.Sprintf("%s/%s", object.GetNamespace(), object.GetName()))
+}
+
+func parseCertificateMonitor(object *unstructured.Unstructured) (*certificateMonitor, error) {
+	monitor := &certificateMonitor{
+		namespace: object.GetNamespace(),
+		name:      object.GetName(),
+	}
+
+	spec, found, err := unstructured.NestedMap(object.Object, "spec")
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &monitor.spec); err != nil {
+			return nil, err
+		}
+	}
+
+	for index, secret := range monitor.spec.Secrets {
+		if len(secret.Name) == 0 {
+			return nil, fmt.Errorf("secret n°%d has no name", index+1)
+		}
+		if len(secret.Keys) == 0 {
+			monitor.spec.Secrets[index].Keys = []string{defaultCertificateMonitorSecretKey}
+		}
+	}
+
+	for index, endpoint := range monitor.spec.Endpoints {
+		if len(endpoint) == 0 {
+			return nil, fmt.Errorf("endpoint n°%d is empty", index+1)
+		}
+	}
+
+	return monitor, nil
+}
+
+// collectCertificateMonitors : Build the refs of the sources declared by CertificateMonitor resources,
+// secrets being fetched from the API server
+func (exporter *Exporter) collectCertificateMonitors(ctx context.Context) ([]*certificateRef, []error) {
+	exporter.monitorsMutex.Lock()
+	kubeClient := exporter.monitorsKubeClient
+	monitors := make([]*certificateMonitor, 0, len(exporter.monitors))
+	for _, monitor := range exporter.monitors {
+		monitors = append(monitors, monitor)
+	}
+	exporter.monitorsMutex.Unlock()
+
+	sort.Slice(monitors, func(i, j int) bool {
+		if monitors[i].namespace != monitors[j].namespace {
+			return monitors[i].namespace < monitors[j].namespace
+		}
+		return monitors[i].name < monitors[j].name
+	})
+
+	output := []*certificateRef{}
+	outputErrors := []error{}
+	for _, monitor := range monitors {
+		monitorName := fmt.Sprintf("%s/%s", monitor.namespace, monitor.name)
+
+		for _, monitorSecret := range monitor.spec.Secrets {
+			secret, err := kubeClient.CoreV1().Secrets(monitor.namespace).Get(ctx, monitorSecret.Name, metav1.GetOptions{})
+			if err != nil {
+				outputErrors = append(outputErrors, fmt.Errorf("failed to fetch secret \"%s\" of certificate monitor \"%s\": %s", monitorSecret.Name, monitorName, err.Error()))
+				continue
+			}
+
+			for _, key := range monitorSecret.Keys {
+				output = append(output, &certificateRef{
+					path:   fmt.Sprintf("k8s/%s/%s", monitor.namespace, monitorSecret.Name),
+					format: certificateFormatKubeSecret,
+					kubeSecret: v1.Secret{
+						ObjectMeta: metav1.ObjectMeta{Name: secret.Name, Namespace: secret.Namespace},
+						Type:       secret.Type,
+						Data:       map[string][]byte{key: secret.Data[key]},
+					},
+					kubeSecretKey:      key,
+					certificateMonitor: monitorName,
+				})
+			}
+		}
+
+		for _, address := range monitor.spec.Endpoints {
+			output = append(output, &certificateRef{
+				path:               fmt.Sprintf("endpoint/%s", address),
+				format:             certificateFormatEndpoint,
+				endpoint:           exporter.getEndpointState(TLSEndpoint{Address: address}),
+				certificateMonitor: monitorName,
+			})
+		}
+	}
+
+	return output, outputErrors
+}
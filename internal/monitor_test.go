@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newCertificateMonitor(namespace string, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "x509-certificate-exporter.enix.io/v1alpha1",
+		"kind":       "CertificateMonitor",
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+		},
+		"spec": spec,
+	}}
+}
+
+func TestCertificateMonitors(t *testing.T) {
+	basic, err := os.ReadFile("../test/basic.pem")
+	assert.NoError(t, err)
+	double, err := os.ReadFile("../test/double.pem")
+	assert.NoError(t, err)
+
+	kubeClient := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-tls", Namespace: "app"},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": basic, "ca.crt": double},
+		},
+	)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{CertificateMonitorResource: "CertificateMonitorList"},
+		newCertificateMonitor("app", "app", map[string]interface{}{
+			"secrets": []interface{}{map[string]interface{}{"name": "app-tls"}},
+		}),
+		// invalid, ignored
+		newCertificateMonitor("app", "broken", map[string]interface{}{
+			"secrets": []interface{}{map[string]interface{}{"keys": []interface{}{"tls.crt"}}},
+		}),
+	)
+
+	exporter := &Exporter{ExposeErrorMetrics: true}
+	assert.NoError(t, exporter.startCertificateMonitorInformer(dynamicClient, kubeClient))
+	defer exporter.Shutdown()
+
+	countCerts := func() int {
+		refs, _ := exporter.parseAllCertificates(context.Background())
+		certCount := 0
+		for _, ref := range refs {
+			certCount += len(ref.certificates)
+		}
+		return certCount
+	}
+	assert.Equal(t, 1, countCerts())
+
+	monitors := dynamicClient.Resource(CertificateMonitorResource).Namespace("app")
+
+	// updates are reconciled: the CA key is now watched too
+	_, err = monitors.Update(context.Background(), newCertificateMonitor("app", "app", map[string]interface{}{
+		"secrets": []interface{}{
+			map[string]interface{}{"name": "app-tls", "keys": []interface{}{"tls.crt", "ca.crt"}},
+		},
+	}), metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return countCerts() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	// and so are deletions
+	err = monitors.Delete(context.Background(), "app", metav1.DeleteOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return countCerts() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	_, err = monitors.Create(context.Background(), newCertificateMonitor("app", "other", map[string]interface{}{
+		"secrets": []interface{}{
+			map[string]interface{}{"name": "app-tls"},
+			map[string]interface{}{"name": "missing"},
+		},
+	}), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return countCerts() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		foundMetrics := getMetricsForName(metrics, "x509_cert_not_after")
+		assert.Len(t, foundMetrics, 1)
+		assert.Equal(t, "app/other", getLabelValue(foundMetrics[0], "certificate_monitor"))
+		assert.Equal(t, "app-tls", getLabelValue(foundMetrics[0], "secret_name"))
+		assert.Equal(t, "app", getLabelValue(foundMetrics[0], "secret_namespace"))
+		assert.Equal(t, "tls.crt", getLabelValue(foundMetrics[0], "secret_key"))
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+func TestCertificateMonitorEndpoints(t *testing.T) {
+	server := startFakeTLSServer(t, generateTestCertificate(leafTemplate("server", time.Now().Add(time.Hour)), nil), nil)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{CertificateMonitorResource: "CertificateMonitorList"},
+		newCertificateMonitor("app", "app", map[string]interface{}{
+			"endpoints": []interface{}{server.address()},
+		}),
+	)
+
+	exporter := &Exporter{}
+	assert.NoError(t, exporter.startCertificateMonitorInformer(dynamicClient, fake.NewSimpleClientset()))
+	defer exporter.Shutdown()
+
+	hasEndpointState := func() bool {
+		exporter.parseAllCertificates(context.Background())
+		exporter.endpointsMutex.Lock()
+		defer exporter.endpointsMutex.Unlock()
+		_, found := exporter.endpointStates[server.address()]
+		return found
+	}
+	assert.Eventually(t, hasEndpointState, 5*time.Second, 10*time.Millisecond)
+
+	// the endpoint isn't refreshed anymore once its monitor is deleted
+	err := dynamicClient.Resource(CertificateMonitorResource).Namespace("app").Delete(context.Background(), "app", metav1.DeleteOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return !hasEndpointState() }, 5*time.Second, 10*time.Millisecond)
+}
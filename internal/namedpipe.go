@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const namedPipePrefix = `\\.\pipe\`
+
+const namedPipeTimeout = 10 * time.Second
+
+// ParseNamedPipe : Check a local Windows named pipe path, e.g. \\.\pipe\certificates
+func ParseNamedPipe(spec string) (string, error) {
+	if len(spec) <= len(namedPipePrefix) || !strings.EqualFold(spec[:len(namedPipePrefix)], namedPipePrefix) {
+		return "", fmt.Errorf("expected %s<name>, got \"%s\"", namedPipePrefix, spec)
+	}
+
+	name := spec[len(namedPipePrefix):]
+	if strings.Contains(name, `\`) {
+		return "", fmt.Errorf("unexpected backslash in pipe name \"%s\"", name)
+	}
+
+	return namedPipePrefix + name, nil
+}
+
+func (exporter *Exporter) collectNamedPipes() []*certificateRef {
+	output := []*certificateRef{}
+
+	for _, pipe := range exporter.NamedPipes {
+		output = append(output, &certificateRef{
+			path:   pipe,
+			format: certificateFormatNamedPipe,
+		})
+	}
+
+	return output
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package internal
+
+import (
+	"context"
+	"errors"
+)
+
+func readAndParseNamedPipe(_ context.Context, _ string) ([]*parsedCertificate, error) {
+	return nil, errors.New("named pipes can only be read on Windows")
+}
@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNamedPipe(t *testing.T) {
+	pipe, err := ParseNamedPipe(`\\.\pipe\certificates`)
+	assert.NoError(t, err)
+	assert.Equal(t, `\\.\pipe\certificates`, pipe)
+
+	pipe, err = ParseNamedPipe(`\\.\PIPE\certificates`)
+	assert.NoError(t, err)
+	assert.Equal(t, `\\.\pipe\certificates`, pipe)
+
+	for _, invalid := range []string{"", `\\.\pipe\`, `\\server\pipe\certificates`, `C:\certificates.pem`, `\\.\pipe\a\b`} {
+		_, err := ParseNamedPipe(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
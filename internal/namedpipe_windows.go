@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"context"
+	"io"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// readAndParseNamedPipe : Connect to a pipe and read PEM certificates until the server closes it,
+// giving up after namedPipeTimeout
+func readAndParseNamedPipe(ctx context.Context, pipe string) ([]*parsedCertificate, error) {
+	ctx, cancel := context.WithTimeout(ctx, namedPipeTimeout)
+	defer cancel()
+
+	conn, err := winio.DialPipeContext(ctx, pipe)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	contents, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := parsePEM(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []*parsedCertificate{}
+	for _, cert := range certs {
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	return output, nil
+}
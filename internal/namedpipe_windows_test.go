@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestPipeServer : Serve the given PEM contents to each client of a pipe, closed with the test
+func startTestPipeServer(t *testing.T, contents []byte) string {
+	pipe := fmt.Sprintf(`\\.\pipe\x509-certificate-exporter-test-%d`, time.Now().UnixNano())
+	listener, err := winio.ListenPipe(pipe, nil)
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			//nolint:errcheck
+			conn.Write(contents)
+			conn.Close()
+		}
+	}()
+
+	return pipe
+}
+
+func TestNamedPipe(t *testing.T) {
+	contents := bytes.Buffer{}
+	for _, cert := range []*testCertificate{
+		generateTestCertificate(leafTemplate("first", time.Now().Add(time.Hour)), nil),
+		generateTestCertificate(leafTemplate("second", time.Now().Add(-time.Hour)), nil),
+	} {
+		assert.NoError(t, pem.Encode(&contents, &pem.Block{Type: "CERTIFICATE", Bytes: cert.cert.Raw}))
+	}
+	pipe := startTestPipeServer(t, contents.Bytes())
+
+	testRequest(t, &Exporter{
+		NamedPipes: []string{pipe, `\\.\pipe\x509-certificate-exporter-does-not-exist`},
+	}, func(metrics []model.MetricFamily) {
+		expired := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_expired") {
+			assert.Equal(t, pipe, getLabelValue(metric, "named_pipe"))
+			expired[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"first": 0, "second": 1}, expired)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+	})
+}
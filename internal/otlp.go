@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const otlpTimeout = 30 * time.Second
+
+const defaultOTLPInterval = time.Minute
+
+// otlpMetricsPath : Path appended to endpoints given without one, as OTLP/HTTP collectors serve metrics there
+const otlpMetricsPath = "/v1/metrics"
+
+const otlpScopeName = "github.com/enix/x509-certificate-exporter"
+
+// RunOTLPExporter : Push metrics to the OTLP endpoint every OTLPInterval, without serving them to Prometheus
+func (exporter *Exporter) RunOTLPExporter() error {
+	if len(exporter.OTLPEndpoint) == 0 {
+		return errors.New("no OTLP endpoint configured")
+	}
+
+	exporter.DiscoverCertificates()
+	exporter.startEndpointRefresher()
+	exporter.runOTLPExporter(context.Background())
+	return nil
+}
+
+// startOTLPExporter : Push metrics to the OTLP endpoint in the background until Shutdown is called
+func (exporter *Exporter) startOTLPExporter() {
+	if len(exporter.OTLPEndpoint) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exporter.stopOTLPExporter = cancel
+	go exporter.runOTLPExporter(ctx)
+}
+
+func (exporter *Exporter) runOTLPExporter(ctx context.Context) {
+	interval := exporter.OTLPInterval
+	if interval == 0 {
+		interval = defaultOTLPInterval
+	}
+
+	for {
+		if err := exporter.pushOTLP(ctx); err != nil && ctx.Err() == nil {
+			log.Warnf("failed to push metrics to %s: %s", exporter.OTLPEndpoint, err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pushOTLP : Collect the metrics served to Prometheus and send them as an OTLP/HTTP protobuf request
+func (exporter *Exporter) pushOTLP(ctx context.Context) error {
+	endpoint, err := url.Parse(exporter.OTLPEndpoint)
+	if err != nil {
+		return err
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = otlpMetricsPath
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{exporter: exporter})
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	body, err := proto.Marshal(toOTLPRequest(families, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, otlpTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	for key, value := range exporter.OTLPHeaders {
+		request.Header.Set(key, value)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", response.Status, string(message))
+	}
+
+	return nil
+}
+
+// toOTLPRequest : Convert gathered metrics, gauges staying gauges, counters becoming cumulative monotonic sums
+// and histograms cumulative histograms
+func toOTLPRequest(families []*dto.MetricFamily, now time.Time) *collectormetrics.ExportMetricsServiceRequest {
+	timestamp := uint64(now.UnixNano())
+	metrics := []*metricspb.Metric{}
+
+	for _, family := range families {
+		metric := &metricspb.Metric{
+			Name:        family.GetName(),
+			Description: family.GetHelp(),
+		}
+
+		switch family.GetType() {
+		case dto.MetricType_GAUGE:
+			gauge := &metricspb.Gauge{}
+			for _, sample := range family.GetMetric() {
+				gauge.DataPoints = append(gauge.DataPoints, toOTLPNumberDataPoint(sample, sample.GetGauge().GetValue(), timestamp))
+			}
+			metric.Data = &metricspb.Metric_Gauge{Gauge: gauge}
+		case dto.MetricType_COUNTER:
+			sum := &metricspb.Sum{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}
+			for _, sample := range family.GetMetric() {
+				sum.DataPoints = append(sum.DataPoints, toOTLPNumberDataPoint(sample, sample.GetCounter().GetValue(), timestamp))
+			}
+			metric.Data = &metricspb.Metric_Sum{Sum: sum}
+		case dto.MetricType_HISTOGRAM:
+			histogram := &metricspb.Histogram{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			}
+			for _, sample := range family.GetMetric() {
+				histogram.DataPoints = append(histogram.DataPoints, toOTLPHistogramDataPoint(sample, timestamp))
+			}
+			metric.Data = &metricspb.Metric_Histogram{Histogram: histogram}
+		default:
+			continue
+		}
+
+		metrics = append(metrics, metric)
+	}
+
+	return &collectormetrics.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{
+			{
+				Resource: &resourcepb.Resource{
+					Attributes: []*commonpb.KeyValue{toOTLPAttribute("service.name", "x509-certificate-exporter")},
+				},
+				ScopeMetrics: []*metricspb.ScopeMetrics{
+					{
+						Scope:   &commonpb.InstrumentationScope{Name: otlpScopeName},
+						Metrics: metrics,
+					},
+				},
+			},
+		},
+	}
+}
+
+func toOTLPNumberDataPoint(sample *dto.Metric, value float64, timestamp uint64) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{
+		Attributes:   toOTLPAttributes(sample.GetLabel()),
+		TimeUnixNano: timestamp,
+		Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+	}
+}
+
+// toOTLPHistogramDataPoint : Turn cumulative Prometheus buckets into OTLP per-bucket counts,
+// the +Inf bucket being implicit in OTLP
+func toOTLPHistogramDataPoint(sample *dto.Metric, timestamp uint64) *metricspb.HistogramDataPoint {
+	histogram := sample.GetHistogram()
+	sum := histogram.GetSampleSum()
+	point := &metricspb.HistogramDataPoint{
+		Attributes:   toOTLPAttributes(sample.GetLabel()),
+		TimeUnixNano: timestamp,
+		Count:        histogram.GetSampleCount(),
+		Sum:          &sum,
+	}
+
+	previous := uint64(0)
+	for _, bucket := range histogram.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			continue
+		}
+		point.ExplicitBounds = append(point.ExplicitBounds, bucket.GetUpperBound())
+		point.BucketCounts = append(point.BucketCounts, bucket.GetCumulativeCount()-previous)
+		previous = bucket.GetCumulativeCount()
+	}
+	point.BucketCounts = append(point.BucketCounts, histogram.GetSampleCount()-previous)
+
+	return point
+}
+
+func toOTLPAttributes(labels []*dto.LabelPair) []*commonpb.KeyValue {
+	output := []*commonpb.KeyValue{}
+	for _, label := range labels {
+		output = append(output, toOTLPAttribute(label.GetName(), label.GetValue()))
+	}
+
+	return output
+}
+
+func toOTLPAttribute(key string, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeOTLPCollector : Records the metrics export requests it receives
+type fakeOTLPCollector struct {
+	mutex    sync.Mutex
+	paths    []string
+	headers  []http.Header
+	requests []*collectormetrics.ExportMetricsServiceRequest
+}
+
+func (collector *fakeOTLPCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	request := &collectormetrics.ExportMetricsServiceRequest{}
+	if err == nil {
+		err = proto.Unmarshal(body, request)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	collector.paths = append(collector.paths, r.URL.Path)
+	collector.headers = append(collector.headers, r.Header)
+	collector.requests = append(collector.requests, request)
+}
+
+func (collector *fakeOTLPCollector) count() int {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	return len(collector.requests)
+}
+
+func getOTLPMetric(request *collectormetrics.ExportMetricsServiceRequest, name string) *metricspb.Metric {
+	for _, resourceMetrics := range request.GetResourceMetrics() {
+		for _, scopeMetrics := range resourceMetrics.GetScopeMetrics() {
+			for _, metric := range scopeMetrics.GetMetrics() {
+				if metric.GetName() == name {
+					return metric
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func getOTLPAttribute(point *metricspb.NumberDataPoint, key string) string {
+	for _, attribute := range point.GetAttributes() {
+		if attribute.GetKey() == key {
+			return attribute.GetValue().GetStringValue()
+		}
+	}
+
+	return ""
+}
+
+func TestOTLPPush(t *testing.T) {
+	collector := &fakeOTLPCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	exporter := &Exporter{
+		Files:        []string{"../test/basic.pem"},
+		OTLPEndpoint: server.URL,
+		OTLPHeaders:  map[string]string{"Authorization": "Bearer token"},
+	}
+	assert.NoError(t, exporter.pushOTLP(context.Background()))
+
+	assert.Equal(t, []string{otlpMetricsPath}, collector.paths)
+	assert.Equal(t, "Bearer token", collector.headers[0].Get("Authorization"))
+	assert.Equal(t, "application/x-protobuf", collector.headers[0].Get("Content-Type"))
+
+	notAfter := getOTLPMetric(collector.requests[0], "x509_cert_not_after")
+	if assert.NotNil(t, notAfter) && assert.Len(t, notAfter.GetGauge().GetDataPoints(), 1) {
+		point := notAfter.GetGauge().GetDataPoints()[0]
+		assert.Equal(t, "kubernetes", getOTLPAttribute(point, "subject_CN"))
+		assert.Equal(t, "basic.pem", getOTLPAttribute(point, "filename"))
+		assert.Greater(t, point.GetAsDouble(), 0.)
+	}
+
+	readErrors := getOTLPMetric(collector.requests[0], "x509_read_errors")
+	assert.NotNil(t, readErrors.GetGauge())
+
+	exporter.OTLPEndpoint = server.URL + "/custom/path"
+	assert.NoError(t, exporter.pushOTLP(context.Background()))
+	assert.Equal(t, "/custom/path", collector.paths[1])
+
+	failing := httptest.NewServer(http.NotFoundHandler())
+	defer failing.Close()
+	exporter.OTLPEndpoint = failing.URL
+	assert.ErrorContains(t, exporter.pushOTLP(context.Background()), "404")
+}
+
+func TestOTLPInterval(t *testing.T) {
+	collector := &fakeOTLPCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	exporter := &Exporter{
+		Files:        []string{"../test/basic.pem"},
+		OTLPEndpoint: server.URL,
+		OTLPInterval: 10 * time.Millisecond,
+	}
+	exporter.startOTLPExporter()
+	assert.Eventually(t, func() bool { return collector.count() >= 3 }, 5*time.Second, 10*time.Millisecond)
+
+	exporter.Shutdown()
+	time.Sleep(50 * time.Millisecond)
+	count := collector.count()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, count, collector.count())
+}
+
+func TestToOTLPRequest(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: "test counter"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_seconds", Help: "test histogram", Buckets: []float64{1, 10}})
+	registry.MustRegister(counter, histogram)
+	counter.Add(3)
+	for _, value := range []float64{0.5, 2, 5, 20} {
+		histogram.Observe(value)
+	}
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	request := toOTLPRequest(families, time.Now())
+
+	sum := getOTLPMetric(request, "test_total").GetSum()
+	assert.True(t, sum.GetIsMonotonic())
+	assert.Equal(t, 3., sum.GetDataPoints()[0].GetAsDouble())
+
+	point := getOTLPMetric(request, "test_seconds").GetHistogram().GetDataPoints()[0]
+	assert.Equal(t, uint64(4), point.GetCount())
+	assert.Equal(t, 27.5, point.GetSum())
+	assert.Equal(t, []float64{1, 10}, point.GetExplicitBounds())
+	assert.Equal(t, []uint64{1, 2, 1}, point.GetBucketCounts())
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ParsePublicKeyPin : Decode the SHA-256 hash of a SubjectPublicKeyInfo, given in base64 like HPKP pins
+// (with or without the pin-sha256= or curl's sha256// prefixes) or in hex
+func ParsePublicKeyPin(spec string) ([sha256.Size]byte, error) {
+	pin := [sha256.Size]byte{}
+
+	value := strings.TrimPrefix(strings.TrimPrefix(spec, "pin-sha256="), "sha256//")
+	value = strings.Trim(value, "\"")
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(decoded) != sha256.Size {
+		decoded, err = hex.DecodeString(strings.ReplaceAll(value, ":", ""))
+	}
+	if err != nil || len(decoded) != sha256.Size {
+		return pin, fmt.Errorf("expected a base64 or hex SHA-256 hash, got \"%s\"", spec)
+	}
+
+	copy(pin[:], decoded)
+	return pin, nil
+}
+
+// isPublicKeyPinned : Tell if the SubjectPublicKeyInfo of a certificate hashes to one of the pinned ones
+func (exporter *Exporter) isPublicKeyPinned(cert *x509.Certificate) bool {
+	return slices.Contains(exporter.PinnedPublicKeys, sha256.Sum256(cert.RawSubjectPublicKeyInfo))
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"path"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicKeyPinning(t *testing.T) {
+	dir := t.TempDir()
+	pinned := generateTestCertificate(leafTemplate("pinned", time.Now().Add(time.Hour)), nil)
+	unpinned := generateTestCertificate(leafTemplate("unpinned", time.Now().Add(time.Hour)), nil)
+	writeTestCertificates(path.Join(dir, "pinned.pem"), pinned)
+	writeTestCertificates(path.Join(dir, "unpinned.pem"), unpinned)
+
+	testRequest(t, &Exporter{
+		Files:            []string{path.Join(dir, "*.pem")},
+		PinnedPublicKeys: [][sha256.Size]byte{sha256.Sum256(pinned.cert.RawSubjectPublicKeyInfo)},
+	}, func(metrics []model.MetricFamily) {
+		values := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_public_key_pinned") {
+			values[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"pinned": 1, "unpinned": 0}, values)
+	})
+
+	testRequest(t, &Exporter{
+		Files: []string{path.Join(dir, "*.pem")},
+	}, func(metrics []model.MetricFamily) {
+		assert.Empty(t, getMetricsForName(metrics, "x509_cert_public_key_pinned"))
+	})
+}
+
+func TestParsePublicKeyPin(t *testing.T) {
+	hash := sha256.Sum256([]byte("public key"))
+	encoded := base64.StdEncoding.EncodeToString(hash[:])
+
+	for _, spec := range []string{encoded, "pin-sha256=\"" + encoded + "\"", "sha256//" + encoded, hex.EncodeToString(hash[:])} {
+		pin, err := ParsePublicKeyPin(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, hash, pin, spec)
+	}
+
+	for _, invalid := range []string{"", "sha256//", "not a hash", base64.StdEncoding.EncodeToString(hash[:16])} {
+		_, err := ParsePublicKeyPin(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ReadinessSelector : A label of the certificates gating readiness, and the regular expression its value must fully match
+type ReadinessSelector struct {
+	Label   string
+	Pattern *regexp.Regexp
+}
+
+// ParseReadinessSelector : Split a <label>=<regular expression> specification, e.g. "criticality=high|critical"
+func ParseReadinessSelector(spec string) (ReadinessSelector, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return ReadinessSelector{}, fmt.Errorf("expected <label>=<regular expression>, got \"%s\"", spec)
+	}
+
+	pattern, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", parts[1]))
+	if err != nil {
+		return ReadinessSelector{}, fmt.Errorf("invalid regular expression \"%s\": %s", parts[1], err.Error())
+	}
+
+	return ReadinessSelector{Label: parts[0], Pattern: pattern}, nil
+}
+
+// isReadinessGated : Tell if a certificate is matched by all readiness selectors (all certificates, when there's none)
+func (exporter *Exporter) isReadinessGated(labels map[string]string) bool {
+	for _, selector := range exporter.ReadinessSelectors {
+		if !selector.Pattern.MatchString(labels[selector.Label]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// handleReadiness : Fail readiness while any of the selected certificates found by the last scrape (or discovery)
+// has less than ReadinessMinLifetime left, so that workloads relying on them are taken out of rotation early;
+// always ready when no minimum lifetime is configured
+func (exporter *Exporter) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if exporter.ReadinessMinLifetime <= 0 {
+		fmt.Fprintln(w, "ok")
+		return
+	}
+
+	exporter.lastParsedMutex.Lock()
+	refs, parsed := exporter.lastParsedRefs, exporter.lastParsedRefs != nil
+	exporter.lastParsedMutex.Unlock()
+
+	if !parsed {
+		ctx, cancel := exporter.newScrapeContext()
+		defer cancel()
+		refs, _ = exporter.parseAllCertificates(ctx)
+	}
+
+	now := exporter.now()
+	expiring := []string{}
+	for _, ref := range refs {
+		for _, cert := range ref.certificates {
+			remaining := cert.cert.NotAfter.Sub(now)
+			if remaining >= exporter.ReadinessMinLifetime || !exporter.isReadinessGated(exporter.getLabels(cert, ref)) {
+				continue
+			}
+
+			expiring = append(expiring, fmt.Sprintf("%s: \"%s\" (%s)", ref.path, cert.cert.Subject.CommonName, formatRemaining(remaining)))
+		}
+	}
+
+	if len(expiring) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "%d certificate(s) with less than %s left:\n", len(expiring), exporter.ReadinessMinLifetime)
+		for _, line := range expiring {
+			fmt.Fprintln(w, line)
+		}
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getReadiness(exporter *Exporter) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	exporter.handleReadiness(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	return recorder
+}
+
+func TestReadinessMinLifetime(t *testing.T) {
+	day := 24 * time.Hour
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "critical-api.pem"), generateTestCertificate(leafTemplate("api", time.Now().Add(10*day)), nil))
+	writeTestCertificates(path.Join(dir, "other.pem"), generateTestCertificate(leafTemplate("other", time.Now().Add(day)), nil))
+
+	selector, err := ParseReadinessSelector("filename=critical-.*")
+	assert.NoError(t, err)
+
+	now := time.Now()
+	exporter := &Exporter{
+		Files:                []string{path.Join(dir, "*.pem")},
+		ReadinessMinLifetime: 7 * day,
+		ReadinessSelectors:   []ReadinessSelector{selector},
+		clock: func() time.Time {
+			return now
+		},
+	}
+
+	// the other certificate expires sooner, but isn't selected
+	recorder := getReadiness(exporter)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "ok\n", recorder.Body.String())
+
+	now = now.Add(4 * day)
+	recorder = getReadiness(exporter)
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "1 certificate(s) with less than 168h0m0s left")
+	assert.Contains(t, recorder.Body.String(), path.Join(dir, "critical-api.pem")+": \"api\" (5d 23h)")
+	assert.NotContains(t, recorder.Body.String(), "other")
+
+	exporter.ReadinessSelectors = nil
+	recorder = getReadiness(exporter)
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "\"other\" (expired 3d 0h ago)")
+
+	exporter.ReadinessMinLifetime = 0
+	assert.Equal(t, http.StatusOK, getReadiness(exporter).Code)
+}
+
+func TestParseReadinessSelector(t *testing.T) {
+	selector, err := ParseReadinessSelector("criticality=high|critical")
+	assert.NoError(t, err)
+	assert.Equal(t, "criticality", selector.Label)
+	assert.True(t, selector.Pattern.MatchString("critical"))
+	// fully matched
+	assert.False(t, selector.Pattern.MatchString("not-critical"))
+
+	// matching certificates without the label
+	selector, err = ParseReadinessSelector("criticality=")
+	assert.NoError(t, err)
+	assert.True(t, selector.Pattern.MatchString(""))
+
+	for _, invalid := range []string{"", "criticality", "=high", "criticality=(high"} {
+		_, err := ParseReadinessSelector(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+// sourceRotations : Last leaf fingerprint seen for a source, how many times it changed, and how long after
+// the not before timestamp of the previous leaf the current one was issued
+type sourceRotations struct {
+	fingerprint [sha256.Size]byte
+	notBefore   time.Time
+	count       int
+	interval    time.Duration
+}
+
+// trackRotations : Compare the leaf of each source with the one seen on the previous scrape,
+// and return the rotations of each of them (the first observation isn't a rotation);
+// sources which are gone are forgotten, starting over if they show up again
+func (exporter *Exporter) trackRotations(refs []*certificateRef) map[*certificateRef]sourceRotations {
+	exporter.rotationsMutex.Lock()
+	defer exporter.rotationsMutex.Unlock()
+
+	if exporter.rotations == nil {
+		exporter.rotations = map[string]*sourceRotations{}
+	}
+
+	present := getSourceKeys(refs)
+	for key := range exporter.rotations {
+		if !present[key] {
+			delete(exporter.rotations, key)
+		}
+	}
+
+	output := map[*certificateRef]sourceRotations{}
+	for _, ref := range refs {
+		leaf := getLeafCertificate(ref.certificates)
+		if leaf == nil {
+			continue
+		}
+
+		key := getSourceKey(ref)
+		fingerprint := sha256.Sum256(leaf.cert.Raw)
+
+		state, found := exporter.rotations[key]
+		if !found {
+			state = &sourceRotations{fingerprint: fingerprint, notBefore: leaf.cert.NotBefore}
+			exporter.rotations[key] = state
+		} else if state.fingerprint != fingerprint {
+			state.fingerprint = fingerprint
+			state.interval = leaf.cert.NotBefore.Sub(state.notBefore)
+			state.notBefore = leaf.cert.NotBefore
+			state.count++
+		}
+
+		output[ref] = *state
+	}
+
+	return output
+}
+
+// getSourceKey : Identify a source across scrapes
+func getSourceKey(ref *certificateRef) string {
+	return fmt.Sprintf("%s:%s", ref.path, ref.kubeSecretKey)
+}
+
+// getSourceKeys : Set of the keys of some sources
+func getSourceKeys(refs []*certificateRef) map[string]bool {
+	output := map[string]bool{}
+	for _, ref := range refs {
+		output[getSourceKey(ref)] = true
+	}
+
+	return output
+}
+
+// getLeafCertificate : Return the first non-CA certificate, or the first one if all are CAs
+func getLeafCertificate(certs []*parsedCertificate) *parsedCertificate {
+	for _, cert := range certs {
+		if !cert.cert.IsCA {
+			return cert
+		}
+	}
+
+	if len(certs) > 0 {
+		return certs[0]
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificateRotation(t *testing.T) {
+	dir := t.TempDir()
+	certPath := path.Join(dir, "tls.pem")
+	root := generateTestCertificate(caTemplate("root", time.Now().Add(time.Hour)), nil)
+	writeTestCertificates(certPath, generateTestCertificate(leafTemplate("first", time.Now().Add(time.Hour)), root), root)
+
+	exporter := &Exporter{
+		Files:                 []string{certPath},
+		ExposeRotationMetrics: true,
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{exporter: exporter})
+
+	getRotations := func() float64 {
+		metrics, err := registry.Gather()
+		assert.NoError(t, err)
+
+		for index := range metrics {
+			if metrics[index].GetName() == "x509_cert_rotation_total" {
+				assert.Len(t, metrics[index].GetMetric(), 1)
+				assert.Equal(t, "tls.pem", getLabelValue(metrics[index].GetMetric()[0], "filename"))
+				return metrics[index].GetMetric()[0].GetCounter().GetValue()
+			}
+		}
+
+		t.Fatal("x509_cert_rotation_total not found")
+		return 0
+	}
+
+	// the first observation isn't a rotation
+	assert.Equal(t, 0., getRotations())
+	assert.Equal(t, 0., getRotations())
+
+	// only the leaf changes, the CA is kept
+	writeTestCertificates(certPath, generateTestCertificate(leafTemplate("second", time.Now().Add(time.Hour)), root), root)
+	assert.Equal(t, 1., getRotations())
+	assert.Equal(t, 1., getRotations())
+
+	// sources which are gone are forgotten
+	exporter.trackRotations(nil)
+	assert.Empty(t, exporter.rotations)
+	assert.Equal(t, 0., getRotations())
+}
+
+func TestCertificateRotationInterval(t *testing.T) {
+	dir := t.TempDir()
+	certPath := path.Join(dir, "tls.pem")
+	issued := time.Now().Add(-72 * time.Hour).Truncate(time.Second)
+
+	writeLeaf := func(name string, notBefore time.Time) {
+		template := leafTemplate(name, time.Now().Add(time.Hour))
+		template.NotBefore = notBefore
+		writeTestCertificates(certPath, generateTestCertificate(template, nil))
+	}
+	writeLeaf("first", issued)
+
+	exporter := &Exporter{
+		Files:                 []string{certPath},
+		ExposeRotationMetrics: true,
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{exporter: exporter})
+
+	getIntervals := func() []float64 {
+		metrics, err := registry.Gather()
+		assert.NoError(t, err)
+
+		intervals := []float64{}
+		for index := range metrics {
+			if metrics[index].GetName() == "x509_cert_last_rotation_interval_seconds" {
+				for _, metric := range metrics[index].GetMetric() {
+					assert.Equal(t, "tls.pem", getLabelValue(metric, "filename"))
+					intervals = append(intervals, metric.GetGauge().GetValue())
+				}
+			}
+		}
+		return intervals
+	}
+
+	// nothing to compare the first leaf with
+	assert.Empty(t, getIntervals())
+	assert.Empty(t, getIntervals())
+
+	writeLeaf("second", issued.Add(48*time.Hour))
+	assert.Equal(t, []float64{172800}, getIntervals())
+
+	writeLeaf("third", issued.Add(60*time.Hour))
+	assert.Equal(t, []float64{43200}, getIntervals())
+	assert.Equal(t, []float64{43200}, getIntervals())
+}
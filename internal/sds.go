@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sdsCertificateFields : Fields of an Envoy secret holding certificates, as object and data source names
+var sdsCertificateFields = [][2]string{
+	{"tls_certificate", "certificate_chain"},
+	{"validation_context", "trusted_ca"},
+}
+
+// readAndParseSDSFile : Extract the certificates of the Envoy secrets (Secret Discovery Service) of a JSON or YAML file,
+// wherever they are: a single secret, the resources of a discovery response (as read by Envoy from files), or the
+// secrets of an admin config dump (e.g. istioctl proxy-config secret -o json)
+func readAndParseSDSFile(filePath string) ([]*parsedCertificate, error) {
+	contents, err := readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err := decodeYAMLDocuments(bytes.NewReader(contents))
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := []map[string]interface{}{}
+	for _, document := range documents {
+		secrets = append(secrets, findSDSSecrets(document)...)
+	}
+
+	output := []*parsedCertificate{}
+	for _, secret := range secrets {
+		name, _ := secret["name"].(string)
+
+		for _, field := range sdsCertificateFields {
+			source := getSDSObject(getSDSObject(secret, field[0]), field[1])
+			if source == nil {
+				continue
+			}
+
+			key := field[0] + "." + field[1]
+			certs, err := parseSDSDataSource(source)
+			if err != nil {
+				return nil, fmt.Errorf("secret \"%s\", %s: %s", name, key, err.Error())
+			}
+
+			for _, cert := range certs {
+				output = append(output, &parsedCertificate{
+					cert:      cert,
+					userID:    key,
+					sdsSecret: name,
+				})
+			}
+		}
+	}
+
+	return output, nil
+}
+
+// findSDSSecrets : Walk a decoded document for the objects having a tls_certificate or validation_context field
+func findSDSSecrets(node interface{}) []map[string]interface{} {
+	output := []map[string]interface{}{}
+
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for _, field := range sdsCertificateFields {
+			if getSDSObject(value, field[0]) != nil {
+				return append(output, value)
+			}
+		}
+
+		// sorted, so that certificates are listed the same way on each read
+		keys := []string{}
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			output = append(output, findSDSSecrets(value[key])...)
+		}
+	case []interface{}:
+		for _, item := range value {
+			output = append(output, findSDSSecrets(item)...)
+		}
+	}
+
+	return output
+}
+
+// getSDSValue : A field of an object, named in snake case as in Envoy's config dumps,
+// or in lower camel case as in the canonical JSON mapping of protobuf
+func getSDSValue(object map[string]interface{}, name string) interface{} {
+	if value, found := object[name]; found {
+		return value
+	}
+
+	parts := strings.Split(name, "_")
+	for index := 1; index < len(parts); index++ {
+		parts[index] = strings.ToUpper(parts[index][:1]) + parts[index][1:]
+	}
+
+	return object[strings.Join(parts, "")]
+}
+
+func getSDSObject(object map[string]interface{}, name string) map[string]interface{} {
+	value, _ := getSDSValue(object, name).(map[string]interface{})
+	return value
+}
+
+// parseSDSDataSource : Decode the PEM (or DER) certificates of an inline Envoy data source,
+// data sources referencing files of the proxy's file system being skipped
+func parseSDSDataSource(source map[string]interface{}) ([]*x509.Certificate, error) {
+	var data []byte
+	if inlineBytes, ok := getSDSValue(source, "inline_bytes").(string); ok {
+		decoded, err := base64.StdEncoding.DecodeString(inlineBytes)
+		if err != nil {
+			return nil, err
+		}
+		data = decoded
+	} else if inlineString, ok := getSDSValue(source, "inline_string").(string); ok {
+		data = []byte(inlineString)
+	} else {
+		return nil, nil
+	}
+
+	certs, err := parsePEM(data)
+	if err != nil || len(certs) > 0 {
+		return certs, err
+	}
+
+	return x509.ParseCertificates(data)
+}
@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSDSFiles(t *testing.T) {
+	notAfter := time.Now().Add(time.Hour)
+	root := generateTestCertificate(caTemplate("root", notAfter), nil)
+	workload := generateTestCertificate(leafTemplate("spiffe-workload", notAfter), root)
+	gateway := generateTestCertificate(leafTemplate("gateway", notAfter), root)
+
+	encode := func(certs ...*testCertificate) []byte {
+		output := []byte{}
+		for _, cert := range certs {
+			output = append(output, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.cert.Raw})...)
+		}
+		return output
+	}
+
+	// as dumped by istioctl proxy-config secret -o json
+	configDump := fmt.Sprintf(`{
+  "configs": [
+    {
+      "@type": "type.googleapis.com/envoy.admin.v3.SecretsConfigDump",
+      "dynamic_active_secrets": [
+        {
+          "name": "default",
+          "version_info": "2024-05-01T00:00:00Z",
+          "secret": {
+            "@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret",
+            "name": "default",
+            "tls_certificate": {
+              "certificate_chain": {"inline_bytes": "%s"},
+              "private_key": {"inline_bytes": "W3JlZGFjdGVkXQ=="}
+            }
+          }
+        },
+        {
+          "name": "ROOTCA",
+          "secret": {
+            "@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret",
+            "name": "ROOTCA",
+            "validation_context": {
+              "trusted_ca": {"inline_bytes": "%s"}
+            }
+          }
+        }
+      ]
+    }
+  ]
+}`, base64.StdEncoding.EncodeToString(encode(workload, root)), base64.StdEncoding.EncodeToString(encode(root)))
+
+	// read by Envoy from a file, in the canonical JSON mapping of protobuf
+	discoveryResponse := fmt.Sprintf(`resources:
+- "@type": type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret
+  name: gateway-cert
+  tlsCertificate:
+    certificateChain:
+      inlineString: |
+        %s
+    privateKey:
+      filename: /etc/envoy/tls.key
+- "@type": type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret
+  name: file-based
+  tls_certificate:
+    certificate_chain:
+      filename: /etc/envoy/tls.crt
+`, strings.ReplaceAll(strings.TrimSpace(string(encode(gateway))), "\n", "\n        "))
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(dir, "config_dump.json"), []byte(configDump), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(dir, "sds.yaml"), []byte(discoveryResponse), 0644))
+
+	testRequest(t, &Exporter{
+		SDSFiles: []string{path.Join(dir, "*")},
+	}, func(metrics []model.MetricFamily) {
+		found := []string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			found = append(found, fmt.Sprintf("%s %s %s %s", getLabelValue(metric, "filename"), getLabelValue(metric, "sds_secret"),
+				getLabelValue(metric, "embedded_key"), getLabelValue(metric, "subject_CN")))
+		}
+		assert.ElementsMatch(t, []string{
+			"config_dump.json default tls_certificate.certificate_chain spiffe-workload",
+			"config_dump.json default tls_certificate.certificate_chain root",
+			"config_dump.json ROOTCA validation_context.trusted_ca root",
+			"sds.yaml gateway-cert tls_certificate.certificate_chain gateway",
+		}, found)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+	})
+}
+
+func TestSDSSharedChain(t *testing.T) {
+	cert := generateTestCertificate(leafTemplate("shared", time.Now().Add(time.Hour)), nil)
+	chain := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.cert.Raw}))
+
+	// both secrets are served the same chain, e.g. a workload and a gateway certificate issued together
+	discoveryResponse := fmt.Sprintf(`{
+  "resources": [
+    {"name": "a", "tls_certificate": {"certificate_chain": {"inline_bytes": "%s"}}},
+    {"name": "b", "tls_certificate": {"certificate_chain": {"inline_bytes": "%s"}}}
+  ]
+}`, chain, chain)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(dir, "sds.json"), []byte(discoveryResponse), 0644))
+
+	testRequest(t, &Exporter{
+		SDSFiles: []string{path.Join(dir, "sds.json")},
+	}, func(metrics []model.MetricFamily) {
+		secrets := []string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			secrets = append(secrets, getLabelValue(metric, "sds_secret"))
+		}
+		assert.ElementsMatch(t, []string{"a", "b"}, secrets)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 0., errMetric[0].GetGauge().GetValue())
+	})
+}
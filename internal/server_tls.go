@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// servingKeyPair : Certificate and key presented by the metrics server, loaded again
+// on handshakes once either file changed so that rotated certificates are picked up
+type servingKeyPair struct {
+	certFile string
+	keyFile  string
+
+	mutex   sync.Mutex
+	stamps  [2]fileStamp
+	keyPair *tls.Certificate
+}
+
+func newServingKeyPair(certFile string, keyFile string) (*servingKeyPair, error) {
+	pair := &servingKeyPair{certFile: certFile, keyFile: keyFile}
+	if err := pair.load(); err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// load : Read the key pair along with the stamps of its files, must be called with the lock held
+func (pair *servingKeyPair) load() error {
+	stamps, err := pair.getStamps()
+	if err != nil {
+		return err
+	}
+
+	keyPair, err := tls.LoadX509KeyPair(pair.certFile, pair.keyFile)
+	if err != nil {
+		return err
+	}
+
+	pair.stamps = stamps
+	pair.keyPair = &keyPair
+	return nil
+}
+
+func (pair *servingKeyPair) getStamps() ([2]fileStamp, error) {
+	certStamp, err := getFileStamp(pair.certFile)
+	if err != nil {
+		return [2]fileStamp{}, err
+	}
+
+	keyStamp, err := getFileStamp(pair.keyFile)
+	if err != nil {
+		return [2]fileStamp{}, err
+	}
+
+	return [2]fileStamp{certStamp, keyStamp}, nil
+}
+
+// current : Return the key pair, loaded again when its files changed,
+// the previous one is kept on failure (e.g. while only one of the files was replaced)
+func (pair *servingKeyPair) current() *tls.Certificate {
+	pair.mutex.Lock()
+	defer pair.mutex.Unlock()
+
+	stamps, err := pair.getStamps()
+	if err == nil && stamps == pair.stamps {
+		return pair.keyPair
+	}
+
+	if err == nil {
+		err = pair.load()
+	}
+	if err != nil {
+		log.Warnf("failed to reload server certificate, still serving the previous one: %s", err.Error())
+	}
+
+	return pair.keyPair
+}
+
+func (pair *servingKeyPair) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return pair.current(), nil
+}
+
+// loadServerTLSConfig : Build the TLS configuration of the metrics server from
+// the configured cert, key and optional client CA (which enables mutual TLS),
+// the key pair being watched for changes
+func (exporter *Exporter) loadServerTLSConfig() (*tls.Config, error) {
+	if len(exporter.TLSCertFile) == 0 && len(exporter.TLSKeyFile) == 0 {
+		if len(exporter.TLSClientCAFile) > 0 {
+			return nil, fmt.Errorf("a client CA requires a server certificate and key")
+		}
+
+		return nil, nil
+	}
+
+	if len(exporter.TLSCertFile) == 0 || len(exporter.TLSKeyFile) == 0 {
+		return nil, fmt.Errorf("both a server certificate and key are required to enable TLS")
+	}
+
+	pair, err := newServingKeyPair(exporter.TLSCertFile, exporter.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %s", err.Error())
+	}
+	exporter.servingKeyPair = pair
+
+	config := &tls.Config{
+		GetCertificate: pair.getCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if len(exporter.TLSClientCAFile) > 0 {
+		contents, err := readFile(exporter.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %s", err.Error())
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(contents) {
+			return nil, fmt.Errorf("no certificate found in client CA file \"%s\"", exporter.TLSClientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// collectServingCertificate : Build a ref for the certificate presented by the metrics server once it serves over TLS,
+// so that the exporter monitors its own certificate as served, i.e. the last one successfully loaded
+func (exporter *Exporter) collectServingCertificate() []*certificateRef {
+	if exporter.tlsConfig == nil || exporter.servingKeyPair == nil {
+		return []*certificateRef{}
+	}
+
+	return []*certificateRef{{
+		path:           exporter.TLSCertFile,
+		format:         certificateFormatSelf,
+		servingKeyPair: exporter.servingKeyPair,
+	}}
+}
+
+func readServingCertificate(pair *servingKeyPair) ([]*parsedCertificate, error) {
+	output := []*parsedCertificate{}
+	for _, der := range pair.current().Certificate {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+
+		output = append(output, &parsedCertificate{cert: cert})
+	}
+
+	return output, nil
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ServiceSource : Source holding the certificate of a logical service, a file path pattern or a TLS endpoint address
+type ServiceSource struct {
+	Service string
+	Source  string
+}
+
+// serviceComparison : Whether the leaf served by the endpoints of a service is one of the leaves of its files
+type serviceComparison struct {
+	service string
+	matches bool
+}
+
+// ParseServiceSource : Split a <service>=<source> specification
+func ParseServiceSource(spec string) (ServiceSource, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return ServiceSource{}, fmt.Errorf("expected <service>=<source>, got \"%s\"", spec)
+	}
+
+	return ServiceSource{Service: parts[0], Source: parts[1]}, nil
+}
+
+// getRefServices : The services a source belongs to, file paths matching the patterns of ServiceFiles
+// and endpoint addresses being equal to the ones of ServiceEndpoints
+func (exporter *Exporter) getRefServices(ref *certificateRef) (services []string, served bool) {
+	switch ref.format {
+	case certificateFormatEndpoint:
+		for _, source := range exporter.ServiceEndpoints {
+			if source.Source == ref.endpoint.endpoint.Address {
+				services = append(services, source.Service)
+			}
+		}
+		return services, true
+	case certificateFormatPEM, certificateFormatYAML, certificateFormatINI, certificateFormatDotenv, certificateFormatAuto, certificateFormatEmbeddedPEM, certificateFormatSDS:
+		for _, source := range exporter.ServiceFiles {
+			if matched, _ := doublestar.Match(source.Source, ref.path); matched {
+				services = append(services, source.Service)
+			}
+		}
+	}
+
+	return services, false
+}
+
+// compareServedCertificates : Tell for each service having both endpoints and files read successfully
+// whether every endpoint serves one of the leaves found in the files, e.g. catching servers which weren't
+// reloaded after their certificate file was renewed
+func (exporter *Exporter) compareServedCertificates(certRefs []*certificateRef) []serviceComparison {
+	if len(exporter.ServiceFiles) == 0 || len(exporter.ServiceEndpoints) == 0 {
+		return nil
+	}
+
+	fileLeaves := map[string]map[[sha256.Size]byte]bool{}
+	servedLeaves := map[string][][sha256.Size]byte{}
+	for _, ref := range certRefs {
+		services, served := exporter.getRefServices(ref)
+		if len(services) == 0 || len(ref.certificates) == 0 {
+			continue
+		}
+
+		for _, service := range services {
+			if served {
+				// the first certificate sent by a server is its leaf
+				servedLeaves[service] = append(servedLeaves[service], sha256.Sum256(ref.certificates[0].cert.Raw))
+				continue
+			}
+
+			if fileLeaves[service] == nil {
+				fileLeaves[service] = map[[sha256.Size]byte]bool{}
+			}
+			for _, cert := range getLeafCertificates(ref.certificates) {
+				fileLeaves[service][sha256.Sum256(cert.cert.Raw)] = true
+			}
+		}
+	}
+
+	output := []serviceComparison{}
+	for service, fingerprints := range servedLeaves {
+		if fileLeaves[service] == nil {
+			continue
+		}
+
+		matches := true
+		for _, fingerprint := range fingerprints {
+			matches = matches && fileLeaves[service][fingerprint]
+		}
+		output = append(output, serviceComparison{service: service, matches: matches})
+	}
+
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].service < output[j].service
+	})
+	return output
+}
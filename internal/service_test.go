@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServedCertificateMatchesFile(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(24 * time.Hour)
+
+	ca := generateTestCertificate(caTemplate("ca", notAfter), nil)
+	current := generateTestCertificate(leafTemplate("current", notAfter), ca)
+	renewed := generateTestCertificate(leafTemplate("renewed", notAfter), ca)
+	writeTestCertificates(path.Join(dir, "api.pem"), current, ca)
+	writeTestCertificates(path.Join(dir, "web.pem"), renewed, ca)
+	writeTestCertificates(path.Join(dir, "unserved.pem"), current)
+
+	// web wasn't reloaded after its file was renewed
+	api := startFakeTLSServer(t, current, nil)
+	web := startFakeTLSServer(t, current, nil)
+
+	testRequest(t, &Exporter{
+		Files:        []string{path.Join(dir, "*.pem")},
+		TLSEndpoints: []TLSEndpoint{{Address: api.address()}, {Address: web.address()}},
+		ServiceFiles: []ServiceSource{
+			{Service: "api", Source: "**/api.pem"},
+			{Service: "web", Source: "**/web.pem"},
+			{Service: "unserved", Source: "**/unserved.pem"},
+		},
+		ServiceEndpoints: []ServiceSource{
+			{Service: "api", Source: api.address()},
+			{Service: "web", Source: web.address()},
+			{Service: "unread", Source: "127.0.0.1:1"},
+		},
+	}, func(metrics []model.MetricFamily) {
+		matches := map[string]float64{}
+		for _, metric := range getMetricsForName(metrics, "x509_service_served_cert_matches_file") {
+			matches[getLabelValue(metric, "service")] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"api": 1, "web": 0}, matches)
+	})
+
+	testRequest(t, &Exporter{
+		Files:        []string{path.Join(dir, "*.pem")},
+		TLSEndpoints: []TLSEndpoint{{Address: api.address()}},
+	}, func(metrics []model.MetricFamily) {
+		assert.Len(t, getMetricsForName(metrics, "x509_service_served_cert_matches_file"), 0)
+	})
+}
+
+func TestParseServiceSource(t *testing.T) {
+	source, err := ParseServiceSource("api=/etc/certs/api-*.pem")
+	assert.NoError(t, err)
+	assert.Equal(t, ServiceSource{Service: "api", Source: "/etc/certs/api-*.pem"}, source)
+
+	for _, invalid := range []string{"", "api", "=api.example.com:443", "api="} {
+		_, err := ParseServiceSource(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
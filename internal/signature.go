@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ParseSignatureAlgorithm : Find a signature algorithm by the name Go gives it (e.g. "SHA256-RSA", "ECDSA-SHA384"),
+// ignoring case
+func ParseSignatureAlgorithm(name string) (x509.SignatureAlgorithm, error) {
+	for algorithm := x509.MD2WithRSA; algorithm <= x509.PureEd25519; algorithm++ {
+		if strings.EqualFold(algorithm.String(), name) {
+			return algorithm, nil
+		}
+	}
+
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unknown signature algorithm \"%s\"", name)
+}
+
+// hasSignaturePolicy : Tell if either list of the signature algorithm policy is configured
+func (exporter *Exporter) hasSignaturePolicy() bool {
+	return len(exporter.AllowedSigAlgorithms) > 0 || len(exporter.DeniedSigAlgorithms) > 0
+}
+
+// isSignatureCompliant : Tell if a certificate is signed with an allowed algorithm (any, when none is listed)
+// which isn't denied
+func (exporter *Exporter) isSignatureCompliant(cert *x509.Certificate) bool {
+	if len(exporter.AllowedSigAlgorithms) > 0 && !slices.Contains(exporter.AllowedSigAlgorithms, cert.SignatureAlgorithm) {
+		return false
+	}
+
+	return !slices.Contains(exporter.DeniedSigAlgorithms, cert.SignatureAlgorithm)
+}
@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"crypto/x509"
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignatureAlgorithmPolicy(t *testing.T) {
+	dir := t.TempDir()
+	for _, algorithm := range []x509.SignatureAlgorithm{x509.ECDSAWithSHA1, x509.ECDSAWithSHA256} {
+		template := caTemplate(algorithm.String(), time.Now().Add(24*time.Hour))
+		template.SignatureAlgorithm = algorithm
+		writeTestCertificates(path.Join(dir, fmt.Sprintf("%s.pem", algorithm.String())), generateTestCertificate(template, nil))
+	}
+
+	test := func(allowed []x509.SignatureAlgorithm, denied []x509.SignatureAlgorithm, expected map[string]float64) {
+		testRequest(t, &Exporter{
+			Files:                []string{path.Join(dir, "*.pem")},
+			AllowedSigAlgorithms: allowed,
+			DeniedSigAlgorithms:  denied,
+		}, func(metrics []model.MetricFamily) {
+			compliance := map[string]float64{}
+			for _, metric := range getMetricsForName(metrics, "x509_cert_signature_algorithm_compliant") {
+				assert.Equal(t, getLabelValue(metric, "subject_CN"), getLabelValue(metric, "signature_algorithm"))
+				compliance[getLabelValue(metric, "signature_algorithm")] = metric.GetGauge().GetValue()
+			}
+			assert.Equal(t, expected, compliance)
+		})
+	}
+
+	test([]x509.SignatureAlgorithm{x509.ECDSAWithSHA256, x509.SHA256WithRSA}, nil, map[string]float64{"ECDSA-SHA1": 0, "ECDSA-SHA256": 1})
+	test(nil, []x509.SignatureAlgorithm{x509.ECDSAWithSHA1}, map[string]float64{"ECDSA-SHA1": 0, "ECDSA-SHA256": 1})
+	test([]x509.SignatureAlgorithm{x509.ECDSAWithSHA256}, []x509.SignatureAlgorithm{x509.ECDSAWithSHA256}, map[string]float64{"ECDSA-SHA1": 0, "ECDSA-SHA256": 0})
+	test(nil, nil, map[string]float64{})
+}
+
+func TestParseSignatureAlgorithm(t *testing.T) {
+	algorithm, err := ParseSignatureAlgorithm("sha256-rsa")
+	assert.NoError(t, err)
+	assert.Equal(t, x509.SHA256WithRSA, algorithm)
+
+	algorithm, err = ParseSignatureAlgorithm("Ed25519")
+	assert.NoError(t, err)
+	assert.Equal(t, x509.PureEd25519, algorithm)
+
+	for _, invalid := range []string{"", "SHA256", "0", "SHA3-RSA"} {
+		_, err := ParseSignatureAlgorithm(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SQLSource : Contains information to read certificates from a database table,
+// Query must return the PEM data in its first column and an identifier in its second one
+type SQLSource struct {
+	Driver string
+	DSN    string
+	Query  string
+}
+
+var sqlPasswordRegexp = regexp.MustCompile(`password=\S+`)
+
+const sqlQueryTimeout = 30 * time.Second
+
+// ParseSQLSource : Split a <driver>:<dsn> specification, to be read with the given query
+func ParseSQLSource(spec string, query string) (SQLSource, error) {
+	driver, dsn, found := strings.Cut(spec, ":")
+	if !found || len(driver) == 0 || len(dsn) == 0 {
+		return SQLSource{}, fmt.Errorf("expected <driver>:<dsn>, got \"%s\"", redactDSN(spec))
+	}
+
+	if len(strings.TrimSpace(query)) == 0 {
+		return SQLSource{}, fmt.Errorf("no query for \"%s\"", redactDSN(dsn))
+	}
+
+	return SQLSource{Driver: driver, DSN: dsn, Query: query}, nil
+}
+
+func (exporter *Exporter) collectSQLSources() []*certificateRef {
+	output := []*certificateRef{}
+
+	// queries of the same database are told apart by their position
+	counts := map[string]int{}
+	for _, source := range exporter.SQLSources {
+		counts[redactDSN(source.DSN)]++
+	}
+
+	indexes := map[string]int{}
+	for index := range exporter.SQLSources {
+		source := &exporter.SQLSources[index]
+		dsn := redactDSN(source.DSN)
+		path := fmt.Sprintf("sql/%s", dsn)
+		if counts[dsn] > 1 {
+			path = fmt.Sprintf("%s(%d)", path, indexes[dsn])
+			indexes[dsn]++
+		}
+
+		output = append(output, &certificateRef{
+			path:      path,
+			format:    certificateFormatSQL,
+			sqlSource: source,
+			sqlClient: exporter.getSQLDB,
+		})
+	}
+
+	return output
+}
+
+// getSQLDB : Lazily open one connection pool per database, kept across scrapes
+func (exporter *Exporter) getSQLDB(source *SQLSource) (*sql.DB, error) {
+	exporter.sqlMutex.Lock()
+	defer exporter.sqlMutex.Unlock()
+
+	if exporter.sqlDBs == nil {
+		exporter.sqlDBs = map[SQLSource]*sql.DB{}
+	}
+
+	key := SQLSource{Driver: source.Driver, DSN: source.DSN}
+	if db, found := exporter.sqlDBs[key]; found {
+		return db, nil
+	}
+
+	db, err := sql.Open(source.Driver, source.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter.sqlDBs[key] = db
+	return db, nil
+}
+
+// closeSQLDBs : Close the connection pools opened by scrapes
+func (exporter *Exporter) closeSQLDBs() {
+	exporter.sqlMutex.Lock()
+	defer exporter.sqlMutex.Unlock()
+
+	for key, db := range exporter.sqlDBs {
+		db.Close()
+		delete(exporter.sqlDBs, key)
+	}
+}
+
+func readAndParseSQLSource(ctx context.Context, source *SQLSource, getDB func(*SQLSource) (*sql.DB, error)) ([]*parsedCertificate, error) {
+	db, err := getDB(source)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sqlQueryTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, source.Query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := []*parsedCertificate{}
+	for rows.Next() {
+		var data []byte
+		var id string
+		if err := rows.Scan(&data, &id); err != nil {
+			return nil, err
+		}
+
+		certs, err := parsePEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("row \"%s\": %s", id, err.Error())
+		}
+
+		for index, cert := range certs {
+			displayName := id
+			if len(certs) > 1 {
+				displayName = fmt.Sprintf("%s(%d)", id, index)
+			}
+			output = append(output, &parsedCertificate{
+				cert:   cert,
+				userID: displayName,
+			})
+		}
+	}
+
+	return output, rows.Err()
+}
+
+// redactDSN : Strip credentials from a DSN so it can be used as a label
+func redactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		if parsed, err := url.Parse(dsn); err == nil {
+			parsed.User = nil
+			parsed.RawQuery = ""
+			parsed.Fragment = ""
+			return parsed.String()
+		}
+	}
+
+	if index := strings.LastIndex(dsn, "@"); index >= 0 {
+		return dsn[index+1:]
+	}
+
+	return sqlPasswordRegexp.ReplaceAllString(dsn, "password=redacted")
+}
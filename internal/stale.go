@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"slices"
+	"time"
+)
+
+// lastGoodRef : Copy of a source as of its last successful parse
+type lastGoodRef struct {
+	ref      *certificateRef
+	parsedAt time.Time
+}
+
+// keepStaleCertificates : Serve the last known certificates of sources which failed to parse, or disappeared
+// (e.g. a file briefly missing during a rotation), for up to StaleTolerance after their last successful parse
+func (exporter *Exporter) keepStaleCertificates(refs []*certificateRef, failed []*certificateRef) []*certificateRef {
+	exporter.staleMutex.Lock()
+	defer exporter.staleMutex.Unlock()
+
+	if exporter.lastGoodRefs == nil {
+		exporter.lastGoodRefs = map[string]*lastGoodRef{}
+	}
+
+	now := time.Now()
+	present := map[string]bool{}
+	for _, ref := range refs {
+		key := getSourceKey(ref)
+		present[key] = true
+
+		if len(ref.certificates) > 0 && !slices.Contains(failed, ref) {
+			copied := *ref
+			copied.certificates = copyParsedCertificates(ref.certificates)
+			exporter.lastGoodRefs[key] = &lastGoodRef{ref: &copied, parsedAt: now}
+		}
+	}
+
+	for key, entry := range exporter.lastGoodRefs {
+		if now.Sub(entry.parsedAt) > exporter.StaleTolerance {
+			delete(exporter.lastGoodRefs, key)
+		}
+	}
+
+	for _, ref := range failed {
+		if entry, found := exporter.lastGoodRefs[getSourceKey(ref)]; found {
+			ref.certificates = copyParsedCertificates(entry.ref.certificates)
+			ref.stale = true
+		}
+	}
+
+	for key, entry := range exporter.lastGoodRefs {
+		if !present[key] {
+			copied := *entry.ref
+			copied.certificates = copyParsedCertificates(entry.ref.certificates)
+			copied.stale = true
+			refs = append(refs, &copied)
+		}
+	}
+
+	return refs
+}
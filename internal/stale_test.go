@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaleTolerance(t *testing.T) {
+	dir := t.TempDir()
+	certPath := path.Join(dir, "tls.pem")
+	otherPath := path.Join(dir, "other.pem")
+	writeTestCertificates(certPath, generateTestCertificate(leafTemplate("rotated", time.Now().Add(time.Hour)), nil))
+	writeTestCertificates(otherPath, generateTestCertificate(leafTemplate("other", time.Now().Add(time.Hour)), nil))
+
+	exporter := &Exporter{
+		Files:              []string{certPath, otherPath},
+		StaleTolerance:     time.Minute,
+		ExposeErrorMetrics: true,
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&collector{exporter: exporter})
+
+	scrape := func() (map[string]float64, map[string]float64, float64) {
+		metrics, err := registry.Gather()
+		assert.NoError(t, err)
+
+		notAfter := map[string]float64{}
+		certErrors := map[string]float64{}
+		readErrors := 0.
+		for index := range metrics {
+			for _, metric := range metrics[index].GetMetric() {
+				switch metrics[index].GetName() {
+				case "x509_cert_not_after":
+					notAfter[getLabelValue(metric, "subject_CN")] = metric.GetGauge().GetValue()
+				case "x509_cert_error":
+					certErrors[getLabelValue(metric, "filename")] = metric.GetGauge().GetValue()
+				case "x509_read_errors":
+					readErrors = metric.GetGauge().GetValue()
+				}
+			}
+		}
+
+		return notAfter, certErrors, readErrors
+	}
+	notAfter, certErrors, readErrors := scrape()
+	assert.Len(t, notAfter, 2)
+	assert.Equal(t, map[string]float64{"tls.pem": 0, "other.pem": 0}, certErrors)
+	assert.Equal(t, 0., readErrors)
+
+	// missing during a rotation: served from the last successful read, reported as failing
+	assert.NoError(t, os.Remove(certPath))
+	notAfter, certErrors, readErrors = scrape()
+	assert.Contains(t, notAfter, "rotated")
+	assert.Equal(t, map[string]float64{"tls.pem": 1, "other.pem": 0}, certErrors)
+	assert.Equal(t, 1., readErrors)
+
+	// concurrent scrapes each resolve issuers on their own copies of the last known certificates
+	exporter.ExposeIssuerMetrics = true
+	parseConcurrently(exporter)
+	first, _ := exporter.parseAllCertificates(context.Background())
+	second, _ := exporter.parseAllCertificates(context.Background())
+	for index := range first {
+		if first[index].stale {
+			assert.NotSame(t, first[index].certificates[0], second[index].certificates[0])
+		}
+	}
+	exporter.ExposeIssuerMetrics = false
+
+	// corrupted instead of missing, same thing
+	assert.NoError(t, os.WriteFile(certPath, []byte("-----BEGIN CERTIFICATE-----\ngarbage\n"), 0644))
+	notAfter, certErrors, readErrors = scrape()
+	assert.Contains(t, notAfter, "rotated")
+	assert.Equal(t, map[string]float64{"tls.pem": 1, "other.pem": 0}, certErrors)
+	assert.Equal(t, 1., readErrors)
+
+	// still failing once the tolerance is over: the series are gone, the error stays
+	exporter.staleMutex.Lock()
+	for _, entry := range exporter.lastGoodRefs {
+		entry.parsedAt = entry.parsedAt.Add(-2 * time.Minute)
+	}
+	exporter.staleMutex.Unlock()
+
+	notAfter, certErrors, readErrors = scrape()
+	assert.NotContains(t, notAfter, "rotated")
+	assert.Contains(t, notAfter, "other")
+	assert.Equal(t, map[string]float64{"tls.pem": 1, "other.pem": 0}, certErrors)
+	assert.Equal(t, 1., readErrors)
+}
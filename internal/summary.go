@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type summarySource struct {
+	Path         string
+	Certificates []summaryCertificate
+}
+
+type summaryCertificate struct {
+	SubjectCN string
+	IssuerCN  string
+	Serial    string
+	NotAfter  time.Time
+	Remaining string
+	Class     string
+}
+
+var summaryTemplate = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>x509 Certificate Exporter</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.3em 1em; text-align: left; border-bottom: 1px solid #ddd; }
+.expired { background: #f8d7da; }
+.critical { background: #ffe5b4; }
+.warning { background: #fff3cd; }
+.valid { background: #d4edda; }
+</style>
+</head>
+<body>
+<h1>x509 Certificate Exporter</h1>
+<p><a href="/metrics">Metrics</a> &middot; {{ .Count }} certificate(s), {{ .Errors }} read error(s)</p>
+{{ range .Sources }}
+<h2>{{ .Path }}</h2>
+<table>
+<tr><th>Subject CN</th><th>Issuer CN</th><th>Serial number</th><th>Not after</th><th>Remaining</th></tr>
+{{ range .Certificates }}<tr class="{{ .Class }}"><td>{{ .SubjectCN }}</td><td>{{ .IssuerCN }}</td><td>{{ .Serial }}</td><td>{{ .NotAfter.UTC.Format "2006-01-02 15:04:05 MST" }}</td><td>{{ .Remaining }}</td></tr>
+{{ end }}</table>
+{{ end }}
+</body>
+</html>
+`))
+
+// storeLastParsed : Keep the result of the last parse, for the summary page
+func (exporter *Exporter) storeLastParsed(refs []*certificateRef, errs []*certificateError) {
+	exporter.lastParsedMutex.Lock()
+	defer exporter.lastParsedMutex.Unlock()
+
+	exporter.lastParsedRefs = refs
+	exporter.lastParsedErrors = len(errs)
+}
+
+// handleSummary : Human readable list of the certificates found by the last scrape (or discovery),
+// grouped by source, sources expiring first at the top
+func (exporter *Exporter) handleSummary(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	exporter.lastParsedMutex.Lock()
+	refs, errorCount, parsed := exporter.lastParsedRefs, exporter.lastParsedErrors, exporter.lastParsedRefs != nil
+	exporter.lastParsedMutex.Unlock()
+
+	if !parsed {
+		ctx, cancel := exporter.newScrapeContext()
+		defer cancel()
+		var errs []*certificateError
+		refs, errs = exporter.parseAllCertificates(ctx)
+		errorCount = len(errs)
+	}
+
+	now := time.Now()
+	sources := []summarySource{}
+	count := 0
+	for _, ref := range refs {
+		if len(ref.certificates) == 0 {
+			continue
+		}
+
+		source := summarySource{Path: ref.path}
+		if len(ref.kubeSecretKey) > 0 {
+			source.Path = fmt.Sprintf("%s (%s)", ref.path, ref.kubeSecretKey)
+		}
+
+		for _, cert := range ref.certificates {
+			remaining := cert.cert.NotAfter.Sub(now)
+			source.Certificates = append(source.Certificates, summaryCertificate{
+				SubjectCN: cert.cert.Subject.CommonName,
+				IssuerCN:  cert.cert.Issuer.CommonName,
+				Serial:    cert.cert.SerialNumber.String(),
+				NotAfter:  cert.cert.NotAfter,
+				Remaining: formatRemaining(remaining),
+				Class:     string(getValidityState(remaining)),
+			})
+		}
+
+		sort.SliceStable(source.Certificates, func(i, j int) bool {
+			return source.Certificates[i].NotAfter.Before(source.Certificates[j].NotAfter)
+		})
+		sources = append(sources, source)
+		count += len(source.Certificates)
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Certificates[0].NotAfter.Before(sources[j].Certificates[0].NotAfter)
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := summaryTemplate.Execute(w, map[string]interface{}{
+		"Sources": sources,
+		"Count":   count,
+		"Errors":  errorCount,
+	})
+	if err != nil {
+		log.Warnf("failed to render summary page: %s", err.Error())
+	}
+}
+
+// formatRemaining : Countdown to a not after timestamp, in days and hours
+func formatRemaining(remaining time.Duration) string {
+	format := "%dd %dh"
+	if remaining < 0 {
+		remaining = -remaining
+		format = "expired %dd %dh ago"
+	}
+
+	return fmt.Sprintf(format, int(remaining/(24*time.Hour)), int(remaining%(24*time.Hour)/time.Hour))
+}
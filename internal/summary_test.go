@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getSummary(t *testing.T, exporter *Exporter, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	exporter.handleSummary(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
+
+func TestSummaryPage(t *testing.T) {
+	day := 24 * time.Hour
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "later.pem"),
+		generateTestCertificate(leafTemplate("in-90-days", time.Now().Add(90*day)), nil),
+		generateTestCertificate(leafTemplate("in-10-days", time.Now().Add(10*day)), nil),
+	)
+	writeTestCertificates(path.Join(dir, "sooner.pem"),
+		generateTestCertificate(leafTemplate("in-2-days", time.Now().Add(2*day)), nil),
+		generateTestCertificate(leafTemplate("expired", time.Now().Add(-day)), nil),
+	)
+
+	exporter := &Exporter{Files: []string{path.Join(dir, "*.pem")}}
+
+	recorder := getSummary(t, exporter, "/")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/html")
+	body := recorder.Body.String()
+
+	// sources expiring first come first, and certificates are sorted within them
+	positions := []int{}
+	for _, expected := range []string{"sooner.pem</h2>", "<td>expired</td>", "<td>in-2-days</td>", "later.pem</h2>", "<td>in-10-days</td>", "<td>in-90-days</td>"} {
+		position := strings.Index(body, expected)
+		assert.NotEqual(t, -1, position, expected)
+		positions = append(positions, position)
+	}
+	assert.IsIncreasing(t, positions)
+
+	assert.Contains(t, body, `<tr class="expired"><td>expired</td>`)
+	assert.Contains(t, body, `<tr class="critical"><td>in-2-days</td>`)
+	assert.Contains(t, body, `<tr class="warning"><td>in-10-days</td>`)
+	assert.Contains(t, body, `<tr class="valid"><td>in-90-days</td>`)
+	assert.Contains(t, body, "expired 1d 0h ago")
+	assert.Contains(t, body, "4 certificate(s), 0 read error(s)")
+
+	assert.Equal(t, http.StatusNotFound, getSummary(t, exporter, "/favicon.ico").Code)
+}
+
+func TestSummaryPageReusesLastScrape(t *testing.T) {
+	certPath := path.Join(t.TempDir(), "tls.pem")
+	writeTestCertificates(certPath, generateTestCertificate(leafTemplate("scraped", time.Now().Add(time.Hour)), nil))
+
+	exporter := &Exporter{Files: []string{certPath}}
+	exporter.parseAllCertificates(context.Background())
+
+	// served from the last parse, sources aren't read again
+	assert.NoError(t, os.Remove(certPath))
+	assert.Contains(t, getSummary(t, exporter, "/").Body.String(), "scraped")
+
+	exporter.parseAllCertificates(context.Background())
+	body := getSummary(t, exporter, "/").Body.String()
+	assert.NotContains(t, body, "scraped")
+	assert.Contains(t, body, "0 certificate(s), 1 read error(s)")
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// systemdCredentialsDirectoryEnv : Set by systemd for services given credentials (LoadCredential=, SetCredential=...)
+const systemdCredentialsDirectoryEnv = "CREDENTIALS_DIRECTORY"
+
+// GetSystemdCredentialsDirectory : Directory holding the credentials of the current service, empty when not run
+// by systemd or without credentials
+func GetSystemdCredentialsDirectory() string {
+	return os.Getenv(systemdCredentialsDirectoryEnv)
+}
+
+// collectSystemdCredentials : Build a ref for each credential matching one of the watched names (glob patterns allowed),
+// none when the credentials directory isn't set
+func (exporter *Exporter) collectSystemdCredentials() ([]*certificateRef, []error) {
+	dir := GetSystemdCredentialsDirectory()
+	if len(exporter.SystemdCredentials) == 0 || len(dir) == 0 {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to list systemd credentials: %s", err.Error())}
+	}
+
+	output := []*certificateRef{}
+	outputErrors := []error{}
+	for _, pattern := range exporter.SystemdCredentials {
+		matches := 0
+		for _, entry := range entries {
+			if matched, _ := path.Match(pattern, entry.Name()); !matched || entry.IsDir() {
+				continue
+			}
+
+			matches++
+			output = append(output, &certificateRef{
+				path:   path.Join(dir, entry.Name()),
+				format: certificateFormatSystemdCredential,
+			})
+		}
+
+		if matches == 0 {
+			outputErrors = append(outputErrors, fmt.Errorf("no systemd credential matches \"%s\"", pattern))
+		}
+	}
+
+	return output, outputErrors
+}
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemdCredentials(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(24 * time.Hour)
+	ca := generateTestCertificate(caTemplate("ca", notAfter), nil)
+	leaf := generateTestCertificate(leafTemplate("leaf", notAfter), ca)
+
+	writeTestCertificates(path.Join(dir, "ca.crt"), ca)
+	assert.NoError(t, os.WriteFile(path.Join(dir, "leaf.crt"), leaf.cert.Raw, 0400))
+	assert.NoError(t, os.WriteFile(path.Join(dir, "token"), []byte("secret"), 0400))
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+
+	exporter := &Exporter{
+		SystemdCredentials: []string{"*.crt", "missing"},
+		ExposeErrorMetrics: true,
+	}
+	testRequest(t, exporter, func(metrics []model.MetricFamily) {
+		credentials := map[string]string{}
+		for _, metric := range getMetricsForName(metrics, "x509_cert_not_after") {
+			credentials[getLabelValue(metric, "systemd_credential")] = getLabelValue(metric, "subject_CN")
+		}
+		assert.Equal(t, map[string]string{"ca.crt": "ca", "leaf.crt": "leaf"}, credentials)
+
+		errMetric := getMetricsForName(metrics, "x509_read_errors")
+		assert.Equal(t, 1., errMetric[0].GetGauge().GetValue())
+	})
+
+	// not run by systemd
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	refs, errs := exporter.parseAllCertificates(context.Background())
+	assert.Empty(t, refs)
+	assert.Empty(t, errs)
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ValidityState : Status of a certificate, by the validity it has left
+type ValidityState string
+
+// Validity states, from the healthiest one
+const (
+	ValidityStateValid    ValidityState = "valid"
+	ValidityStateWarning  ValidityState = "warning"
+	ValidityStateCritical ValidityState = "critical"
+	ValidityStateExpired  ValidityState = "expired"
+)
+
+// validityWarningThreshold : Remaining validity below which certificates are in the warning state
+const validityWarningThreshold = 30 * 24 * time.Hour
+
+// validityCriticalThreshold : Remaining validity below which certificates are in the critical state
+const validityCriticalThreshold = 7 * 24 * time.Hour
+
+// ParseValidityState : Find a validity state by its name, ignoring case
+func ParseValidityState(name string) (ValidityState, error) {
+	for _, state := range []ValidityState{ValidityStateValid, ValidityStateWarning, ValidityStateCritical, ValidityStateExpired} {
+		if strings.EqualFold(string(state), name) {
+			return state, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown validity state \"%s\", expected valid, warning, critical or expired", name)
+}
+
+func getValidityState(remaining time.Duration) ValidityState {
+	switch {
+	case remaining < 0:
+		return ValidityStateExpired
+	case remaining < validityCriticalThreshold:
+		return ValidityStateCritical
+	case remaining < validityWarningThreshold:
+		return ValidityStateWarning
+	}
+
+	return ValidityStateValid
+}
+
+// isValidityStateExported : Tell if the series of a certificate are exported, all of them being when no state is selected
+func (exporter *Exporter) isValidityStateExported(cert *x509.Certificate) bool {
+	if len(exporter.ValidityStates) == 0 {
+		return true
+	}
+
+	return slices.Contains(exporter.ValidityStates, getValidityState(cert.NotAfter.Sub(exporter.now())))
+}
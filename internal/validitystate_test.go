@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidityStateFilter(t *testing.T) {
+	day := 24 * time.Hour
+	dir := t.TempDir()
+	writeTestCertificates(path.Join(dir, "bundle.pem"),
+		generateTestCertificate(leafTemplate("valid", time.Now().Add(90*day)), nil),
+		generateTestCertificate(leafTemplate("warning", time.Now().Add(20*day)), nil),
+		generateTestCertificate(leafTemplate("critical", time.Now().Add(3*day)), nil),
+		generateTestCertificate(leafTemplate("expired", time.Now().Add(-day)), nil),
+	)
+
+	test := func(states []ValidityState, expected []string) {
+		testRequest(t, &Exporter{
+			Files:          []string{path.Join(dir, "bundle.pem")},
+			ValidityStates: states,
+		}, func(metrics []model.MetricFamily) {
+			for _, name := range []string{"x509_cert_not_after", "x509_cert_expired"} {
+				found := []string{}
+				for _, metric := range getMetricsForName(metrics, name) {
+					found = append(found, getLabelValue(metric, "subject_CN"))
+				}
+				assert.ElementsMatch(t, expected, found, name)
+			}
+
+			// aggregates still account for all certificates
+			for _, metric := range getMetricsForName(metrics, "x509_cert_distinct_issuer_count") {
+				assert.Equal(t, 4., metric.GetGauge().GetValue())
+			}
+		})
+	}
+
+	test([]ValidityState{ValidityStateCritical}, []string{"critical"})
+	test([]ValidityState{ValidityStateWarning, ValidityStateCritical, ValidityStateExpired}, []string{"warning", "critical", "expired"})
+	test(nil, []string{"valid", "warning", "critical", "expired"})
+}
+
+func TestParseValidityState(t *testing.T) {
+	state, err := ParseValidityState("Critical")
+	assert.NoError(t, err)
+	assert.Equal(t, ValidityStateCritical, state)
+
+	for _, invalid := range []string{"", "expiring", "not-yet-valid"} {
+		_, err := ParseValidityState(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
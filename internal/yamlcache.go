@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileStamp : Modification time and size of a file, telling if it may have changed
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// yamlFileCache : Certificates last parsed from a YAML file, reused while neither this file
+// nor the files referenced by its "file" formatted values change
+type yamlFileCache struct {
+	mutex        sync.Mutex
+	stamps       map[string]fileStamp
+	certificates []*parsedCertificate
+}
+
+func (exporter *Exporter) getYAMLFileCache(filePath string) *yamlFileCache {
+	exporter.yamlCachesMutex.Lock()
+	defer exporter.yamlCachesMutex.Unlock()
+
+	if exporter.yamlCaches == nil {
+		exporter.yamlCaches = map[string]*yamlFileCache{}
+	}
+
+	cache, found := exporter.yamlCaches[filePath]
+	if !found {
+		cache = &yamlFileCache{}
+		exporter.yamlCaches[filePath] = cache
+	}
+
+	return cache
+}
+
+func getFileStamp(filePath string) (fileStamp, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fileStamp{}, err
+	}
+
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// isFresh : Tell if none of the files the cached certificates come from changed, must be called with the lock held
+func (cache *yamlFileCache) isFresh() bool {
+	if cache.certificates == nil {
+		return false
+	}
+
+	for filePath, cachedStamp := range cache.stamps {
+		stamp, err := getFileStamp(filePath)
+		if err != nil || stamp != cachedStamp {
+			return false
+		}
+	}
+
+	return true
+}
+
+func readAndParseCachedYAMLFile(filePath string, yamlPaths []YAMLCertRef, cache *yamlFileCache) ([]*parsedCertificate, error) {
+	if cache == nil {
+		certs, _, err := readAndParseYAMLFile(filePath, yamlPaths)
+		return certs, err
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if cache.isFresh() {
+		return copyParsedCertificates(cache.certificates), nil
+	}
+
+	// stamped before reading, so a change made meanwhile is picked up next time
+	stamp, err := getFileStamp(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, referencedFiles, err := readAndParseYAMLFile(filePath, yamlPaths)
+	if err != nil {
+		cache.certificates = nil
+
+		// not cached, so the warnings are reported on each read until the missing files show up
+		var warnings readWarnings
+		if errors.As(err, &warnings) {
+			return certs, err
+		}
+		return nil, err
+	}
+
+	stamps := map[string]fileStamp{filePath: stamp}
+	for _, referencedFile := range referencedFiles {
+		stamp, err := getFileStamp(referencedFile)
+		if err != nil {
+			cache.certificates = nil
+			return certs, nil
+		}
+		stamps[referencedFile] = stamp
+	}
+
+	cache.stamps = stamps
+	cache.certificates = certs
+	return copyParsedCertificates(certs), nil
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLFileCache(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfigPath := path.Join(dir, "kubeconfig.yaml")
+	clientPath := path.Join(dir, "client.pem")
+	notAfter := time.Now().Add(24 * time.Hour)
+
+	writeKubeconfig := func(caName string, modTime time.Time) {
+		ca := generateTestCertificate(caTemplate(caName, notAfter), nil)
+		err := os.WriteFile(kubeconfigPath, []byte(fmt.Sprintf(`clusters:
+- name: cluster
+  cluster:
+    certificate-authority-data: %s
+users:
+- name: user
+  user:
+    client-certificate: client.pem
+`, base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})))), 0644)
+		assert.NoError(t, err)
+		assert.NoError(t, os.Chtimes(kubeconfigPath, modTime, modTime))
+	}
+
+	writeClient := func(clientName string, modTime time.Time) {
+		writeTestCertificates(clientPath, generateTestCertificate(leafTemplate(clientName, notAfter), nil))
+		assert.NoError(t, os.Chtimes(clientPath, modTime, modTime))
+	}
+
+	exporter := &Exporter{
+		YAMLs:     []string{kubeconfigPath},
+		YAMLPaths: DefaultYamlPaths,
+	}
+
+	parse := func() map[string]*parsedCertificate {
+		refs, _ := exporter.parseAllCertificates(context.Background())
+		output := map[string]*parsedCertificate{}
+		for _, ref := range refs {
+			for _, cert := range ref.certificates {
+				output[cert.cert.Subject.CommonName] = cert
+			}
+		}
+		return output
+	}
+
+	start := time.Now().Add(-time.Hour)
+	writeKubeconfig("ca-1", start)
+	writeClient("client-1", start)
+
+	first := parse()
+	assert.Len(t, first, 2)
+	assert.Contains(t, first, "ca-1")
+	assert.Contains(t, first, "client-1")
+
+	// unchanged: the YAML file isn't matched again
+	second := parse()
+	assert.Same(t, first["ca-1"].cert, second["ca-1"].cert)
+	assert.Same(t, first["client-1"].cert, second["client-1"].cert)
+
+	// a referenced file changed
+	writeClient("client-2", start.Add(time.Minute))
+	third := parse()
+	assert.Len(t, third, 2)
+	assert.Contains(t, third, "client-2")
+	assert.NotSame(t, first["ca-1"].cert, third["ca-1"].cert)
+
+	// the YAML file changed
+	writeKubeconfig("ca-2", start.Add(2*time.Minute))
+	fourth := parse()
+	assert.Len(t, fourth, 2)
+	assert.Contains(t, fourth, "ca-2")
+	assert.Same(t, fourth["ca-2"].cert, parse()["ca-2"].cert)
+}
+
+func TestYAMLFileCacheConcurrentScrapes(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(24 * time.Hour)
+	root := generateTestCertificate(caTemplate("root", notAfter), nil)
+	leaf := generateTestCertificate(leafTemplate("leaf", notAfter), root)
+	writeTestCertificates(path.Join(dir, "root.pem"), root)
+
+	encode := func(cert *testCertificate) string {
+		return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.cert.Raw}))
+	}
+
+	kubeconfigPath := path.Join(dir, "kubeconfig.yaml")
+	assert.NoError(t, os.WriteFile(kubeconfigPath, []byte(fmt.Sprintf(`clusters:
+- name: cluster
+  cluster:
+    certificate-authority-data: %s
+users:
+- name: user
+  user:
+    client-certificate-data: %s
+`, encode(root), encode(leaf))), 0644))
+
+	exporter := &Exporter{
+		YAMLs:               []string{kubeconfigPath},
+		YAMLPaths:           DefaultYamlPaths,
+		ExposeIssuerMetrics: true,
+		TrustedRootFiles:    []string{path.Join(dir, "root.pem")},
+	}
+	parseConcurrently(exporter)
+
+	refs, _ := exporter.parseAllCertificates(context.Background())
+	assert.Len(t, refs, 1)
+	for _, cert := range refs[0].certificates {
+		if cert.cert.Subject.CommonName == "leaf" {
+			assert.Equal(t, root.cert.Raw, cert.issuer.Raw)
+			assert.True(t, *cert.verified)
+		}
+	}
+}